@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/backup"
+	"github.com/OpenListTeam/OpenList/v4/internal/bootstrap"
+	"github.com/OpenListTeam/OpenList/v4/internal/bootstrap/data"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	backupOutput    string
+	backupWithIndex bool
+	restoreFile     string
+	restoreForce    bool
+	restoreNoIndex  bool
+)
+
+// BackupCmd exports the database and the index in a format independent of the database type
+var BackupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Backup all tables of the database, and optionally the bleve index",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		initDBOnly()
+		defer Release()
+		if backupOutput == "" {
+			backupOutput = fmt.Sprintf("openlist-backup-%s.tar.gz", time.Now().Format("20060102-150405"))
+		}
+		f, err := os.OpenFile(backupOutput, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to create backup file: %+v", err)
+		}
+		m, err := backup.Backup(f, backupWithIndex)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(backupOutput)
+			return fmt.Errorf("failed to backup: %+v", err)
+		}
+		for table, n := range m.Tables {
+			fmt.Printf("%s: %d rows\n", table, n)
+		}
+		utils.Log.Infof("backup is saved to %s from CLI", backupOutput)
+		fmt.Printf("Backup is saved to %s\n", backupOutput)
+		return nil
+	},
+}
+
+var RestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore a backup to the configured database, please stop the server first",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if restoreFile == "" {
+			return fmt.Errorf("file is required")
+		}
+		f, err := os.Open(restoreFile)
+		if err != nil {
+			return fmt.Errorf("failed to open backup file: %+v", err)
+		}
+		defer f.Close()
+		initDBOnly()
+		defer Release()
+		m, err := backup.Restore(f, backup.RestoreOptions{Force: restoreForce, SkipIndex: restoreNoIndex})
+		if err != nil {
+			return fmt.Errorf("failed to restore: %+v", err)
+		}
+		// upgrade the restored data made by an older version, and add the new settings
+		bootstrap.LastLaunchedVersion = m.Version
+		data.InitData()
+		bootstrap.InitUpgradePatch()
+		utils.Log.Infof("backup %s made by %s at %s is restored from CLI", restoreFile, m.Version, m.CreatedAt)
+		fmt.Printf("Backup made by %s from %s at %s is restored\n", m.Version, m.Database, m.CreatedAt.Format(time.RFC3339))
+		return nil
+	},
+}
+
+// initDBOnly initializes the database without loading the data, so the index is not opened
+func initDBOnly() {
+	bootstrap.InitConfig()
+	bootstrap.Log()
+	bootstrap.InitDB()
+}
+
+func init() {
+	RootCmd.AddCommand(BackupCmd, RestoreCmd)
+	BackupCmd.Flags().StringVarP(&backupOutput, "output", "o", "", "output file, defaults to openlist-backup-[time].tar.gz")
+	BackupCmd.Flags().BoolVar(&backupWithIndex, "with-index", false, "include the bleve index directory")
+	RestoreCmd.Flags().StringVarP(&restoreFile, "file", "f", "", "backup file to restore")
+	RestoreCmd.Flags().BoolVar(&restoreForce, "force", false, "restore the backup made by a version with a newer schema")
+	RestoreCmd.Flags().BoolVar(&restoreNoIndex, "skip-index", false, "skip the bleve index in the backup")
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/OpenListTeam/OpenList/v4/internal/bootstrap"
 	"github.com/OpenListTeam/OpenList/v4/internal/bootstrap/data"
+	"github.com/OpenListTeam/OpenList/v4/internal/cluster"
 	"github.com/OpenListTeam/OpenList/v4/internal/db"
 	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
 	log "github.com/sirupsen/logrus"
@@ -18,11 +19,13 @@ func Init() {
 	bootstrap.InitDB()
 	data.InitData()
 	bootstrap.InitStreamLimit()
+	bootstrap.InitBlockCache()
 	bootstrap.InitIndex()
 	bootstrap.InitUpgradePatch()
 }
 
 func Release() {
+	cluster.Release()
 	db.Close()
 }
 
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/declarative"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configPassphrase string
+	configOutput     string
+	configFile       string
+	configDryRun     bool
+	configPrune      bool
+)
+
+// ConfigCmd exports and applies the storages, users, metas, settings and shares as YAML
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Export or apply the configuration as a YAML document",
+}
+
+var ConfigExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export storages, users, metas, settings and shares, the secrets are redacted without a passphrase",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		Init()
+		defer Release()
+		d, err := declarative.Export(passphrase())
+		if err != nil {
+			return fmt.Errorf("failed to export: %+v", err)
+		}
+		data, err := d.Marshal()
+		if err != nil {
+			return fmt.Errorf("failed to marshal: %+v", err)
+		}
+		if configOutput == "" || configOutput == "-" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		if err := os.WriteFile(configOutput, data, 0o600); err != nil {
+			return fmt.Errorf("failed to write file: %+v", err)
+		}
+		utils.Log.Infof("configuration is exported to %s from CLI", configOutput)
+		return nil
+	},
+}
+
+var ConfigApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply a YAML document, the storages take effect after restart",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if configFile == "" {
+			return fmt.Errorf("file is required")
+		}
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %+v", err)
+		}
+		d, err := declarative.Parse(data)
+		if err != nil {
+			return err
+		}
+		Init()
+		defer Release()
+		changes, err := declarative.Apply(context.Background(), d, declarative.ApplyOptions{
+			Passphrase: passphrase(),
+			DryRun:     configDryRun,
+			Prune:      configPrune,
+		})
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to apply: %+v", err)
+		}
+		if len(changes) == 0 {
+			fmt.Println("No changes")
+		} else if !configDryRun {
+			utils.Log.Infof("%d changes of configuration are applied from CLI", len(changes))
+		}
+		return nil
+	},
+}
+
+func passphrase() string {
+	if configPassphrase != "" {
+		return configPassphrase
+	}
+	return os.Getenv("OPENLIST_CONFIG_PASSPHRASE")
+}
+
+func init() {
+	RootCmd.AddCommand(ConfigCmd)
+	ConfigCmd.AddCommand(ConfigExportCmd, ConfigApplyCmd)
+	ConfigCmd.PersistentFlags().StringVar(&configPassphrase, "passphrase", "",
+		"passphrase to encrypt or decrypt the secrets, defaults to env OPENLIST_CONFIG_PASSPHRASE")
+	ConfigExportCmd.Flags().StringVarP(&configOutput, "output", "o", "", "output file, defaults to stdout")
+	ConfigApplyCmd.Flags().StringVarP(&configFile, "file", "f", "", "YAML document to apply")
+	ConfigApplyCmd.Flags().BoolVar(&configDryRun, "dry-run", false, "only print the changes")
+	ConfigApplyCmd.Flags().BoolVar(&configPrune, "prune", false, "delete the objects not in the document, only for the sections present in it")
+}
@@ -20,7 +20,7 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
 	"github.com/OpenListTeam/OpenList/v4/server"
 	"github.com/OpenListTeam/OpenList/v4/server/middlewares"
-	"github.com/OpenListTeam/sftpd-openlist"
+	"github.com/OpenListTeam/OpenList/v4/server/sftp"
 	ftpserver "github.com/fclairamb/ftpserverlib"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
@@ -43,6 +43,7 @@ the address is defined in config file`,
 			utils.Log.Infof("delayed start for %d seconds", conf.Conf.DelayedStart)
 			time.Sleep(time.Duration(conf.Conf.DelayedStart) * time.Second)
 		}
+		bootstrap.InitCluster()
 		bootstrap.InitOfflineDownloadTools()
 		bootstrap.LoadStorages()
 		bootstrap.InitTaskManager()
@@ -175,7 +176,7 @@ the address is defined in config file`,
 			}
 		}
 		var sftpDriver *server.SftpDriver
-		var sftpServer *sftpd.SftpServer
+		var sftpServer *sftp.Server
 		if conf.Conf.SFTP.Listen != "" && conf.Conf.SFTP.Enable {
 			var err error
 			sftpDriver, err = server.NewSftpDriver()
@@ -185,8 +186,8 @@ the address is defined in config file`,
 				fmt.Printf("start sftp server on %s", conf.Conf.SFTP.Listen)
 				utils.Log.Infof("start sftp server on %s", conf.Conf.SFTP.Listen)
 				go func() {
-					sftpServer = sftpd.NewSftpServer(sftpDriver)
-					err = sftpServer.RunServer()
+					sftpServer = sftp.NewServer(sftpDriver)
+					err = sftpServer.ListenAndServe()
 					if err != nil {
 						utils.Log.Fatalf("problem sftp server listening: %s", err.Error())
 					}
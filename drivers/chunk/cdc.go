@@ -0,0 +1,98 @@
+package chunk
+
+import (
+	"io"
+	"math/bits"
+)
+
+// gear is the random table of the gear hash, it must never change or the chunks stored cut by it
+// no longer match the ones cut from the same content
+var gear = func() (table [256]uint64) {
+	// splitmix64
+	x := uint64(0x4f70656e4c697374)
+	for i := range table {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return
+}()
+
+// cdcChunker cuts a stream into chunks by content with FastCDC, so the same content is cut
+// into the same chunks wherever it is in the stream
+type cdcChunker struct {
+	r        io.Reader
+	min, avg int
+	// the mask with more bits is used before the average size, so the chunks are close to it
+	maskS, maskL uint64
+	buf          []byte
+	start, end   int
+	eof          bool
+}
+
+func newCDCChunker(r io.Reader, avg int) *cdcChunker {
+	n := bits.Len(uint(avg)) - 1
+	return &cdcChunker{
+		r:     r,
+		min:   avg / 4,
+		avg:   avg,
+		maskS: highBits(n + 2),
+		maskL: highBits(n - 2),
+		buf:   make([]byte, avg*4),
+	}
+}
+
+func highBits(n int) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	return ^uint64(0) << (64 - n)
+}
+
+// Next returns the next chunk, which is only valid until the next call, or io.EOF at the end
+func (c *cdcChunker) Next() ([]byte, error) {
+	if c.end-c.start < len(c.buf) && !c.eof {
+		copy(c.buf, c.buf[c.start:c.end])
+		c.end -= c.start
+		c.start = 0
+		n, err := io.ReadFull(c.r, c.buf[c.end:])
+		c.end += n
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			c.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+	data := c.buf[c.start:c.end]
+	n := c.cut(data)
+	c.start += n
+	return data[:n], nil
+}
+
+func (c *cdcChunker) cut(data []byte) int {
+	n := len(data)
+	if n <= c.min {
+		return n
+	}
+	normal := min(c.avg, n)
+	var fp uint64
+	i := c.min
+	for ; i < normal; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		fp = (fp << 1) + gear[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
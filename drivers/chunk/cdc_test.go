@@ -0,0 +1,88 @@
+package chunk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+const testAvg = 4096
+
+func cutAll(t *testing.T, r io.Reader) [][32]byte {
+	var ret [][32]byte
+	c := newCDCChunker(r, testAvg)
+	for {
+		data, err := c.Next()
+		if err == io.EOF {
+			return ret
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > testAvg*4 {
+			t.Fatalf("chunk of %d bytes is larger than the buffer", len(data))
+		}
+		ret = append(ret, sha256.Sum256(data))
+	}
+}
+
+func TestCDCChunker(t *testing.T) {
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	chunks := cutAll(t, bytes.NewReader(data))
+	if n := len(chunks); n < len(data)/testAvg/4 || n > len(data)/(testAvg/4) {
+		t.Fatalf("got %d chunks for %d bytes", n, len(data))
+	}
+	// the cut points depend on the content only, not on how it is read
+	if again := cutAll(t, iotest.OneByteReader(bytes.NewReader(data))); !equalChunks(chunks, again) {
+		t.Fatal("the chunks differ when read in another way")
+	}
+
+	// an insertion only changes the chunks around it
+	shifted := append([]byte("some bytes inserted at the start"), data...)
+	common := countCommon(chunks, cutAll(t, bytes.NewReader(shifted)))
+	if common < len(chunks)-2 {
+		t.Fatalf("only %d of %d chunks are kept after an insertion", common, len(chunks))
+	}
+}
+
+func TestCDCChunker_Small(t *testing.T) {
+	if chunks := cutAll(t, bytes.NewReader(nil)); len(chunks) != 0 {
+		t.Fatalf("got %d chunks of nothing", len(chunks))
+	}
+	data := []byte("smaller than the min size")
+	chunks := cutAll(t, bytes.NewReader(data))
+	if len(chunks) != 1 || chunks[0] != sha256.Sum256(data) {
+		t.Fatalf("got %d chunks", len(chunks))
+	}
+}
+
+func equalChunks(a, b [][32]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func countCommon(a, b [][32]byte) int {
+	set := make(map[[32]byte]struct{}, len(b))
+	for _, h := range b {
+		set[h] = struct{}{}
+	}
+	n := 0
+	for _, h := range a {
+		if _, ok := set[h]; ok {
+			n++
+		}
+	}
+	return n
+}
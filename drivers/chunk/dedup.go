@@ -0,0 +1,485 @@
+package chunk
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	stdpath "path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// poolDirName is the dir under the remote path holding the chunks of the dedup files by their sha256
+const poolDirName = ".openlist_chunk_pool"
+
+const manifestPrefix = "dedup_"
+
+// dedupManifest lists the chunks of a dedup file in order, it is stored in the chunk folder
+// of the file as dedup_<size>
+type dedupManifest struct {
+	Chunks []dedupChunk `json:"chunks"`
+}
+
+type dedupChunk struct {
+	Hash string `json:"h"`
+	Size int64  `json:"s"`
+}
+
+func (m *dedupManifest) hashes() []string {
+	ret := make([]string, 0, len(m.Chunks))
+	for _, c := range m.Chunks {
+		ret = append(ret, c.Hash)
+	}
+	return ret
+}
+
+func (m *dedupManifest) sizes() []int64 {
+	if len(m.Chunks) == 0 {
+		return []int64{0}
+	}
+	ret := make([]int64, 0, len(m.Chunks))
+	for _, c := range m.Chunks {
+		ret = append(ret, c.Size)
+	}
+	return ret
+}
+
+// parseManifestName returns the size of the dedup file if name is its manifest
+func (d *Chunk) parseManifestName(name string) (int64, bool) {
+	after, ok := strings.CutPrefix(strings.TrimSuffix(name, d.CustomExt), manifestPrefix)
+	if !ok {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(after, 10, 64)
+	return size, err == nil
+}
+
+func (d *Chunk) poolPath(remoteRoot, hash string) string {
+	return stdpath.Join(remoteRoot, poolDirName, hash[:2], hash+d.CustomExt)
+}
+
+func (d *Chunk) readManifest(ctx context.Context, storage driver.Driver, path string) (*dedupManifest, error) {
+	link, obj, err := op.Link(ctx, storage, path, model.LinkArgs{})
+	if err != nil {
+		return nil, err
+	}
+	defer link.Close()
+	size := link.ContentLength
+	if size <= 0 {
+		size = obj.GetSize()
+	}
+	rrf, err := stream.GetRangeReaderFromLink(size, link)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := rrf.RangeRead(ctx, http_range.Range{Length: -1})
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var m dedupManifest
+	if err = utils.Json.NewDecoder(rc).Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid dedup manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// walkManifests calls fn with the manifest of each dedup file at or under path
+func (d *Chunk) walkManifests(ctx context.Context, storage driver.Driver, path string, refresh bool, fn func(m *dedupManifest)) error {
+	if utils.IsCanceled(ctx) {
+		return ctx.Err()
+	}
+	objs, err := op.List(ctx, storage, path, model.ListArgs{Refresh: refresh})
+	if err != nil {
+		return err
+	}
+	isChunkDir := strings.HasPrefix(stdpath.Base(path), d.ChunkPrefix)
+	for _, obj := range objs {
+		name := obj.GetName()
+		if !obj.IsDir() {
+			if _, ok := d.parseManifestName(name); ok && isChunkDir {
+				m, err := d.readManifest(ctx, storage, stdpath.Join(path, name))
+				if err != nil {
+					return err
+				}
+				fn(m)
+			}
+			continue
+		}
+		if name == poolDirName || isChunkDir {
+			continue
+		}
+		if err = d.walkManifests(ctx, storage, stdpath.Join(path, name), refresh, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectHashes returns the chunks referred to by the dedup files at or under path
+func (d *Chunk) collectHashes(ctx context.Context, storage driver.Driver, path string) ([]string, error) {
+	obj, err := op.Get(ctx, storage, path)
+	if err != nil {
+		if errs.IsObjectNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !obj.IsDir() {
+		return nil, nil
+	}
+	var hashes []string
+	err = d.walkManifests(ctx, storage, path, false, func(m *dedupManifest) {
+		hashes = append(hashes, m.hashes()...)
+	})
+	return hashes, err
+}
+
+// releaseChunks drops the references of the hashes, and removes the chunks no longer referred to from the pool
+func (d *Chunk) releaseChunks(ctx context.Context, storage driver.Driver, remoteRoot string, hashes []string) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	d.poolMu.Lock()
+	defer d.poolMu.Unlock()
+	released, err := db.ReleaseChunkRefs(d.ID, hashes)
+	if err != nil {
+		return err
+	}
+	for _, hash := range released {
+		if e := op.Remove(ctx, storage, d.poolPath(remoteRoot, hash)); e != nil && !errs.IsObjectNotFound(e) {
+			err = errors.Join(err, e)
+		}
+	}
+	return err
+}
+
+// referChunks increases the references of the chunks already in the pool
+func (d *Chunk) referChunks(hashes []string) error {
+	d.poolMu.Lock()
+	defer d.poolMu.Unlock()
+	return db.AddChunkRefs(d.ID, hashes)
+}
+
+// storeChunk refers to the chunk, and uploads it to the pool unless it is already there.
+// The same chunk is uploaded once at a time, the others wait for it.
+func (d *Chunk) storeChunk(ctx context.Context, storage driver.Driver, remoteRoot, hash string, data []byte) error {
+	p := d.poolPath(remoteRoot, hash)
+	for {
+		d.poolMu.Lock()
+		done, ok := d.uploading[hash]
+		if !ok {
+			break
+		}
+		d.poolMu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	err := db.AddChunkRefs(d.ID, []string{hash})
+	if err != nil {
+		d.poolMu.Unlock()
+		return err
+	}
+	obj, err := op.Get(ctx, storage, p)
+	if err == nil && obj.GetSize() == int64(len(data)) {
+		d.poolMu.Unlock()
+		return nil
+	}
+	done := make(chan struct{})
+	if d.uploading == nil {
+		d.uploading = make(map[string]chan struct{})
+	}
+	d.uploading[hash] = done
+	d.poolMu.Unlock()
+
+	if err == nil || errs.IsObjectNotFound(err) {
+		// a chunk left incomplete is uploaded again
+		err = op.Put(ctx, storage, stdpath.Dir(p), &stream.FileStream{
+			Obj: &model.Object{
+				Name:     stdpath.Base(p),
+				Size:     int64(len(data)),
+				Modified: time.Now(),
+			},
+			Mimetype: "application/octet-stream",
+			Reader:   bytes.NewReader(data),
+		}, nil, true)
+	}
+	d.poolMu.Lock()
+	delete(d.uploading, hash)
+	close(done)
+	if err != nil {
+		_, _ = db.ReleaseChunkRefs(d.ID, []string{hash})
+	}
+	d.poolMu.Unlock()
+	return err
+}
+
+// putDedup cuts the file by content, stores the chunks missing in the pool and writes the manifest.
+// The new manifest is written before the old content of the file is removed, so the file is never
+// left without a manifest referring to its chunks.
+func (d *Chunk) putDedup(ctx context.Context, storage driver.Driver, remoteRoot, dstDirPath string, file model.FileStreamer, up driver.UpdateProgress) error {
+	d.gcMu.RLock()
+	defer d.gcMu.RUnlock()
+	chunker := newCDCChunker(&driver.ReaderUpdatingProgress{
+		Reader:         file,
+		UpdateProgress: up,
+	}, int(d.DedupChunkSize))
+	var (
+		m    dedupManifest
+		size int64
+	)
+	for {
+		data, err := chunker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			sum := sha256.Sum256(data)
+			hash := hex.EncodeToString(sum[:])
+			if err = d.storeChunk(ctx, storage, remoteRoot, hash, data); err == nil {
+				m.Chunks = append(m.Chunks, dedupChunk{Hash: hash, Size: int64(len(data))})
+				size += int64(len(data))
+				continue
+			}
+		}
+		return errors.Join(err, d.releaseChunks(ctx, storage, remoteRoot, m.hashes()))
+	}
+
+	dst := stdpath.Join(dstDirPath, d.ChunkPrefix+file.GetName())
+	manifestName := fmt.Sprintf("%s%d%s", manifestPrefix, size, d.CustomExt)
+	olds, err := op.List(ctx, storage, dst, model.ListArgs{Refresh: true})
+	if errs.IsObjectNotFound(err) {
+		err = nil
+	}
+	// the chunks of the manifest of the same name are released once it is overwritten
+	var replaced []string
+	for _, o := range olds {
+		if err == nil && o.GetName() == manifestName {
+			var old *dedupManifest
+			if old, err = d.readManifest(ctx, storage, stdpath.Join(dst, o.GetName())); err == nil {
+				replaced = old.hashes()
+			}
+		}
+	}
+	var content []byte
+	if err == nil {
+		content, err = utils.Json.Marshal(m)
+	}
+	if err == nil {
+		err = op.Put(ctx, storage, dst, &stream.FileStream{
+			Obj: &model.Object{
+				Name:     manifestName,
+				Size:     int64(len(content)),
+				Modified: file.ModTime(),
+			},
+			Mimetype: "application/json",
+			Reader:   bytes.NewReader(content),
+		}, nil)
+	}
+	if err != nil {
+		return errors.Join(err, d.releaseChunks(ctx, storage, remoteRoot, m.hashes()))
+	}
+	keep := d.putHashMarkers(ctx, storage, dst, file)
+	keep[manifestName] = struct{}{}
+
+	// the old manifests, parts and hash markers of the file are removed then
+	for _, o := range olds {
+		if _, ok := keep[o.GetName()]; ok || o.IsDir() {
+			continue
+		}
+		path := stdpath.Join(dst, o.GetName())
+		var old *dedupManifest
+		if _, ok := d.parseManifestName(o.GetName()); ok {
+			if old, err = d.readManifest(ctx, storage, path); err != nil {
+				// kept with its chunks, so the refs still match the manifests
+				log.Warnf("chunk: failed to read the old manifest %s: %v", path, err)
+				continue
+			}
+		}
+		if err = op.Remove(ctx, storage, path); err != nil {
+			log.Warnf("chunk: failed to remove %s: %v", path, err)
+			continue
+		}
+		if old != nil {
+			replaced = append(replaced, old.hashes()...)
+		}
+	}
+	return d.releaseChunks(ctx, storage, remoteRoot, replaced)
+}
+
+// dropManifests removes the manifests left in the chunk folder by a dedup put of the file and releases
+// their chunks, a manifest wins over the parts so the file would still read its old content
+func (d *Chunk) dropManifests(ctx context.Context, storage driver.Driver, remoteRoot, dst string) error {
+	d.gcMu.RLock()
+	defer d.gcMu.RUnlock()
+	objs, err := op.List(ctx, storage, dst, model.ListArgs{Refresh: true})
+	if err != nil {
+		return err
+	}
+	var hashes []string
+	for _, o := range objs {
+		if _, ok := d.parseManifestName(o.GetName()); !ok || o.IsDir() {
+			continue
+		}
+		path := stdpath.Join(dst, o.GetName())
+		m, err := d.readManifest(ctx, storage, path)
+		if err != nil {
+			// its chunks are left to the gc, which counts the refs from the manifests again
+			log.Warnf("chunk: failed to read the old manifest %s: %v", path, err)
+		}
+		if err = op.Remove(ctx, storage, path); err != nil {
+			return err
+		}
+		if m != nil {
+			hashes = append(hashes, m.hashes()...)
+		}
+	}
+	return d.releaseChunks(ctx, storage, remoteRoot, hashes)
+}
+
+// GCReport is the result of the latest garbage collection of the pool of a chunk driver
+type GCReport struct {
+	Running   bool      `json:"running"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	// Files is the count of the dedup files found
+	Files int `json:"files"`
+	// Chunks is the count of the chunks they refer to
+	Chunks  int      `json:"chunks"`
+	Removed int      `json:"removed"`
+	Errors  []string `json:"errors"`
+}
+
+func isAdmin(ctx context.Context) bool {
+	user, ok := ctx.Value(conf.UserKey).(*model.User)
+	return ok && user.IsAdmin()
+}
+
+// startGC counts the references of the chunks from all the manifests in the background,
+// removes the chunks not referred to from the pool and resets the refs in the database.
+// Writes of dedup files wait until it is done.
+func (d *Chunk) startGC(ctx context.Context) error {
+	storage, remoteRoot, err := op.GetStorageAndActualPath(d.RemotePath)
+	if err != nil {
+		return err
+	}
+	d.gcReportMu.Lock()
+	defer d.gcReportMu.Unlock()
+	if d.gcReport != nil && d.gcReport.Running {
+		return errors.New("gc is running, please try later")
+	}
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	d.gcCancel = cancel
+	r := &GCReport{Running: true, StartedAt: time.Now()}
+	d.gcReport = r
+	go func() {
+		defer cancel()
+		d.gcMu.Lock()
+		err := d.gc(ctx, storage, remoteRoot, r)
+		d.gcMu.Unlock()
+		d.gcReportMu.Lock()
+		if err != nil {
+			r.Errors = append(r.Errors, err.Error())
+		}
+		r.Running = false
+		r.EndedAt = time.Now()
+		d.gcReportMu.Unlock()
+		log.Infof("chunk gc of %s done, %d files, %d chunks, removed %d, %d errors",
+			d.MountPath, r.Files, r.Chunks, r.Removed, len(r.Errors))
+	}()
+	return nil
+}
+
+func (d *Chunk) gc(ctx context.Context, storage driver.Driver, remoteRoot string, r *GCReport) error {
+	refs := make(map[string]int64)
+	err := d.walkManifests(ctx, storage, remoteRoot, true, func(m *dedupManifest) {
+		for _, hash := range m.hashes() {
+			refs[hash]++
+		}
+		d.gcReportMu.Lock()
+		r.Files++
+		r.Chunks = len(refs)
+		d.gcReportMu.Unlock()
+	})
+	if err != nil {
+		// nothing is removed without knowing all the references
+		return err
+	}
+	poolPath := stdpath.Join(remoteRoot, poolDirName)
+	dirs, err := op.List(ctx, storage, poolPath, model.ListArgs{Refresh: true})
+	if err != nil && !errs.IsObjectNotFound(err) {
+		return err
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		dirPath := stdpath.Join(poolPath, dir.GetName())
+		objs, err := op.List(ctx, storage, dirPath, model.ListArgs{Refresh: true})
+		if err != nil {
+			d.gcError(r, fmt.Sprintf("%s: %v", dirPath, err))
+			continue
+		}
+		for _, obj := range objs {
+			if utils.IsCanceled(ctx) {
+				return ctx.Err()
+			}
+			if _, ok := refs[strings.TrimSuffix(obj.GetName(), d.CustomExt)]; ok || obj.IsDir() {
+				continue
+			}
+			if err = op.Remove(ctx, storage, stdpath.Join(dirPath, obj.GetName())); err != nil {
+				d.gcError(r, err.Error())
+				continue
+			}
+			d.gcReportMu.Lock()
+			r.Removed++
+			d.gcReportMu.Unlock()
+		}
+	}
+	return db.ResetChunkRefs(d.ID, refs)
+}
+
+func (d *Chunk) gcError(r *GCReport, msg string) {
+	d.gcReportMu.Lock()
+	defer d.gcReportMu.Unlock()
+	r.Errors = append(r.Errors, msg)
+}
+
+// getGCReport returns a copy of the latest report, nil if there is none
+func (d *Chunk) getGCReport() *GCReport {
+	d.gcReportMu.Lock()
+	defer d.gcReportMu.Unlock()
+	if d.gcReport == nil {
+		return nil
+	}
+	r := *d.gcReport
+	r.Errors = append([]string(nil), r.Errors...)
+	return &r
+}
+
+func (d *Chunk) stopGC() {
+	d.gcReportMu.Lock()
+	defer d.gcReportMu.Unlock()
+	if d.gcCancel != nil {
+		d.gcCancel()
+	}
+}
@@ -9,6 +9,7 @@ import (
 	stdpath "path"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
@@ -27,6 +28,16 @@ import (
 type Chunk struct {
 	model.Storage
 	Addition
+
+	// poolMu keeps a chunk from being removed from the pool while it is referred to again
+	poolMu sync.Mutex
+	// uploading holds the chunks being uploaded to the pool, closed once done, guarded by poolMu
+	uploading map[string]chan struct{}
+	// gcMu is held by the writes of the dedup files for reading, and by the gc for writing
+	gcMu       sync.RWMutex
+	gcReportMu sync.Mutex
+	gcReport   *GCReport
+	gcCancel   context.CancelFunc
 }
 
 func (d *Chunk) Config() driver.Config {
@@ -44,11 +55,24 @@ func (d *Chunk) Init(ctx context.Context) error {
 	if len(d.ChunkPrefix) <= 0 {
 		return errors.New("chunk folder prefix must not be empty")
 	}
+	if d.Dedup && d.DedupChunkSize < 4096 {
+		return errors.New("dedup chunk size must be at least 4096")
+	}
 	d.RemotePath = utils.FixAndCleanPath(d.RemotePath)
+	if d.Dedup {
+		// the refs are counted by storage, so a pool can't be shared
+		for _, s := range op.GetAllStorages() {
+			other, ok := s.(*Chunk)
+			if ok && other.ID != d.ID && other.Dedup && other.RemotePath == d.RemotePath {
+				return fmt.Errorf("the remote path is used by the dedup chunk storage %s", other.MountPath)
+			}
+		}
+	}
 	return nil
 }
 
 func (d *Chunk) Drop(ctx context.Context) error {
+	d.stopGC()
 	return nil
 }
 
@@ -86,11 +110,21 @@ func (d *Chunk) Get(ctx context.Context, path string) (model.Obj, error) {
 	// 0号块默认为-1 以支持空文件
 	chunkSizes := []int64{-1}
 	h := make(map[*utils.HashType]string)
-	var first model.Obj
+	var (
+		first        model.Obj
+		manifest     string
+		manifestSize int64
+	)
 	for _, o := range chunkObjs {
 		if o.IsDir() {
 			continue
 		}
+		if size, ok := d.parseManifestName(o.GetName()); ok {
+			manifestSize = size
+			first = o
+			manifest = o.GetName()
+			continue
+		}
 		if after, ok := strings.CutPrefix(o.GetName(), "hash_"); ok {
 			hn, value, ok := strings.Cut(strings.TrimSuffix(after, d.CustomExt), "_")
 			if ok {
@@ -120,6 +154,10 @@ func (d *Chunk) Get(ctx context.Context, path string) (model.Obj, error) {
 			chunkSizes[idx] = o.GetSize()
 		}
 	}
+	if manifest != "" {
+		// the parts left by an overwrite are ignored
+		totalSize = manifestSize
+	}
 	reqDir, _ := stdpath.Split(path)
 	objRes := chunkObject{
 		Object: model.Object{
@@ -130,6 +168,7 @@ func (d *Chunk) Get(ctx context.Context, path string) (model.Obj, error) {
 			Ctime:    first.CreateTime(),
 		},
 		chunkSizes: chunkSizes,
+		manifest:   manifest,
 	}
 	if len(h) > 0 {
 		objRes.HashInfo = utils.NewHashInfoByMap(h)
@@ -151,12 +190,16 @@ func (d *Chunk) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([
 		return nil, err
 	}
 	result := make([]model.Obj, 0, len(remoteObjs))
+	isRoot := utils.PathEqual(dir.GetPath(), "/")
 	listG, listCtx := errgroup.NewGroupWithContext(ctx, d.NumListWorkers, retry.Attempts(3))
 	for _, obj := range remoteObjs {
 		if utils.IsCanceled(listCtx) {
 			break
 		}
 		rawName := obj.GetName()
+		if isRoot && rawName == poolDirName {
+			continue
+		}
 		if obj.IsDir() {
 			if name, ok := strings.CutPrefix(rawName, d.ChunkPrefix); ok {
 				resultIdx := len(result)
@@ -169,13 +212,18 @@ func (d *Chunk) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([
 					if err != nil {
 						return err
 					}
-					totalSize := int64(0)
+					totalSize, manifestSize := int64(0), int64(-1)
 					h := make(map[*utils.HashType]string)
 					first := obj
 					for _, o := range chunkObjs {
 						if o.IsDir() {
 							continue
 						}
+						if size, ok := d.parseManifestName(o.GetName()); ok {
+							first = o
+							manifestSize = size
+							continue
+						}
 						if after, ok := strings.CutPrefix(strings.TrimSuffix(o.GetName(), d.CustomExt), "hash_"); ok {
 							hn, value, ok := strings.Cut(after, "_")
 							if ok {
@@ -195,6 +243,9 @@ func (d *Chunk) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([
 						}
 						totalSize += o.GetSize()
 					}
+					if manifestSize >= 0 {
+						totalSize = manifestSize
+					}
 					objRes := model.Object{
 						Name:     name,
 						Size:     totalSize,
@@ -259,6 +310,7 @@ func (d *Chunk) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (
 		return nil, err
 	}
 	chunkFile, ok := file.(*chunkObject)
+	remoteRoot := remoteActualPath
 	remoteActualPath = stdpath.Join(remoteActualPath, file.GetPath())
 	if !ok {
 		l, _, err := op.Link(ctx, remoteStorage, remoteActualPath, args)
@@ -269,15 +321,29 @@ func (d *Chunk) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (
 		resultLink.SyncClosers = utils.NewSyncClosers(l)
 		return &resultLink, nil
 	}
+	chunkSizes := chunkFile.chunkSizes
+	partPath := func(idx int) string {
+		return stdpath.Join(remoteActualPath, d.getPartName(idx))
+	}
+	if chunkFile.manifest != "" {
+		m, err := d.readManifest(ctx, remoteStorage, stdpath.Join(remoteActualPath, chunkFile.manifest))
+		if err != nil {
+			return nil, err
+		}
+		chunkSizes = m.sizes()
+		partPath = func(idx int) string {
+			return d.poolPath(remoteRoot, m.Chunks[idx].Hash)
+		}
+	}
 	// 检查0号块不等于-1 以支持空文件
 	// 如果块数量大于1 最后一块不可能为0
 	// 只检查中间块是否有0
-	for i, l := 0, len(chunkFile.chunkSizes)-2; ; i++ {
+	for i, l := 0, len(chunkSizes)-2; ; i++ {
 		if i == 0 {
-			if chunkFile.chunkSizes[i] == -1 {
+			if chunkSizes[i] == -1 {
 				return nil, fmt.Errorf("chunk part[%d] are missing", i)
 			}
-		} else if chunkFile.chunkSizes[i] == 0 {
+		} else if chunkSizes[i] == 0 {
 			return nil, fmt.Errorf("chunk part[%d] are missing", i)
 		}
 		if i >= l {
@@ -300,9 +366,9 @@ func (d *Chunk) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (
 			rc       io.ReadCloser
 			readFrom bool
 		)
-		for idx, chunkSize := range chunkFile.chunkSizes {
+		for idx, chunkSize := range chunkSizes {
 			if readFrom {
-				l, o, err := op.Link(ctx, remoteStorage, stdpath.Join(remoteActualPath, d.getPartName(idx)), args)
+				l, o, err := op.Link(ctx, remoteStorage, partPath(idx), args)
 				if err != nil {
 					_ = cs.Close()
 					return nil, err
@@ -343,7 +409,7 @@ func (d *Chunk) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (
 			} else if newStart := start - chunkSize; newStart >= 0 {
 				start = newStart
 			} else {
-				l, o, err := op.Link(ctx, remoteStorage, stdpath.Join(remoteActualPath, d.getPartName(idx)), args)
+				l, o, err := op.Link(ctx, remoteStorage, partPath(idx), args)
 				if err != nil {
 					_ = cs.Close()
 					return nil, err
@@ -408,12 +474,49 @@ func (d *Chunk) Rename(ctx context.Context, srcObj model.Obj, newName string) er
 func (d *Chunk) Copy(ctx context.Context, srcObj, dstDir model.Obj) error {
 	dst := stdpath.Join(d.RemotePath, dstDir.GetPath())
 	src := stdpath.Join(d.RemotePath, srcObj.GetPath())
-	_, err := fs.Copy(ctx, src, dst)
-	return err
+	if !d.Dedup {
+		_, err := fs.Copy(ctx, src, dst)
+		return err
+	}
+	// the copies of the manifests refer to the same chunks
+	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(d.RemotePath)
+	if err != nil {
+		return err
+	}
+	d.gcMu.RLock()
+	defer d.gcMu.RUnlock()
+	hashes, err := d.collectHashes(ctx, remoteStorage, stdpath.Join(remoteActualPath, srcObj.GetPath()))
+	if err != nil {
+		return err
+	}
+	if err = d.referChunks(hashes); err != nil {
+		return err
+	}
+	if _, err = fs.Copy(ctx, src, dst); err != nil {
+		return errors.Join(err, d.releaseChunks(ctx, remoteStorage, remoteActualPath, hashes))
+	}
+	return nil
 }
 
 func (d *Chunk) Remove(ctx context.Context, obj model.Obj) error {
-	return fs.Remove(ctx, stdpath.Join(d.RemotePath, obj.GetPath()))
+	path := stdpath.Join(d.RemotePath, obj.GetPath())
+	if chunkFile, ok := obj.(*chunkObject); !d.Dedup && (!ok || chunkFile.manifest == "") {
+		return fs.Remove(ctx, path)
+	}
+	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(d.RemotePath)
+	if err != nil {
+		return err
+	}
+	d.gcMu.RLock()
+	defer d.gcMu.RUnlock()
+	hashes, err := d.collectHashes(ctx, remoteStorage, stdpath.Join(remoteActualPath, obj.GetPath()))
+	if err != nil {
+		return err
+	}
+	if err = fs.Remove(ctx, path); err != nil {
+		return err
+	}
+	return d.releaseChunks(ctx, remoteStorage, remoteActualPath, hashes)
 }
 
 func (d *Chunk) Put(ctx context.Context, dstDir model.Obj, file model.FileStreamer, up driver.UpdateProgress) error {
@@ -424,24 +527,15 @@ func (d *Chunk) Put(ctx context.Context, dstDir model.Obj, file model.FileStream
 	if (d.Thumbnail && dstDir.GetName() == ".thumbnails") || (d.ChunkLargeFileOnly && file.GetSize() <= d.PartSize) {
 		return op.Put(ctx, remoteStorage, stdpath.Join(remoteActualPath, dstDir.GetPath()), file, up)
 	}
+	if d.Dedup {
+		return d.putDedup(ctx, remoteStorage, remoteActualPath, stdpath.Join(remoteActualPath, dstDir.GetPath()), file, up)
+	}
 	upReader := &driver.ReaderUpdatingProgress{
 		Reader:         file,
 		UpdateProgress: up,
 	}
 	dst := stdpath.Join(remoteActualPath, dstDir.GetPath(), d.ChunkPrefix+file.GetName())
-	if d.StoreHash {
-		for ht, value := range file.GetHash().All() {
-			_ = op.Put(ctx, remoteStorage, dst, &stream.FileStream{
-				Obj: &model.Object{
-					Name:     fmt.Sprintf("hash_%s_%s%s", ht.Name, value, d.CustomExt),
-					Size:     1,
-					Modified: file.ModTime(),
-				},
-				Mimetype: "application/octet-stream",
-				Reader:   bytes.NewReader([]byte{0}), // 兼容不支持空文件的驱动
-			}, nil, true)
-		}
-	}
+	d.putHashMarkers(ctx, remoteStorage, dst, file)
 	fullPartCount := int(file.GetSize() / d.PartSize)
 	tailSize := file.GetSize() % d.PartSize
 	if tailSize == 0 && fullPartCount > 0 {
@@ -476,8 +570,32 @@ func (d *Chunk) Put(ctx context.Context, dstDir model.Obj, file model.FileStream
 	}, nil)
 	if err != nil {
 		_ = op.Remove(ctx, remoteStorage, dst)
+		return err
 	}
-	return err
+	// the file may have been put while dedup was enabled
+	return d.dropManifests(ctx, remoteStorage, remoteActualPath, dst)
+}
+
+// putHashMarkers stores the hashes of the file as empty files in its chunk folder, and returns their names
+func (d *Chunk) putHashMarkers(ctx context.Context, remoteStorage driver.Driver, dst string, file model.FileStreamer) map[string]struct{} {
+	names := make(map[string]struct{})
+	if !d.StoreHash {
+		return names
+	}
+	for ht, value := range file.GetHash().All() {
+		name := fmt.Sprintf("hash_%s_%s%s", ht.Name, value, d.CustomExt)
+		names[name] = struct{}{}
+		_ = op.Put(ctx, remoteStorage, dst, &stream.FileStream{
+			Obj: &model.Object{
+				Name:     name,
+				Size:     1,
+				Modified: file.ModTime(),
+			},
+			Mimetype: "application/octet-stream",
+			Reader:   bytes.NewReader([]byte{0}), // 兼容不支持空文件的驱动
+		}, nil, true)
+	}
+	return names
 }
 
 func (d *Chunk) getPartName(part int) string {
@@ -498,4 +616,19 @@ func (d *Chunk) GetDetails(ctx context.Context) (*model.StorageDetails, error) {
 	}, nil
 }
 
+func (d *Chunk) Other(ctx context.Context, args model.OtherArgs) (interface{}, error) {
+	switch args.Method {
+	case "gc":
+		if !isAdmin(ctx) {
+			return nil, errs.PermissionDenied
+		}
+		return nil, d.startGC(ctx)
+	case "gc_report":
+		return d.getGCReport(), nil
+	default:
+		return nil, errs.NotSupport
+	}
+}
+
 var _ driver.Driver = (*Chunk)(nil)
+var _ driver.Other = (*Chunk)(nil)
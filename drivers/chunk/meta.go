@@ -13,6 +13,8 @@ type Addition struct {
 	CustomExt          string `json:"custom_ext" type:"string"`
 	StoreHash          bool   `json:"store_hash" type:"bool" default:"true"`
 	NumListWorkers     int    `json:"num_list_workers" required:"true" type:"number" default:"5"`
+	Dedup              bool   `json:"dedup" default:"false" help:"cut files by content and store the chunks once by hash in a pool shared by all files, part_size is not used"`
+	DedupChunkSize     int64  `json:"dedup_chunk_size" type:"number" default:"1048576" help:"average chunk size of dedup in bytes, chunks are between 1/4 and 4 times of it"`
 
 	Thumbnail  bool `json:"thumbnail" required:"true" default:"false" help:"enable thumbnail which pre-generated under .thumbnails folder"`
 	ShowHidden bool `json:"show_hidden"  default:"true" required:"false" help:"show hidden directories and files"`
@@ -33,6 +35,7 @@ func init() {
 			Addition: Addition{
 				ChunkPrefix:    "[openlist_chunk]",
 				NumListWorkers: 5,
+				DedupChunkSize: 1048576,
 			},
 		}
 	})
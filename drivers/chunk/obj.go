@@ -5,4 +5,6 @@ import "github.com/OpenListTeam/OpenList/v4/internal/model"
 type chunkObject struct {
 	model.Object
 	chunkSizes []int64
+	// manifest is the name of the manifest in the chunk folder if it is a dedup file
+	manifest string
 }
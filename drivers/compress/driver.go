@@ -0,0 +1,339 @@
+package compress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	stdpath "path"
+	"strconv"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/klauspost/compress/zstd"
+)
+
+type Compress struct {
+	model.Storage
+	Addition
+	encoder *zstd.Encoder
+	skipExt map[string]struct{}
+}
+
+// compressedSuffix ends the names of the compressed files, which are
+// <name>.<original size>.ol.zst so that the size is known without reading them
+const compressedSuffix = ".ol.zst"
+
+var levels = map[string]zstd.EncoderLevel{
+	"fastest": zstd.SpeedFastest,
+	"default": zstd.SpeedDefault,
+	"better":  zstd.SpeedBetterCompression,
+	"best":    zstd.SpeedBestCompression,
+}
+
+func (d *Compress) Config() driver.Config {
+	return config
+}
+
+func (d *Compress) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *Compress) Init(ctx context.Context) error {
+	d.RemotePath = utils.FixAndCleanPath(d.RemotePath)
+	if d.FrameSize <= 0 {
+		d.FrameSize = 1024 * 1024
+	}
+	if d.FrameSize < 4096 || d.FrameSize > 256*1024*1024 {
+		return errors.New("frame size must be between 4096 and 268435456")
+	}
+	level, ok := levels[d.Level]
+	if !ok {
+		level = zstd.SpeedDefault
+	}
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level))
+	if err != nil {
+		return fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+	d.encoder = encoder
+	d.skipExt = make(map[string]struct{})
+	for _, ext := range strings.Split(d.SkipExtensions, ",") {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext != "" {
+			d.skipExt[ext] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (d *Compress) Drop(ctx context.Context) error {
+	if d.encoder != nil {
+		_ = d.encoder.Close()
+	}
+	return nil
+}
+
+func compressedName(name string, size int64) string {
+	return fmt.Sprintf("%s.%d%s", name, size, compressedSuffix)
+}
+
+// parseCompressedName returns the original name and size of a compressed file
+func parseCompressedName(rawName string) (string, int64, bool) {
+	s, ok := strings.CutSuffix(rawName, compressedSuffix)
+	if !ok {
+		return "", 0, false
+	}
+	i := strings.LastIndexByte(s, '.')
+	if i <= 0 {
+		return "", 0, false
+	}
+	size, err := strconv.ParseInt(s[i+1:], 10, 64)
+	if err != nil || size < 0 {
+		return "", 0, false
+	}
+	return s[:i], size, true
+}
+
+// convert returns the object shown of the remote object in remoteDir
+func convert(remoteDir string, obj model.Obj) *model.Object {
+	rawName := model.UnwrapObj(obj).GetName()
+	objRes := &model.Object{
+		Path:     stdpath.Join(remoteDir, rawName),
+		Name:     rawName,
+		Size:     obj.GetSize(),
+		Modified: obj.ModTime(),
+		IsFolder: obj.IsDir(),
+		Ctime:    obj.CreateTime(),
+		HashInfo: obj.GetHash(),
+	}
+	if obj.IsDir() {
+		return objRes
+	}
+	if name, size, ok := parseCompressedName(rawName); ok {
+		objRes.Name = name
+		objRes.Size = size
+		// the hashes are of the compressed data
+		objRes.HashInfo = utils.HashInfo{}
+	}
+	return objRes
+}
+
+func (d *Compress) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	remoteFullPath := dir.GetPath()
+	objs, err := fs.List(ctx, remoteFullPath, &fs.ListArgs{NoLog: true, Refresh: args.Refresh})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]model.Obj, 0, len(objs))
+	for _, obj := range objs {
+		objRes := convert(remoteFullPath, obj)
+		if !d.ShowHidden && strings.HasPrefix(objRes.Name, ".") {
+			continue
+		}
+		result = append(result, objRes)
+	}
+	return result, nil
+}
+
+func (d *Compress) Get(ctx context.Context, path string) (model.Obj, error) {
+	if utils.PathEqual(path, "/") {
+		return &model.Object{
+			Name:     "Root",
+			IsFolder: true,
+			Path:     d.RemotePath,
+		}, nil
+	}
+	remoteFullPath := stdpath.Join(d.RemotePath, path)
+	remoteObj, err := fs.Get(ctx, remoteFullPath, &fs.GetArgs{NoLog: true})
+	if err == nil {
+		return convert(stdpath.Dir(remoteFullPath), remoteObj), nil
+	}
+	if !errs.IsObjectNotFound(err) {
+		return nil, err
+	}
+	remoteDir, name := stdpath.Split(remoteFullPath)
+	objs, err := fs.List(ctx, remoteDir, &fs.ListArgs{NoLog: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		if obj.IsDir() {
+			continue
+		}
+		if n, _, ok := parseCompressedName(model.UnwrapObj(obj).GetName()); ok && n == name {
+			return convert(remoteDir, obj), nil
+		}
+	}
+	return nil, errs.ObjectNotFound
+}
+
+func (d *Compress) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(file.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	if _, _, ok := parseCompressedName(stdpath.Base(remoteActualPath)); !ok {
+		l, _, err := op.Link(ctx, remoteStorage, remoteActualPath, args)
+		if err != nil {
+			return nil, err
+		}
+		resultLink := *l
+		resultLink.SyncClosers = utils.NewSyncClosers(l)
+		return &resultLink, nil
+	}
+	remoteLink, remoteFile, err := op.Link(ctx, remoteStorage, remoteActualPath, model.LinkArgs{})
+	if err != nil {
+		return nil, err
+	}
+	remoteSize := remoteLink.ContentLength
+	if remoteSize <= 0 {
+		remoteSize = remoteFile.GetSize()
+	}
+	rrf, err := stream.GetRangeReaderFromLink(remoteSize, remoteLink)
+	if err != nil {
+		_ = remoteLink.Close()
+		return nil, err
+	}
+	table, err := readSeekTable(ctx, rrf, remoteSize)
+	if err != nil {
+		_ = remoteLink.Close()
+		return nil, err
+	}
+	return &model.Link{
+		RangeReader: stream.RangeReaderFunc(func(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
+			return table.openRange(ctx, rrf, httpRange)
+		}),
+		SyncClosers:      utils.NewSyncClosers(remoteLink),
+		RequireReference: remoteLink.RequireReference,
+	}, nil
+}
+
+func (d *Compress) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) error {
+	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(parentDir.GetPath())
+	if err != nil {
+		return err
+	}
+	return op.MakeDir(ctx, remoteStorage, stdpath.Join(remoteActualPath, dirName))
+}
+
+func (d *Compress) Move(ctx context.Context, srcObj, dstDir model.Obj) error {
+	_, err := fs.Move(ctx, srcObj.GetPath(), dstDir.GetPath())
+	return err
+}
+
+func (d *Compress) Rename(ctx context.Context, srcObj model.Obj, newName string) error {
+	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(srcObj.GetPath())
+	if err != nil {
+		return err
+	}
+	if _, size, ok := parseCompressedName(stdpath.Base(remoteActualPath)); ok && !srcObj.IsDir() {
+		newName = compressedName(newName, size)
+	} else if !srcObj.IsDir() && strings.HasSuffix(newName, compressedSuffix) {
+		return errors.New("the names ending with " + compressedSuffix + " are kept for the compressed files")
+	}
+	return op.Rename(ctx, remoteStorage, remoteActualPath, newName)
+}
+
+func (d *Compress) Copy(ctx context.Context, srcObj, dstDir model.Obj) error {
+	_, err := fs.Copy(ctx, srcObj.GetPath(), dstDir.GetPath())
+	return err
+}
+
+func (d *Compress) Remove(ctx context.Context, obj model.Obj) error {
+	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(obj.GetPath())
+	if err != nil {
+		return err
+	}
+	return op.Remove(ctx, remoteStorage, remoteActualPath)
+}
+
+func (d *Compress) Put(ctx context.Context, dstDir model.Obj, streamer model.FileStreamer, up driver.UpdateProgress) error {
+	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(dstDir.GetPath())
+	if err != nil {
+		return err
+	}
+	exist := streamer.GetExist()
+	var name string
+	// a name like a compressed one is always compressed, or it would be taken for one
+	if _, ok := d.skipExt[utils.Ext(streamer.GetName())]; ok && !strings.HasSuffix(streamer.GetName(), compressedSuffix) {
+		name = streamer.GetName()
+		err = op.Put(ctx, remoteStorage, remoteActualPath, streamer, up)
+	} else {
+		name, err = d.putCompressed(ctx, remoteStorage, remoteActualPath, streamer, up)
+	}
+	if err != nil {
+		return err
+	}
+	// the file replaced is of another name if the size changes
+	if exist != nil && !exist.IsDir() && exist.GetPath() != stdpath.Join(dstDir.GetPath(), name) {
+		storage, actualPath, err := op.GetStorageAndActualPath(exist.GetPath())
+		if err != nil {
+			return err
+		}
+		return op.Remove(ctx, storage, actualPath)
+	}
+	return nil
+}
+
+// putCompressed compresses the file to a temp file before uploading it, since the size is needed by most
+// of the storages, and returns the name uploaded
+func (d *Compress) putCompressed(ctx context.Context, remoteStorage driver.Driver, dstDirPath string, streamer model.FileStreamer, up driver.UpdateProgress) (string, error) {
+	tmp, err := os.CreateTemp(conf.Conf.TempDir, "file-*")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	size, err := compressFrames(tmp, &driver.ReaderUpdatingProgress{
+		Reader:         streamer,
+		UpdateProgress: model.UpdateProgressWithRange(up, 0, 50),
+	}, d.encoder, int(d.FrameSize))
+	if err != nil {
+		return "", err
+	}
+	compressedSize, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	name := compressedName(streamer.GetName(), size)
+	err = op.Put(ctx, remoteStorage, dstDirPath, &stream.FileStream{
+		Obj: &model.Object{
+			Name:     name,
+			Size:     compressedSize,
+			Modified: streamer.ModTime(),
+		},
+		Reader:   tmp,
+		Mimetype: "application/zstd",
+	}, model.UpdateProgressWithRange(up, 50, 100))
+	return name, err
+}
+
+func (d *Compress) GetDetails(ctx context.Context) (*model.StorageDetails, error) {
+	remoteStorage, _, err := op.GetStorageAndActualPath(d.RemotePath)
+	if err != nil {
+		return nil, errs.NotImplement
+	}
+	remoteDetails, err := op.GetStorageDetails(ctx, remoteStorage)
+	if err != nil {
+		return nil, err
+	}
+	return &model.StorageDetails{
+		DiskUsage: remoteDetails.DiskUsage,
+	}, nil
+}
+
+var _ driver.Driver = (*Compress)(nil)
@@ -0,0 +1,31 @@
+package compress
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+type Addition struct {
+	RemotePath     string `json:"remote_path" required:"true" help:"This is where the compressed data stores"`
+	Level          string `json:"level" type:"select" options:"fastest,default,better,best" default:"default"`
+	FrameSize      int64  `json:"frame_size" type:"number" default:"1048576" help:"bytes of the original data in a frame, a range request decompresses the frames it covers"`
+	SkipExtensions string `json:"skip_extensions" default:"zip,rar,7z,gz,tgz,bz2,xz,zst,br,lz4,jpg,jpeg,png,gif,webp,heic,avif,mp3,flac,aac,ogg,opus,m4a,mp4,mkv,avi,mov,webm,flv,docx,xlsx,pptx,apk,iso" help:"files of these extensions are already compressed, and are stored as they are"`
+
+	ShowHidden bool `json:"show_hidden"  default:"true" required:"false" help:"show hidden directories and files"`
+}
+
+var config = driver.Config{
+	Name:        "Compress",
+	LocalSort:   true,
+	OnlyProxy:   true,
+	NoCache:     true,
+	DefaultRoot: "/",
+	NoLinkURL:   true,
+	CheckStatus: true,
+}
+
+func init() {
+	op.RegisterDriver(func() driver.Driver {
+		return &Compress{}
+	})
+}
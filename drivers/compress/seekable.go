@@ -0,0 +1,193 @@
+package compress
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/klauspost/compress/zstd"
+)
+
+// The files are stored in the zstd seekable format
+// https://github.com/facebook/zstd/blob/dev/contrib/seekable_format/zstd_seekable_compression_format.md
+// they are valid zstd files made of independent frames, followed by a skippable frame
+// holding the sizes of each frame, so a range can be read by decompressing only the frames it covers.
+const (
+	skippableMagic  = 0x184D2A5E
+	seekableMagic   = 0x8F92EAB1
+	seekFooterSize  = 9
+	seekEntrySize   = 8
+	skippableHeader = 8
+)
+
+type frame struct {
+	// the offset and the size in the compressed file
+	cOff, cSize int64
+	// the offset and the size in the original file
+	dOff, dSize int64
+}
+
+type seekTable []frame
+
+// compressFrames reads r to the end, and writes it to w as frames of at most frameSize bytes
+// before compressing, followed by the seek table. It returns the size of the data read.
+func compressFrames(w io.Writer, r io.Reader, enc *zstd.Encoder, frameSize int) (int64, error) {
+	var (
+		entries []byte
+		n       uint32
+		size    int64
+		buf     = make([]byte, frameSize)
+		out     []byte
+	)
+	for {
+		l, err := io.ReadFull(r, buf)
+		if l > 0 {
+			out = enc.EncodeAll(buf[:l], out[:0])
+			if _, err := w.Write(out); err != nil {
+				return 0, err
+			}
+			entries = binary.LittleEndian.AppendUint32(entries, uint32(len(out)))
+			entries = binary.LittleEndian.AppendUint32(entries, uint32(l))
+			n++
+			size += int64(l)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	table := make([]byte, 0, skippableHeader+len(entries)+seekFooterSize)
+	table = binary.LittleEndian.AppendUint32(table, skippableMagic)
+	table = binary.LittleEndian.AppendUint32(table, uint32(len(entries)+seekFooterSize))
+	table = append(table, entries...)
+	table = binary.LittleEndian.AppendUint32(table, n)
+	// no checksums in the entries
+	table = append(table, 0)
+	table = binary.LittleEndian.AppendUint32(table, seekableMagic)
+	_, err := w.Write(table)
+	return size, err
+}
+
+// readSeekTable reads the seek table at the end of the compressed file of size
+func readSeekTable(ctx context.Context, rrf model.RangeReaderIF, size int64) (seekTable, error) {
+	if size < skippableHeader+seekFooterSize {
+		return nil, errors.New("not a seekable zstd file")
+	}
+	footer, err := readRange(ctx, rrf, size-seekFooterSize, seekFooterSize)
+	if err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(footer[5:]) != seekableMagic {
+		return nil, errors.New("not a seekable zstd file")
+	}
+	n := int64(binary.LittleEndian.Uint32(footer))
+	entrySize := int64(seekEntrySize)
+	if footer[4]&0x80 != 0 {
+		// with checksums
+		entrySize += 4
+	}
+	tableSize := n*entrySize + seekFooterSize + skippableHeader
+	if tableSize > size {
+		return nil, errors.New("invalid seek table")
+	}
+	entries, err := readRange(ctx, rrf, size-tableSize+skippableHeader, n*entrySize)
+	if err != nil {
+		return nil, err
+	}
+	table := make(seekTable, 0, n)
+	var cOff, dOff int64
+	for i := int64(0); i < n; i++ {
+		e := entries[i*entrySize:]
+		f := frame{
+			cOff:  cOff,
+			cSize: int64(binary.LittleEndian.Uint32(e)),
+			dOff:  dOff,
+			dSize: int64(binary.LittleEndian.Uint32(e[4:])),
+		}
+		cOff += f.cSize
+		dOff += f.dSize
+		table = append(table, f)
+	}
+	if cOff > size-tableSize {
+		return nil, errors.New("invalid seek table")
+	}
+	return table, nil
+}
+
+func readRange(ctx context.Context, rrf model.RangeReaderIF, start, length int64) ([]byte, error) {
+	if length == 0 {
+		return nil, nil
+	}
+	rc, err := rrf.RangeRead(ctx, http_range.Range{Start: start, Length: length})
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	buf := make([]byte, length)
+	if _, err = io.ReadFull(rc, buf); err != nil {
+		return nil, fmt.Errorf("failed to read the seek table: %w", err)
+	}
+	return buf, nil
+}
+
+func (t seekTable) size() int64 {
+	if len(t) == 0 {
+		return 0
+	}
+	last := t[len(t)-1]
+	return last.dOff + last.dSize
+}
+
+// frames returns the frames covering the range of the original file
+func (t seekTable) frames(start, length int64) []frame {
+	end := start + length
+	i := 0
+	for i < len(t) && t[i].dOff+t[i].dSize <= start {
+		i++
+	}
+	j := i
+	for j < len(t) && t[j].dOff < end {
+		j++
+	}
+	return t[i:j]
+}
+
+// openRange returns the range of the original file, decompressing only the frames covering it
+func (t seekTable) openRange(ctx context.Context, rrf model.RangeReaderIF, httpRange http_range.Range) (io.ReadCloser, error) {
+	size := t.size()
+	start, length := httpRange.Start, httpRange.Length
+	if length < 0 || start+length > size {
+		length = size - start
+	}
+	frames := t.frames(start, length)
+	if length <= 0 || len(frames) == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	first, last := frames[0], frames[len(frames)-1]
+	rc, err := rrf.RangeRead(ctx, http_range.Range{Start: first.cOff, Length: last.cOff + last.cSize - first.cOff})
+	if err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(rc, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		_ = rc.Close()
+		return nil, err
+	}
+	closeFunc := func() error {
+		dec.Close()
+		return rc.Close()
+	}
+	if _, err = utils.CopyWithBufferN(io.Discard, dec, start-first.dOff); err != nil {
+		_ = closeFunc()
+		return nil, err
+	}
+	return utils.NewLimitReadCloser(dec, closeFunc, length), nil
+}
@@ -0,0 +1,108 @@
+package compress
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/klauspost/compress/zstd"
+)
+
+const testFrameSize = 1000
+
+func compressTest(t *testing.T, data []byte) ([]byte, seekTable) {
+	enc, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer enc.Close()
+	var buf bytes.Buffer
+	size, err := compressFrames(&buf, bytes.NewReader(data), enc, testFrameSize)
+	if err != nil || size != int64(len(data)) {
+		t.Fatalf("compressed %d bytes of %d: %v", size, len(data), err)
+	}
+	compressed := buf.Bytes()
+	table, err := readSeekTable(context.Background(), stream.GetRangeReaderFromMFile(int64(len(compressed)), bytes.NewReader(compressed)), int64(len(compressed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return compressed, table
+}
+
+func TestSeekTable(t *testing.T) {
+	data := make([]byte, 10*testFrameSize+123)
+	rand.New(rand.NewSource(1)).Read(data[:len(data)/2])
+
+	compressed, table := compressTest(t, data)
+	if len(table) != 11 || table.size() != int64(len(data)) {
+		t.Fatalf("got %d frames of %d bytes", len(table), table.size())
+	}
+	// the seekable file is a valid zstd file too
+	dec, err := zstd.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+	plain, err := io.ReadAll(dec)
+	if err != nil || !bytes.Equal(plain, data) {
+		t.Fatalf("decompressed %d bytes: %v", len(plain), err)
+	}
+
+	if _, table := compressTest(t, nil); len(table) != 0 || table.size() != 0 {
+		t.Fatalf("got %d frames of nothing", len(table))
+	}
+}
+
+func TestSeekTable_Frames(t *testing.T) {
+	_, table := compressTest(t, make([]byte, 3*testFrameSize))
+	for _, tt := range []struct {
+		start, length int64
+		want          int
+	}{
+		{0, 1, 1},
+		{0, testFrameSize, 1},
+		{testFrameSize - 1, 2, 2},
+		{testFrameSize, testFrameSize, 1},
+		{1, 3*testFrameSize - 2, 3},
+		{3 * testFrameSize, 10, 0},
+	} {
+		if frames := table.frames(tt.start, tt.length); len(frames) != tt.want {
+			t.Fatalf("range %d+%d: got %d frames, want %d", tt.start, tt.length, len(frames), tt.want)
+		}
+	}
+}
+
+func TestSeekTable_OpenRange(t *testing.T) {
+	data := make([]byte, 5*testFrameSize+10)
+	rand.New(rand.NewSource(2)).Read(data)
+	compressed, table := compressTest(t, data)
+	rrf := stream.GetRangeReaderFromMFile(int64(len(compressed)), bytes.NewReader(compressed))
+	size := int64(len(data))
+	for _, r := range []http_range.Range{
+		{Start: 0, Length: -1},
+		{Start: 10, Length: 20},
+		{Start: testFrameSize - 5, Length: 10},
+		{Start: testFrameSize / 2, Length: 3 * testFrameSize},
+		{Start: 2 * testFrameSize, Length: testFrameSize},
+		{Start: size - 15, Length: 100},
+		{Start: size, Length: 10},
+	} {
+		rc, err := table.openRange(context.Background(), rrf, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		_ = rc.Close()
+		end := size
+		if r.Length >= 0 {
+			end = min(r.Start+r.Length, size)
+		}
+		if err != nil || !bytes.Equal(got, data[r.Start:end]) {
+			t.Fatalf("range %d+%d: got %d bytes, %v", r.Start, r.Length, len(got), err)
+		}
+	}
+}
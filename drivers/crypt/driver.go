@@ -30,6 +30,11 @@ type Crypt struct {
 	model.Storage
 	Addition
 	cipher *rcCrypt.Cipher
+	// pendingCipher is the cipher of the pending key, nil if there is none
+	pendingCipher *rcCrypt.Cipher
+	// rekeying is set while a rekey task runs, the writes hold rekeyMu for reading
+	rekeyMu  sync.RWMutex
+	rekeying bool
 }
 
 const obfuscatedPrefix = "___Obfuscated___"
@@ -61,8 +66,29 @@ func (d *Crypt) Init(ctx context.Context) error {
 	d.EncryptedSuffix = utils.GetNoneEmpty(d.EncryptedSuffix, ".bin")
 	d.RemotePath = utils.FixAndCleanPath(d.RemotePath)
 
-	p, _ := strings.CutPrefix(d.Password, obfuscatedPrefix)
-	p2, _ := strings.CutPrefix(d.Salt, obfuscatedPrefix)
+	c, err := d.newCipher(d.Password, d.Salt)
+	if err != nil {
+		return fmt.Errorf("failed to create Cipher: %w", err)
+	}
+	d.cipher = c
+	d.pendingCipher = nil
+	if d.PendingPassword != "" {
+		d.pendingCipher, err = d.newCipher(d.PendingPassword, d.PendingSalt)
+		if err != nil {
+			return fmt.Errorf("failed to create the Cipher of the pending key: %w", err)
+		}
+	}
+
+	if d.VerifyMarker {
+		return d.checkMarker(ctx)
+	}
+	return nil
+}
+
+// newCipher creates a cipher of the obfuscated password and salt with the other settings of the storage
+func (d *Crypt) newCipher(password, salt string) (*rcCrypt.Cipher, error) {
+	p, _ := strings.CutPrefix(password, obfuscatedPrefix)
+	p2, _ := strings.CutPrefix(salt, obfuscatedPrefix)
 	config := configmap.Simple{
 		"password":                  p,
 		"password2":                 p2,
@@ -72,26 +98,27 @@ func (d *Crypt) Init(ctx context.Context) error {
 		"suffix":                    d.EncryptedSuffix,
 		"pass_bad_blocks":           "",
 	}
-	c, err := rcCrypt.NewCipher(config)
+	return rcCrypt.NewCipher(config)
+}
+
+func (d *Crypt) updateObfusParm(str *string) error {
+	temp, err := obfuscate(*str)
 	if err != nil {
-		return fmt.Errorf("failed to create Cipher: %w", err)
+		return err
 	}
-	d.cipher = c
-
+	*str = temp
 	return nil
 }
 
-func (d *Crypt) updateObfusParm(str *string) error {
-	temp := *str
-	if !strings.HasPrefix(temp, obfuscatedPrefix) {
-		temp, err := obscure.Obscure(temp)
-		if err != nil {
-			return err
-		}
-		temp = obfuscatedPrefix + temp
-		*str = temp
+func obfuscate(str string) (string, error) {
+	if strings.HasPrefix(str, obfuscatedPrefix) {
+		return str, nil
 	}
-	return nil
+	temp, err := obscure.Obscure(str)
+	if err != nil {
+		return "", err
+	}
+	return obfuscatedPrefix + temp, nil
 }
 
 func (d *Crypt) Drop(ctx context.Context) error {
@@ -111,7 +138,7 @@ func (d *Crypt) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([
 	for _, obj := range objs {
 		rawName := model.UnwrapObj(obj).GetName()
 		if obj.IsDir() {
-			name, err := d.cipher.DecryptDirName(rawName)
+			name, _, err := d.decryptName(rawName, true)
 			if err != nil {
 				// filter illegal files
 				continue
@@ -136,7 +163,7 @@ func (d *Crypt) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([
 			// filter illegal files
 			continue
 		}
-		name, err := d.cipher.DecryptFileName(rawName)
+		name, _, err := d.decryptName(rawName, false)
 		if err != nil {
 			// filter illegal files
 			continue
@@ -183,19 +210,24 @@ func (d *Crypt) Get(ctx context.Context, path string) (model.Obj, error) {
 	}
 
 	firstTryIsFolder, secondTry := guessPath(path)
-	remoteFullPath := stdpath.Join(d.RemotePath, d.encryptPath(path, firstTryIsFolder))
-	remoteObj, err := fs.Get(ctx, remoteFullPath, &fs.GetArgs{NoLog: true})
-	if err != nil {
-		if secondTry && errs.IsObjectNotFound(err) {
+	var remoteFullPath string
+	var remoteObj model.Obj
+	var err error
+	// the path may be encrypted with the pending key if the file is already rewritten
+	for _, c := range d.ciphers() {
+		remoteFullPath = stdpath.Join(d.RemotePath, encryptPath(c, path, firstTryIsFolder))
+		remoteObj, err = fs.Get(ctx, remoteFullPath, &fs.GetArgs{NoLog: true})
+		if err != nil && secondTry && errs.IsObjectNotFound(err) {
 			// try the opposite
-			remoteFullPath = stdpath.Join(d.RemotePath, d.encryptPath(path, !firstTryIsFolder))
+			remoteFullPath = stdpath.Join(d.RemotePath, encryptPath(c, path, !firstTryIsFolder))
 			remoteObj, err = fs.Get(ctx, remoteFullPath, &fs.GetArgs{NoLog: true})
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			return nil, err
 		}
+		if err == nil || !errs.IsObjectNotFound(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	size := remoteObj.GetSize()
@@ -205,12 +237,12 @@ func (d *Crypt) Get(ctx context.Context, path string) (model.Obj, error) {
 		if err != nil {
 			log.Warnf("DecryptedSize failed for %s ,will use original size, err:%s", path, err)
 		}
-		name, err = d.cipher.DecryptFileName(name)
+		name, _, err = d.decryptName(name, false)
 		if err != nil {
 			log.Warnf("DecryptFileName failed for %s ,will use original name, err:%s", path, err)
 		}
 	} else {
-		name, err = d.cipher.DecryptDirName(name)
+		name, _, err = d.decryptName(name, true)
 		if err != nil {
 			log.Warnf("DecryptDirName failed for %s ,will use original name, err:%s", path, err)
 		}
@@ -233,6 +265,10 @@ func (d *Crypt) Link(ctx context.Context, file model.Obj, _ model.LinkArgs) (*mo
 	if err != nil {
 		return nil, err
 	}
+	c, err := d.dataCipher(ctx, remoteStorage, remoteActualPath)
+	if err != nil {
+		return nil, err
+	}
 	remoteLink, remoteFile, err := op.Link(ctx, remoteStorage, remoteActualPath, model.LinkArgs{})
 	if err != nil {
 		return nil, err
@@ -293,7 +329,7 @@ func (d *Crypt) Link(ctx context.Context, file model.Obj, _ model.LinkArgs) (*mo
 	}
 	return &model.Link{
 		RangeReader: stream.RangeReaderFunc(func(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
-			readSeeker, err := d.cipher.DecryptDataSeek(ctx, rangeReaderFunc, httpRange.Start, httpRange.Length)
+			readSeeker, err := c.DecryptDataSeek(ctx, rangeReaderFunc, httpRange.Start, httpRange.Length)
 			if err != nil {
 				return nil, err
 			}
@@ -305,6 +341,11 @@ func (d *Crypt) Link(ctx context.Context, file model.Obj, _ model.LinkArgs) (*mo
 }
 
 func (d *Crypt) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) error {
+	done, err := d.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
 	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(parentDir.GetPath())
 	if err != nil {
 		return err
@@ -314,26 +355,50 @@ func (d *Crypt) MakeDir(ctx context.Context, parentDir model.Obj, dirName string
 }
 
 func (d *Crypt) Move(ctx context.Context, srcObj, dstDir model.Obj) error {
-	_, err := fs.Move(ctx, srcObj.GetPath(), dstDir.GetPath())
+	done, err := d.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	_, err = fs.Move(ctx, srcObj.GetPath(), dstDir.GetPath())
 	return err
 }
 
 func (d *Crypt) Rename(ctx context.Context, srcObj model.Obj, newName string) error {
+	done, err := d.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
 	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(srcObj.GetPath())
 	if err != nil {
 		return err
 	}
+	// keep the key the name is encrypted with, which is the key of the data
 	var newEncryptedName string
 	if srcObj.IsDir() {
-		newEncryptedName = d.cipher.EncryptDirName(newName)
+		_, c, err := d.decryptName(stdpath.Base(remoteActualPath), true)
+		if err != nil {
+			return err
+		}
+		newEncryptedName = c.EncryptDirName(newName)
 	} else {
-		newEncryptedName = d.cipher.EncryptFileName(newName)
+		c, err := d.dataCipher(ctx, remoteStorage, remoteActualPath)
+		if err != nil {
+			return err
+		}
+		newEncryptedName = c.EncryptFileName(newName)
 	}
 	return op.Rename(ctx, remoteStorage, remoteActualPath, newEncryptedName)
 }
 
 func (d *Crypt) Copy(ctx context.Context, srcObj, dstDir model.Obj) error {
-	_, err := fs.Copy(ctx, srcObj.GetPath(), dstDir.GetPath())
+	done, err := d.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	_, err = fs.Copy(ctx, srcObj.GetPath(), dstDir.GetPath())
 	return err
 }
 
@@ -346,6 +411,11 @@ func (d *Crypt) Remove(ctx context.Context, obj model.Obj) error {
 }
 
 func (d *Crypt) Put(ctx context.Context, dstDir model.Obj, streamer model.FileStreamer, up driver.UpdateProgress) error {
+	done, err := d.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
 	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(dstDir.GetPath())
 	if err != nil {
 		return err
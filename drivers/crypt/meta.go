@@ -18,6 +18,13 @@ type Addition struct {
 	Thumbnail bool `json:"thumbnail" required:"true" default:"false" help:"enable thumbnail which pre-generated under .thumbnails folder"`
 
 	ShowHidden bool `json:"show_hidden"  default:"true" required:"false" help:"show hidden directories and files"`
+
+	VerifyMarker bool `json:"verify_marker" default:"false" help:"store a marker file encrypted with the password under the remote path, then a wrong password or salt fails to init instead of listing nothing"`
+
+	// PendingPassword and PendingSalt are the key of an unfinished in place re-encryption,
+	// the files already rewritten under it are still readable until it is finished
+	PendingPassword string `json:"pending_password" ignore:"true"`
+	PendingSalt     string `json:"pending_salt" ignore:"true"`
 }
 
 var config = driver.Config{
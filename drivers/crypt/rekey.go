@@ -0,0 +1,420 @@
+package crypt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	stdpath "path"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/task"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/OpenListTeam/tache"
+	"github.com/pkg/errors"
+	rcCrypt "github.com/rclone/rclone/backend/crypt"
+	log "github.com/sirupsen/logrus"
+)
+
+var RekeyTaskManager *tache.Manager[*RekeyTask]
+
+// rekeyTempSuffix is appended to the file rewritten in place until the old one is removed
+const rekeyTempSuffix = ".rekey"
+
+// RekeyTask rewrites the data of a crypt storage under a new password and salt, in place
+// or into a new remote path, and then switches the storage to them. The files already
+// rewritten are skipped, so a retried or restored task goes on where it stopped.
+// The new key of an in place task is kept as the pending key of the storage until it
+// is finished, so the rewritten files stay readable and are not taken for illegal ones.
+type RekeyTask struct {
+	task.TaskExtension
+	status    string
+	StorageMp string `json:"storage_mp"`
+	// DstPath is the remote path of the rewritten data, empty to rewrite in place
+	DstPath string `json:"dst_path"`
+	// Password and Salt are obfuscated
+	Password string `json:"password"`
+	Salt     string `json:"salt"`
+}
+
+type rekeyFile struct {
+	srcDir    string
+	rawName   string
+	plainPath string
+	size      int64
+}
+
+func (t *RekeyTask) GetName() string {
+	if t.DstPath == "" {
+		return fmt.Sprintf("re-encrypt [%s] in place", t.StorageMp)
+	}
+	return fmt.Sprintf("re-encrypt [%s] to (%s)", t.StorageMp, t.DstPath)
+}
+
+func (t *RekeyTask) GetStatus() string {
+	return t.status
+}
+
+func (t *RekeyTask) Run() error {
+	if err := t.ReinitCtx(); err != nil {
+		return err
+	}
+	t.ClearEndTime()
+	t.SetStartTime(time.Now())
+	defer func() { t.SetEndTime(time.Now()) }()
+	storage, err := op.GetStorageByMountPath(t.StorageMp)
+	if err != nil {
+		return err
+	}
+	d, ok := storage.(*Crypt)
+	if !ok {
+		return errors.Errorf("[%s] is not a crypt storage", t.StorageMp)
+	}
+	newCipher, err := d.newCipher(t.Password, t.Salt)
+	if err != nil {
+		return errors.WithMessage(err, "failed to create the new cipher")
+	}
+	inPlace := t.DstPath == ""
+	dstRoot := d.RemotePath
+	if !inPlace {
+		dstRoot = utils.FixAndCleanPath(t.DstPath)
+	}
+	if err = d.checkPendingKey(inPlace, t.Password, t.Salt); err != nil {
+		return err
+	}
+	// the files written meanwhile could be missed by collect, and left under the old key or remote path
+	d.setRekeying(true)
+	defer d.setRekeying(false)
+	if inPlace && d.PendingPassword == "" {
+		d.PendingPassword, d.PendingSalt = t.Password, t.Salt
+		d.pendingCipher = newCipher
+		op.MustSaveDriverStorage(d)
+	}
+
+	t.status = "listing the files"
+	var files []rekeyFile
+	var oldDirs []string
+	if err = t.collect(d, newCipher, inPlace, d.RemotePath, "/", &files, &oldDirs); err != nil {
+		return err
+	}
+	var total, done int64
+	for _, f := range files {
+		total += f.size
+	}
+	t.SetTotalBytes(total)
+	for _, f := range files {
+		if utils.IsCanceled(t.Ctx()) {
+			return t.Ctx().Err()
+		}
+		t.status = "rewriting " + f.plainPath
+		err = t.rekeyFile(d, newCipher, dstRoot, inPlace, f, func(p float64) {
+			if total > 0 {
+				t.SetProgress((float64(done) + min(p, 100)/100*float64(f.size)) / float64(total) * 100)
+			}
+		})
+		if err != nil {
+			return errors.WithMessagef(err, "failed to rewrite %s", f.plainPath)
+		}
+		done += f.size
+	}
+	// the old dirs are emptied if their names are changed
+	for _, dir := range oldDirs {
+		objs, err := fs.List(t.Ctx(), dir, &fs.ListArgs{NoLog: true, Refresh: true})
+		if err == nil && len(objs) == 0 {
+			err = fs.Remove(t.Ctx(), dir)
+		}
+		if err != nil {
+			log.Warnf("[crypt] failed to remove the old dir %s: %v", dir, err)
+		}
+	}
+
+	t.status = "switching the storage to the new key"
+	return t.switchStorage(d, dstRoot)
+}
+
+// collect lists the files still under the old key recursively
+func (t *RekeyTask) collect(d *Crypt, newCipher *rcCrypt.Cipher, inPlace bool, srcDir, plainDir string, files *[]rekeyFile, oldDirs *[]string) error {
+	objs, err := fs.List(t.Ctx(), srcDir, &fs.ListArgs{NoLog: true, Refresh: true})
+	if err != nil {
+		return errors.WithMessagef(err, "failed to list %s", srcDir)
+	}
+	names := make(map[string]struct{}, len(objs))
+	for _, obj := range objs {
+		names[model.UnwrapObj(obj).GetName()] = struct{}{}
+	}
+	for _, obj := range objs {
+		rawName := model.UnwrapObj(obj).GetName()
+		if obj.IsDir() {
+			name, err := d.cipher.DecryptDirName(rawName)
+			if err != nil {
+				if _, err := newCipher.DecryptDirName(rawName); inPlace && err == nil {
+					// already under the new key
+					continue
+				}
+				return errors.Errorf("%s can be decrypted with neither the old nor the new key", stdpath.Join(srcDir, rawName))
+			}
+			dir := stdpath.Join(srcDir, rawName)
+			if err = t.collect(d, newCipher, inPlace, dir, stdpath.Join(plainDir, name), files, oldDirs); err != nil {
+				return err
+			}
+			if inPlace && newCipher.EncryptDirName(name) != rawName {
+				*oldDirs = append(*oldDirs, dir)
+			}
+			continue
+		}
+		if inPlace {
+			if target, ok := strings.CutSuffix(rawName, rekeyTempSuffix); ok {
+				t.finishSwap(srcDir, rawName, target, names)
+				continue
+			}
+		}
+		if rawName == markerName {
+			continue
+		}
+		name, err := d.cipher.DecryptFileName(rawName)
+		if err != nil {
+			if _, err := newCipher.DecryptFileName(rawName); inPlace && err == nil {
+				continue
+			}
+			return errors.Errorf("%s can be decrypted with neither the old nor the new key", stdpath.Join(srcDir, rawName))
+		}
+		if inPlace && d.namesAmbiguous() {
+			// the name is accepted by both keys, so the data tells if it's rewritten
+			storage, actualPath, err := op.GetStorageAndActualPath(stdpath.Join(srcDir, rawName))
+			if err != nil {
+				return err
+			}
+			rewritten, err := isRewritten(t.Ctx(), d.cipher, newCipher, storage, actualPath)
+			if err != nil {
+				return errors.WithMessage(err, stdpath.Join(srcDir, rawName))
+			}
+			if rewritten {
+				continue
+			}
+		}
+		size, err := d.cipher.DecryptedSize(obj.GetSize())
+		if err != nil {
+			return errors.WithMessagef(err, "bad size of %s", stdpath.Join(srcDir, rawName))
+		}
+		*files = append(*files, rekeyFile{
+			srcDir:    srcDir,
+			rawName:   rawName,
+			plainPath: stdpath.Join(plainDir, name),
+			size:      size,
+		})
+	}
+	return nil
+}
+
+// finishSwap renames the temp file of an interrupted task if the old file has been removed,
+// or removes the temp file which may be incomplete
+func (t *RekeyTask) finishSwap(dir, tempName, target string, names map[string]struct{}) {
+	var err error
+	if _, ok := names[target]; ok {
+		err = fs.Remove(t.Ctx(), stdpath.Join(dir, tempName))
+	} else {
+		err = fs.Rename(t.Ctx(), stdpath.Join(dir, tempName), target)
+	}
+	if err != nil {
+		log.Warnf("[crypt] failed to clean up %s: %v", stdpath.Join(dir, tempName), err)
+	}
+}
+
+func (t *RekeyTask) rekeyFile(d *Crypt, newCipher *rcCrypt.Cipher, dstRoot string, inPlace bool, f rekeyFile, up func(float64)) error {
+	ctx := t.Ctx()
+	srcStorage, srcActualPath, err := op.GetStorageAndActualPath(stdpath.Join(f.srcDir, f.rawName))
+	if err != nil {
+		return err
+	}
+	dstDir := stdpath.Join(dstRoot, newCipher.EncryptDirName(stdpath.Dir(f.plainPath)))
+	dstStorage, dstDirActualPath, err := op.GetStorageAndActualPath(dstDir)
+	if err != nil {
+		return err
+	}
+	newName := newCipher.EncryptFileName(stdpath.Base(f.plainPath))
+	// the files rewritten in place are skipped by collect already
+	sameName := inPlace && stdpath.Join(f.srcDir, f.rawName) == stdpath.Join(dstDir, newName)
+	if !inPlace {
+		// the names are the same under another key if they are not encrypted, so check the data too
+		dstPath := stdpath.Join(dstDirActualPath, newName)
+		obj, err := op.Get(ctx, dstStorage, dstPath)
+		if err == nil && obj.GetSize() == newCipher.EncryptedSize(f.size) && decryptable(ctx, newCipher, dstStorage, dstPath) {
+			return nil
+		}
+	}
+
+	rc, err := openRemote(ctx, srcStorage, srcActualPath)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	plain, err := d.cipher.DecryptData(rc)
+	if err != nil {
+		return err
+	}
+	putName := newName
+	if sameName {
+		putName += rekeyTempSuffix
+	}
+	if err = putEncrypted(ctx, newCipher, dstStorage, dstDirActualPath, putName, plain, f.size, up); err != nil {
+		return err
+	}
+	if !inPlace {
+		return nil
+	}
+	if err = op.Remove(ctx, srcStorage, srcActualPath); err != nil {
+		return err
+	}
+	if sameName {
+		return op.Rename(ctx, dstStorage, stdpath.Join(dstDirActualPath, putName), newName)
+	}
+	return nil
+}
+
+// isRewritten reports whether the file can be decrypted with the new cipher but not the old one
+func isRewritten(ctx context.Context, oldCipher, newCipher *rcCrypt.Cipher, storage driver.Driver, path string) (bool, error) {
+	if decryptable(ctx, oldCipher, storage, path) {
+		return false, nil
+	}
+	if decryptable(ctx, newCipher, storage, path) {
+		return true, nil
+	}
+	return false, errors.New("the file can be decrypted with neither the old nor the new key")
+}
+
+// decryptable tries to decrypt the first block of the file
+func decryptable(ctx context.Context, c *rcCrypt.Cipher, storage driver.Driver, path string) bool {
+	rc, err := openRemote(ctx, storage, path)
+	if err != nil {
+		return false
+	}
+	defer rc.Close()
+	plain, err := c.DecryptData(rc)
+	if err != nil {
+		return false
+	}
+	_, err = plain.Read(make([]byte, 1))
+	return err == nil || err == io.EOF
+}
+
+// switchStorage updates the storage to the new key and remote path, the new marker is written by Init
+func (t *RekeyTask) switchStorage(d *Crypt, dstRoot string) error {
+	if d.VerifyMarker {
+		err := fs.Remove(t.Ctx(), stdpath.Join(dstRoot, markerName))
+		if err != nil && !errs.IsObjectNotFound(err) {
+			return err
+		}
+	}
+	storage := *d.GetStorage()
+	var addition Addition
+	if err := json.Unmarshal([]byte(storage.Addition), &addition); err != nil {
+		return err
+	}
+	addition.Password = t.Password
+	addition.Salt = t.Salt
+	addition.PendingPassword = ""
+	addition.PendingSalt = ""
+	addition.RemotePath = dstRoot
+	data, err := json.Marshal(addition)
+	if err != nil {
+		return err
+	}
+	storage.Addition = string(data)
+	return op.UpdateStorage(context.WithoutCancel(t.Ctx()), storage)
+}
+
+// errRekeying is returned by the writes to a storage being re-encrypted
+var errRekeying = errors.New("the storage is being re-encrypted, retry when it's finished")
+
+// beginWrite holds off a rekey task until the write is done, it fails while one is running
+func (d *Crypt) beginWrite() (func(), error) {
+	d.rekeyMu.RLock()
+	if d.rekeying {
+		d.rekeyMu.RUnlock()
+		return nil, errRekeying
+	}
+	return d.rekeyMu.RUnlock, nil
+}
+
+// setRekeying blocks or allows the writes, the writes in progress are waited for before blocking
+func (d *Crypt) setRekeying(rekeying bool) {
+	d.rekeyMu.Lock()
+	d.rekeying = rekeying
+	d.rekeyMu.Unlock()
+}
+
+// checkPendingKey refuses to rekey a storage with an unfinished in place task under another key,
+// as the files already rewritten can only be read with the pending key
+func (d *Crypt) checkPendingKey(inPlace bool, password, salt string) error {
+	if d.PendingPassword == "" {
+		return nil
+	}
+	if !inPlace {
+		return errors.New("an in place re-encryption of the storage is unfinished, retry it first")
+	}
+	if !sameKey(d.PendingPassword, d.PendingSalt, password, salt) {
+		return errors.New("an in place re-encryption of the storage under another password is unfinished, retry it with the same password and salt")
+	}
+	return nil
+}
+
+func (t *RekeyTask) OnSucceeded() {
+	task.HandleHook(t, true)
+}
+
+func (t *RekeyTask) OnFailed() {
+	task.HandleHook(t, false)
+}
+
+// StartRekey queues a task rewriting the data of the storage under the new password and salt,
+// into dstPath of the remote or in place if it is empty
+func (d *Crypt) StartRekey(ctx context.Context, password, salt, dstPath string) (task.TaskExtensionInfo, error) {
+	if password == "" {
+		return nil, errors.New("password is required")
+	}
+	if dstPath != "" {
+		dstPath = utils.FixAndCleanPath(dstPath)
+		if dstPath == d.RemotePath {
+			dstPath = ""
+		} else if utils.IsSubPath(d.RemotePath, dstPath) || utils.IsSubPath(dstPath, d.RemotePath) {
+			return nil, errors.New("the new remote path can't be inside or contain the old one")
+		} else if utils.IsSubPath(d.MountPath, dstPath) {
+			return nil, errors.New("the new remote path can't be inside the storage itself")
+		}
+	}
+	if err := d.checkPendingKey(dstPath == "", password, salt); err != nil {
+		return nil, err
+	}
+	running := RekeyTaskManager.GetByCondition(func(t *RekeyTask) bool {
+		return t.StorageMp == d.MountPath && !utils.SliceContains([]tache.State{tache.StateSucceeded, tache.StateFailed, tache.StateCanceled}, t.GetState())
+	})
+	if len(running) > 0 {
+		return nil, errors.New("the storage is being re-encrypted")
+	}
+	p, err := obfuscate(password)
+	if err != nil {
+		return nil, err
+	}
+	s, err := obfuscate(salt)
+	if err != nil {
+		return nil, err
+	}
+	t := &RekeyTask{
+		StorageMp: d.MountPath,
+		DstPath:   dstPath,
+		Password:  p,
+		Salt:      s,
+	}
+	t.Creator, _ = ctx.Value(conf.UserKey).(*model.User)
+	t.ApiUrl = common.GetApiUrl(ctx)
+	RekeyTaskManager.Add(t)
+	return t, nil
+}
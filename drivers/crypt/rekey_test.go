@@ -0,0 +1,164 @@
+package crypt
+
+import (
+	"context"
+	"io"
+	stdpath "path"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/OpenListTeam/OpenList/v4/drivers/local"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+var testFiles = map[string]string{
+	"/a.txt":       "the first file",
+	"/b.txt":       "the second file",
+	"/dir/c.txt":   "a file in a dir",
+	"/dir/d/e.txt": strings.Repeat("a larger file ", 10000),
+}
+
+func initTestStorage(t *testing.T, filenameEnc string) *Crypt {
+	d, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Conf = conf.DefaultConfig(t.TempDir())
+	db.Init(d)
+	ctx := context.Background()
+	for _, s := range []model.Storage{{
+		Driver:    "Local",
+		MountPath: "/remote",
+		Addition:  `{"root_folder_path":"` + t.TempDir() + `"}`,
+	}, {
+		Driver:    "Crypt",
+		MountPath: "/enc",
+		Addition: `{"filename_encryption":"` + filenameEnc + `","directory_name_encryption":"true",` +
+			`"remote_path":"/remote","password":"old","encrypted_suffix":".bin","filename_encoding":"base64"}`,
+	}} {
+		id, err := op.CreateStorage(ctx, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = op.DeleteStorageById(ctx, id) })
+	}
+	storage, err := op.GetStorageByMountPath("/enc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := storage.(*Crypt)
+	remote, _, err := op.GetStorageAndActualPath("/remote")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range testFiles {
+		dir, name := stdpath.Split(p)
+		err = putEncrypted(ctx, c.cipher, remote, c.cipher.EncryptDirName(dir), c.cipher.EncryptFileName(name),
+			strings.NewReader(content), int64(len(content)), func(float64) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+// checkFiles reads the files through the crypt storage
+func checkFiles(t *testing.T) {
+	storage, err := op.GetStorageByMountPath("/enc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, content := range testFiles {
+		rc, err := openRemote(context.Background(), storage, p)
+		if err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+		data, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil || string(data) != content {
+			t.Fatalf("%s: got %d bytes, %v", p, len(data), err)
+		}
+	}
+}
+
+func TestRekey_Resume(t *testing.T) {
+	for _, filenameEnc := range []string{"off", "obfuscate", "standard"} {
+		t.Run(filenameEnc, func(t *testing.T) {
+			d := initTestStorage(t, filenameEnc)
+			password, _ := obfuscate("new")
+			task := &RekeyTask{StorageMp: "/enc", Password: password}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			task.SetCtx(ctx)
+			task.SetCancelFunc(cancel)
+
+			// rewrite half of the files like an interrupted task
+			newCipher, err := d.newCipher(task.Password, task.Salt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d.PendingPassword, d.pendingCipher = task.Password, newCipher
+			op.MustSaveDriverStorage(d)
+			var files []rekeyFile
+			var oldDirs []string
+			if err = task.collect(d, newCipher, true, d.RemotePath, "/", &files, &oldDirs); err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != len(testFiles) {
+				t.Fatalf("got %d files to rewrite", len(files))
+			}
+			for _, f := range files[:len(files)/2] {
+				if err = task.rekeyFile(d, newCipher, d.RemotePath, true, f, func(float64) {}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			checkFiles(t)
+
+			if err = task.Run(); err != nil {
+				t.Fatal(err)
+			}
+			storage, err := op.GetStorageByMountPath("/enc")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := storage.(*Crypt); d.PendingPassword != "" || !sameKey(d.Password, d.Salt, password, "") {
+				t.Fatal("the storage isn't switched to the new key")
+			}
+			checkFiles(t)
+		})
+	}
+}
+
+func TestRekey_RefuseWrites(t *testing.T) {
+	d := &Crypt{}
+	done, err := d.beginWrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocked := make(chan struct{})
+	go func() {
+		d.setRekeying(true)
+		close(blocked)
+	}()
+	select {
+	case <-blocked:
+		t.Fatal("expect the rekey to wait for the write in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+	done()
+	<-blocked
+	if _, err = d.beginWrite(); err != errRekeying {
+		t.Fatalf("expect the write to be refused, got %v", err)
+	}
+	d.setRekeying(false)
+	if done, err = d.beginWrite(); err != nil {
+		t.Fatal(err)
+	}
+	done()
+}
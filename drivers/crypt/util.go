@@ -1,9 +1,27 @@
 package crypt
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	stdpath "path"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	rcCrypt "github.com/rclone/rclone/backend/crypt"
+	"github.com/rclone/rclone/fs/config/obscure"
+	log "github.com/sirupsen/logrus"
 )
 
 // will give the best guessing based on the path
@@ -20,10 +38,176 @@ func guessPath(path string) (isFolder, secondTry bool) {
 	return false, true
 }
 
-func (d *Crypt) encryptPath(path string, isFolder bool) string {
+func encryptPath(c *rcCrypt.Cipher, path string, isFolder bool) string {
 	if isFolder {
-		return d.cipher.EncryptDirName(path)
+		return c.EncryptDirName(path)
 	}
 	dir, fileName := filepath.Split(path)
-	return stdpath.Join(d.cipher.EncryptDirName(dir), d.cipher.EncryptFileName(fileName))
+	return stdpath.Join(c.EncryptDirName(dir), c.EncryptFileName(fileName))
+}
+
+// ciphers returns the cipher of the storage, and the cipher of the pending key
+// while an in place re-encryption is unfinished
+func (d *Crypt) ciphers() []*rcCrypt.Cipher {
+	if d.pendingCipher == nil {
+		return []*rcCrypt.Cipher{d.cipher}
+	}
+	return []*rcCrypt.Cipher{d.cipher, d.pendingCipher}
+}
+
+// decryptName decrypts the raw name with the cipher which encrypts the result back to it,
+// or else with the first one which can, and returns the cipher used
+func (d *Crypt) decryptName(rawName string, isDir bool) (string, *rcCrypt.Cipher, error) {
+	var err error
+	var firstName string
+	var first *rcCrypt.Cipher
+	for _, c := range d.ciphers() {
+		var name, encrypted string
+		if isDir {
+			name, err = c.DecryptDirName(rawName)
+			encrypted = c.EncryptDirName(name)
+		} else {
+			name, err = c.DecryptFileName(rawName)
+			encrypted = c.EncryptFileName(name)
+		}
+		if err != nil {
+			continue
+		}
+		if encrypted == rawName {
+			return name, c, nil
+		}
+		if first == nil {
+			firstName, first = name, c
+		}
+	}
+	if first != nil {
+		return firstName, first, nil
+	}
+	return "", nil, err
+}
+
+// namesAmbiguous reports whether a name can be decrypted under any key, the names are
+// kept as they are with "off" and deobfuscated without a check with "obfuscate"
+func (d *Crypt) namesAmbiguous() bool {
+	return d.FileNameEnc == "off" || d.FileNameEnc == "obfuscate"
+}
+
+// dataCipher returns the cipher of the data of the remote file. If the name can be decrypted
+// under both keys, the data tells which key is used.
+func (d *Crypt) dataCipher(ctx context.Context, storage driver.Driver, path string) (*rcCrypt.Cipher, error) {
+	_, c, err := d.decryptName(stdpath.Base(path), false)
+	if err != nil {
+		return nil, err
+	}
+	if d.pendingCipher != nil && d.namesAmbiguous() && !decryptable(ctx, c, storage, path) {
+		for _, other := range d.ciphers() {
+			if other != c && decryptable(ctx, other, storage, path) {
+				return other, nil
+			}
+		}
+	}
+	return c, nil
+}
+
+// sameKey compares two obfuscated passwords and salts
+func sameKey(password, salt, password2, salt2 string) bool {
+	reveal := func(s string) string {
+		s, ok := strings.CutPrefix(s, obfuscatedPrefix)
+		if !ok {
+			return s
+		}
+		plain, err := obscure.Reveal(s)
+		if err != nil {
+			return obfuscatedPrefix + s
+		}
+		return plain
+	}
+	return reveal(password) == reveal(password2) && reveal(salt) == reveal(salt2)
+}
+
+// markerName is not encrypted, so it is hidden from the listing like the other illegal names
+const markerName = ".openlist_crypt_marker"
+
+var markerContent = []byte("OpenList crypt verification marker")
+
+// checkMarker decrypts the marker file under the remote path with the cipher of the storage,
+// or writes one if there is none. It is skipped if the remote path is not ready yet.
+func (d *Crypt) checkMarker(ctx context.Context) error {
+	remoteDir, err := fs.Get(ctx, d.RemotePath, &fs.GetArgs{NoLog: true})
+	if err != nil || !remoteDir.IsDir() {
+		log.Warnf("[crypt] skip checking the marker of %s: remote path %s is not ready", d.MountPath, d.RemotePath)
+		return nil
+	}
+	remoteStorage, remoteActualPath, err := op.GetStorageAndActualPath(d.RemotePath)
+	if err != nil {
+		return err
+	}
+	markerPath := stdpath.Join(remoteActualPath, markerName)
+	rc, err := openRemote(ctx, remoteStorage, markerPath)
+	if errs.IsObjectNotFound(err) {
+		return writeMarker(ctx, d.cipher, remoteStorage, remoteActualPath)
+	}
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	plain, err := d.cipher.DecryptData(rc)
+	if err == nil {
+		var data []byte
+		data, err = io.ReadAll(plain)
+		if err == nil && !bytes.Equal(data, markerContent) {
+			err = errors.New("content mismatch")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("wrong password or salt, failed to decrypt the marker file: %w", err)
+	}
+	return nil
+}
+
+func writeMarker(ctx context.Context, c *rcCrypt.Cipher, storage driver.Driver, dirPath string) error {
+	return putEncrypted(ctx, c, storage, dirPath, markerName, bytes.NewReader(markerContent), int64(len(markerContent)), nil)
+}
+
+// openRemote opens the whole remote file for reading
+func openRemote(ctx context.Context, storage driver.Driver, path string) (io.ReadCloser, error) {
+	link, obj, err := op.Link(ctx, storage, path, model.LinkArgs{})
+	if err != nil {
+		return nil, err
+	}
+	size := link.ContentLength
+	if size <= 0 {
+		size = obj.GetSize()
+	}
+	rrf, err := stream.GetRangeReaderFromLink(size, link)
+	if err != nil {
+		_ = link.Close()
+		return nil, err
+	}
+	rc, err := rrf.RangeRead(ctx, http_range.Range{Length: -1})
+	if err != nil {
+		_ = link.Close()
+		return nil, err
+	}
+	return utils.NewReadCloser(rc, func() error {
+		return errors.Join(rc.Close(), link.Close())
+	}), nil
+}
+
+// putEncrypted encrypts the plain data of size with the cipher, and uploads it as the name which is already encrypted
+func putEncrypted(ctx context.Context, c *rcCrypt.Cipher, storage driver.Driver, dirPath, name string, plain io.Reader, size int64, up driver.UpdateProgress) error {
+	wrappedIn, err := c.EncryptData(plain)
+	if err != nil {
+		return fmt.Errorf("failed to EncryptData: %w", err)
+	}
+	return op.Put(ctx, storage, dirPath, &stream.FileStream{
+		Obj: &model.Object{
+			Name:     name,
+			Size:     c.EncryptedSize(size),
+			Modified: time.Now(),
+		},
+		Reader:            wrappedIn,
+		Mimetype:          "application/octet-stream",
+		ForceStreamUpload: true,
+	}, up)
 }
@@ -42,6 +42,8 @@ type Local struct {
 	// video thumb position
 	videoThumbPos             float64
 	videoThumbPosIsPercentage bool
+
+	watcher *watcher
 }
 
 func (d *Local) Config() driver.Config {
@@ -121,10 +123,16 @@ func (d *Local) Init(ctx context.Context) error {
 		d.videoThumbPosIsPercentage = false
 		d.videoThumbPos = val
 	}
+	if d.Watch {
+		if err := d.startWatch(); err != nil {
+			return fmt.Errorf("failed to watch root folder: %w", err)
+		}
+	}
 	return nil
 }
 
 func (d *Local) Drop(ctx context.Context) error {
+	d.stopWatch()
 	return nil
 }
 
@@ -433,6 +441,11 @@ func (d *Local) Put(ctx context.Context, dstDir model.Obj, stream model.FileStre
 	return nil
 }
 
+func (d *Local) SetModTime(ctx context.Context, obj model.Obj, modified, _ time.Time) error {
+	// the creation time can't be changed on most file systems
+	return os.Chtimes(obj.GetPath(), time.Time{}, modified)
+}
+
 func (d *Local) GetDetails(ctx context.Context) (*model.StorageDetails, error) {
 	du, err := getDiskUsage(d.RootFolderPath)
 	if err != nil {
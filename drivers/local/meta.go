@@ -15,6 +15,7 @@ type Addition struct {
 	ShowHidden       bool   `json:"show_hidden" default:"true" required:"false" help:"show hidden directories and files"`
 	MkdirPerm        string `json:"mkdir_perm" default:"777"`
 	RecycleBinPath   string `json:"recycle_bin_path" default:"delete permanently" help:"path to recycle bin, delete permanently if empty or keep 'delete permanently'"`
+	Watch            bool   `json:"watch" default:"false" help:"watch the root folder for the changes by other programs, and update the search index and strm files of them at once"`
 }
 
 var config = driver.Config{
@@ -0,0 +1,225 @@
+package local
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// watchDelay is how long the changes of a dir are collected before it is listed again
+	watchDelay = time.Second
+	// watchMaxDelay bounds the delay of the dirs changed all the time
+	watchMaxDelay = 10 * watchDelay
+)
+
+// watcher lists the dirs changed by the other programs again, so the objs update hooks
+// of search and strm learn about the changes without waiting for a rescan
+type watcher struct {
+	d      *Local
+	fw     *fsnotify.Watcher
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	pending map[string]struct{}
+	// since is when the first of the pending dirs is changed
+	since time.Time
+	timer *time.Timer
+	// rescan is set when the events may be lost, then all the dirs are watched and listed again
+	rescan bool
+	// adding holds the new dirs to watch, which are walked off the event loop
+	adding    map[string]struct{}
+	addSignal chan struct{}
+}
+
+func (d *Local) startWatch() error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &watcher{
+		d:         d,
+		fw:        fw,
+		ctx:       ctx,
+		cancel:    cancel,
+		pending:   make(map[string]struct{}),
+		adding:    make(map[string]struct{}),
+		addSignal: make(chan struct{}, 1),
+	}
+	w.addRecursive(d.GetRootPath(), nil)
+	d.watcher = w
+	go w.run()
+	go w.runAdd()
+	return nil
+}
+
+func (d *Local) stopWatch() {
+	if d.watcher == nil {
+		return
+	}
+	d.watcher.cancel()
+	_ = d.watcher.fw.Close()
+	d.watcher.mu.Lock()
+	if d.watcher.timer != nil {
+		d.watcher.timer.Stop()
+	}
+	d.watcher.mu.Unlock()
+	d.watcher = nil
+}
+
+// skip reports whether the dir is not watched
+func (w *watcher) skip(path string) bool {
+	if !w.d.ShowHidden && strings.HasPrefix(filepath.Base(path), ".") && path != w.d.GetRootPath() {
+		return true
+	}
+	for _, p := range []string{w.d.ThumbCacheFolder, w.d.RecycleBinPath} {
+		if p != "" && p != "delete permanently" && filepath.Clean(p) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// addRecursive watches the dir and the dirs under it, and calls fn with each of them
+func (w *watcher) addRecursive(root string, fn func(dir string)) {
+	_ = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if w.ctx.Err() != nil {
+			return filepath.SkipAll
+		}
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if w.skip(path) {
+			return filepath.SkipDir
+		}
+		if fn != nil {
+			fn(path)
+		}
+		if err = w.fw.Add(path); err != nil {
+			// most likely the limit of inotify watches is reached
+			log.Warnf("[local] failed to watch %s: %v", path, err)
+			return filepath.SkipAll
+		}
+		return nil
+	})
+}
+
+func (w *watcher) run() {
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case err, ok := <-w.fw.Errors:
+			if !ok {
+				return
+			}
+			// the events may be lost, such as fsnotify.ErrEventOverflow
+			log.Warnf("[local] watch error of %s, rescan it: %v", w.d.MountPath, err)
+			w.mu.Lock()
+			w.rescan = true
+			w.mu.Unlock()
+			w.touch(w.d.GetRootPath())
+		case e, ok := <-w.fw.Events:
+			if !ok {
+				return
+			}
+			w.handle(e)
+		}
+	}
+}
+
+func (w *watcher) handle(e fsnotify.Event) {
+	if e.Has(fsnotify.Chmod) && !e.Has(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) {
+		return
+	}
+	if e.Has(fsnotify.Create) && !w.skip(e.Name) {
+		if info, err := os.Stat(e.Name); err == nil && info.IsDir() {
+			w.mu.Lock()
+			w.adding[e.Name] = struct{}{}
+			w.mu.Unlock()
+			select {
+			case w.addSignal <- struct{}{}:
+			default:
+			}
+		}
+	}
+	w.touch(filepath.Dir(e.Name))
+}
+
+// runAdd watches the new dirs, the files and dirs may be created in them before they are watched,
+// so they are all listed again
+func (w *watcher) runAdd() {
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-w.addSignal:
+		}
+		w.mu.Lock()
+		adding := w.adding
+		w.adding = make(map[string]struct{})
+		w.mu.Unlock()
+		for dir := range adding {
+			w.addRecursive(dir, w.touch)
+		}
+	}
+}
+
+// touch marks the dir as changed, and lists the changed dirs again when there is no change for a while,
+// or once the first of them has waited for watchMaxDelay
+func (w *watcher) touch(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending[dir] = struct{}{}
+	if w.timer == nil {
+		w.since = time.Now()
+		w.timer = time.AfterFunc(watchDelay, w.flush)
+	} else if time.Since(w.since) < watchMaxDelay {
+		w.timer.Reset(min(watchDelay, watchMaxDelay-time.Since(w.since)))
+	}
+}
+
+func (w *watcher) flush() {
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = make(map[string]struct{})
+	w.timer = nil
+	rescan := w.rescan
+	w.rescan = false
+	w.mu.Unlock()
+	root := w.d.GetRootPath()
+	if rescan {
+		w.addRecursive(root, func(dir string) {
+			pending[dir] = struct{}{}
+		})
+	}
+	for dir := range pending {
+		if w.ctx.Err() != nil {
+			return
+		}
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+			continue
+		}
+		path := "/"
+		if rel != "." {
+			path += filepath.ToSlash(rel)
+		}
+		// the objs update hooks are called by the listing, a dir removed fails to be listed,
+		// and it is gone from the listing of its parent
+		if _, err = op.List(w.ctx, w.d, path, model.ListArgs{Refresh: true}); err != nil {
+			log.Debugf("[local] failed to list %s changed: %v", path, err)
+		}
+	}
+}
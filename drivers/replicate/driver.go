@@ -0,0 +1,262 @@
+package replicate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	stdpath "path"
+	"sync"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/sign"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	log "github.com/sirupsen/logrus"
+)
+
+type Replicate struct {
+	model.Storage
+	Addition
+	replicas []*replica
+
+	reconcileMu     sync.Mutex
+	reconcileReport *ReconcileReport
+	reconcileCancel context.CancelFunc
+}
+
+func (d *Replicate) Config() driver.Config {
+	return config
+}
+
+func (d *Replicate) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *Replicate) Init(ctx context.Context) error {
+	d.replicas = nil
+	for _, path := range parseReplicas(d.Replicas) {
+		path = utils.FixAndCleanPath(path)
+		if utils.IsSubPath(d.MountPath, path) {
+			return fmt.Errorf("replica %s is inside the storage itself", path)
+		}
+		d.replicas = append(d.replicas, &replica{path: path})
+	}
+	if len(d.replicas) < 2 {
+		return errors.New("at least two replicas are required")
+	}
+	return nil
+}
+
+func (d *Replicate) Drop(ctx context.Context) error {
+	d.stopReconcile()
+	d.replicas = nil
+	return nil
+}
+
+func (d *Replicate) Get(ctx context.Context, path string) (model.Obj, error) {
+	if utils.PathEqual(path, "/") {
+		return &model.Object{
+			Name:     "Root",
+			IsFolder: true,
+			Path:     "/",
+		}, nil
+	}
+	var ret model.Obj
+	err := d.read(func(r *replica) error {
+		obj, err := fs.Get(ctx, r.join(path), &fs.GetArgs{NoLog: true})
+		if err != nil {
+			return err
+		}
+		ret = &model.Object{
+			Path:     path,
+			Name:     obj.GetName(),
+			Size:     obj.GetSize(),
+			Modified: obj.ModTime(),
+			Ctime:    obj.CreateTime(),
+			IsFolder: obj.IsDir(),
+			HashInfo: obj.GetHash(),
+		}
+		return nil
+	})
+	return ret, err
+}
+
+func (d *Replicate) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	var objs []model.Obj
+	err := d.read(func(r *replica) error {
+		tmp, err := fs.List(ctx, r.join(dir.GetPath()), &fs.ListArgs{
+			NoLog:   true,
+			Refresh: args.Refresh,
+		})
+		if err != nil {
+			return err
+		}
+		objs, err = utils.SliceConvert(tmp, func(obj model.Obj) (model.Obj, error) {
+			objRes := model.Object{
+				Name:     obj.GetName(),
+				Size:     obj.GetSize(),
+				Modified: obj.ModTime(),
+				Ctime:    obj.CreateTime(),
+				IsFolder: obj.IsDir(),
+				HashInfo: obj.GetHash(),
+			}
+			if thumb, ok := model.GetThumb(obj); ok {
+				return &model.ObjThumb{
+					Object: objRes,
+					Thumbnail: model.Thumbnail{
+						Thumbnail: thumb,
+					},
+				}, nil
+			}
+			return &objRes, nil
+		})
+		return err
+	})
+	return objs, err
+}
+
+func (d *Replicate) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	// proxy || ftp,s3
+	if common.GetApiUrl(ctx) == "" {
+		args.Redirect = false
+	}
+	var ret *model.Link
+	err := d.read(func(r *replica) error {
+		reqPath := r.join(file.GetPath())
+		link, fi, err := d.link(ctx, reqPath, args)
+		if err != nil {
+			return err
+		}
+		if link == nil {
+			ret = &model.Link{
+				URL: fmt.Sprintf("%s/p%s?sign=%s",
+					common.GetApiUrl(ctx),
+					utils.EncodePath(reqPath, true),
+					sign.Sign(reqPath)),
+			}
+			return nil
+		}
+		resultLink := *link
+		resultLink.SyncClosers = utils.NewSyncClosers(link)
+		if !args.Redirect && resultLink.ContentLength == 0 {
+			resultLink.ContentLength = fi.GetSize()
+		}
+		ret = &resultLink
+		return nil
+	})
+	return ret, err
+}
+
+func (d *Replicate) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) error {
+	return d.write(func(r *replica) error {
+		return fs.MakeDir(ctx, r.join(stdpath.Join(parentDir.GetPath(), dirName)))
+	})
+}
+
+func (d *Replicate) Move(ctx context.Context, srcObj, dstDir model.Obj) error {
+	return d.write(func(r *replica) error {
+		_, err := fs.Move(ctx, r.join(srcObj.GetPath()), r.join(dstDir.GetPath()))
+		return err
+	})
+}
+
+func (d *Replicate) Rename(ctx context.Context, srcObj model.Obj, newName string) error {
+	return d.write(func(r *replica) error {
+		return fs.Rename(ctx, r.join(srcObj.GetPath()), newName)
+	})
+}
+
+func (d *Replicate) Copy(ctx context.Context, srcObj, dstDir model.Obj) error {
+	return d.write(func(r *replica) error {
+		_, err := fs.Copy(ctx, r.join(srcObj.GetPath()), r.join(dstDir.GetPath()))
+		return err
+	})
+}
+
+func (d *Replicate) Remove(ctx context.Context, obj model.Obj) error {
+	return d.write(func(r *replica) error {
+		return fs.Remove(ctx, r.join(obj.GetPath()))
+	})
+}
+
+func (d *Replicate) Put(ctx context.Context, dstDir model.Obj, s model.FileStreamer, up driver.UpdateProgress) error {
+	if d.WriteMode == writeAsync {
+		return d.putAsync(ctx, dstDir, s, up)
+	}
+	file, err := s.CacheFullAndWriter(nil, nil)
+	if err != nil {
+		return err
+	}
+	replicas := d.healthiest()
+	count := float64(len(replicas) + 1)
+	up(100 / count)
+	for i, r := range replicas {
+		storage, reqActualPath, e := op.GetStorageAndActualPath(r.join(dstDir.GetPath()))
+		if e == nil {
+			e = op.Put(ctx, storage, reqActualPath, &stream.FileStream{
+				Obj:      s,
+				Mimetype: s.GetMimetype(),
+				Reader:   file,
+			}, nil)
+		}
+		err = errors.Join(err, e)
+		up(float64(i+2) / count * 100)
+		if _, e = file.Seek(0, io.SeekStart); e != nil {
+			return errors.Join(err, e)
+		}
+	}
+	return err
+}
+
+// putAsync uploads to the healthiest replica, then queues the copy tasks to the others
+func (d *Replicate) putAsync(ctx context.Context, dstDir model.Obj, s model.FileStreamer, up driver.UpdateProgress) error {
+	replicas := d.healthiest()
+	storage, reqActualPath, err := op.GetStorageAndActualPath(replicas[0].join(dstDir.GetPath()))
+	if err != nil {
+		return err
+	}
+	err = op.Put(ctx, storage, reqActualPath, &stream.FileStream{
+		Obj:      s,
+		Mimetype: s.GetMimetype(),
+		Reader:   s,
+	}, up)
+	if err != nil {
+		return err
+	}
+	srcPath := replicas[0].join(stdpath.Join(dstDir.GetPath(), s.GetName()))
+	for _, r := range replicas[1:] {
+		if _, e := fs.Copy(ctx, srcPath, r.join(dstDir.GetPath())); e != nil {
+			log.Warnf("[replicate] failed to queue the copy to replica %s: %v", r.path, e)
+		}
+	}
+	return nil
+}
+
+func (d *Replicate) PutURL(ctx context.Context, dstDir model.Obj, name, url string) error {
+	return d.write(func(r *replica) error {
+		return fs.PutURL(ctx, r.join(dstDir.GetPath()), name, url)
+	})
+}
+
+func (d *Replicate) Other(ctx context.Context, args model.OtherArgs) (interface{}, error) {
+	switch args.Method {
+	case "reconcile", "reconcile_remove":
+		if !isAdmin(ctx) {
+			return nil, errs.PermissionDenied
+		}
+		return nil, d.startReconcile(ctx, args.Method == "reconcile_remove")
+	case "reconcile_report":
+		return d.getReconcileReport(), nil
+	default:
+		return nil, errs.NotSupport
+	}
+}
+
+var _ driver.Driver = (*Replicate)(nil)
+var _ driver.Other = (*Replicate)(nil)
@@ -0,0 +1,29 @@
+package replicate
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+type Addition struct {
+	Replicas  string `json:"replicas" required:"true" type:"text" help:"Mount paths of the replicas, one per line, at least two. Reconciling takes the newest version of each entry, and removing the extra entries keeps the ones of the first replica"`
+	WriteMode string `json:"write_mode" type:"select" options:"sync,async" default:"sync" help:"async writes the healthiest replica only, and queues copy tasks for the others"`
+}
+
+const (
+	writeSync  = "sync"
+	writeAsync = "async"
+)
+
+var config = driver.Config{
+	Name:        "Replicate",
+	LocalSort:   true,
+	NoCache:     true,
+	DefaultRoot: "/",
+}
+
+func init() {
+	op.RegisterDriver(func() driver.Driver {
+		return &Replicate{}
+	})
+}
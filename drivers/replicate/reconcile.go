@@ -0,0 +1,198 @@
+package replicate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	stdpath "path"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReconcileReport is the result of the latest reconciling of a replicate driver
+type ReconcileReport struct {
+	Running   bool      `json:"running"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	// Queued is the count of the copy tasks queued to repair the replicas
+	Queued  int      `json:"queued"`
+	Removed int      `json:"removed"`
+	Errors  []string `json:"errors"`
+}
+
+func isAdmin(ctx context.Context) bool {
+	user, ok := ctx.Value(conf.UserKey).(*model.User)
+	return ok && user.IsAdmin()
+}
+
+// startReconcile walks all the replicas together in the background. Each entry is taken from the replica
+// holding its newest version, as the async writes go to whichever replica is the healthiest at the time,
+// and the copy tasks are queued for the replicas missing it or holding another version.
+// With removeExtra the entries missing from the first replica are removed from the others instead.
+func (d *Replicate) startReconcile(ctx context.Context, removeExtra bool) error {
+	d.reconcileMu.Lock()
+	defer d.reconcileMu.Unlock()
+	if d.reconcileReport != nil && d.reconcileReport.Running {
+		return errors.New("reconcile is running, please try later")
+	}
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	d.reconcileCancel = cancel
+	r := &ReconcileReport{Running: true, StartedAt: time.Now()}
+	d.reconcileReport = r
+	replicas := d.replicas
+	go func() {
+		defer cancel()
+		for _, rep := range replicas {
+			if err := fs.MakeDir(ctx, rep.path); err != nil {
+				d.reportError(r, fmt.Sprintf("%s: %v", rep.path, err))
+				return
+			}
+		}
+		d.reconcileDir(ctx, replicas, "/", r, removeExtra)
+		d.reconcileMu.Lock()
+		r.Running = false
+		r.EndedAt = time.Now()
+		d.reconcileMu.Unlock()
+		log.Infof("replicate reconcile of %s done, queued %d, removed %d, %d errors",
+			d.MountPath, r.Queued, r.Removed, len(r.Errors))
+	}()
+	return nil
+}
+
+func (d *Replicate) reconcileDir(ctx context.Context, replicas []*replica, path string, r *ReconcileReport, removeExtra bool) {
+	if utils.IsCanceled(ctx) {
+		return
+	}
+	// the objs of each replica by name, and all the names in the order they are found
+	listings := make([]map[string]model.Obj, len(replicas))
+	var names []string
+	seen := make(map[string]struct{})
+	for i, rep := range replicas {
+		objs, err := fs.List(ctx, rep.join(path), &fs.ListArgs{NoLog: true, Refresh: true})
+		if err != nil {
+			d.reportError(r, fmt.Sprintf("%s: %v", rep.join(path), err))
+			return
+		}
+		listings[i] = make(map[string]model.Obj, len(objs))
+		for _, obj := range objs {
+			if _, ok := seen[obj.GetName()]; !ok {
+				seen[obj.GetName()] = struct{}{}
+				names = append(names, obj.GetName())
+			}
+			listings[i][obj.GetName()] = obj
+		}
+	}
+	for _, name := range names {
+		if utils.IsCanceled(ctx) {
+			return
+		}
+		p := stdpath.Join(path, name)
+		if removeExtra && listings[0][name] == nil {
+			for i, rep := range replicas[1:] {
+				if listings[i+1][name] == nil {
+					continue
+				}
+				if err := fs.Remove(ctx, rep.join(p)); err != nil {
+					d.reportError(r, err.Error())
+					continue
+				}
+				d.reconcileMu.Lock()
+				r.Removed++
+				d.reconcileMu.Unlock()
+			}
+			continue
+		}
+		src := newest(listings, name)
+		srcObj := listings[src][name]
+		for i, rep := range replicas {
+			obj := listings[i][name]
+			if i == src || obj != nil && obj.IsDir() && srcObj.IsDir() {
+				continue
+			}
+			if obj != nil && !obj.IsDir() && !srcObj.IsDir() && sameFile(srcObj, obj) {
+				continue
+			}
+			// a file and a dir of the same name
+			if obj != nil && obj.IsDir() != srcObj.IsDir() {
+				if err := fs.Remove(ctx, rep.join(p)); err != nil {
+					d.reportError(r, err.Error())
+					continue
+				}
+			}
+			if srcObj.IsDir() {
+				// the content is reconciled by the walk below
+				if err := fs.MakeDir(ctx, rep.join(p)); err != nil {
+					d.reportError(r, err.Error())
+				}
+				continue
+			}
+			if _, err := fs.Copy(ctx, replicas[src].join(p), rep.join(path)); err != nil {
+				d.reportError(r, err.Error())
+				continue
+			}
+			d.reconcileMu.Lock()
+			r.Queued++
+			d.reconcileMu.Unlock()
+		}
+		if srcObj.IsDir() {
+			d.reconcileDir(ctx, replicas, p, r, removeExtra)
+		}
+	}
+}
+
+// newest returns the index of the listing holding the newest obj of the name, the first one wins a tie
+func newest(listings []map[string]model.Obj, name string) int {
+	ret := -1
+	for i, l := range listings {
+		obj := l[name]
+		if obj != nil && (ret < 0 || obj.ModTime().After(listings[ret][name].ModTime())) {
+			ret = i
+		}
+	}
+	return ret
+}
+
+// sameFile compares the size, and the hashes of the types both replicas provide
+func sameFile(a, b model.Obj) bool {
+	if a.GetSize() != b.GetSize() {
+		return false
+	}
+	bHash := b.GetHash()
+	for ht, h := range a.GetHash().All() {
+		if other := bHash.GetHash(ht); other != "" && h != "" && other != h {
+			return false
+		}
+	}
+	return true
+}
+
+// getReconcileReport returns a copy of the latest report, nil if there is none
+func (d *Replicate) getReconcileReport() *ReconcileReport {
+	d.reconcileMu.Lock()
+	defer d.reconcileMu.Unlock()
+	if d.reconcileReport == nil {
+		return nil
+	}
+	r := *d.reconcileReport
+	r.Errors = append([]string(nil), r.Errors...)
+	return &r
+}
+
+func (d *Replicate) stopReconcile() {
+	d.reconcileMu.Lock()
+	defer d.reconcileMu.Unlock()
+	if d.reconcileCancel != nil {
+		d.reconcileCancel()
+	}
+}
+
+func (d *Replicate) reportError(r *ReconcileReport, msg string) {
+	d.reconcileMu.Lock()
+	defer d.reconcileMu.Unlock()
+	r.Errors = append(r.Errors, msg)
+}
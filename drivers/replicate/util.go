@@ -0,0 +1,118 @@
+package replicate
+
+import (
+	"context"
+	"errors"
+	stdpath "path"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	log "github.com/sirupsen/logrus"
+)
+
+type replica struct {
+	path string
+	// failures is the count of the reads failed in a row
+	failures atomic.Int32
+}
+
+func (r *replica) join(path string) string {
+	return stdpath.Join(r.path, path)
+}
+
+func (r *replica) available() bool {
+	storage, _, err := op.GetStorageAndActualPath(r.path)
+	if err != nil {
+		return false
+	}
+	return !storage.Config().CheckStatus || storage.GetStorage().Status == op.WORK
+}
+
+func (r *replica) report(err error) {
+	if err == nil {
+		r.failures.Store(0)
+	} else if !errs.IsObjectNotFound(err) {
+		r.failures.Add(1)
+	}
+}
+
+// healthiest returns the replicas in the order to be read,
+// the available ones with the fewest failures first
+func (d *Replicate) healthiest() []*replica {
+	scores := make(map[*replica]int32, len(d.replicas))
+	for _, r := range d.replicas {
+		score := r.failures.Load()
+		if !r.available() {
+			score = 1 << 30
+		}
+		scores[r] = score
+	}
+	ret := slices.Clone(d.replicas)
+	slices.SortStableFunc(ret, func(a, b *replica) int {
+		return int(scores[a] - scores[b])
+	})
+	return ret
+}
+
+// read calls f on the replicas from the healthiest one, until one succeeds
+func (d *Replicate) read(f func(r *replica) error) error {
+	var err error
+	for _, r := range d.healthiest() {
+		e := f(r)
+		r.report(e)
+		if e == nil {
+			return nil
+		}
+		if !errs.IsObjectNotFound(e) {
+			log.Warnf("[replicate] failed to read replica %s, failing over: %v", r.path, e)
+		}
+		err = e
+	}
+	return err
+}
+
+// write applies f to all the replicas. In async mode only the failure
+// of the healthiest one is returned, the others are left to reconciling.
+func (d *Replicate) write(f func(r *replica) error) error {
+	var err error
+	for i, r := range d.healthiest() {
+		e := f(r)
+		if e == nil {
+			continue
+		}
+		if i == 0 || d.WriteMode == writeSync {
+			err = errors.Join(err, e)
+			continue
+		}
+		log.Warnf("[replicate] failed to write replica %s: %v", r.path, e)
+	}
+	return err
+}
+
+func (d *Replicate) link(ctx context.Context, reqPath string, args model.LinkArgs) (*model.Link, model.Obj, error) {
+	storage, reqActualPath, err := op.GetStorageAndActualPath(reqPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if args.Redirect && common.ShouldProxy(storage, stdpath.Base(reqPath)) {
+		obj, err := op.Get(ctx, storage, reqActualPath)
+		return nil, obj, err
+	}
+	return op.Link(ctx, storage, reqActualPath, args)
+}
+
+func parseReplicas(s string) []string {
+	var ret []string
+	for _, path := range strings.Split(s, "\n") {
+		path = strings.TrimSpace(path)
+		if path != "" {
+			ret = append(ret, path)
+		}
+	}
+	return ret
+}
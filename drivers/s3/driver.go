@@ -3,7 +3,9 @@ package s3
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	stdpath "path"
 	"strings"
@@ -16,6 +18,8 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/pkg/cron"
 	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
 	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -83,6 +87,35 @@ func (d *S3) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]mo
 	return d.listV1(dir.GetPath(), args)
 }
 
+// Get reads the times kept in the metadata, which List can't return,
+// the directories are only prefixes and are found by listing their parent
+func (d *S3) Get(ctx context.Context, path string) (model.Obj, error) {
+	path = stdpath.Join(d.GetRootPath(), path)
+	key := getKey(path, false)
+	if key == "" {
+		return nil, errs.NotSupport
+	}
+	head, err := d.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: &d.Bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		var reqErr awserr.RequestFailure
+		if errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound {
+			return nil, errs.NotSupport
+		}
+		return nil, err
+	}
+	obj := &model.Object{
+		Path:     path,
+		Name:     stdpath.Base(path),
+		Size:     aws.Int64Value(head.ContentLength),
+		Modified: aws.TimeValue(head.LastModified),
+	}
+	getTimeMetadata(head.Metadata, obj)
+	return obj, nil
+}
+
 func (d *S3) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
 	path := getKey(file.GetPath(), false)
 	fileName := stdpath.Base(path)
@@ -201,6 +234,8 @@ func (d *S3) Put(ctx context.Context, dstDir model.Obj, s model.FileStreamer, up
 	key := getKey(stdpath.Join(dstDir.GetPath(), s.GetName()), false)
 	contentType := s.GetMimetype()
 	log.Debugln("key:", key)
+	meta := make(map[string]*string)
+	setTimeMetadata(meta, s.ModTime(), s.CreateTime())
 	input := &s3manager.UploadInput{
 		Bucket: &d.Bucket,
 		Key:    &key,
@@ -209,11 +244,20 @@ func (d *S3) Put(ctx context.Context, dstDir model.Obj, s model.FileStreamer, up
 			UpdateProgress: up,
 		}),
 		ContentType: &contentType,
+		Metadata:    meta,
 	}
 	_, err := uploader.UploadWithContext(ctx, input)
 	return err
 }
 
+func (d *S3) SetModTime(ctx context.Context, obj model.Obj, modified, created time.Time) error {
+	if obj.IsDir() {
+		// the directories are only prefixes
+		return errs.NotSupport
+	}
+	return d.setTimes(ctx, obj.GetPath(), modified, created)
+}
+
 func (d *S3) GetDirectUploadTools() []string {
 	if !d.EnableDirectUpload {
 		return nil
@@ -244,3 +288,4 @@ func (d *S3) GetDirectUploadInfo(ctx context.Context, _ string, dstDir model.Obj
 }
 
 var _ driver.Driver = (*S3)(nil)
+var _ driver.SetModTime = (*S3)(nil)
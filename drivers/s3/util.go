@@ -3,11 +3,15 @@ package s3
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
@@ -276,3 +280,81 @@ func (d *S3) removeFile(src string) error {
 	_, err := d.client.DeleteObject(input)
 	return err
 }
+
+// the times are kept in the user metadata under the names used by rclone,
+// since LastModified is always the upload time. Only Get reads them back,
+// the listing doesn't return the metadata
+const (
+	metaMtime = "Mtime"
+	metaBtime = "Btime"
+)
+
+// setTimeMetadata adds the times which are not zero to the metadata
+func setTimeMetadata(meta map[string]*string, modified, created time.Time) {
+	if !modified.IsZero() {
+		// seconds with the fraction, e.g. 1700000000.123456789
+		sec := strings.TrimRight(fmt.Sprintf("%d.%09d", modified.Unix(), modified.Nanosecond()), "0")
+		meta[metaMtime] = aws.String(strings.TrimSuffix(sec, "."))
+	}
+	if !created.IsZero() {
+		meta[metaBtime] = aws.String(created.UTC().Format(time.RFC3339Nano))
+	}
+}
+
+// getTimeMetadata sets the times of obj found in the metadata, the ones missing
+// or invalid are left as they are
+func getTimeMetadata(meta map[string]*string, obj *model.Object) {
+	if v := aws.StringValue(meta[metaMtime]); v != "" {
+		sec, frac, _ := strings.Cut(v, ".")
+		s, err := strconv.ParseInt(sec, 10, 64)
+		if frac = (frac + "000000000")[:9]; err == nil {
+			if ns, err := strconv.ParseInt(frac, 10, 64); err == nil && ns >= 0 {
+				obj.Modified = time.Unix(s, ns)
+			}
+		}
+	}
+	if v := aws.StringValue(meta[metaBtime]); v != "" {
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			obj.Ctime = t
+		}
+	}
+}
+
+// maxCopySize is the largest object a single CopyObject can copy
+const maxCopySize = 5 << 30
+
+// setTimes replaces the metadata of the object by copying it onto itself,
+// the larger objects would need a multipart copy and are refused
+func (d *S3) setTimes(ctx context.Context, p string, modified, created time.Time) error {
+	key := getKey(p, false)
+	head, err := d.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: &d.Bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return err
+	}
+	if size := aws.Int64Value(head.ContentLength); size > maxCopySize {
+		return errs.NewErr(errs.NotSupport, "can't set the times of %s, it's larger than 5 GiB", p)
+	}
+	meta := head.Metadata
+	if meta == nil {
+		meta = make(map[string]*string)
+	}
+	setTimeMetadata(meta, modified, created)
+	input := &s3.CopyObjectInput{
+		Bucket:             &d.Bucket,
+		CopySource:         aws.String(url.PathEscape(d.Bucket + "/" + key)),
+		Key:                &key,
+		MetadataDirective:  aws.String(s3.MetadataDirectiveReplace),
+		Metadata:           meta,
+		ContentType:        head.ContentType,
+		ContentDisposition: head.ContentDisposition,
+		ContentEncoding:    head.ContentEncoding,
+		ContentLanguage:    head.ContentLanguage,
+		CacheControl:       head.CacheControl,
+		StorageClass:       head.StorageClass,
+	}
+	_, err = d.client.CopyObjectWithContext(ctx, input)
+	return err
+}
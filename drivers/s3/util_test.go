@@ -0,0 +1,34 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestTimeMetadata(t *testing.T) {
+	for _, modified := range []time.Time{
+		time.Unix(1700000000, 123456789),
+		time.Unix(1700000000, 120000000),
+		time.Unix(1700000000, 0),
+	} {
+		created := modified.Add(-time.Hour)
+		meta := make(map[string]*string)
+		setTimeMetadata(meta, modified, created)
+		var obj model.Object
+		getTimeMetadata(meta, &obj)
+		if !obj.Modified.Equal(modified) || !obj.Ctime.Equal(created) {
+			t.Fatalf("%s: got %s and %s", *meta[metaMtime], obj.Modified, obj.Ctime)
+		}
+	}
+
+	// the invalid values are ignored
+	last := time.Unix(1600000000, 0)
+	obj := model.Object{Modified: last}
+	getTimeMetadata(map[string]*string{metaMtime: aws.String("x.5"), metaBtime: aws.String("yesterday")}, &obj)
+	if !obj.Modified.Equal(last) || !obj.Ctime.IsZero() {
+		t.Fatalf("got %s and %s", obj.Modified, obj.Ctime)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
@@ -112,15 +113,30 @@ func (d *SFTP) Put(ctx context.Context, dstDir model.Obj, stream model.FileStrea
 	if err := d.clientReconnectOnConnectionError(); err != nil {
 		return err
 	}
-	dstFile, err := d.client.Create(path.Join(dstDir.GetPath(), stream.GetName()))
+	dstPath := path.Join(dstDir.GetPath(), stream.GetName())
+	dstFile, err := d.client.Create(dstPath)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		_ = dstFile.Close()
-	}()
 	err = utils.CopyWithCtx(ctx, dstFile, driver.NewLimitedUploadStream(ctx, stream), stream.GetSize(), up)
-	return err
+	_ = dstFile.Close()
+	if err != nil {
+		return err
+	}
+	if modTime := stream.ModTime(); !modTime.IsZero() {
+		if err := d.client.Chtimes(dstPath, modTime, modTime); err != nil {
+			log.Errorf("[sftp] failed to change time of %s: %s", dstPath, err)
+		}
+	}
+	return nil
+}
+
+func (d *SFTP) SetModTime(ctx context.Context, obj model.Obj, modified, _ time.Time) error {
+	if err := d.clientReconnectOnConnectionError(); err != nil {
+		return err
+	}
+	// sftp has no creation time, and the access time can't be left unchanged
+	return d.client.Chtimes(obj.GetPath(), modified, modified)
 }
 
 func (d *SFTP) GetDetails(ctx context.Context) (*model.StorageDetails, error) {
@@ -142,3 +158,4 @@ func (d *SFTP) GetDetails(ctx context.Context) (*model.StorageDetails, error) {
 }
 
 var _ driver.Driver = (*SFTP)(nil)
+var _ driver.SetModTime = (*SFTP)(nil)
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
@@ -12,6 +13,7 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
 
 	"github.com/cloudsoda/go-smb2"
+	log "github.com/sirupsen/logrus"
 )
 
 type SMB struct {
@@ -187,16 +189,34 @@ func (d *SMB) Put(ctx context.Context, dstDir model.Obj, stream model.FileStream
 		return err
 	}
 	d.updateLastConnTime()
-	defer func() {
-		_ = out.Close()
+	err = utils.CopyWithCtx(ctx, out, driver.NewLimitedUploadStream(ctx, stream), stream.GetSize(), up)
+	// the time is set after closing, or the server may update it on close
+	_ = out.Close()
+	if err != nil {
 		if errors.Is(err, context.Canceled) {
 			_ = d.fs.Remove(fullPath)
 		}
-	}()
-	err = utils.CopyWithCtx(ctx, out, driver.NewLimitedUploadStream(ctx, stream), stream.GetSize(), up)
+		return err
+	}
+	if modTime := stream.ModTime(); !modTime.IsZero() {
+		if err := d.fs.Chtimes(fullPath, modTime, modTime); err != nil {
+			log.Errorf("[smb] failed to change time of %s: %s", fullPath, err)
+		}
+	}
+	return nil
+}
+
+func (d *SMB) SetModTime(ctx context.Context, obj model.Obj, modified, _ time.Time) error {
+	if err := d.checkConn(ctx); err != nil {
+		return err
+	}
+	// go-smb2 can't change the creation time
+	err := d.fs.Chtimes(obj.GetPath(), modified, modified)
 	if err != nil {
+		d.cleanLastConnTime()
 		return err
 	}
+	d.updateLastConnTime()
 	return nil
 }
 
@@ -221,3 +241,4 @@ func (d *SMB) GetDetails(ctx context.Context) (*model.StorageDetails, error) {
 //}
 
 var _ driver.Driver = (*SMB)(nil)
+var _ driver.SetModTime = (*SMB)(nil)
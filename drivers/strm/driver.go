@@ -6,6 +6,7 @@ import (
 	"fmt"
 	stdpath "path"
 	"strings"
+	"sync"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
@@ -27,6 +28,10 @@ type Strm struct {
 
 	supportSuffix  map[string]struct{}
 	downloadSuffix map[string]struct{}
+
+	syncMu     sync.Mutex
+	syncReport *SyncReport
+	syncCancel context.CancelFunc
 }
 
 func (d *Strm) Config() driver.Config {
@@ -44,6 +49,9 @@ func (d *Strm) Init(ctx context.Context) error {
 	if d.SaveStrmToLocal && len(d.SaveStrmLocalPath) <= 0 {
 		return errors.New("SaveStrmLocalPath is required")
 	}
+	if d.UpdateLocalOnWrite && !d.SaveStrmToLocal {
+		return errors.New("UpdateLocalOnWrite requires SaveStrmToLocal")
+	}
 	d.pathMap = make(map[string][]string)
 	for _, path := range strings.Split(d.Paths, "\n") {
 		path = strings.TrimSpace(path)
@@ -121,6 +129,7 @@ func (d *Strm) Init(ctx context.Context) error {
 }
 
 func (d *Strm) Drop(ctx context.Context) error {
+	d.stopResync()
 	d.pathMap = nil
 	d.downloadSuffix = nil
 	d.supportSuffix = nil
@@ -6,6 +6,7 @@ import (
 	"os"
 	stdpath "path"
 	"strings"
+	"sync"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
@@ -16,36 +17,59 @@ import (
 	"github.com/tchap/go-patricia/v2/patricia"
 )
 
-var strmTrie = patricia.NewTrie()
+var (
+	strmTrie   = patricia.NewTrie()
+	strmTrieMu sync.RWMutex
+)
 
-func UpdateLocalStrm(ctx context.Context, path string, objs []model.Obj) {
-	path = utils.FixAndCleanPath(path)
-	updateLocal := func(driver *Strm, basePath string, objs []model.Obj) {
-		relParent := strings.TrimPrefix(basePath, driver.MountPath)
-		localParentPath := stdpath.Join(driver.SaveStrmLocalPath, relParent)
-		for _, obj := range objs {
-			localPath := stdpath.Join(localParentPath, obj.GetName())
-			generateStrm(ctx, driver, obj, localPath)
-		}
-		deleteExtraFiles(driver, localParentPath, objs)
-	}
+// localTarget is where a source path is mirrored by a strm driver
+type localTarget struct {
+	driver *Strm
+	path   string
+}
 
+// localTargets returns the local paths of the source path for the drivers saving strm files locally
+func localTargets(path string) []localTarget {
+	var targets []localTarget
+	strmTrieMu.RLock()
+	defer strmTrieMu.RUnlock()
 	_ = strmTrie.VisitPrefixes(patricia.Prefix(path), func(needPathPrefix patricia.Prefix, item patricia.Item) error {
-		strmDrivers := item.([]*Strm)
 		needPath := string(needPathPrefix)
 		restPath := strings.TrimPrefix(path, needPath)
 		if len(restPath) > 0 && restPath[0] != '/' {
 			return nil
 		}
-		for _, strmDriver := range strmDrivers {
-			strmObjs := strmDriver.convert2strmObjs(ctx, path, objs)
-			updateLocal(strmDriver, stdpath.Join(stdpath.Base(needPath), restPath), strmObjs)
+		for _, strmDriver := range item.([]*Strm) {
+			targets = append(targets, localTarget{
+				driver: strmDriver,
+				path:   strmDriver.localPath(needPath, restPath),
+			})
 		}
 		return nil
 	})
+	return targets
+}
+
+// localPath maps the source path, which is restPath under the configured path needPath, to the local path
+func (d *Strm) localPath(needPath, restPath string) string {
+	relPath := strings.TrimPrefix(stdpath.Join(stdpath.Base(needPath), restPath), d.MountPath)
+	return stdpath.Join(d.SaveStrmLocalPath, relPath)
+}
+
+func UpdateLocalStrm(ctx context.Context, path string, objs []model.Obj) {
+	path = utils.FixAndCleanPath(path)
+	for _, target := range localTargets(path) {
+		strmObjs := target.driver.convert2strmObjs(ctx, path, objs)
+		for _, obj := range strmObjs {
+			generateStrm(ctx, target.driver, obj, stdpath.Join(target.path, obj.GetName()))
+		}
+		deleteExtraFiles(target.driver, target.path, strmObjs)
+	}
 }
 
 func InsertStrm(dstPath string, d *Strm) error {
+	strmTrieMu.Lock()
+	defer strmTrieMu.Unlock()
 	prefix := patricia.Prefix(strings.TrimRight(dstPath, "/"))
 	existing := strmTrie.Get(prefix)
 
@@ -65,6 +89,8 @@ func InsertStrm(dstPath string, d *Strm) error {
 }
 
 func RemoveStrm(dstPath string, d *Strm) {
+	strmTrieMu.Lock()
+	defer strmTrieMu.Unlock()
 	prefix := patricia.Prefix(strings.TrimRight(dstPath, "/"))
 	existing := strmTrie.Get(prefix)
 	if existing == nil {
@@ -88,12 +114,13 @@ func RemoveStrm(dstPath string, d *Strm) {
 	}
 }
 
-func generateStrm(ctx context.Context, driver *Strm, obj model.Obj, localPath string) {
+// generateStrm writes the strm file, or downloads the file which is downloaded with strm, reports whether it's done
+func generateStrm(ctx context.Context, driver *Strm, obj model.Obj, localPath string) bool {
 	if !obj.IsDir() {
 		link, err := driver.Link(ctx, obj, model.LinkArgs{})
 		if err != nil {
 			log.Warnf("failed to generate strm of obj %s: failed to link: %v", localPath, err)
-			return
+			return false
 		}
 		defer link.Close()
 		size := link.ContentLength
@@ -103,24 +130,27 @@ func generateStrm(ctx context.Context, driver *Strm, obj model.Obj, localPath st
 		rrf, err := stream.GetRangeReaderFromLink(size, link)
 		if err != nil {
 			log.Warnf("failed to generate strm of obj %s: failed to get range reader: %v", localPath, err)
-			return
+			return false
 		}
 		rc, err := rrf.RangeRead(ctx, http_range.Range{Length: -1})
 		if err != nil {
 			log.Warnf("failed to generate strm of obj %s: failed to read range: %v", localPath, err)
-			return
+			return false
 		}
 		defer rc.Close()
 		file, err := utils.CreateNestedFile(localPath)
 		if err != nil {
 			log.Warnf("failed to generate strm of obj %s: failed to create local file: %v", localPath, err)
-			return
+			return false
 		}
 		defer file.Close()
 		if _, err := utils.CopyWithBuffer(file, rc); err != nil {
 			log.Warnf("failed to generate strm of obj %s: copy failed: %v", localPath, err)
+			return false
 		}
+		return true
 	}
+	return false
 }
 
 func deleteExtraFiles(driver *Strm, localPath string, objs []model.Obj) {
@@ -129,7 +159,18 @@ func deleteExtraFiles(driver *Strm, localPath string, objs []model.Obj) {
 		log.Errorf("Failed to read local files from %s: %v", localPath, err)
 		return
 	}
+	for _, localFile := range extraFiles(driver, localPath, localFiles, objs) {
+		err := os.Remove(localFile)
+		if err != nil {
+			log.Errorf("Failed to delete file: %s, error: %v\n", localFile, err)
+		} else {
+			log.Infof("Deleted file %s", localFile)
+		}
+	}
+}
 
+// extraFiles returns the local files of the directory which match none of the strm objects
+func extraFiles(driver *Strm, localPath string, localFiles []string, objs []model.Obj) []string {
 	objsSet := make(map[string]struct{})
 	objsBaseNameSet := make(map[string]struct{})
 	for _, obj := range objs {
@@ -143,6 +184,7 @@ func deleteExtraFiles(driver *Strm, localPath string, objs []model.Obj) {
 		objsBaseNameSet[stdpath.Join(localPath, objBaseName[:len(objBaseName)-1])] = struct{}{}
 	}
 
+	var extra []string
 	for _, localFile := range localFiles {
 		if _, exists := objsSet[localFile]; !exists {
 			ext := utils.Ext(localFile)
@@ -153,15 +195,10 @@ func deleteExtraFiles(driver *Strm, localPath string, objs []model.Obj) {
 			if driver.KeepLocalDownloadFile && nameExists && downloadFile {
 				continue
 			}
-
-			err := os.Remove(localFile)
-			if err != nil {
-				log.Errorf("Failed to delete file: %s, error: %v\n", localFile, err)
-			} else {
-				log.Infof("Deleted file %s", localFile)
-			}
+			extra = append(extra, localFile)
 		}
 	}
+	return extra
 }
 
 func getLocalFiles(localPath string) ([]string, error) {
@@ -0,0 +1,351 @@
+package strm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	stdpath "path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type writeEvent struct {
+	ctx     context.Context
+	typ     string
+	srcPath string
+	dstPath string
+}
+
+var (
+	// the events are handled one by one, a rename followed by a put of the same name must not be reordered
+	writeEvents     = make(chan writeEvent, 1024)
+	writeWorkerOnce sync.Once
+
+	// the drivers missing some events as the queue was full, they are resynced once the writes calm down
+	pendingResyncs  = make(map[*Strm]struct{})
+	pendingResyncMu sync.Mutex
+	resyncTimer     *time.Timer
+)
+
+// resyncDelay is how long the writes are waited for before resyncing the drivers missing events
+const resyncDelay = time.Minute
+
+// handleObjWrite queues the write of a source path watched by a driver updating the local strm files on write
+func handleObjWrite(ctx context.Context, typ, srcPath, dstPath string) {
+	if !watchedOnWrite(srcPath) && (dstPath == "" || !watchedOnWrite(dstPath)) {
+		return
+	}
+	writeWorkerOnce.Do(func() {
+		go func() {
+			for e := range writeEvents {
+				e.handle()
+			}
+		}()
+	})
+	// the writes must not wait for the local files, a full queue drops the event and resyncs instead
+	select {
+	case writeEvents <- writeEvent{ctx: context.WithoutCancel(ctx), typ: typ, srcPath: srcPath, dstPath: dstPath}:
+	default:
+		for _, path := range []string{srcPath, dstPath} {
+			if path == "" {
+				continue
+			}
+			for _, target := range localTargets(path) {
+				if target.driver.UpdateLocalOnWrite {
+					scheduleResync(target.driver)
+				}
+			}
+		}
+	}
+}
+
+func scheduleResync(d *Strm) {
+	pendingResyncMu.Lock()
+	defer pendingResyncMu.Unlock()
+	if _, ok := pendingResyncs[d]; !ok {
+		log.Warnf("strm write queue is full, %s will be resynced", d.MountPath)
+	}
+	pendingResyncs[d] = struct{}{}
+	if resyncTimer == nil {
+		resyncTimer = time.AfterFunc(resyncDelay, runPendingResyncs)
+	} else {
+		resyncTimer.Reset(resyncDelay)
+	}
+}
+
+func runPendingResyncs() {
+	pendingResyncMu.Lock()
+	pending := pendingResyncs
+	pendingResyncs = make(map[*Strm]struct{})
+	resyncTimer = nil
+	pendingResyncMu.Unlock()
+	for d := range pending {
+		// the orphans left by the dropped removals are only reported, nothing is deleted behind the user's back
+		if err := d.StartResync(context.Background(), false, false); err != nil && d.SaveStrmToLocal {
+			// a resync started before the events were dropped may miss them
+			scheduleResync(d)
+		}
+	}
+}
+
+func watchedOnWrite(path string) bool {
+	for _, target := range localTargets(path) {
+		if target.driver.UpdateLocalOnWrite {
+			return true
+		}
+	}
+	return false
+}
+
+func (e writeEvent) handle() {
+	switch e.typ {
+	case op.ObjWritePut:
+		syncLocal(e.ctx, e.srcPath)
+	case op.ObjWriteRemove:
+		removeLocal(e.srcPath)
+	case op.ObjWriteMove, op.ObjWriteRename:
+		removeLocal(e.srcPath)
+		syncLocal(e.ctx, e.dstPath)
+	case op.ObjWriteCopy:
+		syncLocal(e.ctx, e.dstPath)
+	}
+}
+
+// syncLocal writes the local files of the source file, or of all the files under the source directory
+func syncLocal(ctx context.Context, path string) {
+	var obj model.Obj
+	for _, target := range localTargets(path) {
+		if !target.driver.UpdateLocalOnWrite {
+			continue
+		}
+		if obj == nil {
+			var err error
+			obj, err = fs.Get(ctx, path, &fs.GetArgs{NoLog: true})
+			if err != nil {
+				log.Warnf("failed to update the local strm files of %s: %v", path, err)
+				return
+			}
+		}
+		if obj.IsDir() {
+			r := &SyncReport{}
+			target.driver.syncDir(ctx, path, target.path, r, syncWrite)
+			for _, e := range r.Errors {
+				log.Warnf("failed to update the local strm files: %s", e)
+			}
+			continue
+		}
+		for _, strmObj := range target.driver.convert2strmObjs(ctx, stdpath.Dir(path), []model.Obj{obj}) {
+			generateStrm(ctx, target.driver, strmObj, stdpath.Join(stdpath.Dir(target.path), strmObj.GetName()))
+		}
+	}
+}
+
+// removeLocal removes the local files of the removed source file or directory
+func removeLocal(path string) {
+	for _, target := range localTargets(path) {
+		d := target.driver
+		if !d.UpdateLocalOnWrite {
+			continue
+		}
+		if info, err := os.Stat(target.path); err == nil && info.IsDir() {
+			if err = os.RemoveAll(target.path); err != nil {
+				log.Errorf("Failed to delete dir: %s, error: %v", target.path, err)
+			}
+			continue
+		}
+		name := stdpath.Base(path)
+		ext := strings.ToLower(utils.Ext(name))
+		localPath := target.path
+		if _, ok := d.downloadSuffix[ext]; ok {
+			if d.KeepLocalDownloadFile {
+				continue
+			}
+		} else if _, ok := d.supportSuffix[ext]; ok {
+			localPath = strings.TrimSuffix(localPath, utils.SourceExt(name)) + "strm"
+		} else {
+			continue
+		}
+		if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+			log.Errorf("Failed to delete file: %s, error: %v", localPath, err)
+		}
+	}
+}
+
+// SyncReport is the result of the latest resync or orphan scan of a strm driver
+type SyncReport struct {
+	Running   bool      `json:"running"`
+	DryRun    bool      `json:"dry_run"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Written   int       `json:"written"`
+	Removed   int       `json:"removed"`
+	// Orphans are the local files and directories without a source, they are kept unless removing is requested
+	Orphans []string `json:"orphans"`
+	Errors  []string `json:"errors"`
+}
+
+type syncMode int
+
+const (
+	// syncWrite only writes the files
+	syncWrite syncMode = iota
+	// syncScan only reports the orphans
+	syncScan
+	// syncFull writes the files and reports the orphans
+	syncFull
+	// syncFullRemove writes the files and removes the orphans
+	syncFullRemove
+)
+
+// StartResync walks all the source paths in the background, writes the local files,
+// and reports or removes the orphans. scan only reports the orphans without writing anything,
+// though the listings not cached are still handled by UpdateLocalStrm like any other listing.
+func (d *Strm) StartResync(ctx context.Context, scan, removeOrphans bool) error {
+	if !d.SaveStrmToLocal {
+		return errors.New("the strm files are not saved locally")
+	}
+	mode := syncFull
+	if scan {
+		mode = syncScan
+	} else if removeOrphans {
+		mode = syncFullRemove
+	}
+	d.syncMu.Lock()
+	defer d.syncMu.Unlock()
+	if d.syncReport != nil && d.syncReport.Running {
+		return errors.New("resync is running, please try later")
+	}
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	d.syncCancel = cancel
+	r := &SyncReport{Running: true, DryRun: scan, StartedAt: time.Now()}
+	d.syncReport = r
+	go func() {
+		defer cancel()
+		for _, path := range strings.Split(d.Paths, "\n") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+			srcPath := utils.FixAndCleanPath(path)
+			d.syncDir(ctx, srcPath, d.localPath(srcPath, ""), r, mode)
+		}
+		d.syncMu.Lock()
+		r.Running = false
+		r.EndedAt = time.Now()
+		d.syncMu.Unlock()
+		log.Infof("strm resync of %s done, written %d, removed %d, %d orphans, %d errors",
+			d.MountPath, r.Written, r.Removed, len(r.Orphans), len(r.Errors))
+	}()
+	return nil
+}
+
+// GetSyncReport returns a copy of the latest report, nil if there is none
+func (d *Strm) GetSyncReport() *SyncReport {
+	d.syncMu.Lock()
+	defer d.syncMu.Unlock()
+	if d.syncReport == nil {
+		return nil
+	}
+	r := *d.syncReport
+	r.Orphans = append([]string(nil), r.Orphans...)
+	r.Errors = append([]string(nil), r.Errors...)
+	return &r
+}
+
+func (d *Strm) stopResync() {
+	pendingResyncMu.Lock()
+	delete(pendingResyncs, d)
+	pendingResyncMu.Unlock()
+	d.syncMu.Lock()
+	defer d.syncMu.Unlock()
+	if d.syncCancel != nil {
+		d.syncCancel()
+	}
+}
+
+// syncDir mirrors the source directory to the local directory recursively
+func (d *Strm) syncDir(ctx context.Context, srcPath, localPath string, r *SyncReport, mode syncMode) {
+	if utils.IsCanceled(ctx) {
+		return
+	}
+	objs, err := fs.List(ctx, srcPath, &fs.ListArgs{NoLog: true})
+	if err != nil {
+		d.reportError(r, fmt.Sprintf("%s: %v", srcPath, err))
+		return
+	}
+	strmObjs := d.convert2strmObjs(ctx, srcPath, objs)
+	dirs := make(map[string]struct{})
+	for _, obj := range strmObjs {
+		if utils.IsCanceled(ctx) {
+			return
+		}
+		if obj.IsDir() {
+			dirs[obj.GetName()] = struct{}{}
+			d.syncDir(ctx, stdpath.Join(srcPath, obj.GetName()), stdpath.Join(localPath, obj.GetName()), r, mode)
+			continue
+		}
+		if mode == syncScan {
+			continue
+		}
+		if generateStrm(ctx, d, obj, stdpath.Join(localPath, obj.GetName())) {
+			d.syncMu.Lock()
+			r.Written++
+			d.syncMu.Unlock()
+		} else {
+			d.reportError(r, fmt.Sprintf("%s: failed to write", stdpath.Join(localPath, obj.GetName())))
+		}
+	}
+	if mode == syncWrite {
+		return
+	}
+	entries, err := os.ReadDir(localPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			d.reportError(r, err.Error())
+		}
+		return
+	}
+	var localFiles, orphans []string
+	for _, entry := range entries {
+		p := stdpath.Join(localPath, entry.Name())
+		if !entry.IsDir() {
+			localFiles = append(localFiles, p)
+		} else if _, ok := dirs[entry.Name()]; !ok {
+			orphans = append(orphans, p+"/")
+		}
+	}
+	orphans = append(orphans, extraFiles(d, localPath, localFiles, strmObjs)...)
+	for _, orphan := range orphans {
+		if mode != syncFullRemove {
+			d.syncMu.Lock()
+			r.Orphans = append(r.Orphans, orphan)
+			d.syncMu.Unlock()
+			continue
+		}
+		if err = os.RemoveAll(orphan); err != nil {
+			d.reportError(r, err.Error())
+			continue
+		}
+		d.syncMu.Lock()
+		r.Removed++
+		d.syncMu.Unlock()
+	}
+}
+
+func (d *Strm) reportError(r *SyncReport, msg string) {
+	d.syncMu.Lock()
+	defer d.syncMu.Unlock()
+	r.Errors = append(r.Errors, msg)
+}
+
+func init() {
+	op.RegisterObjWriteHook(handleObjWrite)
+}
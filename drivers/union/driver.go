@@ -0,0 +1,300 @@
+package union
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	stdpath "path"
+	"strings"
+	"sync/atomic"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/sign"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	log "github.com/sirupsen/logrus"
+)
+
+type Union struct {
+	model.Storage
+	Addition
+	branches []string
+	next     atomic.Uint64
+}
+
+func (d *Union) Config() driver.Config {
+	return config
+}
+
+func (d *Union) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *Union) Init(ctx context.Context) error {
+	d.branches = nil
+	for _, branch := range strings.Split(d.Branches, "\n") {
+		branch = strings.TrimSpace(branch)
+		if branch == "" {
+			continue
+		}
+		branch = utils.FixAndCleanPath(branch)
+		if utils.IsSubPath(d.MountPath, branch) {
+			return fmt.Errorf("branch %s is inside the union itself", branch)
+		}
+		d.branches = append(d.branches, branch)
+	}
+	if len(d.branches) == 0 {
+		return errors.New("branches is required")
+	}
+	return nil
+}
+
+func (d *Union) Drop(ctx context.Context) error {
+	d.branches = nil
+	return nil
+}
+
+func (d *Union) Get(ctx context.Context, path string) (model.Obj, error) {
+	if utils.PathEqual(path, "/") {
+		return &model.Object{
+			Name:     "Root",
+			IsFolder: true,
+			Path:     "/",
+		}, nil
+	}
+	found, err := d.lookup(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	obj := found[0].obj
+	return &model.Object{
+		Path:     path,
+		Name:     obj.GetName(),
+		Size:     obj.GetSize(),
+		Modified: obj.ModTime(),
+		Ctime:    obj.CreateTime(),
+		IsFolder: obj.IsDir(),
+		HashInfo: obj.GetHash(),
+	}, nil
+}
+
+func (d *Union) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	var objs []model.Obj
+	index := make(map[string]int)
+	listed := false
+	var lastErr error
+	for _, branch := range d.branches {
+		tmp, err := fs.List(ctx, stdpath.Join(branch, dir.GetPath()), &fs.ListArgs{
+			NoLog:   true,
+			Refresh: args.Refresh,
+		})
+		if err != nil {
+			if !errs.IsObjectNotFound(err) {
+				log.Warnf("[union] failed to list %s from branch %s: %v", dir.GetPath(), branch, err)
+				lastErr = err
+			}
+			continue
+		}
+		listed = true
+		for _, obj := range tmp {
+			objRes := model.Object{
+				Name:     obj.GetName(),
+				Size:     obj.GetSize(),
+				Modified: obj.ModTime(),
+				Ctime:    obj.CreateTime(),
+				IsFolder: obj.IsDir(),
+				HashInfo: obj.GetHash(),
+			}
+			var res model.Obj = &objRes
+			if thumb, ok := model.GetThumb(obj); ok {
+				res = &model.ObjThumb{
+					Object: objRes,
+					Thumbnail: model.Thumbnail{
+						Thumbnail: thumb,
+					},
+				}
+			}
+			// the dirs of the same name are merged
+			i, ok := index[obj.GetName()]
+			if !ok {
+				index[obj.GetName()] = len(objs)
+				objs = append(objs, res)
+			} else if d.better(obj, objs[i]) {
+				objs[i] = res
+			}
+		}
+	}
+	if !listed {
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, errs.ObjectNotFound
+	}
+	return objs, nil
+}
+
+func (d *Union) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	found, err := d.lookup(ctx, file.GetPath())
+	if err != nil {
+		return nil, err
+	}
+	// proxy || ftp,s3
+	if common.GetApiUrl(ctx) == "" {
+		args.Redirect = false
+	}
+	// fall back to the other branches having the file if one fails
+	for _, f := range found {
+		reqPath := f.path(file.GetPath())
+		link, e := d.link(ctx, reqPath, args)
+		if e != nil {
+			log.Warnf("[union] failed to link %s from branch %s: %v", file.GetPath(), f.branch, e)
+			err = e
+			continue
+		}
+		if link == nil {
+			return &model.Link{
+				URL: fmt.Sprintf("%s/p%s?sign=%s",
+					common.GetApiUrl(ctx),
+					utils.EncodePath(reqPath, true),
+					sign.Sign(reqPath)),
+			}, nil
+		}
+		resultLink := *link
+		resultLink.SyncClosers = utils.NewSyncClosers(link)
+		if !args.Redirect && resultLink.ContentLength == 0 {
+			resultLink.ContentLength = f.obj.GetSize()
+		}
+		return &resultLink, nil
+	}
+	return nil, err
+}
+
+func (d *Union) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) error {
+	if !d.Writable {
+		return errs.PermissionDenied
+	}
+	path := stdpath.Join(parentDir.GetPath(), dirName)
+	branch, err := d.createBranch(ctx, path)
+	if err != nil {
+		return err
+	}
+	return fs.MakeDir(ctx, stdpath.Join(branch, path))
+}
+
+func (d *Union) Move(ctx context.Context, srcObj, dstDir model.Obj) error {
+	if !d.Writable {
+		return errs.PermissionDenied
+	}
+	found, err := d.lookup(ctx, srcObj.GetPath())
+	if err != nil {
+		return err
+	}
+	// every copy is moved within its branch, so no same-name file is left behind
+	for _, f := range found {
+		dstPath := f.path(dstDir.GetPath())
+		if e := fs.MakeDir(ctx, dstPath); e != nil {
+			err = errors.Join(err, e)
+			continue
+		}
+		_, e := fs.Move(ctx, f.path(srcObj.GetPath()), dstPath)
+		err = errors.Join(err, e)
+	}
+	return err
+}
+
+func (d *Union) Rename(ctx context.Context, srcObj model.Obj, newName string) error {
+	if !d.Writable {
+		return errs.PermissionDenied
+	}
+	found, err := d.lookup(ctx, srcObj.GetPath())
+	if err != nil {
+		return err
+	}
+	for _, f := range found {
+		err = errors.Join(err, fs.Rename(ctx, f.path(srcObj.GetPath()), newName))
+	}
+	return err
+}
+
+func (d *Union) Copy(ctx context.Context, srcObj, dstDir model.Obj) error {
+	if !d.Writable {
+		return errs.PermissionDenied
+	}
+	found, err := d.lookup(ctx, srcObj.GetPath())
+	if err != nil {
+		return err
+	}
+	// only the shown one is copied, within its branch
+	f := found[0]
+	dstPath := f.path(dstDir.GetPath())
+	if err = fs.MakeDir(ctx, dstPath); err != nil {
+		return err
+	}
+	_, err = fs.Copy(ctx, f.path(srcObj.GetPath()), dstPath)
+	return err
+}
+
+func (d *Union) Remove(ctx context.Context, obj model.Obj) error {
+	if !d.Writable {
+		return errs.PermissionDenied
+	}
+	found, err := d.lookup(ctx, obj.GetPath())
+	if err != nil {
+		return err
+	}
+	for _, f := range found {
+		err = errors.Join(err, fs.Remove(ctx, f.path(obj.GetPath())))
+	}
+	return err
+}
+
+func (d *Union) Put(ctx context.Context, dstDir model.Obj, s model.FileStreamer, up driver.UpdateProgress) error {
+	if !d.Writable {
+		return errs.PermissionDenied
+	}
+	branch, err := d.putBranch(ctx, stdpath.Join(dstDir.GetPath(), s.GetName()))
+	if err != nil {
+		return err
+	}
+	storage, reqActualPath, err := op.GetStorageAndActualPath(stdpath.Join(branch, dstDir.GetPath()))
+	if err != nil {
+		return err
+	}
+	return op.Put(ctx, storage, reqActualPath, &stream.FileStream{
+		Obj:      s,
+		Mimetype: s.GetMimetype(),
+		Reader:   s,
+	}, up)
+}
+
+func (d *Union) PutURL(ctx context.Context, dstDir model.Obj, name, url string) error {
+	if !d.Writable {
+		return errs.PermissionDenied
+	}
+	branch, err := d.putBranch(ctx, stdpath.Join(dstDir.GetPath(), name))
+	if err != nil {
+		return err
+	}
+	return fs.PutURL(ctx, stdpath.Join(branch, dstDir.GetPath()), name, url)
+}
+
+// putBranch overwrites the shown file of the same name if any, instead of adding another one
+func (d *Union) putBranch(ctx context.Context, path string) (string, error) {
+	found, err := d.lookup(ctx, path)
+	if err == nil && !found[0].obj.IsDir() {
+		return found[0].branch, nil
+	}
+	return d.createBranch(ctx, path)
+}
+
+func (d *Union) GetDetails(ctx context.Context) (*model.StorageDetails, error) {
+	return d.details(ctx)
+}
+
+var _ driver.Driver = (*Union)(nil)
+var _ driver.WithDetails = (*Union)(nil)
@@ -0,0 +1,41 @@
+package union
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+)
+
+type Addition struct {
+	Branches     string `json:"branches" required:"true" type:"text" help:"Mount paths of the branches, one per line, in priority order"`
+	CreatePolicy string `json:"create_policy" type:"select" options:"first_found,most_free_space,round_robin,path_preserving" default:"most_free_space" help:"How to choose the branch of new files and dirs"`
+	DedupRule    string `json:"dedup_rule" type:"select" options:"first,newest,largest" default:"first" help:"Which one to show when several branches have a file of the same name"`
+	Writable     bool   `json:"writable" type:"bool" default:"true"`
+}
+
+const (
+	policyFirstFound     = "first_found"
+	policyMostFreeSpace  = "most_free_space"
+	policyRoundRobin     = "round_robin"
+	policyPathPreserving = "path_preserving"
+
+	dedupFirst   = "first"
+	dedupNewest  = "newest"
+	dedupLargest = "largest"
+)
+
+var config = driver.Config{
+	Name:        "Union",
+	LocalSort:   true,
+	NoCache:     true,
+	DefaultRoot: "/",
+}
+
+func init() {
+	op.RegisterDriver(func() driver.Driver {
+		return &Union{
+			Addition: Addition{
+				Writable: true,
+			},
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package union
+
+import (
+	"context"
+	"errors"
+	stdpath "path"
+	"slices"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	log "github.com/sirupsen/logrus"
+)
+
+type found struct {
+	branch string
+	obj    model.Obj
+}
+
+func (f found) path(sub string) string {
+	return stdpath.Join(f.branch, sub)
+}
+
+// lookup finds the path on all the branches, the one preferred by the dedup rule first.
+// A failing branch is skipped, its error is only returned if no other branch has the path.
+func (d *Union) lookup(ctx context.Context, path string) ([]found, error) {
+	var ret []found
+	var lastErr error
+	for _, branch := range d.branches {
+		obj, err := fs.Get(ctx, stdpath.Join(branch, path), &fs.GetArgs{NoLog: true})
+		if err != nil {
+			if !errs.IsObjectNotFound(err) {
+				log.Warnf("[union] failed to get %s from branch %s: %v", path, branch, err)
+				lastErr = err
+			}
+			continue
+		}
+		ret = append(ret, found{branch: branch, obj: obj})
+	}
+	if len(ret) == 0 {
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, errs.ObjectNotFound
+	}
+	slices.SortStableFunc(ret, func(a, b found) int {
+		if d.better(a.obj, b.obj) {
+			return -1
+		}
+		if d.better(b.obj, a.obj) {
+			return 1
+		}
+		return 0
+	})
+	return ret, nil
+}
+
+// better reports whether a should be shown instead of b of the same name
+func (d *Union) better(a, b model.Obj) bool {
+	if a.IsDir() || b.IsDir() {
+		return false
+	}
+	switch d.DedupRule {
+	case dedupNewest:
+		return a.ModTime().After(b.ModTime())
+	case dedupLargest:
+		return a.GetSize() > b.GetSize()
+	default:
+		return false
+	}
+}
+
+// writableBranches returns the branches whose storage is working and accepts uploads
+func (d *Union) writableBranches() []string {
+	var ret []string
+	for _, branch := range d.branches {
+		storage, _, err := op.GetStorageAndActualPath(branch)
+		if err != nil {
+			continue
+		}
+		if storage.Config().CheckStatus && storage.GetStorage().Status != op.WORK {
+			continue
+		}
+		if storage.Config().NoUpload {
+			continue
+		}
+		ret = append(ret, branch)
+	}
+	return ret
+}
+
+// createBranch chooses the branch of a new object at path by the create policy
+func (d *Union) createBranch(ctx context.Context, path string) (string, error) {
+	candidates := d.writableBranches()
+	if len(candidates) == 0 {
+		return "", errors.New("no writable branch")
+	}
+	switch d.CreatePolicy {
+	case policyFirstFound:
+		return candidates[0], nil
+	case policyRoundRobin:
+		return candidates[(d.next.Add(1)-1)%uint64(len(candidates))], nil
+	case policyPathPreserving:
+		parent := stdpath.Dir(path)
+		candidates = slices.DeleteFunc(candidates, func(branch string) bool {
+			obj, err := fs.Get(ctx, stdpath.Join(branch, parent), &fs.GetArgs{NoLog: true})
+			return err != nil || !obj.IsDir()
+		})
+		if len(candidates) == 0 {
+			return "", errors.New("no writable branch has the parent dir")
+		}
+		return d.mostFreeSpace(ctx, candidates), nil
+	default:
+		return d.mostFreeSpace(ctx, candidates), nil
+	}
+}
+
+// mostFreeSpace returns the branch with the most free space,
+// or the first one if none of them reports its details
+func (d *Union) mostFreeSpace(ctx context.Context, branches []string) string {
+	ret := branches[0]
+	var free uint64
+	for _, branch := range branches {
+		storage, _, err := op.GetStorageAndActualPath(branch)
+		if err != nil {
+			continue
+		}
+		details, err := op.GetStorageDetails(ctx, storage)
+		if err != nil {
+			continue
+		}
+		if details.FreeSpace > free {
+			ret, free = branch, details.FreeSpace
+		}
+	}
+	return ret
+}
+
+func (d *Union) link(ctx context.Context, reqPath string, args model.LinkArgs) (*model.Link, error) {
+	storage, reqActualPath, err := op.GetStorageAndActualPath(reqPath)
+	if err != nil {
+		return nil, err
+	}
+	if args.Redirect && common.ShouldProxy(storage, stdpath.Base(reqPath)) {
+		return nil, nil
+	}
+	link, _, err := op.Link(ctx, storage, reqActualPath, args)
+	return link, err
+}
+
+func (d *Union) details(ctx context.Context) (*model.StorageDetails, error) {
+	ret := &model.StorageDetails{}
+	seen := make(map[driver.Driver]struct{})
+	ok := false
+	for _, branch := range d.branches {
+		storage, _, err := op.GetStorageAndActualPath(branch)
+		if err != nil {
+			continue
+		}
+		// several branches may be on the same storage
+		if _, exist := seen[storage]; exist {
+			continue
+		}
+		seen[storage] = struct{}{}
+		details, err := op.GetStorageDetails(ctx, storage)
+		if err != nil {
+			continue
+		}
+		ret.TotalSpace += details.TotalSpace
+		ret.FreeSpace += details.FreeSpace
+		ok = true
+	}
+	if !ok {
+		return nil, errs.NotImplement
+	}
+	return ret, nil
+}
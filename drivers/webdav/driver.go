@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"strconv"
 	"time"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
@@ -97,6 +98,13 @@ func (d *WebDav) Put(ctx context.Context, dstDir model.Obj, s model.FileStreamer
 	callback := func(r *http.Request) {
 		r.Header.Set("Content-Type", s.GetMimetype())
 		r.ContentLength = s.GetSize()
+		// ownCloud and Nextcloud keep the times of the headers, the other servers ignore them
+		if modTime := s.ModTime(); !modTime.IsZero() {
+			r.Header.Set("X-OC-Mtime", strconv.FormatInt(modTime.Unix(), 10))
+		}
+		if createTime := s.CreateTime(); !createTime.IsZero() {
+			r.Header.Set("X-OC-Ctime", strconv.FormatInt(createTime.Unix(), 10))
+		}
 	}
 	reader := driver.NewLimitedUploadStream(ctx, &driver.ReaderUpdatingProgress{
 		Reader:         s,
@@ -106,4 +114,10 @@ func (d *WebDav) Put(ctx context.Context, dstDir model.Obj, s model.FileStreamer
 	return err
 }
 
+func (d *WebDav) SetModTime(ctx context.Context, obj model.Obj, modified, _ time.Time) error {
+	// the creation date is protected
+	return d.client.SetModTime(getPath(obj), modified)
+}
+
 var _ driver.Driver = (*WebDav)(nil)
+var _ driver.SetModTime = (*WebDav)(nil)
@@ -1,9 +1,12 @@
 package archive
 
 import (
+	_ "github.com/OpenListTeam/OpenList/v4/internal/archive/ar"
 	_ "github.com/OpenListTeam/OpenList/v4/internal/archive/archives"
+	_ "github.com/OpenListTeam/OpenList/v4/internal/archive/cpio"
 	_ "github.com/OpenListTeam/OpenList/v4/internal/archive/iso9660"
 	_ "github.com/OpenListTeam/OpenList/v4/internal/archive/rardecode"
 	_ "github.com/OpenListTeam/OpenList/v4/internal/archive/sevenzip"
+	_ "github.com/OpenListTeam/OpenList/v4/internal/archive/udf"
 	_ "github.com/OpenListTeam/OpenList/v4/internal/archive/zip"
 )
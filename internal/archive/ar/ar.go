@@ -0,0 +1,61 @@
+package ar
+
+import (
+	"io"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/archive/tool"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+)
+
+// Ar reads unix ar archives, including debian packages whose members are
+// debian-binary, control.tar.* and data.tar.*
+type Ar struct{}
+
+func (Ar) AcceptedExtensions() []string {
+	return []string{".ar", ".a", ".deb", ".udeb"}
+}
+
+func (Ar) AcceptedMultipartExtensions() map[string]tool.MultipartExtension {
+	return map[string]tool.MultipartExtension{}
+}
+
+func (Ar) GetMeta(ss []*stream.SeekableStream, args model.ArchiveArgs) (model.ArchiveMeta, error) {
+	reader, err := getReader(ss)
+	if err != nil {
+		return nil, err
+	}
+	_, tree := tool.GenerateMetaTreeFromFolderTraversal(reader)
+	return &model.ArchiveMetaInfo{
+		Comment:   "",
+		Encrypted: false,
+		Tree:      tree,
+	}, nil
+}
+
+func (Ar) List(ss []*stream.SeekableStream, args model.ArchiveInnerArgs) ([]model.Obj, error) {
+	return nil, errs.NotSupport
+}
+
+func (Ar) Extract(ss []*stream.SeekableStream, args model.ArchiveInnerArgs) (io.ReadCloser, int64, error) {
+	reader, err := getReader(ss)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tool.ExtractFromFolderTraversal(reader, args)
+}
+
+func (Ar) Decompress(ss []*stream.SeekableStream, outputPath string, args model.ArchiveInnerArgs, up model.UpdateProgress) error {
+	reader, err := getReader(ss)
+	if err != nil {
+		return err
+	}
+	return tool.DecompressFromFolderTraversal(reader, outputPath, args, up)
+}
+
+var _ tool.Tool = (*Ar)(nil)
+
+func init() {
+	tool.RegisterTool(Ar{})
+}
@@ -0,0 +1,113 @@
+package ar
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/archive/tool"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+)
+
+const (
+	globalHeader = "!<arch>\n"
+	headerSize   = 60
+)
+
+type Reader struct {
+	files []tool.SubFile
+}
+
+func (r *Reader) Files() []tool.SubFile {
+	return r.files
+}
+
+func getReader(ss []*stream.SeekableStream) (*Reader, error) {
+	readerAt, err := stream.NewMultiReaderAt(ss)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(readerAt, readerAt.Size())
+}
+
+// NewReader walks the member headers of an ar archive, both the GNU and the BSD long name formats are supported
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	magic := make([]byte, len(globalHeader))
+	if _, err := r.ReadAt(magic, 0); err != nil || string(magic) != globalHeader {
+		return nil, errs.UnknownArchiveFormat
+	}
+	ret := &Reader{}
+	var longNames []byte
+	hdr := make([]byte, headerSize)
+	for off := int64(len(globalHeader)); off+headerSize <= size; {
+		if _, err := r.ReadAt(hdr, off); err != nil {
+			return nil, err
+		}
+		if string(hdr[58:60]) != "`\n" {
+			return nil, fmt.Errorf("invalid ar header at %d", off)
+		}
+		name := strings.TrimRight(string(hdr[0:16]), " ")
+		mtime, _ := strconv.ParseInt(strings.TrimSpace(string(hdr[16:28])), 10, 64)
+		dataSize, err := strconv.ParseInt(strings.TrimSpace(string(hdr[48:58])), 10, 64)
+		if err != nil || dataSize < 0 {
+			return nil, fmt.Errorf("invalid ar member size at %d", off)
+		}
+		dataOff := off + headerSize
+		if dataOff+dataSize > size {
+			return nil, fmt.Errorf("ar member at %d exceeds the archive", off)
+		}
+		// members are aligned to 2 bytes
+		off = dataOff + dataSize + dataSize%2
+		switch {
+		case name == "//":
+			// GNU long names table
+			longNames = make([]byte, dataSize)
+			if _, err = r.ReadAt(longNames, dataOff); err != nil {
+				return nil, err
+			}
+			continue
+		case strings.HasPrefix(name, "#1/"):
+			// BSD long name, stored right after the header
+			n, err := strconv.ParseInt(name[3:], 10, 64)
+			if err != nil || n < 0 || n > dataSize {
+				return nil, fmt.Errorf("invalid ar member name: %s", name)
+			}
+			buf := make([]byte, n)
+			if _, err = r.ReadAt(buf, dataOff); err != nil {
+				return nil, err
+			}
+			name = string(bytes.TrimRight(buf, "\x00"))
+			dataOff += n
+			dataSize -= n
+		case len(name) > 1 && name[0] == '/' && name[1] >= '0' && name[1] <= '9':
+			// GNU long name, the offset in the long names table
+			idx, err := strconv.Atoi(name[1:])
+			if err != nil || idx >= len(longNames) {
+				return nil, fmt.Errorf("invalid ar member name: %s", name)
+			}
+			name, _, _ = strings.Cut(string(longNames[idx:]), "\n")
+			name = strings.TrimSuffix(name, "/")
+		default:
+			name = strings.TrimSuffix(name, "/")
+		}
+		// skip the symbol tables
+		if name == "" || name == "/SYM64" || strings.HasPrefix(name, "__.SYMDEF") {
+			continue
+		}
+		ret.files = append(ret.files, &tool.SectionFile{
+			Path: name,
+			Obj: &model.Object{
+				Name:     name,
+				Size:     dataSize,
+				Modified: time.Unix(mtime, 0),
+			},
+			Data: io.NewSectionReader(r, dataOff, dataSize),
+		})
+	}
+	return ret, nil
+}
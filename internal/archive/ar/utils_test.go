@@ -0,0 +1,56 @@
+package ar
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func member(name string, size int, data string) string {
+	return fmt.Sprintf("%-16s%-12d%-6d%-6d%-8s%-10d`\n", name, 0, 0, 0, "644", size) + data
+}
+
+func TestNewReader(t *testing.T) {
+	archive := globalHeader +
+		member("//", 13, "long_name.o/\n") + "\n" +
+		member("/0", 3, "abc") + "\n" +
+		member("#1/6", 8, "bsd.o\x00xy") +
+		member("short.o/", 2, "hi")
+	r, err := NewReader(strings.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"long_name.o": "abc", "bsd.o": "xy", "short.o": "hi"}
+	if len(r.Files()) != len(want) {
+		t.Fatalf("got %d files", len(r.Files()))
+	}
+	for _, f := range r.Files() {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil || string(data) != want[f.Name()] {
+			t.Fatalf("%s: got %q, %v", f.Name(), data, err)
+		}
+	}
+}
+
+func TestNewReader_Malformed(t *testing.T) {
+	for name, archive := range map[string]string{
+		"huge member":         globalHeader + member("a", 9999999999, ""),
+		"huge long names":     globalHeader + member("//", 9999999999, ""),
+		"truncated member":    globalHeader + member("a", 10, "abc"),
+		"negative size":       globalHeader + member("a", -1, ""),
+		"bad header":          globalHeader + strings.Repeat("x", headerSize),
+		"bsd name over data":  globalHeader + member("#1/9", 2, "ab"),
+		"long name out range": globalHeader + member("/5", 0, ""),
+	} {
+		if _, err := NewReader(bytes.NewReader([]byte(archive)), int64(len(archive))); err == nil {
+			t.Fatalf("%s: no error", name)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/archive/tool"
@@ -24,12 +25,20 @@ func (Archives) AcceptedExtensions() []string {
 	}
 }
 
-func (Archives) AcceptedMultipartExtensions() map[string]tool.MultipartExtension {
-	return map[string]tool.MultipartExtension{}
+func (a Archives) AcceptedMultipartExtensions() map[string]tool.MultipartExtension {
+	// split volumes, e.g. .tar.gz.001, .tar.gz.002 ...
+	ret := map[string]tool.MultipartExtension{}
+	for _, ext := range a.AcceptedExtensions() {
+		ret[ext+".001"] = tool.MultipartExtension{
+			PartFileFormat:  regexp.MustCompile(`^.*` + regexp.QuoteMeta(ext) + `\.(\d+)$`),
+			SecondPartIndex: 2,
+		}
+	}
+	return ret
 }
 
 func (Archives) GetMeta(ss []*stream.SeekableStream, args model.ArchiveArgs) (model.ArchiveMeta, error) {
-	fsys, err := getFs(ss[0], args)
+	fsys, err := getFs(ss, args)
 	if err != nil {
 		return nil, err
 	}
@@ -54,7 +63,7 @@ func (Archives) GetMeta(ss []*stream.SeekableStream, args model.ArchiveArgs) (mo
 }
 
 func (Archives) List(ss []*stream.SeekableStream, args model.ArchiveInnerArgs) ([]model.Obj, error) {
-	fsys, err := getFs(ss[0], args.ArchiveArgs)
+	fsys, err := getFs(ss, args.ArchiveArgs)
 	if err != nil {
 		return nil, err
 	}
@@ -76,7 +85,7 @@ func (Archives) List(ss []*stream.SeekableStream, args model.ArchiveInnerArgs) (
 }
 
 func (Archives) Extract(ss []*stream.SeekableStream, args model.ArchiveInnerArgs) (io.ReadCloser, int64, error) {
-	fsys, err := getFs(ss[0], args.ArchiveArgs)
+	fsys, err := getFs(ss, args.ArchiveArgs)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -92,7 +101,7 @@ func (Archives) Extract(ss []*stream.SeekableStream, args model.ArchiveInnerArgs
 }
 
 func (Archives) Decompress(ss []*stream.SeekableStream, outputPath string, args model.ArchiveInnerArgs, up model.UpdateProgress) error {
-	fsys, err := getFs(ss[0], args.ArchiveArgs)
+	fsys, err := getFs(ss, args.ArchiveArgs)
 	if err != nil {
 		return err
 	}
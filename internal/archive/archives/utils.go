@@ -6,6 +6,7 @@ import (
 	fs2 "io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
@@ -15,15 +16,30 @@ import (
 	"github.com/mholt/archives"
 )
 
-func getFs(ss *stream.SeekableStream, args model.ArchiveArgs) (*archives.ArchiveFS, error) {
-	reader, err := stream.NewReadAtSeeker(ss, 0)
-	if err != nil {
-		return nil, err
-	}
-	if r, ok := reader.(*stream.RangeReadReadAtSeeker); ok {
-		r.InitHeadCache()
+var volumeSuffix = regexp.MustCompile(`\.\d+$`)
+
+func getFs(ss []*stream.SeekableStream, args model.ArchiveArgs) (*archives.ArchiveFS, error) {
+	var reader io.ReaderAt
+	var size int64
+	if len(ss) > 1 {
+		multiReader, err := stream.NewMultiReaderAt(ss)
+		if err != nil {
+			return nil, err
+		}
+		reader, size = multiReader, multiReader.Size()
+	} else {
+		r, err := stream.NewReadAtSeeker(ss[0], 0)
+		if err != nil {
+			return nil, err
+		}
+		if rr, ok := r.(*stream.RangeReadReadAtSeeker); ok {
+			rr.InitHeadCache()
+		}
+		reader, size = r, ss[0].GetSize()
 	}
-	format, _, err := archives.Identify(ss.Ctx, ss.GetName(), reader)
+	// identify split volumes by the name of the whole archive, e.g. a.tar.gz.001 -> a.tar.gz
+	name := volumeSuffix.ReplaceAllString(ss[0].GetName(), "")
+	format, _, err := archives.Identify(ss[0].Ctx, name, io.NewSectionReader(reader, 0, size))
 	if err != nil {
 		return nil, errs.UnknownArchiveFormat
 	}
@@ -38,9 +54,9 @@ func getFs(ss *stream.SeekableStream, args model.ArchiveArgs) (*archives.Archive
 		f.Password = args.Password
 	}
 	return &archives.ArchiveFS{
-		Stream:  io.NewSectionReader(reader, 0, ss.GetSize()),
+		Stream:  io.NewSectionReader(reader, 0, size),
 		Format:  extractor,
-		Context: ss.Ctx,
+		Context: ss[0].Ctx,
 	}, nil
 }
 
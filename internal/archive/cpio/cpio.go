@@ -0,0 +1,60 @@
+package cpio
+
+import (
+	"io"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/archive/tool"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+)
+
+// Cpio reads cpio archives in the new ascii (newc, crc) and the portable ascii (odc) formats
+type Cpio struct{}
+
+func (Cpio) AcceptedExtensions() []string {
+	return []string{".cpio"}
+}
+
+func (Cpio) AcceptedMultipartExtensions() map[string]tool.MultipartExtension {
+	return map[string]tool.MultipartExtension{}
+}
+
+func (Cpio) GetMeta(ss []*stream.SeekableStream, args model.ArchiveArgs) (model.ArchiveMeta, error) {
+	reader, err := getReader(ss)
+	if err != nil {
+		return nil, err
+	}
+	_, tree := tool.GenerateMetaTreeFromFolderTraversal(reader)
+	return &model.ArchiveMetaInfo{
+		Comment:   "",
+		Encrypted: false,
+		Tree:      tree,
+	}, nil
+}
+
+func (Cpio) List(ss []*stream.SeekableStream, args model.ArchiveInnerArgs) ([]model.Obj, error) {
+	return nil, errs.NotSupport
+}
+
+func (Cpio) Extract(ss []*stream.SeekableStream, args model.ArchiveInnerArgs) (io.ReadCloser, int64, error) {
+	reader, err := getReader(ss)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tool.ExtractFromFolderTraversal(reader, args)
+}
+
+func (Cpio) Decompress(ss []*stream.SeekableStream, outputPath string, args model.ArchiveInnerArgs, up model.UpdateProgress) error {
+	reader, err := getReader(ss)
+	if err != nil {
+		return err
+	}
+	return tool.DecompressFromFolderTraversal(reader, outputPath, args, up)
+}
+
+var _ tool.Tool = (*Cpio)(nil)
+
+func init() {
+	tool.RegisterTool(Cpio{})
+}
@@ -0,0 +1,158 @@
+package cpio
+
+import (
+	"fmt"
+	"io"
+	stdpath "path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/archive/tool"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+)
+
+const (
+	magicNewc = "070701"
+	magicCrc  = "070702"
+	magicOdc  = "070707"
+	trailer   = "TRAILER!!!"
+
+	newcHeaderSize = 110
+	odcHeaderSize  = 76
+
+	modeType    = 0170000
+	modeDir     = 0040000
+	modeRegular = 0100000
+)
+
+type Reader struct {
+	files []tool.SubFile
+}
+
+func (r *Reader) Files() []tool.SubFile {
+	return r.files
+}
+
+func getReader(ss []*stream.SeekableStream) (*Reader, error) {
+	readerAt, err := stream.NewMultiReaderAt(ss)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(readerAt, readerAt.Size())
+}
+
+type header struct {
+	mode     int64
+	mtime    int64
+	nameSize int64
+	fileSize int64
+}
+
+func parseField(b []byte, base int) (int64, error) {
+	return strconv.ParseInt(strings.TrimSpace(string(b)), base, 64)
+}
+
+func parseHeader(hdr []byte, newc bool) (h header, err error) {
+	type field struct {
+		dst        *int64
+		start, end int
+	}
+	var fields []field
+	base := 8
+	if newc {
+		base = 16
+		fields = []field{{&h.mode, 14, 22}, {&h.mtime, 46, 54}, {&h.fileSize, 54, 62}, {&h.nameSize, 94, 102}}
+	} else {
+		fields = []field{{&h.mode, 18, 24}, {&h.mtime, 48, 59}, {&h.nameSize, 59, 65}, {&h.fileSize, 65, 76}}
+	}
+	for _, f := range fields {
+		if *f.dst, err = parseField(hdr[f.start:f.end], base); err != nil {
+			return h, err
+		}
+	}
+	if h.nameSize <= 0 || h.fileSize < 0 {
+		return h, fmt.Errorf("invalid cpio header")
+	}
+	return h, nil
+}
+
+func align4(n int64) int64 {
+	return (n + 3) &^ 3
+}
+
+// NewReader walks the entries of a cpio archive, only the directories and the regular files are kept
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	ret := &Reader{}
+	hdr := make([]byte, newcHeaderSize)
+	for off := int64(0); off+odcHeaderSize <= size; {
+		if _, err := r.ReadAt(hdr[:6], off); err != nil {
+			return nil, err
+		}
+		var newc bool
+		switch string(hdr[:6]) {
+		case magicNewc, magicCrc:
+			newc = true
+		case magicOdc:
+		default:
+			if off == 0 {
+				return nil, errs.UnknownArchiveFormat
+			}
+			return nil, fmt.Errorf("invalid cpio header at %d", off)
+		}
+		hdrSize := int64(odcHeaderSize)
+		if newc {
+			hdrSize = newcHeaderSize
+		}
+		if _, err := r.ReadAt(hdr[:hdrSize], off); err != nil {
+			return nil, err
+		}
+		h, err := parseHeader(hdr[:hdrSize], newc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cpio header at %d: %w", off, err)
+		}
+		if off+hdrSize+h.nameSize+h.fileSize > size {
+			return nil, fmt.Errorf("cpio entry at %d exceeds the archive", off)
+		}
+		nameBuf := make([]byte, h.nameSize)
+		if _, err = r.ReadAt(nameBuf, off+hdrSize); err != nil {
+			return nil, err
+		}
+		name := strings.TrimRight(string(nameBuf), "\x00")
+		if name == trailer {
+			break
+		}
+		dataOff := off + hdrSize + h.nameSize
+		off = dataOff + h.fileSize
+		if newc {
+			dataOff = align4(dataOff)
+			off = align4(dataOff + h.fileSize)
+		}
+		name = strings.TrimPrefix(stdpath.Clean("/"+name), "/")
+		if name == "" {
+			continue
+		}
+		obj := &model.Object{
+			Name:     stdpath.Base(name),
+			Modified: time.Unix(h.mtime, 0),
+		}
+		switch h.mode & modeType {
+		case modeDir:
+			obj.IsFolder = true
+			name += "/"
+		case modeRegular:
+			obj.Size = h.fileSize
+		default:
+			// links and devices
+			continue
+		}
+		ret.files = append(ret.files, &tool.SectionFile{
+			Path: name,
+			Obj:  obj,
+			Data: io.NewSectionReader(r, dataOff, obj.Size),
+		})
+	}
+	return ret, nil
+}
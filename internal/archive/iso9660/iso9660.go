@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/archive/tool"
@@ -22,18 +23,29 @@ func (ISO9660) AcceptedExtensions() []string {
 }
 
 func (ISO9660) AcceptedMultipartExtensions() map[string]tool.MultipartExtension {
-	return map[string]tool.MultipartExtension{}
+	return map[string]tool.MultipartExtension{
+		".iso.001": {PartFileFormat: regexp.MustCompile(`^.*\.iso\.(\d+)$`), SecondPartIndex: 2},
+	}
 }
 
 func (ISO9660) GetMeta(ss []*stream.SeekableStream, args model.ArchiveArgs) (model.ArchiveMeta, error) {
+	var tree []model.ObjTree
+	if u := getUDF(ss); u != nil {
+		_, tree = tool.GenerateMetaTreeFromFolderTraversal(u)
+	}
 	return &model.ArchiveMetaInfo{
 		Comment:   "",
 		Encrypted: false,
+		Tree:      tree,
 	}, nil
 }
 
 func (ISO9660) List(ss []*stream.SeekableStream, args model.ArchiveInnerArgs) ([]model.Obj, error) {
-	img, err := getImage(ss[0])
+	if getUDF(ss) != nil {
+		// listed from the tree of the meta
+		return nil, errs.NotSupport
+	}
+	img, err := getImage(ss)
 	if err != nil {
 		return nil, err
 	}
@@ -56,7 +68,10 @@ func (ISO9660) List(ss []*stream.SeekableStream, args model.ArchiveInnerArgs) ([
 }
 
 func (ISO9660) Extract(ss []*stream.SeekableStream, args model.ArchiveInnerArgs) (io.ReadCloser, int64, error) {
-	img, err := getImage(ss[0])
+	if u := getUDF(ss); u != nil {
+		return tool.ExtractFromFolderTraversal(u, args)
+	}
+	img, err := getImage(ss)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -71,7 +86,10 @@ func (ISO9660) Extract(ss []*stream.SeekableStream, args model.ArchiveInnerArgs)
 }
 
 func (ISO9660) Decompress(ss []*stream.SeekableStream, outputPath string, args model.ArchiveInnerArgs, up model.UpdateProgress) error {
-	img, err := getImage(ss[0])
+	if u := getUDF(ss); u != nil {
+		return tool.DecompressFromFolderTraversal(u, outputPath, args, up)
+	}
+	img, err := getImage(ss)
 	if err != nil {
 		return err
 	}
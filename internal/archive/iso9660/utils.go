@@ -6,21 +6,38 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/OpenListTeam/OpenList/v4/internal/archive/udf"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/stream"
 	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
 	"github.com/kdomanski/iso9660"
+	log "github.com/sirupsen/logrus"
 )
 
-func getImage(ss *stream.SeekableStream) (*iso9660.Image, error) {
-	reader, err := stream.NewReadAtSeeker(ss, 0)
+func getImage(ss []*stream.SeekableStream) (*iso9660.Image, error) {
+	reader, err := stream.NewMultiReaderAt(ss)
 	if err != nil {
 		return nil, err
 	}
 	return iso9660.OpenImage(reader)
 }
 
+// getUDF returns the UDF file tree if the image is recorded in UDF as well, e.g. DVD and installer images
+// whose ISO 9660 file system may only contain a placeholder, nil means reading the ISO 9660 file system
+func getUDF(ss []*stream.SeekableStream) *udf.Reader {
+	reader, err := stream.NewMultiReaderAt(ss)
+	if err != nil || !udf.IsUDF(reader) {
+		return nil
+	}
+	u, err := udf.NewReader(reader, reader.Size())
+	if err != nil {
+		log.Warnf("failed read udf of %s, fallback to iso9660: %v", ss[0].GetName(), err)
+		return nil
+	}
+	return u
+}
+
 func getObj(img *iso9660.Image, path string) (*iso9660.File, error) {
 	obj, err := img.RootDir()
 	if err != nil {
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/stream"
 )
@@ -114,6 +115,62 @@ type WrapFileInfo struct {
 	model.Obj
 }
 
+func (f *WrapFileInfo) Name() string {
+	return f.GetName()
+}
+
+func (f *WrapFileInfo) Size() int64 {
+	return f.GetSize()
+}
+
+func (f *WrapFileInfo) Mode() fs.FileMode {
+	if f.IsDir() {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (f *WrapFileInfo) Sys() any {
+	return nil
+}
+
+// SectionFile is a SubFile stored as is in a section of the archive
+type SectionFile struct {
+	Path string
+	Obj  model.Obj
+	Data *io.SectionReader
+}
+
+func (f *SectionFile) Name() string {
+	return f.Path
+}
+
+func (f *SectionFile) FileInfo() fs.FileInfo {
+	return &WrapFileInfo{Obj: f.Obj}
+}
+
+func (f *SectionFile) Open() (io.ReadCloser, error) {
+	return io.NopCloser(io.NewSectionReader(f.Data, 0, f.Data.Size())), nil
+}
+
+func ExtractFromFolderTraversal(r ArchiveReader, args model.ArchiveInnerArgs) (io.ReadCloser, int64, error) {
+	innerPath := strings.TrimPrefix(args.InnerPath, "/")
+	for _, file := range r.Files() {
+		if strings.TrimPrefix(file.Name(), "/") != innerPath || file.FileInfo().IsDir() {
+			continue
+		}
+		if encrypt, ok := file.(CanEncryptSubFile); ok && encrypt.IsEncrypted() {
+			encrypt.SetPassword(args.Password)
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, 0, err
+		}
+		return rc, file.FileInfo().Size(), nil
+	}
+	return nil, 0, errs.ObjectNotFound
+}
+
 func DecompressFromFolderTraversal(r ArchiveReader, outputPath string, args model.ArchiveInnerArgs, up model.UpdateProgress) error {
 	var err error
 	files := r.Files()
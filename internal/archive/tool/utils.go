@@ -1,9 +1,15 @@
 package tool
 
 import (
+	"strings"
+
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
 )
 
+// NestedArchiveSuffix marks an archive inside an archive in an inner path, the path after it is
+// the inner path of the nested archive, e.g. /boot/disk.iso!/etc means /etc inside /boot/disk.iso
+const NestedArchiveSuffix = "!"
+
 var (
 	Tools               = make(map[string]Tool)
 	MultipartExtensions = make(map[string]MultipartExtension)
@@ -30,3 +36,44 @@ func GetArchiveTool(ext string) (*MultipartExtension, Tool, error) {
 	}
 	return &partExt, t, nil
 }
+
+// GetArchiveToolByName finds the tool by the longest known extension of the file name,
+// the matched extension is returned along with the tool
+func GetArchiveToolByName(name string) (string, *MultipartExtension, Tool, error) {
+	ext := name
+	for {
+		var found bool
+		_, ext, found = strings.Cut(ext, ".")
+		if !found {
+			return "", nil, nil, errs.UnknownArchiveFormat
+		}
+		partExt, t, err := GetArchiveTool("." + ext)
+		if err == nil {
+			return "." + ext, partExt, t, nil
+		}
+	}
+}
+
+// SplitNestedPath splits the inner path at the first nested archive, the segment of a nested archive
+// ends with NestedArchiveSuffix and has an extension known by a tool
+func SplitNestedPath(innerPath string) (archivePath, rest string, ok bool) {
+	segs := strings.Split(innerPath, "/")
+	for i, seg := range segs {
+		name, found := strings.CutSuffix(seg, NestedArchiveSuffix)
+		if !found || name == "" {
+			continue
+		}
+		if _, _, _, err := GetArchiveToolByName(name); err != nil {
+			continue
+		}
+		archivePath = strings.Join(append(segs[:i:i], name), "/")
+		rest = "/" + strings.Join(segs[i+1:], "/")
+		return archivePath, rest, true
+	}
+	return innerPath, "", false
+}
+
+func IsNestedPath(innerPath string) bool {
+	_, _, ok := SplitNestedPath(innerPath)
+	return ok
+}
@@ -0,0 +1,577 @@
+package udf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	stdpath "path"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/archive/tool"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"go4.org/readerutil"
+)
+
+// descriptor tag identifiers, ECMA-167 3/7.2.1 and 4/7.2.1
+const (
+	tagAnchorVolumeDescriptorPointer = 2
+	tagPartitionDescriptor           = 5
+	tagLogicalVolumeDescriptor       = 6
+	tagTerminatingDescriptor         = 8
+	tagFileSetDescriptor             = 256
+	tagFileIdentifierDescriptor      = 257
+	tagAllocationExtentDescriptor    = 258
+	tagFileEntry                     = 261
+	tagExtendedFileEntry             = 266
+)
+
+const (
+	fileTypeDirectory = 4
+
+	fileCharDirectory = 0x02
+	fileCharDeleted   = 0x04
+	fileCharParent    = 0x08
+
+	adShort    = 0
+	adLong     = 1
+	adEmbedded = 3
+
+	extentRecorded     = 0
+	extentContinuation = 3
+
+	anchorSector = 256
+	// directories deeper than this are ignored, protects from loops in broken images
+	maxDepth = 64
+)
+
+type extent struct {
+	// byte offset in the image, -1 means the extent is not recorded and reads as zeros
+	offset int64
+	length int64
+}
+
+type partition interface {
+	// offset returns the byte offset in the image of the logical block
+	offset(lbn uint32) (int64, error)
+}
+
+type physicalPartition struct {
+	start     int64
+	blockSize int64
+}
+
+func (p *physicalPartition) offset(lbn uint32) (int64, error) {
+	return (p.start + int64(lbn)) * p.blockSize, nil
+}
+
+// metadataPartition is introduced by UDF 2.50, its blocks are stored in the metadata file
+type metadataPartition struct {
+	extents   []extent
+	blockSize int64
+}
+
+func (p *metadataPartition) offset(lbn uint32) (int64, error) {
+	pos := int64(lbn) * p.blockSize
+	for _, e := range p.extents {
+		if pos < e.length {
+			if e.offset < 0 {
+				break
+			}
+			return e.offset + pos, nil
+		}
+		pos -= e.length
+	}
+	return 0, fmt.Errorf("block %d out of the metadata partition", lbn)
+}
+
+type Reader struct {
+	r          io.ReaderAt
+	size       int64
+	blockSize  int64
+	partitions []partition
+	files      []tool.SubFile
+	// the offsets of the directory entries walked, a directory linked again is a loop
+	visited map[int64]struct{}
+}
+
+func (r *Reader) Files() []tool.SubFile {
+	return r.files
+}
+
+// IsUDF checks the volume recognition sequence for the NSR descriptor, ECMA-167 2/8.3
+func IsUDF(r io.ReaderAt) bool {
+	buf := make([]byte, 6)
+	for sector := int64(16); sector < 64; sector++ {
+		if _, err := r.ReadAt(buf, sector*2048); err != nil {
+			return false
+		}
+		switch string(buf[1:6]) {
+		case "NSR02", "NSR03":
+			return true
+		case "BEA01", "TEA01", "CD001", "CDW02", "BOOT2":
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// NewReader reads the file tree of a UDF image with physical, sparable or metadata partitions
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	ret := &Reader{r: r, size: size, visited: make(map[int64]struct{})}
+	avdp, err := ret.findAnchor()
+	if err != nil {
+		return nil, err
+	}
+	fsd, err := ret.readVolumeDescriptors(avdp)
+	if err != nil {
+		return nil, err
+	}
+	// File Set Descriptor, ECMA-167 4/14.1
+	off, err := ret.longAdOffset(fsd)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := ret.readDescriptor(off, tagFileSetDescriptor)
+	if err != nil {
+		return nil, err
+	}
+	if err = ret.walk(buf[400:416], "", 0); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (r *Reader) readAt(off, length int64) ([]byte, error) {
+	if off < 0 || length < 0 || off+length > r.size {
+		return nil, fmt.Errorf("udf: read out of range at %d", off)
+	}
+	buf := make([]byte, length)
+	if _, err := r.r.ReadAt(buf, off); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readDescriptor reads a block and checks the descriptor tag, ECMA-167 3/7.2
+func (r *Reader) readDescriptor(off int64, ids ...uint16) ([]byte, error) {
+	buf, err := r.readAt(off, r.blockSize)
+	if err != nil {
+		return nil, err
+	}
+	var sum byte
+	for i := 0; i < 16; i++ {
+		if i != 4 {
+			sum += buf[i]
+		}
+	}
+	if sum != buf[4] {
+		return nil, fmt.Errorf("udf: bad descriptor tag checksum at %d", off)
+	}
+	id := binary.LittleEndian.Uint16(buf)
+	for _, want := range ids {
+		if id == want {
+			return buf, nil
+		}
+	}
+	return nil, fmt.Errorf("udf: unexpected descriptor %d at %d", id, off)
+}
+
+func (r *Reader) findAnchor() ([]byte, error) {
+	for _, bs := range []int64{2048, 512, 4096, 1024} {
+		r.blockSize = bs
+		buf, err := r.readDescriptor(anchorSector*bs, tagAnchorVolumeDescriptorPointer)
+		if err == nil && binary.LittleEndian.Uint32(buf[12:]) == anchorSector {
+			return buf, nil
+		}
+	}
+	return nil, errs.UnknownArchiveFormat
+}
+
+// readVolumeDescriptors reads the partitions from the main volume descriptor sequence,
+// returns the long_ad of the File Set Descriptor
+func (r *Reader) readVolumeDescriptors(avdp []byte) ([]byte, error) {
+	length := int64(binary.LittleEndian.Uint32(avdp[16:]))
+	location := int64(binary.LittleEndian.Uint32(avdp[20:]))
+	partStarts := make(map[uint16]int64)
+	var lvd []byte
+	for i := int64(0); i < length/r.blockSize; i++ {
+		buf, err := r.readAt((location+i)*r.blockSize, r.blockSize)
+		if err != nil {
+			return nil, err
+		}
+		id := binary.LittleEndian.Uint16(buf)
+		if id == tagTerminatingDescriptor || id == 0 {
+			break
+		}
+		switch id {
+		case tagPartitionDescriptor:
+			partStarts[binary.LittleEndian.Uint16(buf[22:])] = int64(binary.LittleEndian.Uint32(buf[188:]))
+		case tagLogicalVolumeDescriptor:
+			lvd = buf
+		}
+	}
+	if lvd == nil || len(partStarts) == 0 {
+		return nil, fmt.Errorf("udf: logical volume or partition descriptor not found")
+	}
+	if bs := int64(binary.LittleEndian.Uint32(lvd[212:])); bs != r.blockSize {
+		return nil, fmt.Errorf("udf: unsupported logical block size %d", bs)
+	}
+	// partition maps, ECMA-167 3/10.7
+	mapTableLength := int(binary.LittleEndian.Uint32(lvd[264:]))
+	maps := lvd[440:]
+	if mapTableLength > len(maps) {
+		return nil, fmt.Errorf("udf: bad partition map table")
+	}
+	maps = maps[:mapTableLength]
+	var metadataMaps []int
+	for len(maps) >= 2 {
+		typ, l := maps[0], int(maps[1])
+		if l < 6 || l > len(maps) {
+			return nil, fmt.Errorf("udf: bad partition map")
+		}
+		m := maps[:l]
+		maps = maps[l:]
+		var number uint16
+		switch {
+		case typ == 1:
+			number = binary.LittleEndian.Uint16(m[4:])
+		case typ == 2 && l >= 64:
+			ident := string(m[5:28])
+			switch {
+			case strings.HasPrefix(ident, "*UDF Metadata Partition"):
+				metadataMaps = append(metadataMaps, len(r.partitions))
+			case strings.HasPrefix(ident, "*UDF Sparable Partition"):
+				// spared blocks are not remapped, good enough for the images on healthy media
+			default:
+				return nil, fmt.Errorf("udf: unsupported partition type %q", strings.TrimRight(ident, "\x00"))
+			}
+			number = binary.LittleEndian.Uint16(m[38:])
+		default:
+			return nil, fmt.Errorf("udf: unsupported partition map type %d", typ)
+		}
+		start, ok := partStarts[number]
+		if !ok {
+			return nil, fmt.Errorf("udf: partition %d not found", number)
+		}
+		r.partitions = append(r.partitions, &physicalPartition{start: start, blockSize: r.blockSize})
+	}
+	// the metadata file is in the physical partition with the same partition number
+	for _, i := range metadataMaps {
+		physical := r.partitions[i].(*physicalPartition)
+		m := lvd[440:]
+		for j := 0; j < i; j++ {
+			m = m[m[1]:]
+		}
+		off, err := physical.offset(binary.LittleEndian.Uint32(m[40:]))
+		if err != nil {
+			return nil, err
+		}
+		entry, err := r.readDescriptor(off, tagFileEntry, tagExtendedFileEntry)
+		if err != nil {
+			return nil, fmt.Errorf("udf: failed read metadata file: %w", err)
+		}
+		_, extents, _, err := r.fileExtents(entry, physical)
+		if err != nil {
+			return nil, fmt.Errorf("udf: failed read metadata file: %w", err)
+		}
+		r.partitions[i] = &metadataPartition{extents: extents, blockSize: r.blockSize}
+	}
+	return lvd[248:264], nil
+}
+
+// longAdOffset resolves a long_ad, ECMA-167 4/14.14.2
+func (r *Reader) longAdOffset(ad []byte) (int64, error) {
+	p, err := r.partition(binary.LittleEndian.Uint16(ad[8:]))
+	if err != nil {
+		return 0, err
+	}
+	return p.offset(binary.LittleEndian.Uint32(ad[4:]))
+}
+
+func (r *Reader) partition(ref uint16) (partition, error) {
+	if int(ref) >= len(r.partitions) {
+		return nil, fmt.Errorf("udf: partition reference %d out of range", ref)
+	}
+	return r.partitions[ref], nil
+}
+
+type entryInfo struct {
+	fileType byte
+	size     int64
+	modified time.Time
+}
+
+// fileExtents parses a (extended) file entry, ECMA-167 4/14.9 and 4/14.17,
+// returns the extents of the content, or the embedded content
+func (r *Reader) fileExtents(entry []byte, p partition) (entryInfo, []extent, []byte, error) {
+	info := entryInfo{fileType: entry[27]}
+	var eaLenOff, mtimeOff int
+	if binary.LittleEndian.Uint16(entry) == tagExtendedFileEntry {
+		eaLenOff, mtimeOff = 208, 92
+	} else {
+		eaLenOff, mtimeOff = 168, 84
+	}
+	info.size = int64(binary.LittleEndian.Uint64(entry[56:]))
+	if info.size < 0 {
+		return info, nil, nil, fmt.Errorf("udf: bad file size")
+	}
+	info.modified = parseTimestamp(entry[mtimeOff : mtimeOff+12])
+	eaLen := int(binary.LittleEndian.Uint32(entry[eaLenOff:]))
+	adLen := int(binary.LittleEndian.Uint32(entry[eaLenOff+4:]))
+	start := eaLenOff + 8 + eaLen
+	if start+adLen > len(entry) || eaLen < 0 || adLen < 0 {
+		return info, nil, nil, fmt.Errorf("udf: bad file entry")
+	}
+	ads := entry[start : start+adLen]
+	adType := binary.LittleEndian.Uint16(entry[34:]) & 0x7
+	if adType == adEmbedded {
+		if info.size > int64(len(ads)) {
+			return info, nil, nil, fmt.Errorf("udf: bad embedded file entry")
+		}
+		return info, nil, ads[:info.size], nil
+	}
+	var extents []extent
+	for depth := 0; len(ads) > 0 && depth < maxDepth; depth++ {
+		var next []byte
+		for len(ads) > 0 {
+			var adSize int
+			var length uint32
+			var lbn uint32
+			ep := p
+			switch adType {
+			case adShort:
+				adSize = 8
+				if len(ads) < adSize {
+					return info, nil, nil, fmt.Errorf("udf: bad short_ad")
+				}
+				length, lbn = binary.LittleEndian.Uint32(ads), binary.LittleEndian.Uint32(ads[4:])
+			case adLong:
+				adSize = 16
+				if len(ads) < adSize {
+					return info, nil, nil, fmt.Errorf("udf: bad long_ad")
+				}
+				length, lbn = binary.LittleEndian.Uint32(ads), binary.LittleEndian.Uint32(ads[4:])
+				var err error
+				if ep, err = r.partition(binary.LittleEndian.Uint16(ads[8:])); err != nil {
+					return info, nil, nil, err
+				}
+			default:
+				return info, nil, nil, fmt.Errorf("udf: unsupported allocation descriptor type %d", adType)
+			}
+			ads = ads[adSize:]
+			extLen := int64(length & 0x3fffffff)
+			if extLen == 0 {
+				break
+			}
+			off, err := ep.offset(lbn)
+			if err != nil {
+				return info, nil, nil, err
+			}
+			switch length >> 30 {
+			case extentContinuation:
+				// Allocation Extent Descriptor, ECMA-167 4/14.5
+				aed, err := r.readDescriptor(off, tagAllocationExtentDescriptor)
+				if err != nil {
+					return info, nil, nil, err
+				}
+				l := int(binary.LittleEndian.Uint32(aed[20:]))
+				if 24+l > len(aed) {
+					return info, nil, nil, fmt.Errorf("udf: bad allocation extent descriptor")
+				}
+				next = aed[24 : 24+l]
+				ads = nil
+			case extentRecorded:
+				if off < 0 || off+extLen > r.size {
+					return info, nil, nil, fmt.Errorf("udf: extent out of the image")
+				}
+				extents = append(extents, extent{offset: off, length: extLen})
+			default:
+				extents = append(extents, extent{offset: -1, length: extLen})
+			}
+		}
+		ads = next
+	}
+	return info, extents, nil, nil
+}
+
+func (r *Reader) content(info entryInfo, extents []extent, embedded []byte) *io.SectionReader {
+	if embedded != nil {
+		return io.NewSectionReader(bytes.NewReader(embedded), 0, int64(len(embedded)))
+	}
+	readers := make([]readerutil.SizeReaderAt, 0, len(extents))
+	for _, e := range extents {
+		if e.offset < 0 {
+			readers = append(readers, zeroReaderAt(e.length))
+		} else {
+			readers = append(readers, io.NewSectionReader(r.r, e.offset, e.length))
+		}
+	}
+	return io.NewSectionReader(readerutil.NewMultiReaderAt(readers...), 0, info.size)
+}
+
+// walk reads the directory of the ICB recursively, ECMA-167 4/14.4
+func (r *Reader) walk(icb []byte, dir string, depth int) error {
+	if depth > maxDepth {
+		return nil
+	}
+	p, err := r.partition(binary.LittleEndian.Uint16(icb[8:]))
+	if err != nil {
+		return err
+	}
+	off, err := p.offset(binary.LittleEndian.Uint32(icb[4:]))
+	if err != nil {
+		return err
+	}
+	if _, ok := r.visited[off]; ok {
+		return fmt.Errorf("udf: directory loop at %s", dir)
+	}
+	r.visited[off] = struct{}{}
+	entry, err := r.readDescriptor(off, tagFileEntry, tagExtendedFileEntry)
+	if err != nil {
+		return err
+	}
+	info, extents, embedded, err := r.fileExtents(entry, p)
+	if err != nil {
+		return err
+	}
+	// the directory is read into memory, its size is bounded by what is recorded in the image
+	var recorded int64
+	for _, e := range extents {
+		if e.offset >= 0 {
+			recorded += e.length
+		}
+	}
+	if embedded == nil && info.size > recorded {
+		return fmt.Errorf("udf: directory %s larger than its extents", dir)
+	}
+	data := make([]byte, info.size)
+	if _, err = r.content(info, extents, embedded).ReadAt(data, 0); err != nil && err != io.EOF {
+		return err
+	}
+	for len(data) >= 38 {
+		if binary.LittleEndian.Uint16(data) != tagFileIdentifierDescriptor {
+			return fmt.Errorf("udf: bad file identifier descriptor in %s", dir)
+		}
+		chars := data[18]
+		nameLen := int(data[19])
+		iuLen := int(binary.LittleEndian.Uint16(data[36:]))
+		total := (38 + iuLen + nameLen + 3) &^ 3
+		if 38+iuLen+nameLen > len(data) {
+			return fmt.Errorf("udf: bad file identifier descriptor in %s", dir)
+		}
+		childICB := data[20:36]
+		name := decodeName(data[38+iuLen : 38+iuLen+nameLen])
+		data = data[min(total, len(data)):]
+		if chars&(fileCharParent|fileCharDeleted) != 0 || name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+			continue
+		}
+		if err = r.addFile(childICB, stdpath.Join(dir, name), chars&fileCharDirectory != 0, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reader) addFile(icb []byte, path string, isDir bool, depth int) error {
+	if isDir {
+		r.files = append(r.files, &tool.SectionFile{
+			Path: path + "/",
+			Obj:  &model.Object{Name: stdpath.Base(path), IsFolder: true},
+			Data: io.NewSectionReader(zeroReaderAt(0), 0, 0),
+		})
+		return r.walk(icb, path, depth+1)
+	}
+	p, err := r.partition(binary.LittleEndian.Uint16(icb[8:]))
+	if err != nil {
+		return err
+	}
+	off, err := p.offset(binary.LittleEndian.Uint32(icb[4:]))
+	if err != nil {
+		return err
+	}
+	entry, err := r.readDescriptor(off, tagFileEntry, tagExtendedFileEntry)
+	if err != nil {
+		return err
+	}
+	info, extents, embedded, err := r.fileExtents(entry, p)
+	if err != nil {
+		return err
+	}
+	if info.fileType == fileTypeDirectory {
+		return r.addFile(icb, path, true, depth)
+	}
+	r.files = append(r.files, &tool.SectionFile{
+		Path: path,
+		Obj: &model.Object{
+			Name:     stdpath.Base(path),
+			Size:     info.size,
+			Modified: info.modified,
+		},
+		Data: r.content(info, extents, embedded),
+	})
+	return nil
+}
+
+// decodeName decodes a d-characters string compressed by OSTA CS0, UDF 2.1.1
+func decodeName(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	switch b[0] {
+	case 8, 254:
+		runes := make([]rune, 0, len(b)-1)
+		for _, c := range b[1:] {
+			runes = append(runes, rune(c))
+		}
+		return string(runes)
+	case 16, 255:
+		u := make([]uint16, 0, (len(b)-1)/2)
+		for i := 1; i+1 < len(b); i += 2 {
+			u = append(u, binary.BigEndian.Uint16(b[i:]))
+		}
+		return string(utf16.Decode(u))
+	}
+	return ""
+}
+
+// parseTimestamp parses a timestamp, ECMA-167 1/7.3
+func parseTimestamp(b []byte) time.Time {
+	typeAndZone := binary.LittleEndian.Uint16(b)
+	year := int(int16(binary.LittleEndian.Uint16(b[2:])))
+	if year == 0 {
+		return time.Time{}
+	}
+	loc := time.UTC
+	if typeAndZone>>12 == 1 {
+		// the offset is a 12 bits signed integer in minutes, -2047 means unspecified
+		zone := int16(typeAndZone<<4) >> 4
+		if zone != -2047 {
+			loc = time.FixedZone("", int(zone)*60)
+		}
+	}
+	ns := int(b[9])*10_000_000 + int(b[10])*100_000 + int(b[11])*1000
+	return time.Date(year, time.Month(b[4]), int(b[5]), int(b[6]), int(b[7]), int(b[8]), ns, loc)
+}
+
+type zeroReaderAt int64
+
+func (z zeroReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(z) {
+		return 0, io.EOF
+	}
+	n := min(int64(len(p)), int64(z)-off)
+	clear(p[:n])
+	if n < int64(len(p)) {
+		return int(n), io.EOF
+	}
+	return int(n), nil
+}
+
+func (z zeroReaderAt) Size() int64 {
+	return int64(z)
+}
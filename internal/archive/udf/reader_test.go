@@ -0,0 +1,169 @@
+package udf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+const (
+	testBlockSize = 2048
+	testPartStart = 300
+)
+
+// testImage builds a minimal UDF image with one physical partition, the root directory entry
+// is at the logical block 1 of the partition, the blocks after it are filled by the caller
+type testImage []byte
+
+func newTestImage() testImage {
+	img := make(testImage, (testPartStart+8)*testBlockSize)
+	avdp := img.sector(anchorSector)
+	binary.LittleEndian.PutUint32(avdp[16:], 3*testBlockSize)
+	binary.LittleEndian.PutUint32(avdp[20:], anchorSector+1)
+	img.tag(avdp, tagAnchorVolumeDescriptorPointer, anchorSector)
+
+	pd := img.sector(anchorSector + 1)
+	binary.LittleEndian.PutUint32(pd[188:], testPartStart)
+	img.tag(pd, tagPartitionDescriptor, anchorSector+1)
+
+	lvd := img.sector(anchorSector + 2)
+	binary.LittleEndian.PutUint32(lvd[212:], testBlockSize)
+	binary.LittleEndian.PutUint32(lvd[248:], testBlockSize)
+	binary.LittleEndian.PutUint32(lvd[264:], 6)
+	lvd[440], lvd[441] = 1, 6
+	img.tag(lvd, tagLogicalVolumeDescriptor, anchorSector+2)
+	img.tag(img.sector(anchorSector+3), tagTerminatingDescriptor, anchorSector+3)
+
+	fsd := img.block(0)
+	binary.LittleEndian.PutUint32(fsd[400:], testBlockSize)
+	binary.LittleEndian.PutUint32(fsd[404:], 1)
+	img.tag(fsd, tagFileSetDescriptor, 0)
+	return img
+}
+
+func (img testImage) sector(n int) []byte {
+	return img[n*testBlockSize : (n+1)*testBlockSize]
+}
+
+func (img testImage) block(lbn int) []byte {
+	return img.sector(testPartStart + lbn)
+}
+
+func (img testImage) tag(buf []byte, id uint16, location uint32) {
+	binary.LittleEndian.PutUint16(buf, id)
+	binary.LittleEndian.PutUint32(buf[12:], location)
+	var sum byte
+	for i := 0; i < 16; i++ {
+		if i != 4 {
+			sum += buf[i]
+		}
+	}
+	buf[4] = sum
+}
+
+// fileEntry writes a file entry at lbn, the content is embedded if data is given,
+// otherwise it is recorded in the block after the entry
+func (img testImage) fileEntry(lbn int, fileType byte, size int64, data []byte) {
+	fe := img.block(lbn)
+	fe[27] = fileType
+	binary.LittleEndian.PutUint64(fe[56:], uint64(size))
+	if data != nil {
+		binary.LittleEndian.PutUint16(fe[34:], adEmbedded)
+		binary.LittleEndian.PutUint32(fe[172:], uint32(len(data)))
+		copy(fe[176:], data)
+	} else {
+		binary.LittleEndian.PutUint32(fe[172:], 8)
+		binary.LittleEndian.PutUint32(fe[176:], testBlockSize)
+		binary.LittleEndian.PutUint32(fe[180:], uint32(lbn+1))
+	}
+	img.tag(fe, tagFileEntry, uint32(lbn))
+}
+
+// fid returns a file identifier descriptor of the name linking to the entry at lbn
+func fid(name string, lbn uint32, chars byte) []byte {
+	nameLen := len(name) + 1
+	buf := make([]byte, (38+nameLen+3)&^3)
+	binary.LittleEndian.PutUint16(buf, tagFileIdentifierDescriptor)
+	buf[18] = chars
+	buf[19] = byte(nameLen)
+	binary.LittleEndian.PutUint32(buf[20:], testBlockSize)
+	binary.LittleEndian.PutUint32(buf[24:], lbn)
+	buf[38] = 8
+	copy(buf[39:], name)
+	return buf
+}
+
+func (img testImage) reader() (*Reader, error) {
+	return NewReader(bytes.NewReader(img), int64(len(img)))
+}
+
+func TestNewReader(t *testing.T) {
+	img := newTestImage()
+	dir := fid("a.txt", 3, 0)
+	img.fileEntry(1, fileTypeDirectory, int64(len(dir)), nil)
+	copy(img.block(2), dir)
+	img.fileEntry(3, 5, 5, []byte("hello"))
+
+	r, err := img.reader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Files()) != 1 || r.Files()[0].Name() != "a.txt" {
+		t.Fatalf("unexpected files: %v", r.Files())
+	}
+	rc, err := r.Files()[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+}
+
+func TestNewReader_Malformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(img testImage)
+		err   string
+	}{
+		{"huge directory", func(img testImage) {
+			img.fileEntry(1, fileTypeDirectory, 1<<40, nil)
+		}, "larger than its extents"},
+		{"negative size", func(img testImage) {
+			img.fileEntry(1, fileTypeDirectory, -1, nil)
+		}, "bad file size"},
+		{"extent out of the image", func(img testImage) {
+			img.fileEntry(1, fileTypeDirectory, testBlockSize, nil)
+			binary.LittleEndian.PutUint32(img.block(1)[180:], 1<<20)
+			img.tag(img.block(1), tagFileEntry, 1)
+		}, "out of the image"},
+		{"directory loop", func(img testImage) {
+			dir := fid("loop", 1, fileCharDirectory)
+			img.fileEntry(1, fileTypeDirectory, int64(len(dir)), nil)
+			copy(img.block(2), dir)
+		}, "directory loop"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := newTestImage()
+			tt.build(img)
+			_, err := img.reader()
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	// truncated images must fail without panicking
+	img := newTestImage()
+	img.fileEntry(1, fileTypeDirectory, 0, nil)
+	for _, size := range []int{0, 100, anchorSector * testBlockSize, (testPartStart + 1) * testBlockSize} {
+		if _, err := img[:size].reader(); err == nil {
+			t.Fatalf("truncated to %d: no error", size)
+		}
+	}
+}
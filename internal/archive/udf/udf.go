@@ -0,0 +1,60 @@
+package udf
+
+import (
+	"io"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/archive/tool"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+)
+
+// UDF reads the file tree of UDF images, e.g. DVD, Blu-ray and installer images
+type UDF struct{}
+
+func (UDF) AcceptedExtensions() []string {
+	return []string{".udf"}
+}
+
+func (UDF) AcceptedMultipartExtensions() map[string]tool.MultipartExtension {
+	return map[string]tool.MultipartExtension{}
+}
+
+func (UDF) GetMeta(ss []*stream.SeekableStream, args model.ArchiveArgs) (model.ArchiveMeta, error) {
+	reader, err := getReader(ss)
+	if err != nil {
+		return nil, err
+	}
+	_, tree := tool.GenerateMetaTreeFromFolderTraversal(reader)
+	return &model.ArchiveMetaInfo{
+		Comment:   "",
+		Encrypted: false,
+		Tree:      tree,
+	}, nil
+}
+
+func (UDF) List(ss []*stream.SeekableStream, args model.ArchiveInnerArgs) ([]model.Obj, error) {
+	return nil, errs.NotSupport
+}
+
+func (UDF) Extract(ss []*stream.SeekableStream, args model.ArchiveInnerArgs) (io.ReadCloser, int64, error) {
+	reader, err := getReader(ss)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tool.ExtractFromFolderTraversal(reader, args)
+}
+
+func (UDF) Decompress(ss []*stream.SeekableStream, outputPath string, args model.ArchiveInnerArgs, up model.UpdateProgress) error {
+	reader, err := getReader(ss)
+	if err != nil {
+		return err
+	}
+	return tool.DecompressFromFolderTraversal(reader, outputPath, args, up)
+}
+
+var _ tool.Tool = (*UDF)(nil)
+
+func init() {
+	tool.RegisterTool(UDF{})
+}
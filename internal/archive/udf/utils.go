@@ -0,0 +1,13 @@
+package udf
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+)
+
+func getReader(ss []*stream.SeekableStream) (*Reader, error) {
+	readerAt, err := stream.NewMultiReaderAt(ss)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(readerAt, readerAt.Size())
+}
@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/cluster"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ProtocolWeb      = "web"
+	ProtocolWebDAV   = "webdav"
+	ProtocolFTP      = "ftp"
+	ProtocolSFTP     = "sftp"
+	ProtocolS3       = "s3"
+	ProtocolInternal = "internal"
+)
+
+const (
+	OpMakeDir     = "mkdir"
+	OpRename      = "rename"
+	OpMove        = "move"
+	OpCopy        = "copy"
+	OpMerge       = "merge"
+	OpRemove      = "remove"
+	OpUpload      = "upload"
+	OpUploadURL   = "upload_url"
+	OpDecompress  = "decompress"
+	OpShareCreate = "share_create"
+	OpShareDelete = "share_delete"
+)
+
+const (
+	flushInterval = time.Second
+	flushSize     = 100
+	queueSize     = 4096
+	cleanInterval = time.Hour
+)
+
+var (
+	queue     = make(chan model.AuditLog, queueSize)
+	startOnce sync.Once
+)
+
+// Record records an operation of the user in ctx, the logs are written in background
+func Record(ctx context.Context, op, srcPath, dstPath string, bytes int64, err error) {
+	if !setting.GetBool(conf.AuditEnabled) {
+		return
+	}
+	startOnce.Do(func() { go run() })
+	l := model.AuditLog{
+		CreatedAt: time.Now(),
+		Protocol:  ProtocolInternal,
+		Operation: op,
+		SrcPath:   srcPath,
+		DstPath:   dstPath,
+		Success:   err == nil,
+		Bytes:     bytes,
+	}
+	if user, ok := ctx.Value(conf.UserKey).(*model.User); ok && user != nil {
+		l.Username = user.Username
+	}
+	if ip, ok := ctx.Value(conf.ClientIPKey).(string); ok {
+		l.IP = ip
+	}
+	if protocol, ok := ctx.Value(conf.ProtocolKey).(string); ok {
+		l.Protocol = protocol
+	}
+	if err != nil {
+		l.Error = err.Error()
+	}
+	select {
+	case queue <- l:
+	default:
+		log.Warnf("audit log queue is full, drop: %+v", l)
+	}
+}
+
+func run() {
+	flushTicker := time.NewTicker(flushInterval)
+	cleanTicker := time.NewTicker(cleanInterval)
+	defer flushTicker.Stop()
+	defer cleanTicker.Stop()
+	logs := make([]model.AuditLog, 0, flushSize)
+	flush := func() {
+		if len(logs) == 0 {
+			return
+		}
+		if err := db.CreateAuditLogs(logs); err != nil {
+			log.Errorf("failed save %d audit logs: %+v", len(logs), err)
+		}
+		logs = logs[:0]
+	}
+	clean()
+	for {
+		select {
+		case l := <-queue:
+			logs = append(logs, l)
+			if len(logs) >= flushSize {
+				flush()
+			}
+		case <-flushTicker.C:
+			flush()
+		case <-cleanTicker.C:
+			clean()
+		}
+	}
+}
+
+func clean() {
+	// audit logs live in the shared database, the cluster leader cleans them
+	if !cluster.IsLeader() {
+		return
+	}
+	days := setting.GetInt(conf.AuditRetentionDays, 0)
+	if days <= 0 {
+		return
+	}
+	n, err := db.DeleteAuditLogsBefore(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Errorf("failed clean audit logs: %+v", err)
+	} else if n > 0 {
+		log.Infof("cleaned %d audit logs older than %d days", n, days)
+	}
+}
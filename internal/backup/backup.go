@@ -0,0 +1,207 @@
+// Package backup exports all the tables of the database, and optionally the bleve index,
+// to a tar.gz archive independent of the database type, and restores it to any database.
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/bootstrap/patch"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm/schema"
+)
+
+const FormatVersion = 1
+
+const (
+	manifestName = "manifest.json"
+	tablesDir    = "tables/"
+	tableExt     = ".jsonl"
+	indexDir     = "index/"
+)
+
+type Manifest struct {
+	FormatVersion int `json:"format_version"`
+	// version of OpenList which made the backup
+	Version string `json:"version"`
+	// the latest upgrade patch version when the backup is made
+	SchemaVersion string    `json:"schema_version"`
+	Database      string    `json:"database"`
+	CreatedAt     time.Time `json:"created_at"`
+	// rows of each table
+	Tables map[string]int64 `json:"tables"`
+	Index  bool             `json:"index"`
+}
+
+type table struct {
+	model  any
+	schema *schema.Schema
+}
+
+// tables returns the tables registered in db, named without the table prefix
+// so that the backup can be restored to a database with another prefix
+func tables() ([]table, error) {
+	cache := &sync.Map{}
+	var ret []table
+	for _, m := range db.Models() {
+		s, err := schema.Parse(m, cache, schema.NamingStrategy{})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ret = append(ret, table{model: m, schema: s})
+	}
+	return ret, nil
+}
+
+// Backup writes the tables, and the bleve index if includeIndex, to w as a tar.gz archive
+func Backup(w io.Writer, includeIndex bool) (*Manifest, error) {
+	ts, err := tables()
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{
+		FormatVersion: FormatVersion,
+		Version:       conf.Version,
+		SchemaVersion: patch.LatestVersion(),
+		Database:      conf.Conf.Database.Type,
+		CreatedAt:     time.Now(),
+		Tables:        make(map[string]int64, len(ts)),
+	}
+	// the rows are dumped to temp files first, because the size is required by the tar header
+	files := make([]*os.File, 0, len(ts))
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+	for _, t := range ts {
+		f, err := os.CreateTemp(conf.Conf.TempDir, "backup-*"+tableExt)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		files = append(files, f)
+		n, err := dumpTable(t, f)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed dump table %s", t.schema.Table)
+		}
+		m.Tables[t.schema.Table] = n
+	}
+	m.Index = includeIndex && utils.Exists(conf.Conf.BleveDir)
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := writeTarFile(tw, manifestName, int64(len(manifest)), strings.NewReader(string(manifest))); err != nil {
+		return nil, err
+	}
+	for i, t := range ts {
+		f := files[i]
+		info, err := f.Stat()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := writeTarFile(tw, tablesDir+t.schema.Table+tableExt, info.Size(), f); err != nil {
+			return nil, err
+		}
+	}
+	if m.Index {
+		if err := writeIndex(tw); err != nil {
+			return nil, errors.WithMessage(err, "failed backup index")
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return m, errors.WithStack(gw.Close())
+}
+
+// dumpTable writes the rows of the table as json lines keyed by the column names,
+// including the fields hidden from the json of the model, e.g. the password hash
+func dumpTable(t table, w io.Writer) (int64, error) {
+	rows, err := db.GetDb().Model(t.model).Rows()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer rows.Close()
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	ctx := context.Background()
+	typ := t.schema.ModelType
+	var n int64
+	for rows.Next() {
+		item := reflect.New(typ)
+		if err := db.GetDb().ScanRows(rows, item.Interface()); err != nil {
+			return n, errors.WithStack(err)
+		}
+		row := make(map[string]any, len(t.schema.DBNames))
+		for _, field := range t.schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			v, _ := field.ValueOf(ctx, item.Elem())
+			row[field.DBName] = v
+		}
+		if err := enc.Encode(row); err != nil {
+			return n, errors.WithStack(err)
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, errors.WithStack(err)
+	}
+	return n, errors.WithStack(bw.Flush())
+}
+
+func writeTarFile(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    size,
+		ModTime: time.Now(),
+	}); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := io.CopyN(tw, r, size)
+	return errors.WithStack(err)
+}
+
+func writeIndex(tw *tar.Writer) error {
+	root := conf.Conf.BleveDir
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer f.Close()
+		log.Debugf("backup index file: %s", rel)
+		return writeTarFile(tw, indexDir+filepath.ToSlash(rel), info.Size(), f)
+	})
+}
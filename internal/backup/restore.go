@@ -0,0 +1,224 @@
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/bootstrap/patch"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const restoreBatchSize = 100
+
+type RestoreOptions struct {
+	// restore the backup made by a version with a newer schema
+	Force bool
+	// skip the bleve index in the backup
+	SkipIndex bool
+}
+
+// Restore replaces the tables in the backup read from r, the tables not in the backup are kept.
+// All the tables are restored in a transaction, and the index directory is replaced after that.
+func Restore(r io.Reader, opts RestoreOptions) (*Manifest, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid backup")
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != manifestName {
+		return nil, errors.New("invalid backup: manifest not found")
+	}
+	var m Manifest
+	if err := json.NewDecoder(tr).Decode(&m); err != nil {
+		return nil, errors.Wrap(err, "invalid manifest")
+	}
+	if err := checkManifest(&m, opts.Force); err != nil {
+		return nil, err
+	}
+	ts, err := tables()
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]table, len(ts))
+	for _, t := range ts {
+		byName[t.schema.Table] = t
+	}
+
+	// the rows are restored in a transaction, the index is extracted to a temp dir meanwhile
+	var indexTmp string
+	defer func() {
+		if indexTmp != "" {
+			_ = os.RemoveAll(indexTmp)
+		}
+	}()
+	err = db.GetDb().Transaction(func(tx *gorm.DB) error {
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			switch {
+			case strings.HasPrefix(hdr.Name, tablesDir):
+				name := strings.TrimSuffix(strings.TrimPrefix(hdr.Name, tablesDir), tableExt)
+				t, ok := byName[name]
+				if !ok {
+					log.Warnf("skip unknown table in backup: %s", name)
+					continue
+				}
+				n, err := restoreTable(tx, t, tr)
+				if err != nil {
+					return errors.WithMessagef(err, "failed restore table %s", name)
+				}
+				log.Infof("restored %d rows of table %s", n, name)
+			case strings.HasPrefix(hdr.Name, indexDir):
+				if opts.SkipIndex || hdr.Typeflag != tar.TypeReg {
+					continue
+				}
+				if indexTmp == "" {
+					if indexTmp, err = os.MkdirTemp(filepath.Dir(conf.Conf.BleveDir), "bleve-restore-*"); err != nil {
+						return errors.WithStack(err)
+					}
+				}
+				if err := extractFile(indexTmp, strings.TrimPrefix(hdr.Name, indexDir), tr); err != nil {
+					return errors.WithMessage(err, "failed restore index")
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if indexTmp != "" {
+		if err := os.RemoveAll(conf.Conf.BleveDir); err != nil {
+			return &m, errors.Wrap(err, "failed remove old index")
+		}
+		if err := os.Rename(indexTmp, conf.Conf.BleveDir); err != nil {
+			return &m, errors.Wrap(err, "failed replace index")
+		}
+		indexTmp = ""
+	}
+	return &m, nil
+}
+
+func checkManifest(m *Manifest, force bool) error {
+	if m.FormatVersion > FormatVersion {
+		return errors.Errorf("unsupported backup format version %d, please upgrade OpenList", m.FormatVersion)
+	}
+	current := patch.LatestVersion()
+	if patch.NewerVersion(m.SchemaVersion, current) {
+		msg := fmt.Sprintf("the backup is made by %s whose schema version %s is newer than current %s",
+			m.Version, m.SchemaVersion, current)
+		if !force {
+			return errors.New(msg + ", please upgrade OpenList or restore with force")
+		}
+		log.Warn(msg)
+	}
+	return nil
+}
+
+func restoreTable(tx *gorm.DB, t table, r io.Reader) (int64, error) {
+	if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(t.model).Error; err != nil {
+		return 0, errors.WithStack(err)
+	}
+	ctx := context.Background()
+	typ := t.schema.ModelType
+	batch := reflect.MakeSlice(reflect.SliceOf(reflect.PointerTo(typ)), 0, restoreBatchSize)
+	flush := func() error {
+		if batch.Len() == 0 {
+			return nil
+		}
+		err := tx.Model(t.model).Create(batch.Interface()).Error
+		batch = batch.Slice(0, 0)
+		return errors.WithStack(err)
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	var n int64
+	for scanner.Scan() {
+		var row map[string]json.RawMessage
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			return n, errors.Wrapf(err, "invalid row %d", n+1)
+		}
+		item := reflect.New(typ)
+		for _, field := range t.schema.Fields {
+			raw, ok := row[field.DBName]
+			if field.DBName == "" || !ok {
+				continue
+			}
+			v := reflect.New(field.FieldType)
+			if err := json.Unmarshal(raw, v.Interface()); err != nil {
+				return n, errors.Wrapf(err, "invalid column %s of row %d", field.DBName, n+1)
+			}
+			if err := field.Set(ctx, item.Elem(), v.Elem().Interface()); err != nil {
+				return n, errors.Wrapf(err, "failed set column %s of row %d", field.DBName, n+1)
+			}
+		}
+		batch = reflect.Append(batch, item)
+		n++
+		if batch.Len() >= restoreBatchSize {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return n, errors.WithStack(err)
+	}
+	if err := flush(); err != nil {
+		return n, err
+	}
+	return n, resetSequence(tx, t)
+}
+
+// resetSequence makes the sequence of the auto increment primary key of postgres
+// continue from the restored rows, mysql and sqlite do it by themselves
+func resetSequence(tx *gorm.DB, t table) error {
+	pk := t.schema.PrioritizedPrimaryField
+	if conf.Conf.Database.Type != "postgres" || pk == nil ||
+		(pk.DataType != schema.Int && pk.DataType != schema.Uint) {
+		return nil
+	}
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(t.model); err != nil {
+		return errors.WithStack(err)
+	}
+	err := tx.Exec(fmt.Sprintf(`SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE(MAX("%s"), 0) + 1, false) FROM "%s"`,
+		stmt.Table, pk.DBName, pk.DBName, stmt.Table)).Error
+	return errors.WithStack(err)
+}
+
+func extractFile(dir, name string, r io.Reader) error {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return errors.Errorf("illegal file path: %s", name)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	_, err = io.Copy(f, r)
+	return errors.WithStack(err)
+}
@@ -0,0 +1,22 @@
+package bootstrap
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+func InitBlockCache() {
+	c := conf.Conf.BlockCache
+	if !c.Enable {
+		return
+	}
+	cache, err := stream.NewBlockCache(c.Dir, int64(c.BlockSize)*utils.MB, int64(c.MaxSize)*utils.MB)
+	if err != nil {
+		log.Errorf("failed init block cache: %+v", err)
+		return
+	}
+	stream.DefaultBlockCache = cache
+	log.Infof("block cache enabled at %s, %d MB used", c.Dir, cache.Size()/utils.MB)
+}
@@ -0,0 +1,111 @@
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/drivers/crypt"
+	"github.com/OpenListTeam/OpenList/v4/internal/cluster"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/offline_download/tool"
+	"github.com/OpenListTeam/tache"
+	log "github.com/sirupsen/logrus"
+)
+
+const adoptTasksInterval = time.Minute
+
+func InitCluster() {
+	if err := cluster.Init(); err != nil {
+		log.Fatalf("failed join cluster: %+v", err)
+	}
+}
+
+// taskKey returns the key of the persisted tasks of the type.
+// In a cluster every node owns its own row, named type@node.
+func taskKey(type_s string, persist bool) string {
+	if !cluster.Enabled() || !persist {
+		return type_s
+	}
+	key := type_s + "@" + cluster.NodeID()
+	if task, _ := db.GetTaskDataByType(key); task == nil {
+		if err := db.CreateTaskData(&model.TaskItem{Key: key, PersistData: "[]"}); err != nil {
+			log.Errorf("failed create task data %s: %+v", key, err)
+		}
+	}
+	return key
+}
+
+// adoptTasks moves the persisted tasks of dead nodes to the current node.
+// The row saved under the plain type before clustering was enabled belongs to no node, it's adopted as well.
+func adoptTasks[T tache.Task](m *tache.Manager[T], type_s string) {
+	items, err := db.GetTaskDataByPrefix(type_s + "@")
+	if err != nil {
+		log.Errorf("failed get %s tasks of the cluster: %+v", type_s, err)
+		return
+	}
+	if item, _ := db.GetTaskDataByType(type_s); item != nil {
+		items = append(items, *item)
+	}
+	for _, item := range items {
+		node, owned := strings.CutPrefix(item.Key, type_s+"@")
+		if node == cluster.NodeID() {
+			continue
+		}
+		if owned {
+			ctx, cancel := context.WithTimeout(context.Background(), cluster.HeartbeatInterval)
+			claimed, err := cluster.ClaimDead(ctx, node)
+			cancel()
+			if err != nil || !claimed {
+				continue
+			}
+		}
+		var tasks []T
+		if err = json.Unmarshal([]byte(item.PersistData), &tasks); err != nil {
+			log.Errorf("failed decode %s tasks of %s: %+v", type_s, item.Key, err)
+			continue
+		}
+		// the row is deleted by exactly one adopter
+		if ok, err := db.TakeTaskData(item.Key); err != nil || !ok {
+			continue
+		}
+		for _, t := range tasks {
+			if r, ok := tache.Task(t).(tache.Recoverable); ok && !r.Recoverable() {
+				t.SetState(tache.StateFailed)
+				t.SetErr(fmt.Errorf("the task is interrupted and cannot be recovered"))
+			}
+			m.Add(t)
+		}
+		if owned {
+			log.Infof("adopted %d %s tasks of dead node %s", len(tasks), type_s, node)
+		} else {
+			log.Infof("adopted %d %s tasks saved before clustering was enabled", len(tasks), type_s)
+		}
+	}
+}
+
+// runTaskAdoption lets the leader take over the tasks left by dead nodes.
+// Transfer tasks are not adopted, their temp files are on the disk of the dead node.
+func runTaskAdoption() {
+	if !cluster.Enabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(adoptTasksInterval)
+		defer ticker.Stop()
+		for {
+			if cluster.IsLeader() {
+				adoptTasks(fs.CopyTaskManager, "copy")
+				adoptTasks(fs.MoveTaskManager, "move")
+				adoptTasks(tool.DownloadTaskManager, "download")
+				adoptTasks(fs.ArchiveDownloadTaskManager, "decompress")
+				adoptTasks(crypt.RekeyTaskManager, "crypt_rekey")
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -134,6 +134,7 @@ func InitConfig() {
 	convertAbsPath(&conf.Conf.TempDir)
 	convertAbsPath(&conf.Conf.BleveDir)
 	convertAbsPath(&conf.Conf.DistDir)
+	convertAbsPath(&conf.Conf.BlockCache.Dir)
 
 	err := os.MkdirAll(conf.Conf.TempDir, 0o777)
 	if err != nil {
@@ -152,6 +152,7 @@ func InitialSettings() []model.SettingItem {
 		{Key: conf.PreviewArchivesByDefault, Value: "true", Type: conf.TypeBool, Group: model.PREVIEW},
 		{Key: conf.SharePreviewDownloadByDefault, Value: "true", Type: conf.TypeBool, Group: model.PREVIEW},
 		{Key: conf.SharePreviewArchivesByDefault, Value: "false", Type: conf.TypeBool, Group: model.PREVIEW},
+		{Key: conf.NestedArchiveMaxSize, Value: "1024", Type: conf.TypeNumber, Group: model.PREVIEW, Flag: model.PRIVATE, Help: `max size in MB of a nested archive, it is extracted to a temp file each time it is read, 0 means no limit`},
 		{Key: conf.ReadMeAutoRender, Value: "true", Type: conf.TypeBool, Group: model.PREVIEW},
 		{Key: conf.FilterReadMeScripts, Value: "true", Type: conf.TypeBool, Group: model.PREVIEW},
 		{Key: conf.NonEFSZipEncoding, Value: "IBM437", Type: conf.TypeString, Group: model.PREVIEW},
@@ -180,6 +181,16 @@ func InitialSettings() []model.SettingItem {
 		{Key: conf.HandleHookAfterWriting, Value: "false", Type: conf.TypeBool, Group: model.GLOBAL, Flag: model.PRIVATE},
 		{Key: conf.HandleHookRateLimit, Value: "0", Type: conf.TypeNumber, Group: model.GLOBAL, Flag: model.PRIVATE},
 		{Key: conf.IgnoreSystemFiles, Value: "false", Type: conf.TypeBool, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `When enabled, ignores common system files during upload (.DS_Store, desktop.ini, Thumbs.db, and files starting with ._)`},
+		{Key: conf.PrefetchEnabled, Value: "false", Type: conf.TypeBool, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `keep the recently accessed directories and links warm in cache`},
+		{Key: conf.PrefetchPaths, Value: "", Type: conf.TypeText, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `directory trees to warm up in background, one path per line`},
+		{Key: conf.PrefetchRateLimit, Value: "1", Type: conf.TypeNumber, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `max number of prefetch requests per second of each storage, 0 means no limit`},
+		{Key: conf.AuditEnabled, Value: "true", Type: conf.TypeBool, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `record the file operations of users`},
+		{Key: conf.AuditRetentionDays, Value: "90", Type: conf.TypeNumber, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `days to keep the audit logs, 0 means forever`},
+		{Key: conf.WebhookMaxRetries, Value: "5", Type: conf.TypeNumber, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `max retries of a failed webhook delivery, with exponential backoff`},
+		{Key: conf.WebhookRetentionDays, Value: "30", Type: conf.TypeNumber, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `days to keep the webhook delivery logs, 0 means forever`},
+		{Key: conf.HealthCheckEnabled, Value: "false", Type: conf.TypeBool, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `probe the storages periodically, and re-init the failed ones with backoff`},
+		{Key: conf.HealthCheckInterval, Value: "300", Type: conf.TypeNumber, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `seconds between the health checks, at least 60`},
+		{Key: conf.HealthCheckFailures, Value: "3", Type: conf.TypeNumber, Group: model.GLOBAL, Flag: model.PRIVATE, Help: `consecutive failed probes before a storage is marked as failed`},
 
 		// single settings
 		{Key: conf.Token, Value: token, Type: conf.TypeString, Group: model.SINGLE, Flag: model.PRIVATE},
@@ -237,10 +248,21 @@ func InitialSettings() []model.SettingItem {
 		{Key: conf.TaskCopyThreadsNum, Value: strconv.Itoa(conf.Conf.Tasks.Copy.Workers), Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE},
 		{Key: conf.TaskDecompressDownloadThreadsNum, Value: strconv.Itoa(conf.Conf.Tasks.Decompress.Workers), Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE},
 		{Key: conf.TaskDecompressUploadThreadsNum, Value: strconv.Itoa(conf.Conf.Tasks.DecompressUpload.Workers), Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE},
+		{Key: conf.TaskCryptRekeyThreadsNum, Value: strconv.Itoa(conf.Conf.Tasks.CryptRekey.Workers), Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE},
 		{Key: conf.StreamMaxClientDownloadSpeed, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE},
 		{Key: conf.StreamMaxClientUploadSpeed, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE},
 		{Key: conf.StreamMaxServerDownloadSpeed, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE},
 		{Key: conf.StreamMaxServerUploadSpeed, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE},
+		{Key: conf.UserMaxDownloadSpeed, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE, Help: "KB/s for each user, negative means unlimited"},
+		{Key: conf.UserMaxUploadSpeed, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE, Help: "KB/s for each user, negative means unlimited"},
+		{Key: conf.UserMaxConnections, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE, Help: "concurrent transfers of each user, negative means unlimited"},
+		{Key: conf.UserMaxRequests, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE, Help: "requests per minute of each user, negative means unlimited"},
+		{Key: conf.IPMaxDownloadSpeed, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE, Help: "KB/s for each client IP, negative means unlimited"},
+		{Key: conf.IPMaxUploadSpeed, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE, Help: "KB/s for each client IP, negative means unlimited"},
+		{Key: conf.IPMaxConnections, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE, Help: "concurrent transfers of each client IP, negative means unlimited"},
+		{Key: conf.IPMaxRequests, Value: "-1", Type: conf.TypeNumber, Group: model.TRAFFIC, Flag: model.PRIVATE, Help: "requests per minute of each client IP, negative means unlimited"},
+		{Key: conf.RoleTrafficLimits, Value: "{}", Type: conf.TypeText, Group: model.TRAFFIC, Flag: model.PRIVATE,
+			Help: `limits shared by all users of a role, e.g. {"guest": {"download_speed": 1024, "upload_speed": -1, "connections": 8, "requests": 600}}`},
 	}
 	additionalSettingItems := tool.Tools.Items()
 	// 固定顺序
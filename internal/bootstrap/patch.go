@@ -1,8 +1,6 @@
 package bootstrap
 
 import (
-	"fmt"
-
 	"strings"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/bootstrap/patch"
@@ -22,24 +20,6 @@ func safeCall(v string, i int, f func()) {
 	f()
 }
 
-func getVersion(v string) (major, minor, patchNum int, err error) {
-	_, err = fmt.Sscanf(v, "v%d.%d.%d", &major, &minor, &patchNum)
-	return major, minor, patchNum, err
-}
-
-func compareVersion(majorA, minorA, patchNumA, majorB, minorB, patchNumB int) bool {
-	if majorA != majorB {
-		return majorA > majorB
-	}
-	if minorA != minorB {
-		return minorA > minorB
-	}
-	if patchNumA != patchNumB {
-		return patchNumA > patchNumB
-	}
-	return true
-}
-
 func InitUpgradePatch() {
 	if !strings.HasPrefix(conf.Version, "v") {
 		for _, vp := range patch.UpgradePatches {
@@ -55,18 +35,18 @@ func InitUpgradePatch() {
 	if LastLaunchedVersion == "" {
 		LastLaunchedVersion = "v0.0.0"
 	}
-	major, minor, patchNum, err := getVersion(LastLaunchedVersion)
+	major, minor, patchNum, err := patch.ParseVersion(LastLaunchedVersion)
 	if err != nil {
 		utils.Log.Warnf("Failed to parse last launched version %s: %v, skipping all patches and rewrite last launched version", LastLaunchedVersion, err)
 		return
 	}
 	for _, vp := range patch.UpgradePatches {
-		ma, mi, pn, err := getVersion(vp.Version)
+		ma, mi, pn, err := patch.ParseVersion(vp.Version)
 		if err != nil {
 			utils.Log.Errorf("Skip invalid version %s patches: %v", vp.Version, err)
 			continue
 		}
-		if compareVersion(ma, mi, pn, major, minor, patchNum) {
+		if patch.CompareVersion(ma, mi, pn, major, minor, patchNum) >= 0 {
 			for i, p := range vp.Patches {
 				safeCall(vp.Version, i, p)
 			}
@@ -1,6 +1,8 @@
 package patch
 
 import (
+	"fmt"
+
 	"github.com/OpenListTeam/OpenList/v4/internal/bootstrap/patch/v3_24_0"
 	"github.com/OpenListTeam/OpenList/v4/internal/bootstrap/patch/v3_32_0"
 	"github.com/OpenListTeam/OpenList/v4/internal/bootstrap/patch/v3_41_0"
@@ -40,3 +42,42 @@ var UpgradePatches = []VersionPatches{
 		},
 	},
 }
+
+func ParseVersion(v string) (major, minor, patchNum int, err error) {
+	_, err = fmt.Sscanf(v, "v%d.%d.%d", &major, &minor, &patchNum)
+	return major, minor, patchNum, err
+}
+
+// CompareVersion returns a positive number if version a is newer than b,
+// 0 if they are the same and a negative one if a is older
+func CompareVersion(majorA, minorA, patchNumA, majorB, minorB, patchNumB int) int {
+	if majorA != majorB {
+		return majorA - majorB
+	}
+	if minorA != minorB {
+		return minorA - minorB
+	}
+	return patchNumA - patchNumB
+}
+
+// NewerVersion reports whether version a is newer than b, the invalid versions are the oldest
+func NewerVersion(a, b string) bool {
+	ama, ami, apn, aErr := ParseVersion(a)
+	bma, bmi, bpn, bErr := ParseVersion(b)
+	if aErr != nil || bErr != nil {
+		return aErr == nil && bErr != nil
+	}
+	return CompareVersion(ama, ami, apn, bma, bmi, bpn) > 0
+}
+
+// LatestVersion returns the newest version having patches, the data of the versions
+// after it share the same schema
+func LatestVersion() string {
+	latest := ""
+	for _, vp := range UpgradePatches {
+		if NewerVersion(vp.Version, latest) {
+			latest = vp.Version
+		}
+	}
+	return latest
+}
@@ -26,5 +26,7 @@ func LoadStorages() {
 			}
 		}
 		conf.SendStoragesLoadedSignal()
+		op.Prefetcher.Start()
+		op.HealthChecker.Start()
 	}(storages)
 }
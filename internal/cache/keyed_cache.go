@@ -9,6 +9,7 @@ type KeyedCache[T any] struct {
 	entries map[string]*CacheEntry[T]
 	mu      sync.RWMutex
 	ttl     time.Duration
+	stats   HitCounter
 }
 
 func NewKeyedCache[T any](ttl time.Duration) *KeyedCache[T] {
@@ -39,6 +40,12 @@ func (c *KeyedCache[T]) SetWithExpirable(key string, value T, exp Expirable) {
 }
 
 func (c *KeyedCache[T]) Get(key string) (T, bool) {
+	ret, ok := c.get(key)
+	c.stats.Record(ok)
+	return ret, ok
+}
+
+func (c *KeyedCache[T]) get(key string) (T, bool) {
 	c.mu.RLock()
 	entry, exists := c.entries[key]
 	if !exists {
@@ -99,3 +106,20 @@ func (c *KeyedCache[T]) GC() {
 		delete(c.entries, key)
 	}
 }
+
+// ExpiresAt returns the expiration time of the entry,
+// false if not exists or not expires at a fixed time
+func (c *KeyedCache[T]) ExpiresAt(key string) (time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if entry, exists := c.entries[key]; exists {
+		return expiresAt(entry.Expirable)
+	}
+	return time.Time{}, false
+}
+
+func (c *KeyedCache[T]) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stats.Snapshot(len(c.entries))
+}
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// HitCounter counts the hits and misses of a cache
+type HitCounter struct {
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func (s *HitCounter) Record(hit bool) {
+	if hit {
+		s.hits.Add(1)
+	} else {
+		s.misses.Add(1)
+	}
+}
+
+type Stats struct {
+	Hits     uint64  `json:"hits"`
+	Misses   uint64  `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+	Size     int     `json:"size"`
+}
+
+func (s *HitCounter) Snapshot(size int) Stats {
+	ret := Stats{
+		Hits:   s.hits.Load(),
+		Misses: s.misses.Load(),
+		Size:   size,
+	}
+	if total := ret.Hits + ret.Misses; total > 0 {
+		ret.HitRatio = float64(ret.Hits) / float64(total)
+	}
+	return ret
+}
+
+// expiresAt returns the expiration time if the entry expires at a fixed time
+func expiresAt(exp Expirable) (time.Time, bool) {
+	if t, ok := exp.(ExpirationTime); ok {
+		return time.Time(t), true
+	}
+	return time.Time{}, false
+}
@@ -9,6 +9,7 @@ type TypedCache[T any] struct {
 	entries map[string]map[string]*CacheEntry[T]
 	mu      sync.RWMutex
 	ttl     time.Duration
+	stats   HitCounter
 }
 
 func NewTypedCache[T any](ttl time.Duration) *TypedCache[T] {
@@ -44,6 +45,12 @@ func (c *TypedCache[T]) SetTypeWithExpirable(key, typeKey string, value T, exp E
 }
 
 func (c *TypedCache[T]) GetType(key, typeKey string) (T, bool) {
+	ret, ok := c.getType(key, typeKey)
+	c.stats.Record(ok)
+	return ret, ok
+}
+
+func (c *TypedCache[T]) getType(key, typeKey string) (T, bool) {
 	c.mu.RLock()
 	cache, exists := c.entries[key]
 	if !exists {
@@ -111,3 +118,24 @@ func (c *TypedCache[T]) GC() {
 		}
 	}
 }
+
+// TypeExpiresAt returns the expiration time of the entry,
+// false if not exists or not expires at a fixed time
+func (c *TypedCache[T]) TypeExpiresAt(key, typeKey string) (time.Time, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if entry, exists := c.entries[key][typeKey]; exists {
+		return expiresAt(entry.Expirable)
+	}
+	return time.Time{}, false
+}
+
+func (c *TypedCache[T]) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	size := 0
+	for _, entries := range c.entries {
+		size += len(entries)
+	}
+	return c.stats.Snapshot(size)
+}
@@ -0,0 +1,34 @@
+package cluster
+
+import (
+	"context"
+	"time"
+)
+
+// Message is a payload received from a subscribed channel
+type Message struct {
+	Channel string
+	Payload string
+}
+
+// Backend is the shared state store of a cluster.
+// Keys and channels passed to a Backend are already prefixed.
+type Backend interface {
+	Get(ctx context.Context, key string) (string, bool, error)
+	// Set stores the value, ttl <= 0 means no expiration
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+	Del(ctx context.Context, keys ...string) error
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	// Incr increases the counter by one, ttl is applied when the counter is created
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Acquire takes the key if it is free or renews it if it is already held by value
+	Acquire(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	// Claim is Acquire that only succeeds while the absent key does not exist, both are checked atomically
+	Claim(ctx context.Context, key, absent, value string, ttl time.Duration) (bool, error)
+	// Release frees the key only if it is held by value
+	Release(ctx context.Context, key, value string) error
+	Publish(ctx context.Context, channel, payload string) error
+	// Subscribe delivers the messages of the channels until ctx is done
+	Subscribe(ctx context.Context, channels ...string) (<-chan Message, error)
+	Close() error
+}
@@ -0,0 +1,367 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils/random"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	HeartbeatInterval = 5 * time.Second
+	// NodeTTL is how long a node is considered alive after its last heartbeat
+	NodeTTL        = 3 * HeartbeatInterval
+	publishTimeout = 5 * time.Second
+	leaderKey      = "leader"
+	nodeKeyPrefix  = "node:"
+	claimKeyPrefix = "claim:"
+)
+
+// Handler is called with the payload of a message published by another node
+type Handler func(payload json.RawMessage)
+
+type envelope struct {
+	Node    string          `json:"node"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// NodeInfo is stored under the heartbeat key of every live node
+type NodeInfo struct {
+	ID      string    `json:"id"`
+	Version string    `json:"version"`
+	Started time.Time `json:"started"`
+}
+
+// Node is a member of a cluster
+type Node struct {
+	ID       string
+	backend  Backend
+	prefix   string
+	handlers map[string][]Handler
+	leader   atomic.Bool
+	started  time.Time
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func NewNode(id string, backend Backend, prefix string) *Node {
+	return &Node{
+		ID:       id,
+		backend:  backend,
+		prefix:   prefix,
+		handlers: make(map[string][]Handler),
+	}
+}
+
+// Handle registers a handler of the channel, it must be called before Start
+func (n *Node) Handle(channel string, h Handler) {
+	n.handlers[channel] = append(n.handlers[channel], h)
+}
+
+func (n *Node) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+	n.started = time.Now()
+	if len(n.handlers) > 0 {
+		channels := make([]string, 0, len(n.handlers))
+		for c := range n.handlers {
+			channels = append(channels, n.key(c))
+		}
+		msgs, err := n.backend.Subscribe(ctx, channels...)
+		if err != nil {
+			cancel()
+			return errors.WithMessage(err, "failed subscribe cluster channels")
+		}
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			for msg := range msgs {
+				n.dispatch(msg)
+			}
+		}()
+	}
+	n.heartbeat(ctx)
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		ticker := time.NewTicker(HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n.heartbeat(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+func (n *Node) dispatch(msg Message) {
+	var e envelope
+	if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+		log.Warnf("invalid cluster message on %s: %+v", msg.Channel, err)
+		return
+	}
+	if e.Node == n.ID {
+		return
+	}
+	for _, h := range n.handlers[msg.Channel[len(n.prefix):]] {
+		h(e.Payload)
+	}
+}
+
+func (n *Node) heartbeat(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, HeartbeatInterval)
+	defer cancel()
+	info, _ := json.Marshal(NodeInfo{ID: n.ID, Version: conf.Version, Started: n.started})
+	if err := n.backend.Set(ctx, n.key(nodeKeyPrefix+n.ID), string(info), NodeTTL); err != nil {
+		log.Warnf("failed send cluster heartbeat: %+v", err)
+	}
+	ok, err := n.backend.Acquire(ctx, n.key(leaderKey), n.ID, NodeTTL)
+	if err != nil {
+		// keep the current role, the lease outlives a few failed heartbeats
+		log.Warnf("failed renew cluster leadership: %+v", err)
+		return
+	}
+	if n.leader.Swap(ok) != ok {
+		if ok {
+			log.Infof("node %s is the cluster leader now", n.ID)
+		} else {
+			log.Infof("node %s lost the cluster leadership", n.ID)
+		}
+	}
+}
+
+func (n *Node) Stop() {
+	if n.cancel == nil {
+		return
+	}
+	n.cancel()
+	n.wg.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	_ = n.backend.Release(ctx, n.key(leaderKey), n.ID)
+	_ = n.backend.Del(ctx, n.key(nodeKeyPrefix+n.ID))
+	n.leader.Store(false)
+}
+
+func (n *Node) key(k string) string {
+	return n.prefix + k
+}
+
+func (n *Node) IsLeader() bool {
+	return n.leader.Load()
+}
+
+// Leader returns the id of the current leader
+func (n *Node) Leader(ctx context.Context) (string, bool) {
+	id, ok, err := n.backend.Get(ctx, n.key(leaderKey))
+	return id, ok && err == nil
+}
+
+// Alive reports whether the node has sent a heartbeat recently
+func (n *Node) Alive(ctx context.Context, id string) (bool, error) {
+	if id == n.ID {
+		return true, nil
+	}
+	_, ok, err := n.backend.Get(ctx, n.key(nodeKeyPrefix+id))
+	return ok, err
+}
+
+// ClaimDead takes over the node whose heartbeat has expired.
+// Only one node gets the claim, and it fails while the node is alive.
+func (n *Node) ClaimDead(ctx context.Context, id string) (bool, error) {
+	if id == n.ID {
+		return false, nil
+	}
+	return n.backend.Claim(ctx, n.key(claimKeyPrefix+id), n.key(nodeKeyPrefix+id), n.ID, NodeTTL)
+}
+
+// Publish sends the payload to the other nodes handling the channel
+func (n *Node) Publish(ctx context.Context, channel string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	msg, _ := json.Marshal(envelope{Node: n.ID, Payload: data})
+	return n.backend.Publish(ctx, n.key(channel), string(msg))
+}
+
+var (
+	node     *Node
+	handlers = make(map[string][]Handler)
+)
+
+// Subscribe registers a handler of the channel for the node started by Init,
+// it should be called in the init function of the package.
+func Subscribe(channel string, h Handler) {
+	handlers[channel] = append(handlers[channel], h)
+}
+
+func newBackend(c conf.Cluster) (Backend, error) {
+	switch c.Backend {
+	case "memory":
+		return NewMemoryBackend(), nil
+	case "", "redis":
+		return NewRedisBackend(c.Redis.Address, c.Redis.Password, c.Redis.DB)
+	default:
+		return nil, errors.Errorf("unknown cluster backend: %s", c.Backend)
+	}
+}
+
+func nodeID(c conf.Cluster) string {
+	if c.NodeID != "" {
+		return c.NodeID
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return random.String(8)
+}
+
+// Init joins the cluster configured in conf.Conf.Cluster, nothing happens if it's disabled
+func Init() error {
+	c := conf.Conf.Cluster
+	if !c.Enable {
+		return nil
+	}
+	backend, err := newBackend(c)
+	if err != nil {
+		return err
+	}
+	n := NewNode(nodeID(c), backend, c.Redis.KeyPrefix)
+	for channel, hs := range handlers {
+		for _, h := range hs {
+			n.Handle(channel, h)
+		}
+	}
+	if err = n.Start(); err != nil {
+		_ = backend.Close()
+		return err
+	}
+	node = n
+	log.Infof("joined cluster as node %s", n.ID)
+	return nil
+}
+
+func Release() {
+	if node == nil {
+		return
+	}
+	node.Stop()
+	_ = node.backend.Close()
+	node = nil
+}
+
+func Enabled() bool {
+	return node != nil
+}
+
+// NodeID returns the id of the current node, empty if the cluster is disabled
+func NodeID() string {
+	if node == nil {
+		return ""
+	}
+	return node.ID
+}
+
+// IsLeader reports whether the background jobs shared by the cluster should run on this node.
+// A standalone node is always the leader.
+func IsLeader() bool {
+	return node == nil || node.IsLeader()
+}
+
+func Leader(ctx context.Context) (string, bool) {
+	if node == nil {
+		return "", false
+	}
+	return node.Leader(ctx)
+}
+
+func Alive(ctx context.Context, id string) (bool, error) {
+	if node == nil {
+		return id == "", nil
+	}
+	return node.Alive(ctx, id)
+}
+
+func ClaimDead(ctx context.Context, id string) (bool, error) {
+	if node == nil {
+		return false, ErrDisabled
+	}
+	return node.ClaimDead(ctx, id)
+}
+
+// Publish broadcasts the payload to the other nodes, errors are only logged
+func Publish(channel string, payload any) {
+	if node == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	if err := node.Publish(ctx, channel, payload); err != nil {
+		log.Warnf("failed publish cluster message to %s: %+v", channel, err)
+	}
+}
+
+// ErrDisabled is returned by the shared state functions when the cluster is disabled
+var ErrDisabled = errors.New("cluster is disabled")
+
+func Get(ctx context.Context, key string) (string, bool, error) {
+	if node == nil {
+		return "", false, ErrDisabled
+	}
+	return node.backend.Get(ctx, node.key(key))
+}
+
+func Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if node == nil {
+		return ErrDisabled
+	}
+	return node.backend.Set(ctx, node.key(key), value, ttl)
+}
+
+func Del(ctx context.Context, key string) error {
+	if node == nil {
+		return ErrDisabled
+	}
+	return node.backend.Del(ctx, node.key(key))
+}
+
+func Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if node == nil {
+		return ErrDisabled
+	}
+	return node.backend.Expire(ctx, node.key(key), ttl)
+}
+
+func Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	if node == nil {
+		return 0, ErrDisabled
+	}
+	return node.backend.Incr(ctx, node.key(key), ttl)
+}
+
+// Lock takes or renews a lock held by the current node
+func Lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	if node == nil {
+		return false, ErrDisabled
+	}
+	return node.backend.Acquire(ctx, node.key(key), node.ID, ttl)
+}
+
+func Unlock(ctx context.Context, key string) error {
+	if node == nil {
+		return ErrDisabled
+	}
+	return node.backend.Release(ctx, node.key(key), node.ID)
+}
@@ -0,0 +1,106 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNodesShareMemoryBackend(t *testing.T) {
+	backend := NewMemoryBackend()
+	received := make(chan string, 1)
+	a := NewNode("a", backend, "test:")
+	b := NewNode("b", backend, "test:")
+	a.Handle("ping", func(payload json.RawMessage) {
+		t.Errorf("node a received its own message: %s", payload)
+	})
+	b.Handle("ping", func(payload json.RawMessage) {
+		var s string
+		_ = json.Unmarshal(payload, &s)
+		received <- s
+	})
+	if err := a.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("expect a to be the only leader, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+	ctx := context.Background()
+	if err := a.Publish(ctx, "ping", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case s := <-received:
+		if s != "hello" {
+			t.Errorf("expect hello, got %s", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("node b did not receive the message")
+	}
+
+	a.Stop()
+	if alive, _ := b.Alive(ctx, "a"); alive {
+		t.Error("expect a to be dead after stop")
+	}
+	b.heartbeat(ctx)
+	if !b.IsLeader() {
+		t.Error("expect b to take over the leadership")
+	}
+}
+
+func TestMemoryBackendAcquire(t *testing.T) {
+	m := NewMemoryBackend()
+	ctx := context.Background()
+	if ok, _ := m.Acquire(ctx, "lock", "a", 50*time.Millisecond); !ok {
+		t.Fatal("expect a to acquire the free lock")
+	}
+	if ok, _ := m.Acquire(ctx, "lock", "b", time.Minute); ok {
+		t.Fatal("expect b to fail while a holds the lock")
+	}
+	_ = m.Release(ctx, "lock", "b")
+	if _, ok, _ := m.Get(ctx, "lock"); !ok {
+		t.Fatal("expect release of another holder to be ignored")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if ok, _ := m.Acquire(ctx, "lock", "b", time.Minute); !ok {
+		t.Fatal("expect b to acquire the expired lock")
+	}
+}
+
+func TestClaimDead(t *testing.T) {
+	backend := NewMemoryBackend()
+	ctx := context.Background()
+	a := NewNode("a", backend, "test:")
+	b := NewNode("b", backend, "test:")
+	c := NewNode("c", backend, "test:")
+	a.heartbeat(ctx)
+	if ok, _ := b.ClaimDead(ctx, "a"); ok {
+		t.Fatal("expect the claim of a live node to fail")
+	}
+	_ = backend.Del(ctx, a.key(nodeKeyPrefix+"a"))
+	if ok, _ := b.ClaimDead(ctx, "a"); !ok {
+		t.Fatal("expect b to claim the dead node")
+	}
+	if ok, _ := c.ClaimDead(ctx, "a"); ok {
+		t.Fatal("expect c to fail while b holds the claim")
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	ctx := context.Background()
+	if _, _, err := Get(ctx, "k"); err != ErrDisabled {
+		t.Fatalf("get: %v", err)
+	}
+	if err := Set(ctx, "k", "v", 0); err != ErrDisabled {
+		t.Fatalf("set: %v", err)
+	}
+	if _, err := Lock(ctx, "k", time.Second); err != ErrDisabled {
+		t.Fatalf("lock: %v", err)
+	}
+}
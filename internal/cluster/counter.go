@@ -0,0 +1,76 @@
+package cluster
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/OpenListTeam/go-cache"
+	log "github.com/sirupsen/logrus"
+)
+
+// counterTTL keeps forgotten shared counters from living forever
+const counterTTL = 24 * time.Hour
+
+// Counter is a map of named integers, kept in memory when the cluster is disabled
+// and in the shared backend otherwise.
+type Counter struct {
+	name  string
+	local cache.ICache[int]
+}
+
+func NewCounter(name string) *Counter {
+	return &Counter{
+		name:  name,
+		local: cache.NewMemCache[int](),
+	}
+}
+
+func (c *Counter) key(k string) string {
+	return "counter:" + c.name + ":" + k
+}
+
+func (c *Counter) Get(k string) (int, bool) {
+	if !Enabled() {
+		return c.local.Get(k)
+	}
+	v, ok, err := Get(context.Background(), c.key(k))
+	if err != nil {
+		log.Warnf("failed get shared counter %s: %+v", c.key(k), err)
+		return c.local.Get(k)
+	}
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(v)
+	return n, err == nil
+}
+
+func (c *Counter) Set(k string, v int) {
+	if !Enabled() {
+		c.local.Set(k, v)
+		return
+	}
+	if err := Set(context.Background(), c.key(k), strconv.Itoa(v), counterTTL); err != nil {
+		log.Warnf("failed set shared counter %s: %+v", c.key(k), err)
+		c.local.Set(k, v)
+	}
+}
+
+func (c *Counter) Expire(k string, d time.Duration) {
+	c.local.Expire(k, d)
+	if Enabled() {
+		if err := Expire(context.Background(), c.key(k), d); err != nil {
+			log.Warnf("failed expire shared counter %s: %+v", c.key(k), err)
+		}
+	}
+}
+
+func (c *Counter) Del(k string) {
+	c.local.Del(k)
+	if Enabled() {
+		if err := Del(context.Background(), c.key(k)); err != nil {
+			log.Warnf("failed delete shared counter %s: %+v", c.key(k), err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package cluster
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	value    string
+	expireAt time.Time
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && now.After(e.expireAt)
+}
+
+type memorySubscriber struct {
+	channels map[string]struct{}
+	ch       chan Message
+}
+
+// MemoryBackend keeps the shared state in the current process.
+// Several nodes of the same process can share one instance, which makes it a stand-in for redis in tests.
+type MemoryBackend struct {
+	mu          sync.Mutex
+	entries     map[string]memoryEntry
+	subscribers map[*memorySubscriber]struct{}
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		entries:     make(map[string]memoryEntry),
+		subscribers: make(map[*memorySubscriber]struct{}),
+	}
+}
+
+func expireAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// get must be called with the lock held
+func (m *MemoryBackend) get(key string) (memoryEntry, bool) {
+	e, ok := m.entries[key]
+	if ok && e.expired(time.Now()) {
+		delete(m.entries, key)
+		return e, false
+	}
+	return e, ok
+}
+
+func (m *MemoryBackend) Get(ctx context.Context, key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.get(key)
+	return e.value, ok, nil
+}
+
+func (m *MemoryBackend) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = memoryEntry{value: value, expireAt: expireAt(ttl)}
+	return nil
+}
+
+func (m *MemoryBackend) Del(ctx context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+func (m *MemoryBackend) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.get(key); ok {
+		e.expireAt = expireAt(ttl)
+		m.entries[key] = e
+	}
+	return nil
+}
+
+func (m *MemoryBackend) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.get(key)
+	if !ok {
+		e = memoryEntry{value: "0", expireAt: expireAt(ttl)}
+	}
+	n, err := strconv.ParseInt(e.value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	n++
+	e.value = strconv.FormatInt(n, 10)
+	m.entries[key] = e
+	return n, nil
+}
+
+func (m *MemoryBackend) Acquire(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.get(key); ok && e.value != value {
+		return false, nil
+	}
+	m.entries[key] = memoryEntry{value: value, expireAt: expireAt(ttl)}
+	return true, nil
+}
+
+func (m *MemoryBackend) Claim(ctx context.Context, key, absent, value string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.get(absent); ok {
+		return false, nil
+	}
+	if e, ok := m.get(key); ok && e.value != value {
+		return false, nil
+	}
+	m.entries[key] = memoryEntry{value: value, expireAt: expireAt(ttl)}
+	return true, nil
+}
+
+func (m *MemoryBackend) Release(ctx context.Context, key, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.get(key); ok && e.value == value {
+		delete(m.entries, key)
+	}
+	return nil
+}
+
+func (m *MemoryBackend) Publish(ctx context.Context, channel, payload string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for s := range m.subscribers {
+		if _, ok := s.channels[channel]; !ok {
+			continue
+		}
+		select {
+		case s.ch <- Message{Channel: channel, Payload: payload}:
+		default:
+			// a slow subscriber loses messages, the same as a redis client over its output buffer
+		}
+	}
+	return nil
+}
+
+func (m *MemoryBackend) Subscribe(ctx context.Context, channels ...string) (<-chan Message, error) {
+	s := &memorySubscriber{
+		channels: make(map[string]struct{}, len(channels)),
+		ch:       make(chan Message, 256),
+	}
+	for _, c := range channels {
+		s.channels[c] = struct{}{}
+	}
+	m.mu.Lock()
+	m.subscribers[s] = struct{}{}
+	m.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		delete(m.subscribers, s)
+		close(s.ch)
+		m.mu.Unlock()
+	}()
+	return s.ch, nil
+}
+
+func (m *MemoryBackend) Close() error {
+	return nil
+}
+
+var _ Backend = (*MemoryBackend)(nil)
@@ -0,0 +1,394 @@
+package cluster
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	redisPoolSize    = 8
+	redisDialTimeout = 5 * time.Second
+	redisIOTimeout   = 10 * time.Second
+)
+
+const (
+	acquireScript = `local v = redis.call('GET', KEYS[1])
+if v == false then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+if v == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0`
+	releaseScript = `if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`
+	claimScript = `if redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+local v = redis.call('GET', KEYS[1])
+if v == false or v == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0`
+	incrScript = `local n = redis.call('INCR', KEYS[1])
+if n == 1 and tonumber(ARGV[1]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n`
+)
+
+// redisError is an error reply of the server, the connection is still usable after it
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *redisConn) write(args ...string) error {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+func (c *redisConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return "", errors.Errorf("redis: malformed reply line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
+
+// read returns string, int64, []any or nil for a null reply
+func (c *redisConn) read() (any, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err = io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				if _, ok := err.(redisError); !ok {
+					return nil, err
+				}
+				items[i] = err
+			}
+		}
+		return items, nil
+	default:
+		return nil, errors.Errorf("redis: unknown reply type %q", line[0])
+	}
+}
+
+// RedisBackend talks to a redis compatible server with the RESP2 protocol
+type RedisBackend struct {
+	address  string
+	password string
+	db       int
+	pool     chan *redisConn
+	closed   atomic.Bool
+}
+
+func NewRedisBackend(address, password string, db int) (*RedisBackend, error) {
+	b := &RedisBackend{
+		address:  address,
+		password: password,
+		db:       db,
+		pool:     make(chan *redisConn, redisPoolSize),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisDialTimeout)
+	defer cancel()
+	if _, err := b.do(ctx, "PING"); err != nil {
+		return nil, errors.WithMessagef(err, "failed connect redis %s", address)
+	}
+	return b, nil
+}
+
+func (b *RedisBackend) dial(ctx context.Context) (*redisConn, error) {
+	d := net.Dialer{Timeout: redisDialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", b.address)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn)}
+	handshake := func(args ...string) error {
+		_ = conn.SetDeadline(time.Now().Add(redisIOTimeout))
+		if err := c.write(args...); err != nil {
+			return err
+		}
+		_, err := c.read()
+		return err
+	}
+	if b.password != "" {
+		if err = handshake("AUTH", b.password); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	if b.db != 0 {
+		if err = handshake("SELECT", strconv.Itoa(b.db)); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (b *RedisBackend) do(ctx context.Context, args ...string) (any, error) {
+	if b.closed.Load() {
+		return nil, errors.New("redis: backend is closed")
+	}
+	pooled := true
+	var c *redisConn
+	select {
+	case c = <-b.pool:
+	default:
+		var err error
+		if c, err = b.dial(ctx); err != nil {
+			return nil, err
+		}
+		pooled = false
+	}
+	reply, err := b.exec(ctx, c, args)
+	if _, ok := err.(redisError); err != nil && !ok && pooled && ctx.Err() == nil {
+		// a pooled connection may be stale after a server restart or an idle timeout, redial once
+		if c, err = b.dial(ctx); err != nil {
+			return nil, err
+		}
+		reply, err = b.exec(ctx, c, args)
+	}
+	return reply, err
+}
+
+// exec sends the command on c, then puts c back to the pool or closes it on an I/O error
+func (b *RedisBackend) exec(ctx context.Context, c *redisConn, args []string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisIOTimeout)
+	}
+	_ = c.conn.SetDeadline(deadline)
+	err := c.write(args...)
+	var reply any
+	if err == nil {
+		reply, err = c.read()
+	}
+	if err != nil {
+		if _, ok := err.(redisError); !ok {
+			_ = c.conn.Close()
+			return nil, err
+		}
+	}
+	select {
+	case b.pool <- c:
+	default:
+		_ = c.conn.Close()
+	}
+	return reply, err
+}
+
+func (b *RedisBackend) Get(ctx context.Context, key string) (string, bool, error) {
+	reply, err := b.do(ctx, "GET", key)
+	if err != nil || reply == nil {
+		return "", false, err
+	}
+	s, ok := reply.(string)
+	return s, ok, nil
+}
+
+func (b *RedisBackend) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	var err error
+	if ttl > 0 {
+		_, err = b.do(ctx, "SET", key, value, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	} else {
+		_, err = b.do(ctx, "SET", key, value)
+	}
+	return err
+}
+
+func (b *RedisBackend) Del(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := b.do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+func (b *RedisBackend) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	var err error
+	if ttl > 0 {
+		_, err = b.do(ctx, "PEXPIRE", key, strconv.FormatInt(ttl.Milliseconds(), 10))
+	} else {
+		_, err = b.do(ctx, "PERSIST", key)
+	}
+	return err
+}
+
+func (b *RedisBackend) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	reply, err := b.do(ctx, "EVAL", incrScript, "1", key, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return 0, err
+	}
+	n, _ := reply.(int64)
+	return n, nil
+}
+
+func (b *RedisBackend) Acquire(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	reply, err := b.do(ctx, "EVAL", acquireScript, "1", key, value, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	n, _ := reply.(int64)
+	return n == 1, nil
+}
+
+func (b *RedisBackend) Claim(ctx context.Context, key, absent, value string, ttl time.Duration) (bool, error) {
+	reply, err := b.do(ctx, "EVAL", claimScript, "2", key, absent, value, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	n, _ := reply.(int64)
+	return n == 1, nil
+}
+
+func (b *RedisBackend) Release(ctx context.Context, key, value string) error {
+	_, err := b.do(ctx, "EVAL", releaseScript, "1", key, value)
+	return err
+}
+
+func (b *RedisBackend) Publish(ctx context.Context, channel, payload string) error {
+	_, err := b.do(ctx, "PUBLISH", channel, payload)
+	return err
+}
+
+// Subscribe keeps a dedicated connection and reconnects it until ctx is done.
+// Messages published while reconnecting are lost.
+func (b *RedisBackend) Subscribe(ctx context.Context, channels ...string) (<-chan Message, error) {
+	ch := make(chan Message, 256)
+	go func() {
+		defer close(ch)
+		backoff := time.Second
+		for ctx.Err() == nil {
+			err := b.subscribe(ctx, ch, channels)
+			if ctx.Err() != nil || b.closed.Load() {
+				return
+			}
+			log.Warnf("redis subscription lost, reconnect in %s: %+v", backoff, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, time.Minute)
+		}
+	}()
+	return ch, nil
+}
+
+func (b *RedisBackend) subscribe(ctx context.Context, ch chan<- Message, channels []string) error {
+	c, err := b.dial(ctx)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = c.conn.Close()
+	})
+	defer stop()
+	defer c.conn.Close()
+	_ = c.conn.SetDeadline(time.Time{})
+	if err = c.write(append([]string{"SUBSCRIBE"}, channels...)...); err != nil {
+		return err
+	}
+	for {
+		reply, err := c.read()
+		if err != nil {
+			return err
+		}
+		items, ok := reply.([]any)
+		if !ok || len(items) != 3 {
+			continue
+		}
+		if kind, _ := items[0].(string); kind != "message" {
+			continue
+		}
+		channel, _ := items[1].(string)
+		payload, _ := items[2].(string)
+		select {
+		case ch <- Message{Channel: channel, Payload: payload}:
+		default:
+			log.Warnf("cluster message queue is full, drop message of %s", channel)
+		}
+	}
+}
+
+func (b *RedisBackend) Close() error {
+	if b.closed.Swap(true) {
+		return nil
+	}
+	for {
+		select {
+		case c := <-b.pool:
+			_ = c.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (b *RedisBackend) String() string {
+	return fmt.Sprintf("redis://%s/%d", b.address, b.db)
+}
+
+var _ Backend = (*RedisBackend)(nil)
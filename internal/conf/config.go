@@ -73,6 +73,7 @@ type TasksConfig struct {
 	Move               TaskConfig `json:"move" envPrefix:"MOVE_"`
 	Decompress         TaskConfig `json:"decompress" envPrefix:"DECOMPRESS_"`
 	DecompressUpload   TaskConfig `json:"decompress_upload" envPrefix:"DECOMPRESS_UPLOAD_"`
+	CryptRekey         TaskConfig `json:"crypt_rekey" envPrefix:"CRYPT_REKEY_"`
 	AllowRetryCanceled bool       `json:"allow_retry_canceled" env:"ALLOW_RETRY_CANCELED"`
 }
 
@@ -106,6 +107,27 @@ type SFTP struct {
 	Listen string `json:"listen" env:"LISTEN"`
 }
 
+type BlockCache struct {
+	Enable    bool   `json:"enable" env:"ENABLE"`
+	Dir       string `json:"dir" env:"DIR"`
+	MaxSize   int    `json:"max_sizeMB" env:"MAX_SIZE_MB"`
+	BlockSize int    `json:"block_sizeMB" env:"BLOCK_SIZE_MB"`
+}
+
+type Redis struct {
+	Address   string `json:"address" env:"ADDRESS"`
+	Password  string `json:"password" env:"PASSWORD"`
+	DB        int    `json:"db" env:"DB"`
+	KeyPrefix string `json:"key_prefix" env:"KEY_PREFIX"`
+}
+
+type Cluster struct {
+	Enable  bool   `json:"enable" env:"ENABLE"`
+	NodeID  string `json:"node_id" env:"NODE_ID"`
+	Backend string `json:"backend" env:"BACKEND"`
+	Redis   Redis  `json:"redis" envPrefix:"REDIS_"`
+}
+
 type Config struct {
 	Force                 bool        `json:"force" env:"FORCE"`
 	SiteURL               string      `json:"site_url" env:"SITE_URL"`
@@ -130,6 +152,8 @@ type Config struct {
 	S3                    S3          `json:"s3" envPrefix:"S3_"`
 	FTP                   FTP         `json:"ftp" envPrefix:"FTP_"`
 	SFTP                  SFTP        `json:"sftp" envPrefix:"SFTP_"`
+	BlockCache            BlockCache  `json:"block_cache" envPrefix:"BLOCK_CACHE_"`
+	Cluster               Cluster     `json:"cluster" envPrefix:"CLUSTER_"`
 	LastLaunchedVersion   string      `json:"last_launched_version"`
 	ProxyAddress          string      `json:"proxy_address" env:"PROXY_ADDRESS"`
 }
@@ -139,6 +163,7 @@ func DefaultConfig(dataDir string) *Config {
 	indexDir := filepath.Join(dataDir, "bleve")
 	logPath := filepath.Join(dataDir, "log/log.log")
 	dbPath := filepath.Join(dataDir, "data.db")
+	blockCacheDir := filepath.Join(dataDir, "block_cache")
 	return &Config{
 		Scheme: Scheme{
 			Address:    "0.0.0.0",
@@ -216,6 +241,11 @@ func DefaultConfig(dataDir string) *Config {
 				Workers:  5,
 				MaxRetry: 2,
 			},
+			CryptRekey: TaskConfig{
+				Workers:  1,
+				MaxRetry: 2,
+				// TaskPersistant: true,
+			},
 			AllowRetryCanceled: false,
 		},
 		Cors: Cors{
@@ -244,6 +274,20 @@ func DefaultConfig(dataDir string) *Config {
 			Enable: false,
 			Listen: ":5222",
 		},
+		BlockCache: BlockCache{
+			Enable:    false,
+			Dir:       blockCacheDir,
+			MaxSize:   10240,
+			BlockSize: 4,
+		},
+		Cluster: Cluster{
+			Enable:  false,
+			Backend: "redis",
+			Redis: Redis{
+				Address:   "localhost:6379",
+				KeyPrefix: "openlist:",
+			},
+		},
 		LastLaunchedVersion: "",
 		ProxyAddress:        "",
 	}
@@ -36,6 +36,7 @@ const (
 	PreviewArchivesByDefault      = "preview_archives_by_default"
 	SharePreviewDownloadByDefault = "share_preview_download_by_default"
 	SharePreviewArchivesByDefault = "share_preview_archives_by_default"
+	NestedArchiveMaxSize          = "nested_archive_max_size"
 	ReadMeAutoRender              = "readme_autorender"
 	FilterReadMeScripts           = "filter_readme_scripts"
 	NonEFSZipEncoding             = "non_efs_zip_encoding"
@@ -59,6 +60,16 @@ const (
 	HandleHookAfterWriting  = "handle_hook_after_writing"
 	HandleHookRateLimit     = "handle_hook_rate_limit"
 	IgnoreSystemFiles       = "ignore_system_files"
+	PrefetchEnabled         = "prefetch_enabled"
+	PrefetchPaths           = "prefetch_paths"
+	PrefetchRateLimit       = "prefetch_rate_limit"
+	AuditEnabled            = "audit_enabled"
+	AuditRetentionDays      = "audit_retention_days"
+	WebhookMaxRetries       = "webhook_max_retries"
+	WebhookRetentionDays    = "webhook_retention_days"
+	HealthCheckEnabled      = "health_check_enabled"
+	HealthCheckInterval     = "health_check_interval"
+	HealthCheckFailures     = "health_check_failures"
 
 	// index
 	SearchIndex     = "search_index"
@@ -153,10 +164,20 @@ const (
 	TaskMoveThreadsNum                    = "move_task_threads_num"
 	TaskDecompressDownloadThreadsNum      = "decompress_download_task_threads_num"
 	TaskDecompressUploadThreadsNum        = "decompress_upload_task_threads_num"
+	TaskCryptRekeyThreadsNum              = "crypt_rekey_task_threads_num"
 	StreamMaxClientDownloadSpeed          = "max_client_download_speed"
 	StreamMaxClientUploadSpeed            = "max_client_upload_speed"
 	StreamMaxServerDownloadSpeed          = "max_server_download_speed"
 	StreamMaxServerUploadSpeed            = "max_server_upload_speed"
+	UserMaxDownloadSpeed                  = "user_max_download_speed"
+	UserMaxUploadSpeed                    = "user_max_upload_speed"
+	UserMaxConnections                    = "user_max_connections"
+	UserMaxRequests                       = "user_max_requests"
+	IPMaxDownloadSpeed                    = "ip_max_download_speed"
+	IPMaxUploadSpeed                      = "ip_max_upload_speed"
+	IPMaxConnections                      = "ip_max_connections"
+	IPMaxRequests                         = "ip_max_requests"
+	RoleTrafficLimits                     = "role_traffic_limits"
 )
 
 const (
@@ -186,4 +207,6 @@ const (
 	UserAgentKey
 	PathKey
 	SharingIDKey
+	ProtocolKey
+	APITokenKey
 )
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+)
+
+func GetAPITokensByUserId(userId uint, pageIndex, pageSize int) (tokens []model.APIToken, count int64, err error) {
+	tokenDB := db.Model(&model.APIToken{}).Where(model.APIToken{UserId: userId})
+	if err := tokenDB.Count(&count).Error; err != nil {
+		return nil, 0, errors.Wrapf(err, "failed get user's api tokens count")
+	}
+	if err := tokenDB.Order(columnName("id")).Offset((pageIndex - 1) * pageSize).Limit(pageSize).Find(&tokens).Error; err != nil {
+		return nil, 0, errors.Wrapf(err, "failed find user's api tokens")
+	}
+	return tokens, count, nil
+}
+
+func GetAPITokenById(id uint) (*model.APIToken, error) {
+	var t model.APIToken
+	if err := db.First(&t, id).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get api token")
+	}
+	return &t, nil
+}
+
+func GetAPITokenByHash(hash string) (*model.APIToken, error) {
+	t := model.APIToken{Hash: hash}
+	if err := db.Where(t).First(&t).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get api token")
+	}
+	return &t, nil
+}
+
+func CreateAPIToken(t *model.APIToken) error {
+	return errors.WithStack(db.Create(t).Error)
+}
+
+func UpdateAPITokenLastUsed(id uint, t time.Time) error {
+	return errors.WithStack(db.Model(&model.APIToken{ID: id}).Update("last_used_at", t).Error)
+}
+
+func DeleteAPITokenById(id uint) error {
+	return errors.WithStack(db.Delete(&model.APIToken{}, id).Error)
+}
+
+func DeleteAPITokensByUserId(userId uint) error {
+	return errors.WithStack(db.Where(fmt.Sprintf("%s = ?", columnName("user_id")), userId).Delete(&model.APIToken{}).Error)
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+func CreateAuditLogs(logs []model.AuditLog) error {
+	return errors.WithStack(db.CreateInBatches(logs, 100).Error)
+}
+
+func filterAuditLogs(f model.AuditLogFilter) *gorm.DB {
+	tx := db.Model(&model.AuditLog{})
+	if f.Username != "" {
+		tx = tx.Where(fmt.Sprintf("%s = ?", columnName("username")), f.Username)
+	}
+	if f.IP != "" {
+		tx = tx.Where(fmt.Sprintf("%s = ?", columnName("ip")), f.IP)
+	}
+	if f.Protocol != "" {
+		tx = tx.Where(fmt.Sprintf("%s = ?", columnName("protocol")), f.Protocol)
+	}
+	if f.Operation != "" {
+		tx = tx.Where(fmt.Sprintf("%s = ?", columnName("operation")), f.Operation)
+	}
+	if f.Path != "" {
+		// the path itself or anything under it, /foo doesn't match /foobar
+		p := strings.TrimSuffix(f.Path, "/")
+		under := likeEscaper.Replace(p) + "/%"
+		tx = tx.Where(fmt.Sprintf("(%[1]s = ? OR %[1]s LIKE ? ESCAPE '!' OR %[2]s = ? OR %[2]s LIKE ? ESCAPE '!')",
+			columnName("src_path"), columnName("dst_path")), p, under, p, under)
+	}
+	if f.Success != nil {
+		tx = tx.Where(fmt.Sprintf("%s = ?", columnName("success")), *f.Success)
+	}
+	if f.Start > 0 {
+		tx = tx.Where(fmt.Sprintf("%s >= ?", columnName("created_at")), time.Unix(f.Start, 0))
+	}
+	if f.End > 0 {
+		tx = tx.Where(fmt.Sprintf("%s < ?", columnName("created_at")), time.Unix(f.End, 0))
+	}
+	return tx
+}
+
+func GetAuditLogs(f model.AuditLogFilter, pageIndex, pageSize int) (logs []model.AuditLog, count int64, err error) {
+	if err := filterAuditLogs(f).Count(&count).Error; err != nil {
+		return nil, 0, errors.Wrapf(err, "failed get audit logs count")
+	}
+	if err := filterAuditLogs(f).Order(columnName("id") + " DESC").
+		Offset((pageIndex - 1) * pageSize).Limit(pageSize).Find(&logs).Error; err != nil {
+		return nil, 0, errors.Wrapf(err, "failed find audit logs")
+	}
+	return logs, count, nil
+}
+
+// WalkAuditLogs calls fn with the filtered audit logs in batches, from the oldest to the newest
+func WalkAuditLogs(f model.AuditLogFilter, fn func(logs []model.AuditLog) error) error {
+	var logs []model.AuditLog
+	err := filterAuditLogs(f).FindInBatches(&logs, 500, func(tx *gorm.DB, batch int) error {
+		return fn(logs)
+	}).Error
+	return errors.WithStack(err)
+}
+
+func DeleteAuditLogsBefore(t time.Time) (int64, error) {
+	res := db.Where(fmt.Sprintf("%s < ?", columnName("created_at")), t).Delete(&model.AuditLog{})
+	return res.RowsAffected, errors.WithStack(res.Error)
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func TestGetAuditLogsByPath(t *testing.T) {
+	initTestDB(t)
+	logs := []model.AuditLog{
+		{SrcPath: "/foo"},
+		{SrcPath: "/foo/a"},
+		{DstPath: "/foo/b"},
+		{SrcPath: "/foobar"},
+		{SrcPath: "/fooxbar/c"},
+		{SrcPath: "/f_o/d"},
+	}
+	if err := CreateAuditLogs(logs); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]int64{"/foo": 3, "/foo/": 3, "/f_o": 1, "/": 6} {
+		_, count, err := GetAuditLogs(model.AuditLogFilter{Path: path}, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != want {
+			t.Errorf("path %s: got %d logs, want %d", path, count, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// AddChunkRefs increases the references of the chunks by one for each time they appear
+func AddChunkRefs(storageID uint, hashes []string) error {
+	for hash, n := range countHashes(hashes) {
+		update := func() (int64, error) {
+			res := chunkRefQuery(db, storageID, hash).
+				Update("refs", gorm.Expr(fmt.Sprintf("%s + ?", columnName("refs")), n))
+			return res.RowsAffected, res.Error
+		}
+		affected, err := update()
+		if err == nil && affected == 0 {
+			if err = db.Create(&model.ChunkRef{StorageID: storageID, Hash: hash, Refs: n}).Error; err != nil {
+				// created by a concurrent call meanwhile
+				_, err = update()
+			}
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// ReleaseChunkRefs decreases the references of the chunks by one for each time they appear,
+// and returns the chunks no longer referred to
+func ReleaseChunkRefs(storageID uint, hashes []string) ([]string, error) {
+	var released []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for hash, n := range countHashes(hashes) {
+			res := chunkRefQuery(tx, storageID, hash).
+				Update("refs", gorm.Expr(fmt.Sprintf("%s - ?", columnName("refs")), n))
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				// unknown to the refs, left to the garbage collection
+				continue
+			}
+			res = chunkRefQuery(tx, storageID, hash).
+				Where(fmt.Sprintf("%s <= 0", columnName("refs"))).Delete(&model.ChunkRef{})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected > 0 {
+				released = append(released, hash)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return released, nil
+}
+
+func chunkRefQuery(tx *gorm.DB, storageID uint, hash string) *gorm.DB {
+	return tx.Model(&model.ChunkRef{}).
+		Where(fmt.Sprintf("%s = ? AND %s = ?", columnName("storage_id"), columnName("hash")), storageID, hash)
+}
+
+// ResetChunkRefs replaces all the references of the storage
+func ResetChunkRefs(storageID uint, refs map[string]int64) error {
+	return errors.WithStack(db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where(fmt.Sprintf("%s = ?", columnName("storage_id")), storageID).Delete(&model.ChunkRef{}).Error
+		if err != nil {
+			return err
+		}
+		items := make([]model.ChunkRef, 0, len(refs))
+		for hash, n := range refs {
+			items = append(items, model.ChunkRef{StorageID: storageID, Hash: hash, Refs: n})
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(items, 100).Error
+	}))
+}
+
+func countHashes(hashes []string) map[string]int64 {
+	ret := make(map[string]int64, len(hashes))
+	for _, hash := range hashes {
+		ret[hash]++
+	}
+	return ret
+}
@@ -0,0 +1,118 @@
+package db
+
+import (
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func initTestDB(t *testing.T) {
+	d, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Conf = conf.DefaultConfig("data")
+	Init(d)
+}
+
+func chunkRefs(t *testing.T, storageID uint, hash string) int64 {
+	var ref model.ChunkRef
+	err := chunkRefQuery(db, storageID, hash).First(&ref).Error
+	if err == gorm.ErrRecordNotFound {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ref.Refs
+}
+
+func TestChunkRefs(t *testing.T) {
+	initTestDB(t)
+	if err := AddChunkRefs(1, []string{"a", "a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddChunkRefs(1, []string{"b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddChunkRefs(2, []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := chunkRefs(t, 1, "a"), chunkRefs(t, 1, "b"); a != 2 || b != 2 {
+		t.Fatalf("got refs a=%d b=%d", a, b)
+	}
+
+	released, err := ReleaseChunkRefs(1, []string{"a", "b", "unknown"})
+	if err != nil || len(released) != 0 {
+		t.Fatalf("got %v, %v", released, err)
+	}
+	released, err = ReleaseChunkRefs(1, []string{"a", "b", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(released)
+	if !slices.Equal(released, []string{"a", "b"}) {
+		t.Fatalf("got released %v", released)
+	}
+	if a := chunkRefs(t, 1, "a"); a != 0 {
+		t.Fatalf("released ref is kept: %d", a)
+	}
+	// the refs of the other storage are untouched
+	if a := chunkRefs(t, 2, "a"); a != 1 {
+		t.Fatalf("got refs of storage 2: %d", a)
+	}
+
+	if err = ResetChunkRefs(2, map[string]int64{"c": 3}); err != nil {
+		t.Fatal(err)
+	}
+	if a, c := chunkRefs(t, 2, "a"), chunkRefs(t, 2, "c"); a != 0 || c != 3 {
+		t.Fatalf("got refs after reset a=%d c=%d", a, c)
+	}
+}
+
+func TestChunkRefs_Concurrent(t *testing.T) {
+	initTestDB(t)
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*n)
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- AddChunkRefs(1, []string{"x"})
+		}()
+	}
+	wg.Wait()
+	if refs := chunkRefs(t, 1, "x"); refs != n {
+		t.Fatalf("got %d refs, want %d", refs, n)
+	}
+
+	var mu sync.Mutex
+	var released int
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := ReleaseChunkRefs(1, []string{"x"})
+			mu.Lock()
+			released += len(r)
+			mu.Unlock()
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if released != 1 {
+		t.Fatalf("the chunk is released %d times", released)
+	}
+}
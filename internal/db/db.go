@@ -10,9 +10,12 @@ import (
 
 var db *gorm.DB
 
+// models are the tables migrated in Init, the backup exports all of them
+var models = []interface{}{new(model.Storage), new(model.User), new(model.Meta), new(model.SettingItem), new(model.SearchNode), new(model.TaskItem), new(model.SSHPublicKey), new(model.SharingDB), new(model.OfflineDownloadRule), new(model.AuditLog), new(model.Webhook), new(model.WebhookDelivery), new(model.QuotaGroup), new(model.QuotaUsage), new(model.APIToken), new(model.ChunkRef)}
+
 func Init(d *gorm.DB) {
 	db = d
-	err := AutoMigrate(new(model.Storage), new(model.User), new(model.Meta), new(model.SettingItem), new(model.SearchNode), new(model.TaskItem), new(model.SSHPublicKey), new(model.SharingDB))
+	err := AutoMigrate(models...)
 	if err != nil {
 		log.Fatalf("failed migrate database: %s", err.Error())
 	}
@@ -28,6 +31,10 @@ func AutoMigrate(dst ...interface{}) error {
 	return err
 }
 
+func Models() []interface{} {
+	return models
+}
+
 func GetDb() *gorm.DB {
 	return db
 }
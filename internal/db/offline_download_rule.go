@@ -0,0 +1,50 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+)
+
+func GetOfflineDownloadRuleById(id uint) (*model.OfflineDownloadRule, error) {
+	var r model.OfflineDownloadRule
+	if err := db.First(&r, id).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get old rule")
+	}
+	return &r, nil
+}
+
+func GetOfflineDownloadRules(pageIndex, pageSize int) (rules []model.OfflineDownloadRule, count int64, err error) {
+	ruleDB := db.Model(&model.OfflineDownloadRule{})
+	if err := ruleDB.Count(&count).Error; err != nil {
+		return nil, 0, errors.Wrapf(err, "failed get rules count")
+	}
+	if err := ruleDB.Order(fmt.Sprintf("%s, %s", columnName("order"), columnName("id"))).
+		Offset((pageIndex - 1) * pageSize).Limit(pageSize).Find(&rules).Error; err != nil {
+		return nil, 0, errors.Wrapf(err, "failed get find rules")
+	}
+	return rules, count, nil
+}
+
+// GetEnabledOfflineDownloadRulesByUserId returns the enabled global rules and the enabled rules of the user
+func GetEnabledOfflineDownloadRulesByUserId(userId uint) (rules []model.OfflineDownloadRule, err error) {
+	err = db.Where(fmt.Sprintf("%s IN ? AND %s = ?", columnName("user_id"), columnName("disabled")), []uint{0, userId}, false).
+		Order(fmt.Sprintf("%s, %s", columnName("order"), columnName("id"))).Find(&rules).Error
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed get enabled rules")
+	}
+	return rules, nil
+}
+
+func CreateOfflineDownloadRule(r *model.OfflineDownloadRule) error {
+	return errors.WithStack(db.Create(r).Error)
+}
+
+func UpdateOfflineDownloadRule(r *model.OfflineDownloadRule) error {
+	return errors.WithStack(db.Save(r).Error)
+}
+
+func DeleteOfflineDownloadRuleById(id uint) error {
+	return errors.WithStack(db.Delete(&model.OfflineDownloadRule{}, id).Error)
+}
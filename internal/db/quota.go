@@ -0,0 +1,131 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func GetQuotaGroupById(id uint) (*model.QuotaGroup, error) {
+	var g model.QuotaGroup
+	if err := db.First(&g, id).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get quota group")
+	}
+	return &g, nil
+}
+
+func GetQuotaGroups(pageIndex, pageSize int) (groups []model.QuotaGroup, count int64, err error) {
+	groupDB := db.Model(&model.QuotaGroup{})
+	if err := groupDB.Count(&count).Error; err != nil {
+		return nil, 0, errors.Wrapf(err, "failed get quota groups count")
+	}
+	if err := groupDB.Order(columnName("id")).
+		Offset((pageIndex - 1) * pageSize).Limit(pageSize).Find(&groups).Error; err != nil {
+		return nil, 0, errors.Wrapf(err, "failed find quota groups")
+	}
+	return groups, count, nil
+}
+
+func CreateQuotaGroup(g *model.QuotaGroup) error {
+	return errors.WithStack(db.Create(g).Error)
+}
+
+func UpdateQuotaGroup(g *model.QuotaGroup) error {
+	return errors.WithStack(db.Save(g).Error)
+}
+
+func DeleteQuotaGroupById(id uint) error {
+	if err := db.Delete(&model.QuotaGroup{}, id).Error; err != nil {
+		return errors.WithStack(err)
+	}
+	return DeleteQuotaUsage(model.GroupQuotaOwner(id))
+}
+
+func GetQuotaGroupMembers(groupId uint) (users []model.User, err error) {
+	err = db.Where(fmt.Sprintf("%s = ?", columnName("quota_group_id")), groupId).Find(&users).Error
+	return users, errors.Wrapf(err, "failed get quota group members")
+}
+
+// GetQuotaUsers returns the users with a quota of their own or of a group
+func GetQuotaUsers() (users []model.User, err error) {
+	err = db.Where(fmt.Sprintf("%s > 0 OR %s > 0 OR %s > 0",
+		columnName("quota_bytes"), columnName("quota_files"), columnName("quota_group_id"))).Find(&users).Error
+	return users, errors.Wrapf(err, "failed get quota users")
+}
+
+// GetQuotaUsage returns an empty usage if nothing has been accounted yet
+func GetQuotaUsage(owner string) (*model.QuotaUsage, error) {
+	u := model.QuotaUsage{Owner: owner}
+	if err := db.Where(u).Take(&u).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrapf(err, "failed get quota usage")
+	}
+	return &u, nil
+}
+
+// addClamped adds the value to the column, the result is never below 0
+func addClamped(column string, value int64) clause.Expr {
+	return gorm.Expr(fmt.Sprintf("CASE WHEN %[1]s + ? < 0 THEN 0 ELSE %[1]s + ? END", columnName(column)), value, value)
+}
+
+// AddQuotaUsage changes the usage atomically, it creates the record if needed.
+// The usage is kept at 0 or above, the files removed may have been stored before they were accounted.
+func AddQuotaUsage(owner string, bytes, files int64) error {
+	update := func() (int64, error) {
+		res := db.Model(&model.QuotaUsage{}).Where(fmt.Sprintf("%s = ?", columnName("owner")), owner).
+			Updates(map[string]any{
+				"bytes": addClamped("bytes", bytes),
+				"files": addClamped("files", files),
+			})
+		return res.RowsAffected, res.Error
+	}
+	n, err := update()
+	if err != nil || n > 0 {
+		return errors.WithStack(err)
+	}
+	if err = db.Create(&model.QuotaUsage{Owner: owner, Bytes: max(bytes, 0), Files: max(files, 0)}).Error; err != nil {
+		// created by a concurrent call meanwhile
+		_, err = update()
+	}
+	return errors.WithStack(err)
+}
+
+// ReserveQuotaUsage adds the usage only if it stays within maxBytes and maxFiles, a limit <= 0 is unlimited.
+// The check and the add are one statement, so concurrent reservations can't exceed the limits together.
+func ReserveQuotaUsage(owner string, bytes, files, maxBytes, maxFiles int64) (bool, error) {
+	reserve := func() (int64, error) {
+		res := db.Model(&model.QuotaUsage{}).
+			Where(fmt.Sprintf("%s = ?", columnName("owner")), owner).
+			Where(fmt.Sprintf("(? <= 0 OR %s + ? <= ?) AND (? <= 0 OR %s + ? <= ?)", columnName("bytes"), columnName("files")),
+				maxBytes, bytes, maxBytes, maxFiles, files, maxFiles).
+			Updates(map[string]any{
+				"bytes": addClamped("bytes", bytes),
+				"files": addClamped("files", files),
+			})
+		return res.RowsAffected, res.Error
+	}
+	n, err := reserve()
+	if err != nil || n > 0 {
+		return n > 0, errors.WithStack(err)
+	}
+	var count int64
+	if err = db.Model(&model.QuotaUsage{}).Where(fmt.Sprintf("%s = ?", columnName("owner")), owner).
+		Count(&count).Error; err != nil || count > 0 {
+		return false, errors.WithStack(err)
+	}
+	// nothing has been accounted yet, a concurrent call may create the record meanwhile
+	_ = db.Create(&model.QuotaUsage{Owner: owner}).Error
+	n, err = reserve()
+	return n > 0, errors.WithStack(err)
+}
+
+func SaveQuotaUsage(u *model.QuotaUsage) error {
+	return errors.WithStack(db.Save(u).Error)
+}
+
+func DeleteQuotaUsage(owner string) error {
+	return errors.WithStack(db.Where(fmt.Sprintf("%s = ?", columnName("owner")), owner).
+		Delete(&model.QuotaUsage{}).Error)
+}
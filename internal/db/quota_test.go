@@ -0,0 +1,58 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func TestAddQuotaUsageClamped(t *testing.T) {
+	initTestDB(t)
+	owner := model.UserQuotaOwner(1)
+	if err := AddQuotaUsage(owner, -10, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddQuotaUsage(owner, 100, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddQuotaUsage(owner, -150, -3); err != nil {
+		t.Fatal(err)
+	}
+	u, err := GetQuotaUsage(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Bytes != 0 || u.Files != 0 {
+		t.Fatalf("got %d bytes in %d files, want 0 in 0", u.Bytes, u.Files)
+	}
+	if err = AddQuotaUsage(owner, 30, 1); err != nil {
+		t.Fatal(err)
+	}
+	if u, err = GetQuotaUsage(owner); err != nil {
+		t.Fatal(err)
+	}
+	if u.Bytes != 30 || u.Files != 1 {
+		t.Fatalf("got %d bytes in %d files, want 30 in 1", u.Bytes, u.Files)
+	}
+}
+
+func TestReserveQuotaUsage(t *testing.T) {
+	initTestDB(t)
+	owner := model.UserQuotaOwner(2)
+	if ok, err := ReserveQuotaUsage(owner, 60, 1, 100, 0); err != nil || !ok {
+		t.Fatalf("expect the first reservation to succeed, got %v %v", ok, err)
+	}
+	if ok, err := ReserveQuotaUsage(owner, 60, 1, 100, 0); err != nil || ok {
+		t.Fatalf("expect the reservation over the limit to fail, got %v %v", ok, err)
+	}
+	if ok, err := ReserveQuotaUsage(owner, 40, 1, 100, 0); err != nil || !ok {
+		t.Fatalf("expect the reservation up to the limit to succeed, got %v %v", ok, err)
+	}
+	u, err := GetQuotaUsage(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Bytes != 100 || u.Files != 2 {
+		t.Fatalf("got %d bytes in %d files, want 100 in 2", u.Bytes, u.Files)
+	}
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"fmt"
+
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/pkg/errors"
@@ -22,6 +24,23 @@ func CreateTaskData(t *model.TaskItem) error {
 	return errors.WithStack(db.Create(t).Error)
 }
 
+func GetTaskDataByPrefix(prefix string) ([]model.TaskItem, error) {
+	var tasks []model.TaskItem
+	if err := db.Where(fmt.Sprintf("%s LIKE ? ESCAPE '!'", columnName("key")), likeEscaper.Replace(prefix)+"%").Find(&tasks).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return tasks, nil
+}
+
+// TakeTaskData deletes the task data, it returns false if the data has been taken by others
+func TakeTaskData(key string) (bool, error) {
+	res := db.Where(fmt.Sprintf("%s = ?", columnName("key")), key).Delete(&model.TaskItem{})
+	if res.Error != nil {
+		return false, errors.WithStack(res.Error)
+	}
+	return res.RowsAffected > 0, nil
+}
+
 func GetTaskDataFunc(type_s string, enabled bool) func() ([]byte, error) {
 	if !enabled {
 		return nil
@@ -0,0 +1,23 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+)
+
+func TestGetTaskDataByPrefix(t *testing.T) {
+	initTestDB(t)
+	for _, key := range []string{"crypt_rekey@a", "cryptxrekey@b", "crypt_rekey", "copy@a"} {
+		if err := CreateTaskData(&model.TaskItem{Key: key, PersistData: "[]"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	items, err := GetTaskDataByPrefix("crypt_rekey@")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Key != "crypt_rekey@a" {
+		t.Fatalf("got %+v, want only crypt_rekey@a", items)
+	}
+}
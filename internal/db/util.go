@@ -2,6 +2,7 @@ package db
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"gorm.io/gorm"
@@ -14,6 +15,10 @@ func columnName(name string) string {
 	return fmt.Sprintf("`%s`", name)
 }
 
+// likeEscaper escapes the wildcards of a LIKE pattern written with ESCAPE '!',
+// the backslash isn't used as it needs to be escaped again in MySQL
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
 func addStorageOrder(db *gorm.DB) *gorm.DB {
 	return db.Order(fmt.Sprintf("%s, %s", columnName("order"), columnName("id")))
 }
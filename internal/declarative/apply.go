@@ -0,0 +1,435 @@
+package declarative
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+type ApplyOptions struct {
+	// decrypts the encrypted secrets in the document
+	Passphrase string
+	// only computes the changes
+	DryRun bool
+	// deletes the users, storages, metas and shares not in the document,
+	// only for the sections present in the document
+	Prune bool
+	// creates and updates the storages through op so that they are loaded,
+	// otherwise they are only saved to the database and loaded at next start
+	LoadStorages bool
+}
+
+type Change struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	Action string   `json:"action"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+func (c Change) String() string {
+	s := fmt.Sprintf("%s %s [%s]", c.Action, c.Kind, c.Name)
+	if len(c.Fields) > 0 {
+		s += fmt.Sprintf(" %v", c.Fields)
+	}
+	return s
+}
+
+type applier struct {
+	ctx     context.Context
+	opts    ApplyOptions
+	codec   *secretCodec
+	state   *state
+	changes []Change
+}
+
+// Apply diffs the document against the current configuration and applies the changes in order of
+// settings, users, storages, metas and shares. The fields missing in the document are kept,
+// and the redacted secrets keep their current values.
+func Apply(ctx context.Context, d *Document, opts ApplyOptions) ([]Change, error) {
+	codec, err := d.codec(opts.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	s, err := currentState()
+	if err != nil {
+		return nil, err
+	}
+	a := &applier{ctx: ctx, opts: opts, codec: codec, state: s}
+	for _, step := range []func(*Document) error{
+		a.applySettings,
+		a.applyUsers,
+		a.applyStorages,
+		a.applyMetas,
+		a.applyShares,
+	} {
+		if err := step(d); err != nil {
+			return a.changes, err
+		}
+	}
+	return a.changes, nil
+}
+
+func (a *applier) applySettings(d *Document) error {
+	var items []model.SettingItem
+	for _, k := range sortedKeys(d.Settings) {
+		item, ok := a.state.settings[k]
+		if !ok {
+			return errors.Errorf("unknown setting [%s]", k)
+		}
+		v := d.Settings[k]
+		if isSecretField(k) {
+			plain, ok, err := a.codec.decode(v)
+			if err != nil {
+				return errors.WithMessagef(err, "setting [%s]", k)
+			}
+			if !ok {
+				continue
+			}
+			v = plain
+		}
+		if item.Value == v {
+			continue
+		}
+		if item.Flag == model.READONLY {
+			return errors.Errorf("setting [%s] is readonly", k)
+		}
+		item.Value = v
+		items = append(items, item)
+		a.changes = append(a.changes, Change{Kind: "setting", Name: k, Action: ActionUpdate})
+	}
+	if a.opts.DryRun || len(items) == 0 {
+		return nil
+	}
+	return op.SaveSettingItems(items)
+}
+
+// collection applies the objects of a kind
+type collection struct {
+	kind    string
+	key     string
+	current map[string]Object
+	normKey func(string) string
+	create  func(o Object) error
+	update  func(name string, o Object, fields []string) error
+	delete  func(name string) error
+}
+
+func (a *applier) apply(c collection, desired []Object) error {
+	seen := make(map[string]struct{}, len(desired))
+	for _, o := range desired {
+		name, _ := o[c.key].(string)
+		if c.normKey != nil {
+			name = c.normKey(name)
+		}
+		if name == "" {
+			return errors.Errorf("%s without %s", c.kind, c.key)
+		}
+		if _, ok := seen[name]; ok {
+			return errors.Errorf("duplicate %s [%s]", c.kind, name)
+		}
+		seen[name] = struct{}{}
+		o = deepCopy(o)
+		o[c.key] = name
+		cur, exists := c.current[name]
+		if err := a.codec.decodeObject(o, cur); err != nil {
+			return errors.WithMessagef(err, "%s [%s]", c.kind, name)
+		}
+		if !exists {
+			a.changes = append(a.changes, Change{Kind: c.kind, Name: name, Action: ActionCreate})
+			if !a.opts.DryRun {
+				if err := c.create(o); err != nil {
+					return errors.WithMessagef(err, "failed create %s [%s]", c.kind, name)
+				}
+			}
+			continue
+		}
+		merged, fields := merge(cur, o)
+		if len(fields) == 0 {
+			continue
+		}
+		a.changes = append(a.changes, Change{Kind: c.kind, Name: name, Action: ActionUpdate, Fields: fields})
+		if !a.opts.DryRun {
+			if err := c.update(name, merged, fields); err != nil {
+				return errors.WithMessagef(err, "failed update %s [%s]", c.kind, name)
+			}
+		}
+	}
+	if !a.opts.Prune || desired == nil {
+		return nil
+	}
+	for _, name := range sortedKeys(c.current) {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		a.changes = append(a.changes, Change{Kind: c.kind, Name: name, Action: ActionDelete})
+		if !a.opts.DryRun {
+			if err := c.delete(name); err != nil {
+				return errors.WithMessagef(err, "failed delete %s [%s]", c.kind, name)
+			}
+		}
+	}
+	return nil
+}
+
+// merge overlays o on cur, the storage addition is merged by fields,
+// and returns the changed fields
+func merge(cur, o Object) (Object, []string) {
+	merged := deepCopy(cur)
+	var fields []string
+	for _, k := range sortedKeys(o) {
+		v := normalize(o[k])
+		if sub, ok := v.(Object); ok {
+			if curSub, ok := merged[k].(Object); ok {
+				m, subFields := merge(curSub, sub)
+				merged[k] = m
+				for _, f := range subFields {
+					fields = append(fields, k+"."+f)
+				}
+				continue
+			}
+		}
+		if !reflect.DeepEqual(merged[k], v) {
+			merged[k] = v
+			fields = append(fields, k)
+		}
+	}
+	return merged, fields
+}
+
+func (a *applier) applyUsers(d *Document) error {
+	// the plain passwords are only applied when they are changed
+	for _, o := range d.Users {
+		name, _ := o["username"].(string)
+		pwd, ok := o["password"].(string)
+		if u, exists := a.state.userModels[name]; ok && exists && u.ValidateRawPassword(pwd) == nil {
+			delete(o, "password")
+		}
+	}
+	return a.apply(collection{
+		kind:    "user",
+		key:     "username",
+		current: a.state.users,
+		create: func(o Object) error {
+			u, err := toUser(o)
+			if err != nil {
+				return err
+			}
+			if u.IsAdmin() || u.IsGuest() {
+				return errors.New("the admin or guest user can't be created")
+			}
+			if u.PwdHash == "" {
+				return errors.New("password is required")
+			}
+			return op.CreateUser(u)
+		},
+		update: func(name string, o Object, _ []string) error {
+			u, err := toUser(o)
+			if err != nil {
+				return err
+			}
+			old := a.state.userModels[name]
+			u.ID, u.Authn = old.ID, old.Authn
+			if u.PwdHash != old.PwdHash {
+				u.PwdTS = time.Now().Unix()
+			} else {
+				u.PwdTS = old.PwdTS
+			}
+			return op.UpdateUser(u)
+		},
+		delete: func(name string) error {
+			u := a.state.userModels[name]
+			if u.IsAdmin() || u.IsGuest() {
+				return errs.DeleteAdminOrGuest
+			}
+			return op.DeleteUserById(u.ID)
+		},
+	}, d.Users)
+}
+
+func toUser(o Object) (*model.User, error) {
+	var u model.User
+	if err := fromObject(o, &u); err != nil {
+		return nil, err
+	}
+	u.PwdHash, _ = o["pwd_hash"].(string)
+	u.Salt, _ = o["salt"].(string)
+	u.OtpSecret, _ = o["otp_secret"].(string)
+	if u.Password != "" {
+		u.SetPassword(u.Password)
+		u.Password = ""
+	}
+	return &u, nil
+}
+
+func (a *applier) applyStorages(d *Document) error {
+	return a.apply(collection{
+		kind:    "storage",
+		key:     "mount_path",
+		current: a.state.storages,
+		normKey: utils.FixAndCleanPath,
+		create: func(o Object) error {
+			st, err := toStorage(o)
+			if err != nil {
+				return err
+			}
+			if !a.opts.LoadStorages || st.Disabled {
+				return db.CreateStorage(st)
+			}
+			_, err = op.CreateStorage(a.ctx, *st)
+			return err
+		},
+		update: func(name string, o Object, fields []string) error {
+			st, err := toStorage(o)
+			if err != nil {
+				return err
+			}
+			old := a.state.storageModels[name]
+			st.ID, st.Status = old.ID, old.Status
+			if !a.opts.LoadStorages {
+				return db.UpdateStorage(st)
+			}
+			// enabling and disabling drop or load the storage
+			disabled := st.Disabled
+			st.Disabled = old.Disabled
+			if len(fields) > 1 || fields[0] != "disabled" {
+				if err := op.UpdateStorage(a.ctx, *st); err != nil {
+					return err
+				}
+			}
+			if disabled && !old.Disabled {
+				return op.DisableStorage(a.ctx, st.ID)
+			}
+			if !disabled && old.Disabled {
+				return op.EnableStorage(a.ctx, st.ID)
+			}
+			return nil
+		},
+		delete: func(name string) error {
+			id := a.state.storageModels[name].ID
+			if !a.opts.LoadStorages {
+				return db.DeleteStorageById(id)
+			}
+			return op.DeleteStorageById(a.ctx, id)
+		},
+	}, d.Storages)
+}
+
+func toStorage(o Object) (*model.Storage, error) {
+	addition, hasAddition := o["addition"]
+	o = deepCopy(o)
+	delete(o, "addition")
+	var st model.Storage
+	if err := fromObject(o, &st); err != nil {
+		return nil, err
+	}
+	if hasAddition {
+		if s, ok := addition.(string); ok {
+			st.Addition = s
+		} else {
+			s, err := utils.Json.MarshalToString(addition)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			st.Addition = s
+		}
+	}
+	if _, err := op.GetDriver(st.Driver); err != nil {
+		return nil, err
+	}
+	st.Modified = time.Now()
+	return &st, nil
+}
+
+func (a *applier) applyMetas(d *Document) error {
+	return a.apply(collection{
+		kind:    "meta",
+		key:     "path",
+		current: a.state.metas,
+		normKey: utils.FixAndCleanPath,
+		create: func(o Object) error {
+			var m model.Meta
+			if err := fromObject(o, &m); err != nil {
+				return err
+			}
+			return op.CreateMeta(&m)
+		},
+		update: func(name string, o Object, _ []string) error {
+			var m model.Meta
+			if err := fromObject(o, &m); err != nil {
+				return err
+			}
+			m.ID = a.state.metaModels[name].ID
+			return op.UpdateMeta(&m)
+		},
+		delete: func(name string) error {
+			return op.DeleteMetaById(a.state.metaModels[name].ID)
+		},
+	}, d.Metas)
+}
+
+func (a *applier) applyShares(d *Document) error {
+	return a.apply(collection{
+		kind:    "share",
+		key:     "id",
+		current: a.state.shares,
+		create: func(o Object) error {
+			s, err := toSharing(o)
+			if err != nil {
+				return err
+			}
+			_, err = op.CreateSharing(s)
+			return err
+		},
+		update: func(name string, o Object, _ []string) error {
+			s, err := toSharing(o)
+			if err != nil {
+				return err
+			}
+			// keep the access count
+			if old, err := db.GetSharingById(name); err == nil {
+				s.Accessed = old.Accessed
+			}
+			return op.UpdateSharing(s)
+		},
+		delete: func(name string) error {
+			return op.DeleteSharing(name)
+		},
+	}, d.Shares)
+}
+
+func toSharing(o Object) (*model.Sharing, error) {
+	var sdb model.SharingDB
+	if err := fromObject(o, &sdb); err != nil {
+		return nil, err
+	}
+	var files []string
+	rawFiles, _ := o["files"].([]any)
+	for _, f := range rawFiles {
+		if f, ok := f.(string); ok && f != "" {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("files are required")
+	}
+	creator, _ := o["creator"].(string)
+	user, err := op.GetUserByName(creator)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "creator [%s]", creator)
+	}
+	return &model.Sharing{SharingDB: &sdb, Files: files, Creator: user}, nil
+}
@@ -0,0 +1,293 @@
+// Package declarative exports the storages, users, metas, settings and shares as a YAML document,
+// and applies a document by diffing it against the current ones, so deployments can be reproduced from git.
+package declarative
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"sort"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const Version = 1
+
+// Object is an exported model, keyed by its json field names
+type Object = map[string]any
+
+type Document struct {
+	Version int `yaml:"version"`
+	// base64 salt to derive the key from the passphrase, only set when the secrets are encrypted
+	Salt     string            `yaml:"salt,omitempty"`
+	Settings map[string]string `yaml:"settings,omitempty"`
+	Users    []Object          `yaml:"users,omitempty"`
+	Storages []Object          `yaml:"storages,omitempty"`
+	Metas    []Object          `yaml:"metas,omitempty"`
+	Shares   []Object          `yaml:"shares,omitempty"`
+}
+
+func Parse(data []byte) (*Document, error) {
+	var d Document
+	if err := yaml.Unmarshal(data, &d); err != nil {
+		return nil, errors.Wrap(err, "failed parse document")
+	}
+	if d.Version != Version {
+		return nil, errors.Errorf("unsupported document version: %d", d.Version)
+	}
+	return &d, nil
+}
+
+func (d *Document) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(d); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), errors.WithStack(enc.Close())
+}
+
+func (d *Document) codec(passphrase string) (*secretCodec, error) {
+	if passphrase == "" {
+		return &secretCodec{}, nil
+	}
+	salt, err := base64.StdEncoding.DecodeString(d.Salt)
+	if err != nil || len(salt) == 0 {
+		return nil, errors.New("the document has no valid salt for the passphrase")
+	}
+	return newSecretCodec(passphrase, salt)
+}
+
+// Export exports the current configuration, the secrets are encrypted with
+// the passphrase, or redacted if the passphrase is empty
+func Export(passphrase string) (*Document, error) {
+	d := &Document{Version: Version}
+	if passphrase != "" {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		d.Salt = base64.StdEncoding.EncodeToString(salt)
+	}
+	codec, err := d.codec(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	s, err := currentState()
+	if err != nil {
+		return nil, err
+	}
+	d.Settings = make(map[string]string, len(s.settings))
+	for k, item := range s.settings {
+		if item.Flag == model.READONLY {
+			continue
+		}
+		if isSecretField(k) {
+			if d.Settings[k], err = codec.encode(item.Value); err != nil {
+				return nil, err
+			}
+		} else {
+			d.Settings[k] = item.Value
+		}
+	}
+	for _, section := range []struct {
+		dst     *[]Object
+		objects map[string]Object
+	}{
+		{&d.Users, s.users},
+		{&d.Storages, s.storages},
+		{&d.Metas, s.metas},
+		{&d.Shares, s.shares},
+	} {
+		for _, name := range sortedKeys(section.objects) {
+			o := deepCopy(section.objects[name])
+			if err := codec.encodeObject(o); err != nil {
+				return nil, err
+			}
+			*section.dst = append(*section.dst, o)
+		}
+	}
+	return d, nil
+}
+
+// state is the current configuration, the objects are keyed by their identity
+type state struct {
+	settings map[string]model.SettingItem
+	users    map[string]Object
+	storages map[string]Object
+	metas    map[string]Object
+	shares   map[string]Object
+
+	userModels    map[string]model.User
+	storageModels map[string]model.Storage
+	metaModels    map[string]model.Meta
+}
+
+func currentState() (*state, error) {
+	s := &state{
+		settings:      make(map[string]model.SettingItem),
+		users:         make(map[string]Object),
+		storages:      make(map[string]Object),
+		metas:         make(map[string]Object),
+		shares:        make(map[string]Object),
+		userModels:    make(map[string]model.User),
+		storageModels: make(map[string]model.Storage),
+		metaModels:    make(map[string]model.Meta),
+	}
+	items, err := op.GetSettingItems()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if !item.IsDeprecated() {
+			s.settings[item.Key] = item
+		}
+	}
+	users, _, err := op.GetUsers(1, -1)
+	if err != nil {
+		return nil, err
+	}
+	usernames := make(map[uint]string, len(users))
+	for _, u := range users {
+		o, err := userObject(u)
+		if err != nil {
+			return nil, err
+		}
+		s.users[u.Username] = o
+		s.userModels[u.Username] = u
+		usernames[u.ID] = u.Username
+	}
+	storages, _, err := db.GetStorages(1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range storages {
+		o, err := storageObject(st)
+		if err != nil {
+			return nil, err
+		}
+		s.storages[st.MountPath] = o
+		s.storageModels[st.MountPath] = st
+	}
+	metas, _, err := op.GetMetas(1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range metas {
+		o, err := toObject(m, "id")
+		if err != nil {
+			return nil, err
+		}
+		s.metas[m.Path] = o
+		s.metaModels[m.Path] = m
+	}
+	shares, _, err := db.GetSharings(1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, sh := range shares {
+		o, err := shareObject(sh, usernames[sh.CreatorId])
+		if err != nil {
+			return nil, err
+		}
+		s.shares[sh.ID] = o
+	}
+	return s, nil
+}
+
+func userObject(u model.User) (Object, error) {
+	o, err := toObject(u, "id", "password")
+	if err != nil {
+		return nil, err
+	}
+	o["pwd_hash"] = u.PwdHash
+	o["salt"] = u.Salt
+	if u.OtpSecret != "" {
+		o["otp_secret"] = u.OtpSecret
+	}
+	return o, nil
+}
+
+func storageObject(st model.Storage) (Object, error) {
+	o, err := toObject(st, "id", "status", "modified")
+	if err != nil {
+		return nil, err
+	}
+	var addition Object
+	if err := utils.Json.UnmarshalFromString(st.Addition, &addition); err == nil {
+		o["addition"] = addition
+	}
+	return o, nil
+}
+
+func shareObject(sh model.SharingDB, creator string) (Object, error) {
+	o, err := toObject(sh, "accessed")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	if err := utils.Json.UnmarshalFromString(sh.FilesRaw, &files); err != nil {
+		files = []string{}
+	}
+	o["files"] = normalize(files)
+	o["creator"] = creator
+	return o, nil
+}
+
+// toObject converts v to an Object by its json tags, without the omitted fields
+func toObject(v any, omit ...string) (Object, error) {
+	b, err := utils.Json.Marshal(v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var o Object
+	if err := utils.Json.Unmarshal(b, &o); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, k := range omit {
+		delete(o, k)
+	}
+	return o, nil
+}
+
+func fromObject(o Object, v any) error {
+	b, err := utils.Json.Marshal(o)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(utils.Json.Unmarshal(b, v))
+}
+
+// normalize converts v to the json form, so that the values decoded from yaml
+// can be compared with the exported ones, e.g. the numbers are float64
+func normalize(v any) any {
+	b, err := utils.Json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var ret any
+	if err := utils.Json.Unmarshal(b, &ret); err != nil {
+		return v
+	}
+	return ret
+}
+
+func deepCopy(o Object) Object {
+	ret, _ := normalize(o).(Object)
+	return ret
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
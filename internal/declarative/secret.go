@@ -0,0 +1,152 @@
+package declarative
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// Redacted replaces the secrets exported without a passphrase,
+	// the current values are kept when applying it
+	Redacted  = "<redacted>"
+	encPrefix = "enc:"
+)
+
+var secretWords = []string{
+	"password", "passwd", "pwd", "secret", "token", "cookie", "credential",
+	"private_key", "access_key", "api_key", "credit_key", "otp", "salt",
+}
+
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	// paths of the key files and the switches are not secrets
+	if strings.HasSuffix(name, "_path") || strings.HasPrefix(name, "disable_") || strings.Contains(name, "_disable_") {
+		return false
+	}
+	for _, w := range secretWords {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// secretCodec redacts the secrets, or encrypts them with AES-GCM
+// using a key derived from the passphrase if it's not empty
+type secretCodec struct {
+	aead cipher.AEAD
+}
+
+func newSecretCodec(passphrase string, salt []byte) (*secretCodec, error) {
+	if passphrase == "" {
+		return &secretCodec{}, nil
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &secretCodec{aead: aead}, nil
+}
+
+func (c *secretCodec) encode(v string) (string, error) {
+	if v == "" {
+		return v, nil
+	}
+	if c.aead == nil {
+		return Redacted, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", errors.WithStack(err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(v), nil)
+	return encPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// decode returns the plain secret, ok is false if it's redacted
+func (c *secretCodec) decode(v string) (plain string, ok bool, err error) {
+	if v == Redacted {
+		return "", false, nil
+	}
+	if !strings.HasPrefix(v, encPrefix) {
+		return v, true, nil
+	}
+	if c.aead == nil {
+		return "", false, errors.New("passphrase is required to decrypt the secrets")
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(v, encPrefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", false, errors.New("invalid encrypted secret")
+	}
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	b, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", false, errors.New("failed decrypt the secret, wrong passphrase?")
+	}
+	return string(b), true, nil
+}
+
+// encodeObject encodes the secret fields of o in place, including the storage addition
+func (c *secretCodec) encodeObject(o Object) error {
+	for k, v := range o {
+		switch v := v.(type) {
+		case string:
+			if isSecretField(k) {
+				s, err := c.encode(v)
+				if err != nil {
+					return err
+				}
+				o[k] = s
+			}
+		case Object:
+			if err := c.encodeObject(v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodeObject decodes the secret fields of o in place, the redacted ones are taken from cur,
+// or removed if cur doesn't have them
+func (c *secretCodec) decodeObject(o, cur Object) error {
+	for k, v := range o {
+		switch v := v.(type) {
+		case string:
+			if !isSecretField(k) {
+				continue
+			}
+			s, ok, err := c.decode(v)
+			if err != nil {
+				return errors.WithMessagef(err, "field [%s]", k)
+			}
+			if ok {
+				o[k] = s
+			} else if curV, exists := cur[k]; exists {
+				o[k] = curV
+			} else {
+				delete(o, k)
+			}
+		case Object:
+			curV, _ := cur[k].(Object)
+			if err := c.decodeObject(v, curV); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
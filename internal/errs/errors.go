@@ -22,6 +22,8 @@ var (
 	UnknownArchiveFormat      = errors.New("unknown archive format")
 	WrongArchivePassword      = errors.New("wrong archive password")
 	DriverExtractNotSupported = errors.New("driver extraction not supported")
+	MissingArchiveVolume      = errors.New("missing archive volume")
+	NestedArchiveTooLarge     = errors.New("nested archive too large")
 
 	WrongShareCode  = errors.New("wrong share code")
 	InvalidSharing  = errors.New("invalid sharing")
@@ -7,4 +7,7 @@ var (
 	EmptyPassword      = errors.New("password is empty")
 	WrongPassword      = errors.New("password is incorrect")
 	DeleteAdminOrGuest = errors.New("cannot delete admin or guest")
+	QuotaExceeded      = errors.New("quota exceeded")
+	TooManyRequests    = errors.New("too many requests")
+	TooManyConnections = errors.New("too many concurrent transfers")
 )
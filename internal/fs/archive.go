@@ -66,11 +66,23 @@ func (t *ArchiveDownloadTask) Run() error {
 	return nil
 }
 
+func (t *ArchiveDownloadTask) OnSucceeded() {
+	task.HandleHook(t, true)
+}
+
+func (t *ArchiveDownloadTask) OnFailed() {
+	task.HandleHook(t, false)
+}
+
 func (t *ArchiveDownloadTask) RunWithoutPushUploadTask() (*ArchiveContentUploadTask, error) {
 	srcObj, tool, ss, err := op.GetArchiveToolAndStream(t.Ctx(), t.SrcStorage, t.SrcActualPath, model.LinkArgs{})
 	if err != nil {
 		return nil, err
 	}
+	tool, ss, innerArgs, err := op.OpenNestedArchive(t.Ctx(), tool, ss, t.ArchiveInnerArgs)
+	if err != nil {
+		return nil, err
+	}
 	defer func() {
 		var e error
 		for _, s := range ss {
@@ -109,7 +121,7 @@ func (t *ArchiveDownloadTask) RunWithoutPushUploadTask() (*ArchiveContentUploadT
 	if err != nil {
 		return nil, err
 	}
-	err = tool.Decompress(ss, dir, t.ArchiveInnerArgs, decompressUp)
+	err = tool.Decompress(ss, dir, innerArgs, decompressUp)
 	if err != nil {
 		return nil, err
 	}
@@ -119,13 +131,14 @@ func (t *ArchiveDownloadTask) RunWithoutPushUploadTask() (*ArchiveContentUploadT
 			Creator: t.Creator,
 			ApiUrl:  t.ApiUrl,
 		},
-		ObjName:       baseName,
-		InPlace:       !t.PutIntoNewDir,
-		FilePath:      dir,
-		DstActualPath: t.DstActualPath,
-		dstStorage:    t.DstStorage,
-		DstStorageMp:  t.DstStorageMp,
-		overwrite:     t.Overwrite,
+		ObjName:        baseName,
+		InPlace:        !t.PutIntoNewDir,
+		FilePath:       dir,
+		DstActualPath:  t.DstActualPath,
+		dstStorage:     t.DstStorage,
+		DstStorageMp:   t.DstStorageMp,
+		overwrite:      t.Overwrite,
+		QuotaAccounted: t.QuotaAccounted,
 	}
 	return uploadTask, nil
 }
@@ -144,6 +157,8 @@ type ArchiveContentUploadTask struct {
 	finalized     bool
 	groupID       string
 	overwrite     bool
+	// the task is run for a wrapper driver, whose caller accounts the quota
+	QuotaAccounted bool `json:"quota_accounted,omitempty"`
 }
 
 func (t *ArchiveContentUploadTask) GetName() string {
@@ -169,10 +184,12 @@ func (t *ArchiveContentUploadTask) Run() error {
 
 func (t *ArchiveContentUploadTask) OnSucceeded() {
 	task_group.TransferCoordinator.Done(t.groupID, true)
+	task.HandleHook(t, true)
 }
 
 func (t *ArchiveContentUploadTask) OnFailed() {
 	task_group.TransferCoordinator.Done(t.groupID, false)
+	task.HandleHook(t, false)
 }
 
 func (t *ArchiveContentUploadTask) SetRetry(retry int, maxRetry int) {
@@ -195,7 +212,7 @@ func (t *ArchiveContentUploadTask) RunWithNextTaskCallback(f func(nextTask *Arch
 		nextDstActualPath := t.DstActualPath
 		if !t.InPlace {
 			nextDstActualPath = stdpath.Join(nextDstActualPath, t.ObjName)
-			err = op.MakeDir(t.Ctx(), t.dstStorage, nextDstActualPath)
+			err = op.MakeDir(withRecorded(t.Ctx()), t.dstStorage, nextDstActualPath)
 			if err != nil {
 				return err
 			}
@@ -227,14 +244,15 @@ func (t *ArchiveContentUploadTask) RunWithNextTaskCallback(f func(nextTask *Arch
 					Creator: t.Creator,
 					ApiUrl:  t.ApiUrl,
 				},
-				ObjName:       entry.Name(),
-				InPlace:       false,
-				FilePath:      nextFilePath,
-				DstActualPath: nextDstActualPath,
-				dstStorage:    t.dstStorage,
-				DstStorageMp:  t.DstStorageMp,
-				groupID:       t.groupID,
-				overwrite:     t.overwrite,
+				ObjName:        entry.Name(),
+				InPlace:        false,
+				FilePath:       nextFilePath,
+				DstActualPath:  nextDstActualPath,
+				dstStorage:     t.dstStorage,
+				DstStorageMp:   t.DstStorageMp,
+				groupID:        t.groupID,
+				overwrite:      t.overwrite,
+				QuotaAccounted: t.QuotaAccounted,
 			})
 			if err != nil {
 				es = stderrors.Join(es, err)
@@ -267,7 +285,7 @@ func (t *ArchiveContentUploadTask) RunWithNextTaskCallback(f func(nextTask *Arch
 		}
 		fs.Closers.Add(file)
 		t.status = "uploading"
-		err = op.Put(t.Ctx(), t.dstStorage, t.DstActualPath, fs, t.SetProgress, true)
+		err = putAccounted(withRecorded(t.Ctx()), taskUser(t.Ctx(), t.QuotaAccounted), t.dstStorage, t.DstActualPath, fs, t.SetProgress, true)
 		if err != nil {
 			return err
 		}
@@ -381,19 +399,28 @@ func archiveDecompress(ctx context.Context, srcObjPath, dstDirPath string, args
 		return nil, errors.WithMessage(err, "failed get dst storage")
 	}
 	if srcStorage.GetStorage() == dstStorage.GetStorage() {
-		err = op.ArchiveDecompress(ctx, srcStorage, srcObjActualPath, dstDirActualPath, args, lazyCache...)
+		// the size of the decompressed files is unknown, the user is reconciled once they are stored
+		user := ctxUser(ctx)
+		if err = op.CheckQuota(user, 0, 0); err != nil {
+			return nil, err
+		}
+		err = op.ArchiveDecompress(withQuotaAccounted(ctx), srcStorage, srcObjActualPath, dstDirActualPath, args, lazyCache...)
+		if err == nil && op.HasQuota(user) {
+			reconcileLater(user)
+		}
 		if !errors.Is(err, errs.NotImplement) {
 			return nil, err
 		}
 	}
 	tsk := &ArchiveDownloadTask{
 		TaskData: TaskData{
-			SrcStorage:    srcStorage,
-			DstStorage:    dstStorage,
-			SrcActualPath: srcObjActualPath,
-			DstActualPath: dstDirActualPath,
-			SrcStorageMp:  srcStorage.GetStorage().MountPath,
-			DstStorageMp:  dstStorage.GetStorage().MountPath,
+			SrcStorage:     srcStorage,
+			DstStorage:     dstStorage,
+			SrcActualPath:  srcObjActualPath,
+			DstActualPath:  dstDirActualPath,
+			SrcStorageMp:   srcStorage.GetStorage().MountPath,
+			DstStorageMp:   dstStorage.GetStorage().MountPath,
+			QuotaAccounted: quotaAccounted(ctx),
 		},
 		ArchiveDecompressArgs: args,
 	}
@@ -81,10 +81,12 @@ func (t *FileTransferTask) Run() error {
 
 func (t *FileTransferTask) OnSucceeded() {
 	task_group.TransferCoordinator.Done(t.groupID, true)
+	task.HandleHook(t, true)
 }
 
 func (t *FileTransferTask) OnFailed() {
 	task_group.TransferCoordinator.Done(t.groupID, false)
+	task.HandleHook(t, false)
 }
 
 func (t *FileTransferTask) SetRetry(retry int, maxRetry int) {
@@ -112,28 +114,27 @@ func transfer(ctx context.Context, taskType taskType, srcObjPath, dstDirPath str
 	}
 
 	if srcStorage.GetStorage() == dstStorage.GetStorage() {
-		if taskType == copy || taskType == merge {
-			err = op.Copy(ctx, srcStorage, srcObjActualPath, dstDirActualPath, lazyCache...)
-			if !errors.Is(err, errs.NotImplement) && !errors.Is(err, errs.NotSupport) {
-				return nil, err
-			}
-		} else {
-			err = op.Move(ctx, srcStorage, srcObjActualPath, dstDirActualPath, lazyCache...)
-			if !errors.Is(err, errs.NotImplement) && !errors.Is(err, errs.NotSupport) {
-				return nil, err
+		err = transferByDriver(ctx, ctxUser(ctx), taskType, srcStorage, srcObjActualPath, dstDirActualPath, func(ctx context.Context) error {
+			if taskType == copy || taskType == merge {
+				return op.Copy(ctx, srcStorage, srcObjActualPath, dstDirActualPath, lazyCache...)
 			}
+			return op.Move(ctx, srcStorage, srcObjActualPath, dstDirActualPath, lazyCache...)
+		})
+		if !errors.Is(err, errs.NotImplement) && !errors.Is(err, errs.NotSupport) {
+			return nil, err
 		}
 	}
 
 	// not in the same storage
 	t := &FileTransferTask{
 		TaskData: TaskData{
-			SrcStorage:    srcStorage,
-			DstStorage:    dstStorage,
-			SrcActualPath: srcObjActualPath,
-			DstActualPath: dstDirActualPath,
-			SrcStorageMp:  srcStorage.GetStorage().MountPath,
-			DstStorageMp:  dstStorage.GetStorage().MountPath,
+			SrcStorage:     srcStorage,
+			DstStorage:     dstStorage,
+			SrcActualPath:  srcObjActualPath,
+			DstActualPath:  dstDirActualPath,
+			SrcStorageMp:   srcStorage.GetStorage().MountPath,
+			DstStorageMp:   dstStorage.GetStorage().MountPath,
+			QuotaAccounted: quotaAccounted(ctx),
 		},
 		TaskType: taskType,
 	}
@@ -223,12 +224,13 @@ func (t *FileTransferTask) RunWithNextTaskCallback(f func(nextTask *FileTransfer
 						Creator: t.Creator,
 						ApiUrl:  t.ApiUrl,
 					},
-					SrcStorage:    t.SrcStorage,
-					DstStorage:    t.DstStorage,
-					SrcActualPath: stdpath.Join(t.SrcActualPath, obj.GetName()),
-					DstActualPath: dstActualPath,
-					SrcStorageMp:  t.SrcStorageMp,
-					DstStorageMp:  t.DstStorageMp,
+					SrcStorage:     t.SrcStorage,
+					DstStorage:     t.DstStorage,
+					SrcActualPath:  stdpath.Join(t.SrcActualPath, obj.GetName()),
+					DstActualPath:  dstActualPath,
+					SrcStorageMp:   t.SrcStorageMp,
+					DstStorageMp:   t.DstStorageMp,
+					QuotaAccounted: t.QuotaAccounted,
 				},
 			})
 			if err != nil {
@@ -253,8 +255,25 @@ func (t *FileTransferTask) RunWithNextTaskCallback(f func(nextTask *FileTransfer
 		return errors.WithMessagef(err, "failed get [%s] stream", t.SrcActualPath)
 	}
 	t.SetTotalBytes(ss.GetSize())
+	user := taskUser(t.Ctx(), t.QuotaAccounted)
+	var bytes, files int64
+	if op.HasQuota(user) {
+		replaced := getReplaced(t.Ctx(), user, t.DstStorage, t.DstActualPath, srcObj.GetName())
+		bytes, files = transferUsage(user, t.TaskType, stdpath.Join(t.SrcStorageMp, t.SrcActualPath),
+			stdpath.Join(t.DstStorageMp, t.DstActualPath), ss.GetSize(), replaced)
+	}
+	r, err := reserveQuota(user, bytes, files)
+	if err != nil {
+		_ = ss.Close()
+		return err
+	}
 	t.Status = "uploading"
-	return op.Put(t.Ctx(), t.DstStorage, t.DstActualPath, ss, t.SetProgress, true)
+	if err = op.Put(withRecorded(withQuotaAccounted(t.Ctx())), t.DstStorage, t.DstActualPath, ss, t.SetProgress, true); err != nil {
+		r.release()
+		return err
+	}
+	r.settle(bytes, files)
+	return nil
 }
 
 var (
@@ -3,11 +3,15 @@ package fs
 import (
 	"context"
 	"io"
+	stdpath "path"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/OpenListTeam/OpenList/v4/internal/audit"
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/metrics"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/task"
@@ -61,66 +65,92 @@ func Link(ctx context.Context, path string, args model.LinkArgs) (*model.Link, m
 }
 
 func MakeDir(ctx context.Context, path string, lazyCache ...bool) error {
-	err := makeDir(ctx, path, lazyCache...)
+	err := makeDir(withRecorded(ctx), path, lazyCache...)
 	if err != nil {
 		log.Errorf("failed make dir %s: %+v", path, err)
 	}
+	record(ctx, audit.OpMakeDir, "", path, 0, err)
 	return err
 }
 
 func Move(ctx context.Context, srcPath, dstDirPath string, lazyCache ...bool) (task.TaskExtensionInfo, error) {
-	req, err := transfer(ctx, move, srcPath, dstDirPath, lazyCache...)
+	req, err := transfer(withRecorded(ctx), move, srcPath, dstDirPath, lazyCache...)
 	if err != nil {
 		log.Errorf("failed move %s to %s: %+v", srcPath, dstDirPath, err)
 	}
+	record(ctx, audit.OpMove, srcPath, dstDirPath, 0, err)
 	return req, err
 }
 
 func Copy(ctx context.Context, srcObjPath, dstDirPath string, lazyCache ...bool) (task.TaskExtensionInfo, error) {
-	res, err := transfer(ctx, copy, srcObjPath, dstDirPath, lazyCache...)
+	res, err := transfer(withRecorded(ctx), copy, srcObjPath, dstDirPath, lazyCache...)
 	if err != nil {
 		log.Errorf("failed copy %s to %s: %+v", srcObjPath, dstDirPath, err)
 	}
+	record(ctx, audit.OpCopy, srcObjPath, dstDirPath, 0, err)
 	return res, err
 }
 
 func Merge(ctx context.Context, srcObjPath, dstDirPath string, lazyCache ...bool) (task.TaskExtensionInfo, error) {
-	res, err := transfer(ctx, merge, srcObjPath, dstDirPath, lazyCache...)
+	res, err := transfer(withRecorded(ctx), merge, srcObjPath, dstDirPath, lazyCache...)
 	if err != nil {
 		log.Errorf("failed merge %s to %s: %+v", srcObjPath, dstDirPath, err)
 	}
+	record(ctx, audit.OpMerge, srcObjPath, dstDirPath, 0, err)
 	return res, err
 }
 
 func Rename(ctx context.Context, srcPath, dstName string, lazyCache ...bool) error {
-	err := rename(ctx, srcPath, dstName, lazyCache...)
+	err := rename(withRecorded(ctx), srcPath, dstName, lazyCache...)
 	if err != nil {
 		log.Errorf("failed rename %s to %s: %+v", srcPath, dstName, err)
 	}
+	record(ctx, audit.OpRename, srcPath, stdpath.Join(stdpath.Dir(srcPath), dstName), 0, err)
 	return err
 }
 
 func Remove(ctx context.Context, path string) error {
-	err := remove(ctx, path)
+	err := remove(withRecorded(ctx), path)
 	if err != nil {
 		log.Errorf("failed remove %s: %+v", path, err)
 	}
+	record(ctx, audit.OpRemove, path, "", 0, err)
+	if err == nil {
+		emitFile(ctx, model.WebhookEventFileDeleted, path, 0)
+	}
+	return err
+}
+
+func SetModTime(ctx context.Context, path string, modified, created time.Time) error {
+	err := setModTime(withRecorded(ctx), path, modified, created)
+	if err != nil {
+		log.Errorf("failed set modification time of %s: %+v", path, err)
+	}
 	return err
 }
 
 func PutDirectly(ctx context.Context, dstDirPath string, file model.FileStreamer, lazyCache ...bool) error {
-	err := putDirectly(ctx, dstDirPath, file, lazyCache...)
+	err := putDirectly(withRecorded(ctx), dstDirPath, file, lazyCache...)
 	if err != nil {
 		log.Errorf("failed put %s: %+v", dstDirPath, err)
 	}
+	record(ctx, audit.OpUpload, "", stdpath.Join(dstDirPath, file.GetName()), file.GetSize(), err)
+	if err == nil {
+		emitFile(ctx, model.WebhookEventUploadCompleted, stdpath.Join(dstDirPath, file.GetName()), file.GetSize())
+		if !recorded(ctx) {
+			// the client uploads through the server, the puts of copies and wrapper drivers aren't counted
+			metrics.AddProxiedUp(file.GetSize())
+		}
+	}
 	return err
 }
 
 func PutAsTask(ctx context.Context, dstDirPath string, file model.FileStreamer) (task.TaskExtensionInfo, error) {
-	t, err := putAsTask(ctx, dstDirPath, file)
+	t, err := putAsTask(withRecorded(ctx), dstDirPath, file)
 	if err != nil {
 		log.Errorf("failed put %s: %+v", dstDirPath, err)
 	}
+	record(ctx, audit.OpUpload, "", stdpath.Join(dstDirPath, file.GetName()), file.GetSize(), err)
 	return t, err
 }
 
@@ -141,10 +171,11 @@ func ArchiveList(ctx context.Context, path string, args model.ArchiveListArgs) (
 }
 
 func ArchiveDecompress(ctx context.Context, srcObjPath, dstDirPath string, args model.ArchiveDecompressArgs, lazyCache ...bool) (task.TaskExtensionInfo, error) {
-	t, err := archiveDecompress(ctx, srcObjPath, dstDirPath, args, lazyCache...)
+	t, err := archiveDecompress(withRecorded(ctx), srcObjPath, dstDirPath, args, lazyCache...)
 	if err != nil {
 		log.Errorf("failed decompress [%s]%s: %+v", srcObjPath, args.InnerPath, err)
 	}
+	record(ctx, audit.OpDecompress, srcObjPath, dstDirPath, 0, err)
 	return t, err
 }
 
@@ -192,6 +223,12 @@ func Other(ctx context.Context, args model.FsOtherArgs) (interface{}, error) {
 }
 
 func PutURL(ctx context.Context, path, dstName, urlStr string) error {
+	err := putURL(withRecorded(ctx), path, dstName, urlStr)
+	record(ctx, audit.OpUploadURL, urlStr, stdpath.Join(path, dstName), 0, err)
+	return err
+}
+
+func putURL(ctx context.Context, path, dstName, urlStr string) error {
 	storage, dstDirActualPath, err := op.GetStorageAndActualPath(path)
 	if err != nil {
 		return errors.WithMessage(err, "failed get storage")
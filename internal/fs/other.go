@@ -2,6 +2,7 @@ package fs
 
 import (
 	"context"
+	"time"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
@@ -31,7 +32,26 @@ func remove(ctx context.Context, path string) error {
 	if err != nil {
 		return errors.WithMessage(err, "failed get storage")
 	}
-	return op.Remove(ctx, storage, actualPath)
+	user := quotaUserOf(ctxUser(ctx), path)
+	var obj model.Obj
+	if op.HasQuota(user) {
+		obj, _ = op.Get(ctx, storage, actualPath)
+	}
+	if err = op.Remove(withQuotaAccounted(ctx), storage, actualPath); err != nil {
+		return err
+	}
+	if obj != nil {
+		releaseQuota(user, obj)
+	}
+	return nil
+}
+
+func setModTime(ctx context.Context, path string, modified, created time.Time) error {
+	storage, actualPath, err := op.GetStorageAndActualPath(path)
+	if err != nil {
+		return errors.WithMessage(err, "failed get storage")
+	}
+	return op.SetModTime(ctx, storage, actualPath, modified, created)
 }
 
 func other(ctx context.Context, args model.FsOtherArgs) (interface{}, error) {
@@ -52,6 +72,8 @@ type TaskData struct {
 	DstStorage    driver.Driver `json:"-"`
 	SrcStorageMp  string        `json:"src_storage_mp"`
 	DstStorageMp  string        `json:"dst_storage_mp"`
+	// the task is run for a wrapper driver, whose caller accounts the quota
+	QuotaAccounted bool `json:"quota_accounted,omitempty"`
 }
 
 func (t *TaskData) GetStatus() string {
@@ -11,10 +11,12 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/metrics"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/task"
 	"github.com/OpenListTeam/OpenList/v4/internal/task_group"
+	"github.com/OpenListTeam/OpenList/v4/internal/webhook"
 	"github.com/OpenListTeam/tache"
 	"github.com/pkg/errors"
 )
@@ -24,6 +26,8 @@ type UploadTask struct {
 	storage          driver.Driver
 	dstDirActualPath string
 	file             model.FileStreamer
+	// the user the upload is accounted to, nil if it's not accounted
+	quotaUser *model.User
 }
 
 func (t *UploadTask) GetName() string {
@@ -38,15 +42,31 @@ func (t *UploadTask) Run() error {
 	t.ClearEndTime()
 	t.SetStartTime(time.Now())
 	defer func() { t.SetEndTime(time.Now()) }()
-	return op.Put(t.Ctx(), t.storage, t.dstDirActualPath, t.file, t.SetProgress, true)
+	replaced := getReplaced(t.Ctx(), t.quotaUser, t.storage, t.dstDirActualPath, t.file.GetName())
+	bytes, files := putUsage(t.file.GetSize(), replaced)
+	r, err := reserveQuota(t.quotaUser, bytes, files)
+	if err != nil {
+		return err
+	}
+	if err = op.Put(withRecorded(withQuotaAccounted(t.Ctx())), t.storage, t.dstDirActualPath, t.file, t.SetProgress, true); err != nil {
+		r.release()
+		return err
+	}
+	r.settle(putUsage(storedSize(t.Ctx(), t.storage, t.dstDirActualPath, t.file), replaced))
+	return nil
 }
 
 func (t *UploadTask) OnSucceeded() {
-	task_group.TransferCoordinator.Done(stdpath.Join(t.storage.GetStorage().MountPath, t.dstDirActualPath), true)
+	dstDirPath := stdpath.Join(t.storage.GetStorage().MountPath, t.dstDirActualPath)
+	task_group.TransferCoordinator.Done(dstDirPath, true)
+	webhook.EmitFile(t.Ctx(), model.WebhookEventUploadCompleted, stdpath.Join(dstDirPath, t.file.GetName()), t.file.GetSize())
+	metrics.AddProxiedUp(t.file.GetSize())
+	task.HandleHook(t, true)
 }
 
 func (t *UploadTask) OnFailed() {
 	task_group.TransferCoordinator.Done(stdpath.Join(t.storage.GetStorage().MountPath, t.dstDirActualPath), false)
+	task.HandleHook(t, false)
 }
 
 func (t *UploadTask) SetRetry(retry int, maxRetry int) {
@@ -68,6 +88,11 @@ func putAsTask(ctx context.Context, dstDirPath string, file model.FileStreamer)
 	if storage.Config().NoUpload {
 		return nil, errors.WithStack(errs.UploadNotSupported)
 	}
+	user := quotaUserOf(ctxUser(ctx), dstDirPath)
+	bytes, files := putUsage(file.GetSize(), getReplaced(ctx, user, storage, dstDirActualPath, file.GetName()))
+	if err := op.CheckQuota(user, bytes, files); err != nil {
+		return nil, err
+	}
 	if file.NeedStore() {
 		_, err := file.CacheFullAndWriter(nil, nil)
 		if err != nil {
@@ -85,6 +110,7 @@ func putAsTask(ctx context.Context, dstDirPath string, file model.FileStreamer)
 		storage:          storage,
 		dstDirActualPath: dstDirActualPath,
 		file:             file,
+		quotaUser:        user,
 	}
 	t.SetTotalBytes(file.GetSize())
 	task_group.TransferCoordinator.AddTask(dstDirPath, nil)
@@ -103,7 +129,7 @@ func putDirectly(ctx context.Context, dstDirPath string, file model.FileStreamer
 		_ = file.Close()
 		return errors.WithStack(errs.UploadNotSupported)
 	}
-	return op.Put(ctx, storage, dstDirActualPath, file, nil, lazyCache...)
+	return putAccounted(ctx, ctxUser(ctx), storage, dstDirActualPath, file, nil, lazyCache...)
 }
 
 func getDirectUploadInfo(ctx context.Context, tool, dstDirPath, dstName string, fileSize int64) (any, error) {
@@ -0,0 +1,288 @@
+package fs
+
+import (
+	"context"
+	stdpath "path"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// reconciling holds the ids of the users being reconciled, a scan can take a long time
+var reconciling sync.Map
+
+// ReconcileQuota recounts the files under the base path of the user, then the usage of its group
+func ReconcileQuota(ctx context.Context, u *model.User) (*model.QuotaUsage, error) {
+	if _, running := reconciling.LoadOrStore(u.ID, struct{}{}); running {
+		return nil, errors.Errorf("usage of user %s is being reconciled", u.Username)
+	}
+	defer reconciling.Delete(u.ID)
+	admin, err := op.GetAdmin()
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, conf.UserKey, admin)
+	root, err := Get(ctx, u.BasePath, &GetArgs{})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed get base path of user %s", u.Username)
+	}
+	usage := &model.QuotaUsage{Owner: model.UserQuotaOwner(u.ID)}
+	err = WalkFS(ctx, -1, u.BasePath, root, func(_ string, obj model.Obj) error {
+		if utils.IsCanceled(ctx) {
+			return ctx.Err()
+		}
+		if !obj.IsDir() {
+			usage.Bytes += max(obj.GetSize(), 0)
+			usage.Files++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	usage.ReconciledAt = &now
+	if err = op.SaveQuotaUsage(usage); err != nil {
+		return nil, err
+	}
+	if u.QuotaGroupID != 0 {
+		if err = op.RecountGroupUsage(u.QuotaGroupID); err != nil {
+			return usage, err
+		}
+	}
+	log.Infof("reconciled usage of user %s: %d bytes in %d files", u.Username, usage.Bytes, usage.Files)
+	return usage, nil
+}
+
+// ReconcileAllQuotas reconciles every user with a quota one by one
+func ReconcileAllQuotas(ctx context.Context) error {
+	users, err := op.GetQuotaUsers()
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		if _, err := ReconcileQuota(ctx, &users[i]); err != nil {
+			log.Errorf("failed reconcile usage of user %s: %+v", users[i].Username, err)
+		}
+		if utils.IsCanceled(ctx) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+type quotaAccountedKey struct{}
+
+// withQuotaAccounted marks the writes under ctx as accounted by the caller, so the fs calls
+// made by the wrapper drivers (alias, chunk, union, replicate...) don't account them again
+func withQuotaAccounted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, quotaAccountedKey{}, true)
+}
+
+func quotaAccounted(ctx context.Context) bool {
+	accounted, _ := ctx.Value(quotaAccountedKey{}).(bool)
+	return accounted
+}
+
+type quotaUserKey struct{}
+
+// WithQuotaUser accounts the writes under ctx to u without acting as u,
+// e.g. the S3 server has no user of its own and must not be audited as the quota owner
+func WithQuotaUser(ctx context.Context, u *model.User) context.Context {
+	return context.WithValue(ctx, quotaUserKey{}, u)
+}
+
+// ctxUser returns the user to account the writes of ctx to, nil if they are accounted already
+func ctxUser(ctx context.Context) *model.User {
+	if quotaAccounted(ctx) {
+		return nil
+	}
+	if u, ok := ctx.Value(quotaUserKey{}).(*model.User); ok {
+		return u
+	}
+	u, _ := ctx.Value(conf.UserKey).(*model.User)
+	return u
+}
+
+// quotaUserOf returns u if the path is inside its base path, the writes elsewhere are not accounted to it
+func quotaUserOf(u *model.User, path string) *model.User {
+	if u == nil || !utils.IsSubPath(u.BasePath, path) {
+		return nil
+	}
+	return u
+}
+
+// quotaReservation is the usage charged before a write, it's settled with the real usage once the write is done
+type quotaReservation struct {
+	u     *model.User
+	bytes int64
+	files int64
+}
+
+// reserveQuota charges the usage a write adds before it runs, the size of some streams is unknown until they are stored
+func reserveQuota(u *model.User, bytes, files int64) (*quotaReservation, error) {
+	bytes, files = max(bytes, 0), max(files, 0)
+	if err := op.ReserveQuota(u, bytes, files); err != nil {
+		return nil, err
+	}
+	return &quotaReservation{u: u, bytes: bytes, files: files}, nil
+}
+
+// settle charges the real usage of the write in place of the reserved one
+func (r *quotaReservation) settle(bytes, files int64) {
+	if bytes != r.bytes || files != r.files {
+		op.AddQuotaUsage(r.u, bytes-r.bytes, files-r.files)
+	}
+}
+
+// release gives the reservation back after a failed write
+func (r *quotaReservation) release() {
+	r.settle(0, 0)
+}
+
+// taskUser returns the user to account the writes of a task to, nil if the caller of the task accounts them
+func taskUser(ctx context.Context, accounted bool) *model.User {
+	if accounted {
+		return nil
+	}
+	return ctxUser(ctx)
+}
+
+// getReplaced returns the file a put to the dir would replace
+func getReplaced(ctx context.Context, u *model.User, storage driver.Driver, dstDirActualPath, name string) model.Obj {
+	if !op.HasQuota(u) {
+		return nil
+	}
+	obj, err := op.Get(ctx, storage, stdpath.Join(dstDirActualPath, name))
+	if err != nil || obj.IsDir() {
+		return nil
+	}
+	return obj
+}
+
+// storedSize returns the size of the file put, the stored file is asked if the size of the stream is still unknown
+func storedSize(ctx context.Context, storage driver.Driver, dstDirActualPath string, file model.FileStreamer) int64 {
+	if size := file.GetSize(); size >= 0 {
+		return size
+	}
+	obj, err := op.Get(ctx, storage, stdpath.Join(dstDirActualPath, file.GetName()))
+	if err != nil {
+		return 0
+	}
+	return obj.GetSize()
+}
+
+// putUsage returns the usage a put of size adds, less the one of the file replaced
+func putUsage(size int64, replaced model.Obj) (int64, int64) {
+	if replaced == nil {
+		return max(size, 0), 1
+	}
+	return max(size, 0) - max(replaced.GetSize(), 0), 0
+}
+
+// transferUsage returns the usage a copy or a move of a file of size from srcPath into dstDirPath adds,
+// a move gives the usage of the source back if it was in the base path of the user
+func transferUsage(u *model.User, taskType taskType, srcPath, dstDirPath string, size int64, replaced model.Obj) (int64, int64) {
+	var bytes, files int64
+	if utils.IsSubPath(u.BasePath, dstDirPath) {
+		bytes, files = putUsage(size, replaced)
+	}
+	if taskType == move && utils.IsSubPath(u.BasePath, srcPath) {
+		bytes -= max(size, 0)
+		files--
+	}
+	return bytes, files
+}
+
+// putAccounted puts the file like op.Put, the usage is reserved before and settled after.
+// Only the puts inside the base path of the user are accounted.
+func putAccounted(ctx context.Context, u *model.User, storage driver.Driver, dstDirActualPath string, file model.FileStreamer, up driver.UpdateProgress, lazyCache ...bool) error {
+	u = quotaUserOf(u, stdpath.Join(storage.GetStorage().MountPath, dstDirActualPath))
+	replaced := getReplaced(ctx, u, storage, dstDirActualPath, file.GetName())
+	bytes, files := putUsage(file.GetSize(), replaced)
+	r, err := reserveQuota(u, bytes, files)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = op.Put(withQuotaAccounted(ctx), storage, dstDirActualPath, file, up, lazyCache...); err != nil {
+		r.release()
+		return err
+	}
+	r.settle(putUsage(storedSize(ctx, storage, dstDirActualPath, file), replaced))
+	return nil
+}
+
+// PutAccounted puts the file like op.Put and charges it to the user in ctx, less the usage of the file replaced
+func PutAccounted(ctx context.Context, storage driver.Driver, dstDirActualPath string, file model.FileStreamer, up driver.UpdateProgress, lazyCache ...bool) error {
+	return putAccounted(ctx, ctxUser(ctx), storage, dstDirActualPath, file, up, lazyCache...)
+}
+
+// transferByDriver runs a copy or a move made by the driver of the storage and accounts it to the user,
+// the size of a directory is unknown so the user is reconciled once it's transferred
+func transferByDriver(ctx context.Context, u *model.User, taskType taskType, storage driver.Driver, srcActualPath, dstDirActualPath string, transfer func(ctx context.Context) error) error {
+	ctx = withQuotaAccounted(ctx)
+	if !op.HasQuota(u) {
+		return transfer(ctx)
+	}
+	srcObj, err := op.Get(ctx, storage, srcActualPath)
+	if err != nil {
+		// let the driver report it
+		return transfer(ctx)
+	}
+	mountPath := storage.GetStorage().MountPath
+	srcPath, dstDirPath := stdpath.Join(mountPath, srcActualPath), stdpath.Join(mountPath, dstDirActualPath)
+	if srcObj.IsDir() {
+		srcIn, dstIn := utils.IsSubPath(u.BasePath, srcPath), utils.IsSubPath(u.BasePath, dstDirPath)
+		if taskType == move && srcIn == dstIn {
+			return transfer(ctx)
+		}
+		if dstIn {
+			if err = op.CheckQuota(u, 0, 0); err != nil {
+				return err
+			}
+		}
+		if err = transfer(ctx); err != nil {
+			return err
+		}
+		reconcileLater(u)
+		return nil
+	}
+	replaced := getReplaced(ctx, u, storage, dstDirActualPath, srcObj.GetName())
+	bytes, files := transferUsage(u, taskType, srcPath, dstDirPath, srcObj.GetSize(), replaced)
+	r, err := reserveQuota(u, bytes, files)
+	if err != nil {
+		return err
+	}
+	if err = transfer(ctx); err != nil {
+		r.release()
+		return err
+	}
+	r.settle(bytes, files)
+	return nil
+}
+
+// releaseQuota gives back the usage of a removed object,
+// the size of a directory is unknown so the user is reconciled instead
+func releaseQuota(u *model.User, obj model.Obj) {
+	if !obj.IsDir() {
+		op.AddQuotaUsage(u, -max(obj.GetSize(), 0), -1)
+		return
+	}
+	reconcileLater(u)
+}
+
+func reconcileLater(u *model.User) {
+	go func() {
+		if _, err := ReconcileQuota(context.Background(), u); err != nil {
+			log.Warnf("failed reconcile usage of user %s: %+v", u.Username, err)
+		}
+	}()
+}
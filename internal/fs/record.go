@@ -0,0 +1,35 @@
+package fs
+
+import (
+	"context"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/audit"
+	"github.com/OpenListTeam/OpenList/v4/internal/webhook"
+)
+
+type recordedKey struct{}
+
+// withRecorded marks the operations under ctx as recorded by the caller, so the fs calls made by
+// the wrapper drivers (alias, union, replicate...) on their branches aren't audited or emitted again
+func withRecorded(ctx context.Context) context.Context {
+	return context.WithValue(ctx, recordedKey{}, true)
+}
+
+func recorded(ctx context.Context) bool {
+	r, _ := ctx.Value(recordedKey{}).(bool)
+	return r
+}
+
+// record writes the audit log of an operation unless it's made for another one
+func record(ctx context.Context, op, srcPath, dstPath string, bytes int64, err error) {
+	if !recorded(ctx) {
+		audit.Record(ctx, op, srcPath, dstPath, bytes, err)
+	}
+}
+
+// emitFile emits the webhook event of a file unless it's operated for another operation
+func emitFile(ctx context.Context, event, path string, size int64) {
+	if !recorded(ctx) {
+		webhook.EmitFile(ctx, event, path, size)
+	}
+}
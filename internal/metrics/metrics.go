@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const Namespace = "openlist"
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests by route group, method and status code.",
+	}, []string{"group", "method", "code"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests by route group.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"group"})
+
+	driverOps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "driver",
+		Name:      "operations_total",
+		Help:      "Number of driver operations.",
+	}, []string{"driver", "op"})
+	driverErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "driver",
+		Name:      "errors_total",
+		Help:      "Number of failed driver operations.",
+	}, []string{"driver", "op"})
+	driverDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "driver",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of driver operations.",
+		Buckets:   []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"driver", "op"})
+
+	proxiedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "proxy",
+		Name:      "bytes_total",
+		Help:      "Bytes proxied by the server, up for uploading and down for downloading.",
+	}, []string{"direction"})
+	proxiedUp   = proxiedBytes.WithLabelValues("up")
+	proxiedDown = proxiedBytes.WithLabelValues("down")
+
+	limiterWait = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "stream",
+		Name:      "limiter_wait_seconds_total",
+		Help:      "Time spent waiting for the stream limiters.",
+	}, []string{"limiter"})
+)
+
+var registry = prometheus.NewRegistry()
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		driverOps, driverErrors, driverDuration,
+		proxiedBytes, limiterWait,
+	)
+}
+
+// MustRegister registers the collectors which depend on other packages, e.g. tasks and caches
+func MustRegister(cs ...prometheus.Collector) {
+	registry.MustRegister(cs...)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func ObserveHTTP(group, method string, code int, duration time.Duration) {
+	httpRequests.WithLabelValues(group, method, strconv.Itoa(code)).Inc()
+	httpDuration.WithLabelValues(group).Observe(duration.Seconds())
+}
+
+// ObserveDriver records a driver operation started at start
+func ObserveDriver(driver, op string, start time.Time, err error) {
+	driverOps.WithLabelValues(driver, op).Inc()
+	if err != nil {
+		driverErrors.WithLabelValues(driver, op).Inc()
+	}
+	driverDuration.WithLabelValues(driver, op).Observe(time.Since(start).Seconds())
+}
+
+func AddProxiedUp(n int64) {
+	proxiedUp.Add(float64(n))
+}
+
+func AddLimiterWait(limiter string, d time.Duration) {
+	limiterWait.WithLabelValues(limiter).Add(d.Seconds())
+}
+
+// CountingResponseWriter counts the bytes written to the client as proxied down
+type CountingResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *CountingResponseWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	proxiedDown.Add(float64(n))
+	return n, err
+}
+
+func (w *CountingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
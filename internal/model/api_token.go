@@ -0,0 +1,76 @@
+package model
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// APITokenPrefix tells personal API tokens apart from the JWT of a login
+const APITokenPrefix = "olt_"
+
+const (
+	// ScopeRead allows listing, reading and downloading, every token has it
+	ScopeRead = "read"
+	// ScopeUpload allows uploading files and creating directories
+	ScopeUpload = "upload"
+	// ScopeTasks allows offline downloads, decompression and managing tasks
+	ScopeTasks = "tasks"
+	// ScopeAdmin allows everything the owner of the token can do
+	ScopeAdmin = "admin"
+)
+
+var APITokenScopes = []string{ScopeRead, ScopeUpload, ScopeTasks, ScopeAdmin}
+
+// APIToken is a personal access token, only the hash of the token is stored
+type APIToken struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	UserId uint   `json:"-" gorm:"index"`
+	Name   string `json:"name"`
+	Hash   string `json:"-" gorm:"uniqueIndex;size:64"`
+	// Hint is the end of the token to recognize it in the list
+	Hint string `json:"hint"`
+	// comma separated scopes
+	Scopes     string     `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func (t *APIToken) Validate() error {
+	if t.Name == "" {
+		return errors.New("name is empty")
+	}
+	scopes := t.ScopeList()
+	if len(scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, s := range scopes {
+		if !slices.Contains(APITokenScopes, s) {
+			return errors.Errorf("unknown scope [%s]", s)
+		}
+	}
+	return nil
+}
+
+func (t *APIToken) ScopeList() []string {
+	var scopes []string
+	for _, s := range strings.Split(t.Scopes, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// HasScope reports whether the token grants the scope
+func (t *APIToken) HasScope(scope string) bool {
+	scopes := t.ScopeList()
+	return scope == ScopeRead || slices.Contains(scopes, ScopeAdmin) || slices.Contains(scopes, scope)
+}
+
+func (t *APIToken) Expired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
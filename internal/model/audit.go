@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+type AuditLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+	Username  string    `json:"username" gorm:"index"`
+	IP        string    `json:"ip"`
+	// web, webdav, ftp, sftp, s3 or internal
+	Protocol  string `json:"protocol"`
+	Operation string `json:"operation" gorm:"index"`
+	SrcPath   string `json:"src_path"`
+	DstPath   string `json:"dst_path"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error"`
+	Bytes     int64  `json:"bytes"`
+}
+
+type AuditLogFilter struct {
+	Username  string `json:"username" form:"username"`
+	IP        string `json:"ip" form:"ip"`
+	Protocol  string `json:"protocol" form:"protocol"`
+	Operation string `json:"operation" form:"operation"`
+	// matches the source or destination path by prefix
+	Path    string `json:"path" form:"path"`
+	Success *bool  `json:"success" form:"success"`
+	// unix timestamp in seconds
+	Start int64 `json:"start" form:"start"`
+	End   int64 `json:"end" form:"end"`
+}
@@ -0,0 +1,8 @@
+package model
+
+// ChunkRef counts the references of the dedup files of a Chunk storage to a chunk of its pool
+type ChunkRef struct {
+	StorageID uint   `json:"storage_id" gorm:"primaryKey;autoIncrement:false"`
+	Hash      string `json:"hash" gorm:"primaryKey;size:64"`
+	Refs      int64  `json:"refs"`
+}
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	RuleActionDecompress = "decompress"
+	RuleActionRename     = "rename"
+	RuleActionMove       = "move"
+	RuleActionDelete     = "delete"
+)
+
+// RedactedPassword is returned instead of the password of a rule or the secret of a webhook,
+// updating them with it keeps the stored one
+const RedactedPassword = "<redacted>"
+
+// OfflineDownloadRule is evaluated against each file after an offline download has been transferred
+// to its destination. The first enabled rule whose Pattern matches the file name wins.
+type OfflineDownloadRule struct {
+	ID      uint   `json:"id" gorm:"primaryKey"`
+	Name    string `json:"name" binding:"required"`
+	UserId  uint   `json:"user_id"` // 0 means the rule applies to all users
+	Pattern string `json:"pattern" binding:"required"`
+	Action  string `json:"action" binding:"required"`
+	// Value depends on Action:
+	//   rename:     replacement of Pattern, supports $1 style submatches
+	//   move:       target folder, relative to the download destination unless starting with /
+	//   decompress: target folder, empty means the folder where the archive is
+	Value    string `json:"value"`
+	Password string `json:"password"` // archive password, only used by decompress, encrypted in the database
+	Order    int    `json:"order"`
+	Disabled bool   `json:"disabled"`
+
+	reg *regexp.Regexp
+}
+
+func (r *OfflineDownloadRule) Validate() error {
+	switch r.Action {
+	case RuleActionDecompress, RuleActionDelete:
+	case RuleActionRename, RuleActionMove:
+		if r.Value == "" {
+			return errors.Errorf("value is required by action [%s]", r.Action)
+		}
+	default:
+		return errors.Errorf("unknown action [%s]", r.Action)
+	}
+	reg, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return errors.WithMessage(err, "invalid pattern")
+	}
+	r.reg = reg
+	return nil
+}
+
+func (r *OfflineDownloadRule) Match(name string) bool {
+	if r.reg == nil && r.Validate() != nil {
+		return false
+	}
+	return r.reg.MatchString(name)
+}
+
+// NewName returns the name after applying a rename rule
+func (r *OfflineDownloadRule) NewName(name string) string {
+	if r.reg == nil && r.Validate() != nil {
+		return name
+	}
+	return r.reg.ReplaceAllString(name, r.Value)
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"strconv"
+	"time"
+)
+
+// QuotaGroup limits the total usage of the users in it
+type QuotaGroup struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	Name     string `json:"name" gorm:"unique" binding:"required"`
+	MaxBytes int64  `json:"max_bytes"` // 0 means unlimited
+	MaxFiles int64  `json:"max_files"` // 0 means unlimited
+}
+
+// QuotaUsage is the accounted usage of a user or a quota group
+type QuotaUsage struct {
+	Owner        string     `json:"owner" gorm:"primaryKey;size:64"`
+	Bytes        int64      `json:"bytes"`
+	Files        int64      `json:"files"`
+	ReconciledAt *time.Time `json:"reconciled_at"`
+}
+
+func UserQuotaOwner(id uint) string {
+	return "user:" + strconv.FormatUint(uint64(id), 10)
+}
+
+func GroupQuotaOwner(id uint) string {
+	return "group:" + strconv.FormatUint(uint64(id), 10)
+}
+
+// Quota is a limit with its current usage
+type Quota struct {
+	MaxBytes     int64      `json:"max_bytes"`
+	MaxFiles     int64      `json:"max_files"`
+	UsedBytes    int64      `json:"used_bytes"`
+	UsedFiles    int64      `json:"used_files"`
+	ReconciledAt *time.Time `json:"reconciled_at"`
+}
+
+// Allows reports whether the quota can take more bytes and files
+func (q *Quota) Allows(bytes, files int64) bool {
+	if q.MaxBytes > 0 && q.UsedBytes+bytes > q.MaxBytes {
+		return false
+	}
+	if q.MaxFiles > 0 && q.UsedFiles+files > q.MaxFiles {
+		return false
+	}
+	return true
+}
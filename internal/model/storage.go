@@ -17,6 +17,7 @@ type Storage struct {
 	Disabled        bool      `json:"disabled"` // if disabled
 	DisableIndex    bool      `json:"disable_index"`
 	EnableSign      bool      `json:"enable_sign"`
+	Prefetch        bool      `json:"prefetch"` // prefetch even if it's not enabled globally
 	Sort
 	Proxy
 }
@@ -34,6 +35,8 @@ type Proxy struct {
 	DownProxyURL string `json:"down_proxy_url"`
 	// Disable sign for DownProxyURL
 	DisableProxySign bool `json:"disable_proxy_sign"`
+	// Cache the blocks of proxied files on the disk
+	BlockCache bool `json:"block_cache"`
 }
 
 func (s *Storage) GetStorage() *Storage {
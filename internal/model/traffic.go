@@ -0,0 +1,32 @@
+package model
+
+// TrafficLimit limits the download and upload speed in KB/s, the concurrent
+// transfers and the requests per minute. Positive values limit, negative values
+// mean unlimited and zero means unset.
+type TrafficLimit struct {
+	DownloadSpeed int `json:"download_speed"`
+	UploadSpeed   int `json:"upload_speed"`
+	Connections   int `json:"connections"`
+	Requests      int `json:"requests"`
+}
+
+// Override returns l with the fields set in o replaced
+func (l TrafficLimit) Override(o TrafficLimit) TrafficLimit {
+	if o.DownloadSpeed != 0 {
+		l.DownloadSpeed = o.DownloadSpeed
+	}
+	if o.UploadSpeed != 0 {
+		l.UploadSpeed = o.UploadSpeed
+	}
+	if o.Connections != 0 {
+		l.Connections = o.Connections
+	}
+	if o.Requests != 0 {
+		l.Requests = o.Requests
+	}
+	return l
+}
+
+func (l TrafficLimit) Limited() bool {
+	return l.DownloadSpeed > 0 || l.UploadSpeed > 0 || l.Connections > 0 || l.Requests > 0
+}
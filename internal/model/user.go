@@ -6,10 +6,10 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/OpenListTeam/OpenList/v4/internal/cluster"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
 	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
 	"github.com/OpenListTeam/OpenList/v4/pkg/utils/random"
-	"github.com/OpenListTeam/go-cache"
 	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/pkg/errors"
 )
@@ -22,7 +22,7 @@ const (
 
 const StaticHashSalt = "https://github.com/alist-org/alist"
 
-var LoginCache = cache.NewMemCache[int]()
+var LoginCache = cluster.NewCounter("login")
 
 var (
 	DefaultLockDuration   = time.Minute * 5
@@ -59,6 +59,12 @@ type User struct {
 	OtpSecret  string `json:"-"`
 	SsoID      string `json:"sso_id"` // unique by sso platform
 	Authn      string `gorm:"type:text" json:"-"`
+	// storage quota under the base path, 0 means unlimited
+	QuotaBytes   int64 `json:"quota_bytes"`
+	QuotaFiles   int64 `json:"quota_files"`
+	QuotaGroupID uint  `json:"quota_group_id"`
+	// overrides the user_max_* settings
+	Limit TrafficLimit `json:"limit" gorm:"embedded;embeddedPrefix:limit_"`
 }
 
 func (u *User) IsGuest() bool {
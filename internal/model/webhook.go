@@ -0,0 +1,102 @@
+package model
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	WebhookEventUploadCompleted     = "upload.completed"
+	WebhookEventFileDeleted         = "file.deleted"
+	WebhookEventTaskSucceeded       = "task.succeeded"
+	WebhookEventTaskFailed          = "task.failed"
+	WebhookEventStorageStatusChange = "storage.status_changed"
+	WebhookEventShareAccessed       = "share.accessed"
+)
+
+var WebhookEvents = []string{
+	WebhookEventUploadCompleted,
+	WebhookEventFileDeleted,
+	WebhookEventTaskSucceeded,
+	WebhookEventTaskFailed,
+	WebhookEventStorageStatusChange,
+	WebhookEventShareAccessed,
+}
+
+type Webhook struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Name string `json:"name" binding:"required"`
+	URL  string `json:"url" binding:"required"`
+	// the deliveries are signed with the secret if it's not empty, it is redacted when read through the api
+	Secret string `json:"secret"`
+	// comma separated events, empty means all events
+	Events   string `json:"events"`
+	Disabled bool   `json:"disabled"`
+}
+
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return errors.WithMessage(err, "invalid url")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.Errorf("unsupported url scheme [%s]", u.Scheme)
+	}
+	for _, e := range w.EventList() {
+		if !isWebhookEvent(e) {
+			return errors.Errorf("unknown event [%s]", e)
+		}
+	}
+	return nil
+}
+
+func (w *Webhook) EventList() []string {
+	var events []string
+	for _, e := range strings.Split(w.Events, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Accept reports whether the event should be delivered to the webhook
+func (w *Webhook) Accept(event string) bool {
+	events := w.EventList()
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func isWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery records a delivery of an event to a webhook, updated after each attempt
+type WebhookDelivery struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	WebhookID  uint      `json:"webhook_id" gorm:"index"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+	Event      string    `json:"event"`
+	Payload    string    `json:"payload" gorm:"type:text"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error"`
+	Success    bool      `json:"success"`
+	// duration of the last attempt in milliseconds
+	Duration int64 `json:"duration"`
+}
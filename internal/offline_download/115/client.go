@@ -125,6 +125,9 @@ func (p *Cloud115) Status(task *tool.DownloadTask) (*tool.Status, error) {
 			s.Progress = t.Percent
 			s.Status = t.GetStatus()
 			s.Completed = t.IsDone()
+			if s.Completed {
+				s.Names = []string{t.Name}
+			}
 			s.TotalBytes = t.Size
 			if t.IsFailed() {
 				s.Err = fmt.Errorf(t.GetStatus())
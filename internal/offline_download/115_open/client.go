@@ -122,6 +122,9 @@ func (o *Open115) Status(task *tool.DownloadTask) (*tool.Status, error) {
 			s.Progress = float64(t.PercentDone)
 			s.Status = t.GetStatus()
 			s.Completed = t.IsDone()
+			if s.Completed {
+				s.Names = []string{t.Name}
+			}
 			s.TotalBytes = t.Size
 			if t.IsFailed() {
 				s.Err = fmt.Errorf(t.GetStatus())
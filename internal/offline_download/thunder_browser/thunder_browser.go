@@ -152,6 +152,9 @@ func (t *ThunderBrowser) Status(task *tool.DownloadTask) (*tool.Status, error) {
 			s.Progress = float64(t.Progress)
 			s.Status = t.Message
 			s.Completed = t.Phase == "PHASE_TYPE_COMPLETE"
+			if s.Completed {
+				s.Names = []string{t.FileName}
+			}
 			s.TotalBytes, err = strconv.ParseInt(t.FileSize, 10, 64)
 			if err != nil {
 				s.TotalBytes = 0
@@ -52,6 +52,12 @@ func AddURL(ctx context.Context, args *AddURLArgs) (task.TaskExtensionInfo, erro
 	if storage.Config().NoUpload {
 		return nil, errors.WithStack(errs.UploadNotSupported)
 	}
+	// the size is unknown before downloading, the transfer checks it again
+	if user, ok := ctx.Value(conf.UserKey).(*model.User); ok {
+		if err := op.CheckQuota(user, 0, 1); err != nil {
+			return nil, err
+		}
+	}
 	// check path is valid
 	obj, err := op.Get(ctx, storage, dstDirActualPath)
 	if err != nil {
@@ -18,6 +18,8 @@ type Status struct {
 	Completed  bool
 	Status     string
 	Err        error
+	// Names are the entries the task downloaded, reported by the tools downloading into the storage directly
+	Names []string
 }
 
 type Tool interface {
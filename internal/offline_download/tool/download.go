@@ -29,6 +29,8 @@ type DownloadTask struct {
 	GID               string       `json:"-"`
 	tool              Tool
 	callStatusRetried int
+	// the names reported by the tool once the download completed
+	downloadedNames []string
 }
 
 func (t *DownloadTask) Run() error {
@@ -165,6 +167,7 @@ func (t *DownloadTask) Update() (bool, error) {
 	}
 	// if download completed
 	if info.Completed {
+		t.downloadedNames = info.Names
 		err := t.Transfer()
 		return true, errors.WithMessage(err, "failed to transfer file")
 	}
@@ -182,7 +185,8 @@ func (t *DownloadTask) Transfer() error {
 		if t.TempDir != t.DstDirPath {
 			return transferObj(t.Ctx(), t.TempDir, t.DstDirPath, t.DeletePolicy)
 		}
-		return nil
+		// nothing is transferred, so the rules are applied here
+		return t.applyRulesInPlace()
 	}
 	if t.DeletePolicy == UploadDownloadStream {
 		dstStorage, dstDirActualPath, err := op.GetStorageAndActualPath(t.DstDirPath)
@@ -206,13 +210,25 @@ func (t *DownloadTask) Transfer() error {
 			Url:          t.Url,
 		}
 		tsk.SetTotalBytes(t.GetTotalBytes())
-		task_group.TransferCoordinator.AddTask(tsk.groupID, nil)
+		task_group.TransferCoordinator.AddTask(tsk.groupID, newPostDownloadRules(taskCreator, t.TempDir))
 		TransferTaskManager.Add(tsk)
 		return nil
 	}
 	return transferStd(t.Ctx(), t.TempDir, t.DstDirPath, t.DeletePolicy)
 }
 
+// applyRulesInPlace applies the rules to the entries the tool reports for the task,
+// the other entries of the dst dir may be written by other users or tasks meanwhile
+func (t *DownloadTask) applyRulesInPlace() error {
+	if len(t.downloadedNames) == 0 {
+		log.Warnf("tool %s reports no names for %s, the post download rules are skipped", t.tool.Name(), t.Url)
+		return nil
+	}
+	taskCreator, _ := t.Ctx().Value(conf.UserKey).(*model.User)
+	newPostDownloadRules(taskCreator, t.downloadedNames...).Run(t.Ctx(), t.DstDirPath)
+	return nil
+}
+
 func (t *DownloadTask) GetName() string {
 	return fmt.Sprintf("download %s to (%s)", t.Url, t.DstDirPath)
 }
@@ -221,4 +237,12 @@ func (t *DownloadTask) GetStatus() string {
 	return t.Status
 }
 
+func (t *DownloadTask) OnSucceeded() {
+	task.HandleHook(t, true)
+}
+
+func (t *DownloadTask) OnFailed() {
+	task.HandleHook(t, false)
+}
+
 var DownloadTaskManager *tache.Manager[*DownloadTask]
@@ -0,0 +1,126 @@
+package tool
+
+import (
+	"context"
+	stdpath "path"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/task_group"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// postDownloadRules is appended to the transfer group of an offline download,
+// the rules are evaluated after all the downloaded objs have been transferred
+type postDownloadRules struct {
+	creator *model.User
+	// names of the downloaded objs in the dst dir
+	names []string
+}
+
+var _ task_group.PostTransferAction = (*postDownloadRules)(nil)
+
+func newPostDownloadRules(creator *model.User, names ...string) *postDownloadRules {
+	return &postDownloadRules{creator: creator, names: names}
+}
+
+func (p *postDownloadRules) Run(ctx context.Context, dstPath string) {
+	rules, err := op.GetOfflineDownloadRulesForUser(p.creator)
+	if err != nil {
+		log.Errorf("failed get offline download rules: %+v", err)
+		return
+	}
+	if len(rules) == 0 {
+		return
+	}
+	if p.creator != nil {
+		ctx = context.WithValue(ctx, conf.UserKey, p.creator)
+	}
+	for _, name := range p.names {
+		applyRules(ctx, rules, dstPath, stdpath.Join(dstPath, name))
+	}
+}
+
+func applyRules(ctx context.Context, rules []model.OfflineDownloadRule, dstPath, objPath string) {
+	obj, err := fs.Get(ctx, objPath, &fs.GetArgs{NoLog: true})
+	if err != nil {
+		log.Warnf("failed get downloaded obj %s: %v", objPath, err)
+		return
+	}
+	if obj.IsDir() {
+		objs, err := fs.List(ctx, objPath, &fs.ListArgs{NoLog: true})
+		if err != nil {
+			log.Warnf("failed list downloaded dir %s: %v", objPath, err)
+			return
+		}
+		for _, o := range objs {
+			applyRules(ctx, rules, dstPath, stdpath.Join(objPath, o.GetName()))
+		}
+		return
+	}
+	for i := range rules {
+		if !rules[i].Match(obj.GetName()) {
+			continue
+		}
+		if err = applyRule(ctx, &rules[i], dstPath, objPath); err != nil {
+			log.Errorf("failed apply offline download rule [%s] on %s: %+v", rules[i].Name, objPath, err)
+		}
+		return
+	}
+}
+
+func applyRule(ctx context.Context, rule *model.OfflineDownloadRule, dstPath, objPath string) error {
+	dir, name := stdpath.Split(objPath)
+	dir = stdpath.Clean(dir)
+	switch rule.Action {
+	case model.RuleActionDelete:
+		return fs.Remove(ctx, objPath)
+	case model.RuleActionRename:
+		newName := rule.NewName(name)
+		if newName == "" || newName == name {
+			return nil
+		}
+		if strings.Contains(newName, "/") {
+			return errors.Errorf("illegal new name: %s", newName)
+		}
+		return fs.Rename(ctx, objPath, newName)
+	case model.RuleActionMove:
+		target := resolveRulePath(dstPath, rule.Value)
+		if target == dir {
+			return nil
+		}
+		if err := fs.MakeDir(ctx, target); err != nil {
+			return err
+		}
+		_, err := fs.Move(ctx, objPath, target)
+		return err
+	case model.RuleActionDecompress:
+		target := dir
+		if rule.Value != "" {
+			target = resolveRulePath(dstPath, rule.Value)
+			if err := fs.MakeDir(ctx, target); err != nil {
+				return err
+			}
+		}
+		_, err := fs.ArchiveDecompress(ctx, objPath, target, model.ArchiveDecompressArgs{
+			ArchiveInnerArgs: model.ArchiveInnerArgs{
+				ArchiveArgs: model.ArchiveArgs{Password: rule.Password},
+				InnerPath:   "/",
+			},
+			PutIntoNewDir: true,
+		})
+		return err
+	}
+	return errors.Errorf("unknown action [%s]", rule.Action)
+}
+
+func resolveRulePath(dstPath, p string) string {
+	if strings.HasPrefix(p, "/") {
+		return stdpath.Clean(p)
+	}
+	return stdpath.Join(dstPath, p)
+}
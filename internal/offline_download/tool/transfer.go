@@ -75,13 +75,19 @@ func (t *TransferTask) Run() error {
 				Mimetype: mimetype,
 				Closers:  utils.NewClosers(r),
 			}
-			return op.Put(t.Ctx(), t.DstStorage, t.DstActualPath, s, t.SetProgress)
+			return t.put(s)
 		}
 		return transferStdPath(t)
 	}
 	return transferObjPath(t)
 }
 
+// put uploads the file and charges it to the quota of the creator,
+// both the downloaded files and the ones transferred from a storage go through it
+func (t *TransferTask) put(file model.FileStreamer) error {
+	return fs.PutAccounted(t.Ctx(), t.DstStorage, t.DstActualPath, file, t.SetProgress)
+}
+
 func (t *TransferTask) GetName() string {
 	if t.DeletePolicy == UploadDownloadStream {
 		return fmt.Sprintf("upload [%s](%s) to [%s](%s)", t.SrcActualPath, t.Url, t.DstStorageMp, t.DstActualPath)
@@ -98,6 +104,7 @@ func (t *TransferTask) OnSucceeded() {
 		}
 	}
 	task_group.TransferCoordinator.Done(t.groupID, true)
+	task.HandleHook(t, true)
 }
 
 func (t *TransferTask) OnFailed() {
@@ -109,6 +116,7 @@ func (t *TransferTask) OnFailed() {
 		}
 	}
 	task_group.TransferCoordinator.Done(t.groupID, false)
+	task.HandleHook(t, false)
 }
 
 func (t *TransferTask) SetRetry(retry int, maxRetry int) {
@@ -135,7 +143,11 @@ func transferStd(ctx context.Context, tempDir, dstDirPath string, deletePolicy D
 		return err
 	}
 	taskCreator, _ := ctx.Value(conf.UserKey).(*model.User)
+	rules := newPostDownloadRules(taskCreator)
 	for _, entry := range entries {
+		rules.names = append(rules.names, entry.Name())
+	}
+	for i, entry := range entries {
 		t := &TransferTask{
 			TaskData: fs.TaskData{
 				TaskExtension: task.TaskExtension{
@@ -150,7 +162,11 @@ func transferStd(ctx context.Context, tempDir, dstDirPath string, deletePolicy D
 			groupID:      dstDirPath,
 			DeletePolicy: deletePolicy,
 		}
-		task_group.TransferCoordinator.AddTask(dstDirPath, nil)
+		if i == 0 {
+			task_group.TransferCoordinator.AddTask(dstDirPath, rules)
+		} else {
+			task_group.TransferCoordinator.AddTask(dstDirPath, nil)
+		}
 		TransferTaskManager.Add(t)
 	}
 	return nil
@@ -219,7 +235,7 @@ func transferStdFile(t *TransferTask) error {
 		Closers:  utils.NewClosers(rc),
 	}
 	t.SetTotalBytes(info.Size())
-	return op.Put(t.Ctx(), t.DstStorage, t.DstActualPath, s, t.SetProgress)
+	return t.put(s)
 }
 
 func removeStdTemp(t *TransferTask) {
@@ -246,7 +262,11 @@ func transferObj(ctx context.Context, tempDir, dstDirPath string, deletePolicy D
 		return errors.WithMessagef(err, "failed list src [%s] objs", tempDir)
 	}
 	taskCreator, _ := ctx.Value(conf.UserKey).(*model.User) // taskCreator is nil when convert failed
+	rules := newPostDownloadRules(taskCreator)
 	for _, obj := range objs {
+		rules.names = append(rules.names, obj.GetName())
+	}
+	for i, obj := range objs {
 		t := &TransferTask{
 			TaskData: fs.TaskData{
 				TaskExtension: task.TaskExtension{
@@ -263,7 +283,11 @@ func transferObj(ctx context.Context, tempDir, dstDirPath string, deletePolicy D
 			groupID:      dstDirPath,
 			DeletePolicy: deletePolicy,
 		}
-		task_group.TransferCoordinator.AddTask(dstDirPath, nil)
+		if i == 0 {
+			task_group.TransferCoordinator.AddTask(dstDirPath, rules)
+		} else {
+			task_group.TransferCoordinator.AddTask(dstDirPath, nil)
+		}
 		TransferTaskManager.Add(t)
 	}
 	return nil
@@ -331,7 +355,7 @@ func transferObjFile(t *TransferTask) error {
 		return errors.WithMessagef(err, "failed get [%s] stream", t.SrcActualPath)
 	}
 	t.SetTotalBytes(ss.GetSize())
-	return op.Put(t.Ctx(), t.DstStorage, t.DstActualPath, ss, t.SetProgress)
+	return t.put(ss)
 }
 
 func removeObjTemp(t *TransferTask) {
@@ -0,0 +1,80 @@
+package op
+
+import (
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils/random"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// apiTokenTouchInterval limits how often the last used time is written
+const apiTokenTouchInterval = time.Minute
+
+func hashAPIToken(token string) string {
+	return utils.HashData(utils.SHA256, []byte(token))
+}
+
+// CreateAPIToken generates the token, which is returned only once
+func CreateAPIToken(t *model.APIToken) (string, error) {
+	if err := t.Validate(); err != nil {
+		return "", err
+	}
+	if t.ExpiresAt != nil && t.ExpiresAt.Before(time.Now()) {
+		return "", errors.New("expiry is in the past")
+	}
+	token := model.APITokenPrefix + random.String(40)
+	t.Hash = hashAPIToken(token)
+	t.Hint = token[len(token)-4:]
+	t.CreatedAt = time.Now()
+	t.LastUsedAt = nil
+	if err := db.CreateAPIToken(t); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func GetAPITokensByUserId(userId uint, pageIndex, pageSize int) ([]model.APIToken, int64, error) {
+	return db.GetAPITokensByUserId(userId, pageIndex, pageSize)
+}
+
+// DeleteAPIToken revokes a token of the user
+func DeleteAPIToken(id, userId uint) error {
+	t, err := db.GetAPITokenById(id)
+	if err != nil {
+		return err
+	}
+	if t.UserId != userId {
+		return errors.New("api token not found")
+	}
+	return db.DeleteAPITokenById(id)
+}
+
+// ValidateAPIToken returns the token and its owner if the token is usable
+func ValidateAPIToken(token string) (*model.APIToken, *model.User, error) {
+	t, err := db.GetAPITokenByHash(hashAPIToken(token))
+	if err != nil {
+		return nil, nil, errors.New("invalid api token")
+	}
+	if t.Expired() {
+		return nil, nil, errors.New("api token is expired")
+	}
+	user, err := GetUserById(t.UserId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user.Disabled {
+		return nil, nil, errors.New("the owner of the api token is disabled")
+	}
+	now := time.Now()
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) > apiTokenTouchInterval {
+		t.LastUsedAt = &now
+		if err := db.UpdateAPITokenLastUsed(t.ID, now); err != nil {
+			log.Warnf("failed update last used time of api token %d: %+v", t.ID, err)
+		}
+	}
+	return t, user, nil
+}
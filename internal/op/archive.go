@@ -3,6 +3,7 @@ package op
 import (
 	"context"
 	stderrors "errors"
+	"fmt"
 	"io"
 	stdpath "path"
 	"strconv"
@@ -66,20 +67,18 @@ func GetArchiveToolAndStream(ctx context.Context, storage driver.Driver, path st
 	}
 
 	// Get archive tool
-	var partExt *tool.MultipartExtension
-	var t tool.Tool
-	ext := obj.GetName()
-	for {
-		var found bool
-		_, ext, found = strings.Cut(ext, ".")
-		if !found {
-			_ = l.Close()
-			return nil, nil, nil, errors.Errorf("failed get archive tool: the obj does not have an extension.")
-		}
-		partExt, t, err = tool.GetArchiveTool("." + ext)
-		if err == nil {
-			break
-		}
+	ext, partExt, t, err := tool.GetArchiveToolByName(obj.GetName())
+	if err != nil {
+		_ = l.Close()
+		return nil, nil, nil, errors.WithMessage(err, "failed get archive tool")
+	}
+	// other volumes must share the name of the first volume before the matched extension,
+	// and the rest of their names must have as many dots as the extension, so `foo.bar.z01`
+	// or `foobar.z01` is not taken as a volume of `foo.zip`
+	volumePrefix := strings.TrimSuffix(obj.GetName(), ext)
+	isVolume := func(name string) bool {
+		rest, ok := strings.CutPrefix(name, volumePrefix)
+		return ok && strings.HasPrefix(rest, ".") && strings.Count(rest, ".") == strings.Count(ext, ".")
 	}
 
 	// Get first part stream
@@ -100,6 +99,9 @@ func GetArchiveToolAndStream(ctx context.Context, storage driver.Driver, path st
 		return obj, t, ret, nil
 	}
 	for _, o := range objs {
+		if o.IsDir() || !isVolume(o.GetName()) {
+			continue
+		}
 		submatch := partExt.PartFileFormat.FindStringSubmatch(o.GetName())
 		if submatch == nil {
 			continue
@@ -140,15 +142,101 @@ func GetArchiveToolAndStream(ctx context.Context, storage driver.Driver, path st
 		closeAll(ret)
 		return nil, nil, nil, err
 	}
+	var missing []string
 	for i, ss1 := range ret {
 		if ss1 == nil {
-			closeAll(ret)
-			return nil, nil, nil, errors.Errorf("failed merge [%s] parts, missing part %d", path, i)
+			missing = append(missing, volumeName(obj.GetName(), partExt, i))
 		}
 	}
+	if len(missing) > 0 {
+		closeAll(ret)
+		return nil, nil, nil, errors.WithMessagef(errs.MissingArchiveVolume, "failed merge [%s] volumes, missing: %s",
+			path, strings.Join(missing, ", "))
+	}
 	return obj, t, ret, nil
 }
 
+// OpenNestedArchive descends into the archives nested in args.InnerPath (see tool.NestedArchiveSuffix),
+// returns the tool and the streams of the innermost archive and the inner path relative to it.
+// Tools need random access, so each nested archive is extracted to a temp file on every call, which is
+// removed once it is closed, and the archives larger than the nested_archive_max_size setting are refused.
+// Closing it closes the outer streams as well. ss is closed if it fails.
+func OpenNestedArchive(ctx context.Context, t tool.Tool, ss []*stream.SeekableStream, args model.ArchiveInnerArgs) (tool.Tool, []*stream.SeekableStream, model.ArchiveInnerArgs, error) {
+	for {
+		archivePath, rest, ok := tool.SplitNestedPath(args.InnerPath)
+		if !ok {
+			return t, ss, args, nil
+		}
+		name := stdpath.Base(archivePath)
+		_, _, nt, err := tool.GetArchiveToolByName(name)
+		if err != nil {
+			closeStreams(ss)
+			return nil, nil, args, errors.WithMessagef(err, "failed get tool of nested archive [%s]", archivePath)
+		}
+		rc, size, err := t.Extract(ss, model.ArchiveInnerArgs{ArchiveArgs: args.ArchiveArgs, InnerPath: archivePath})
+		if err != nil {
+			closeStreams(ss)
+			return nil, nil, args, errors.WithMessagef(err, "failed extract nested archive [%s]", archivePath)
+		}
+		if maxSize := nestedArchiveMaxSize(); maxSize > 0 && (size < 0 || size > maxSize) {
+			_ = rc.Close()
+			closeStreams(ss)
+			return nil, nil, args, errors.WithMessagef(errs.NestedArchiveTooLarge, "[%s] is %d bytes, the limit is %d bytes", archivePath, size, maxSize)
+		}
+		fs := &stream.FileStream{
+			Ctx:    ctx,
+			Obj:    &model.Object{Name: name, Size: size},
+			Reader: rc,
+		}
+		fs.Add(rc)
+		for _, s := range ss {
+			fs.Add(s)
+		}
+		nss := &stream.SeekableStream{FileStream: fs}
+		if _, err = nss.CacheFullAndWriter(nil, nil); err != nil {
+			_ = fs.Close()
+			return nil, nil, args, errors.WithMessagef(err, "failed cache nested archive [%s]", archivePath)
+		}
+		t, ss = nt, []*stream.SeekableStream{nss}
+		args.InnerPath = rest
+	}
+}
+
+// nestedArchiveMaxSize returns the max size in bytes of a nested archive, 0 means no limit
+func nestedArchiveMaxSize() int64 {
+	item, _ := GetSettingItemByKey(conf.NestedArchiveMaxSize)
+	if item == nil {
+		return 0
+	}
+	mb, err := strconv.ParseInt(item.Value, 10, 64)
+	if err != nil || mb <= 0 {
+		return 0
+	}
+	return mb * 1024 * 1024
+}
+
+func closeStreams(ss []*stream.SeekableStream) {
+	var e error
+	for _, s := range ss {
+		e = stderrors.Join(e, s.Close())
+	}
+	if e != nil {
+		log.Errorf("failed to close file streamer, %v", e)
+	}
+}
+
+// volumeName guesses the name of the volume at partIdx (0 is the first volume) from the name of the first volume,
+// falls back to the volume number if the first volume is not named in the same format as the others (e.g. .zip and .z01)
+func volumeName(firstName string, partExt *tool.MultipartExtension, partIdx int) string {
+	num := partIdx + partExt.SecondPartIndex - 1
+	loc := partExt.PartFileFormat.FindStringSubmatchIndex(firstName)
+	if len(loc) < 4 || loc[2] < 0 {
+		return fmt.Sprintf("volume %d", num)
+	}
+	width := strconv.Itoa(loc[3] - loc[2])
+	return firstName[:loc[2]] + fmt.Sprintf("%0"+width+"d", num) + firstName[loc[3]:]
+}
+
 func getArchiveMeta(ctx context.Context, storage driver.Driver, path string, args model.ArchiveMetaArgs) (model.Obj, *model.ArchiveMetaProvider, error) {
 	storageAr, ok := storage.(driver.ArchiveReader)
 	if ok {
@@ -255,8 +343,9 @@ func ListArchive(ctx context.Context, storage driver.Driver, path string, args m
 }
 
 func _listArchive(ctx context.Context, storage driver.Driver, path string, args model.ArchiveListArgs) (model.Obj, []model.Obj, error) {
+	nested := tool.IsNestedPath(args.InnerPath)
 	storageAr, ok := storage.(driver.ArchiveReader)
-	if ok {
+	if ok && !nested {
 		obj, err := GetUnwrap(ctx, storage, path)
 		if err != nil {
 			return nil, nil, errors.WithMessage(err, "failed to get file")
@@ -273,16 +362,21 @@ func _listArchive(ctx context.Context, storage driver.Driver, path string, args
 	if err != nil {
 		return nil, nil, err
 	}
-	defer func() {
-		var e error
-		for _, s := range ss {
-			e = stderrors.Join(e, s.Close())
-		}
-		if e != nil {
-			log.Errorf("failed to close file streamer, %v", e)
+	t, ss, innerArgs, err := OpenNestedArchive(ctx, t, ss, args.ArchiveInnerArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeStreams(ss)
+	files, err := t.List(ss, innerArgs)
+	if nested && errors.Is(err, errs.NotSupport) {
+		// the meta of nested archives is not cached, get the children from the tree directly
+		var meta model.ArchiveMeta
+		meta, err = t.GetMeta(ss, innerArgs.ArchiveArgs)
+		if err != nil {
+			return nil, nil, err
 		}
-	}()
-	files, err := t.List(ss, args.ArchiveInnerArgs)
+		files, err = getChildrenFromArchiveMeta(meta, innerArgs.InnerPath)
+	}
 	return obj, files, err
 }
 
@@ -385,6 +479,15 @@ func ArchiveGet(ctx context.Context, storage driver.Driver, path string, args mo
 	}
 
 	innerDir, name := stdpath.Split(args.InnerPath)
+	// the root of a nested archive, e.g. /a/b.zip!
+	archiveName, nestedRoot := strings.CutSuffix(name, tool.NestedArchiveSuffix)
+	if nestedRoot {
+		if _, _, _, e := tool.GetArchiveToolByName(archiveName); e == nil {
+			name = archiveName
+		} else {
+			nestedRoot = false
+		}
+	}
 	args.InnerPath = strings.TrimSuffix(innerDir, "/")
 	files, err := ListArchive(ctx, storage, path, args)
 	if err != nil {
@@ -392,6 +495,15 @@ func ArchiveGet(ctx context.Context, storage driver.Driver, path string, args mo
 	}
 	for _, f := range files {
 		if f.GetName() == name {
+			if nestedRoot && !f.IsDir() {
+				return af, &model.Object{
+					Name:     f.GetName(),
+					Path:     f.GetPath(),
+					Size:     f.GetSize(),
+					Modified: f.ModTime(),
+					IsFolder: true,
+				}, nil
+			}
 			return af, f, nil
 		}
 	}
@@ -445,7 +557,7 @@ func DriverExtract(ctx context.Context, storage driver.Driver, path string, args
 
 func driverExtract(ctx context.Context, storage driver.Driver, path string, args model.ArchiveInnerArgs) (*objWithLink, error) {
 	storageAr, ok := storage.(driver.ArchiveReader)
-	if !ok {
+	if !ok || tool.IsNestedPath(args.InnerPath) {
 		return nil, errs.DriverExtractNotSupported
 	}
 	archiveFile, extracted, err := ArchiveGet(ctx, storage, path, model.ArchiveListArgs{
@@ -484,6 +596,10 @@ func InternalExtract(ctx context.Context, storage driver.Driver, path string, ar
 	if err != nil {
 		return nil, 0, err
 	}
+	t, ss, args, err = OpenNestedArchive(ctx, t, ss, args)
+	if err != nil {
+		return nil, 0, err
+	}
 	rc, size, err := t.Extract(ss, args)
 	if err != nil {
 		var e error
@@ -503,6 +619,10 @@ func ArchiveDecompress(ctx context.Context, storage driver.Driver, srcPath, dstD
 	if storage.Config().CheckStatus && storage.GetStorage().Status != WORK {
 		return errors.WithMessagef(errs.StorageNotInit, "storage status: %s", storage.GetStorage().Status)
 	}
+	if tool.IsNestedPath(args.InnerPath) {
+		// nested archives are only supported by the tools
+		return errs.NotImplement
+	}
 	srcPath = utils.FixAndCleanPath(srcPath)
 	dstDirPath = utils.FixAndCleanPath(dstDirPath)
 	srcObj, err := GetUnwrap(ctx, storage, srcPath)
@@ -0,0 +1,36 @@
+package op
+
+import (
+	"fmt"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	log "github.com/sirupsen/logrus"
+)
+
+// useBlockCache makes the reads of the link go through the disk block cache,
+// the URL is kept so that redirecting still works
+func useBlockCache(storage driver.Driver, path string, file model.Obj, link *model.Link) {
+	c := stream.DefaultBlockCache
+	if c == nil || !storage.GetStorage().BlockCache {
+		return
+	}
+	size := file.GetSize()
+	if size <= 0 {
+		return
+	}
+	if _, ok := link.RangeReader.(*model.FileRangeReader); ok {
+		// already on the local disk
+		return
+	}
+	rr, err := stream.GetRangeReaderFromLink(size, link)
+	if err != nil {
+		log.Warnf("skip block cache for %s: %v", path, err)
+		return
+	}
+	key := fmt.Sprintf("%s|%d|%d", Key(storage, path), size, file.ModTime().UnixNano())
+	link.RangeReader = c.RangeReader(key, size, rr)
+	link.Concurrency = 0
+	link.PartSize = 0
+}
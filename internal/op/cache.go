@@ -40,13 +40,21 @@ func Key(storage driver.Driver, path string) string {
 // if it's a file, remove its link from linkCache.
 func (cm *CacheManager) updateDirectoryObject(storage driver.Driver, dirPath string, oldObj model.Obj, newObj model.Obj) {
 	key := Key(storage, dirPath)
+	var e cacheEvent
+	defer func() { publishCacheEvent(e) }()
 	if !oldObj.IsDir() {
-		cm.linkCache.DeleteKey(stdpath.Join(key, oldObj.GetName()))
-		cm.linkCache.DeleteKey(stdpath.Join(key, newObj.GetName()))
+		e.Links = []string{stdpath.Join(key, oldObj.GetName()), stdpath.Join(key, newObj.GetName())}
+		for _, k := range e.Links {
+			cm.linkCache.DeleteKey(k)
+		}
 	}
 	if storage.Config().NoCache {
 		return
 	}
+	e.Dirs = []string{key}
+	if oldObj.IsDir() {
+		e.Trees = []string{stdpath.Join(key, oldObj.GetName())}
+	}
 
 	if cache, exist := cm.dirCache.Get(key); exist {
 		if oldObj.IsDir() {
@@ -61,7 +69,9 @@ func (cm *CacheManager) addDirectoryObject(storage driver.Driver, dirPath string
 	if storage.Config().NoCache {
 		return
 	}
-	cache, exist := cm.dirCache.Get(Key(storage, dirPath))
+	key := Key(storage, dirPath)
+	publishCacheEvent(cacheEvent{Dirs: []string{key}})
+	cache, exist := cm.dirCache.Get(key)
 	if exist {
 		cache.UpdateObject(newObj.GetName(), newObj)
 	}
@@ -72,7 +82,9 @@ func (cm *CacheManager) DeleteDirectoryTree(storage driver.Driver, dirPath strin
 	if storage.Config().NoCache {
 		return
 	}
-	cm.deleteDirectoryTree(Key(storage, dirPath))
+	key := Key(storage, dirPath)
+	cm.deleteDirectoryTree(key)
+	publishCacheEvent(cacheEvent{Trees: []string{key}})
 }
 func (cm *CacheManager) deleteDirectoryTree(key string) {
 	if dirCache, exists := cm.dirCache.Take(key); exists {
@@ -89,7 +101,16 @@ func (cm *CacheManager) DeleteDirectory(storage driver.Driver, dirPath string) {
 	if storage.Config().NoCache {
 		return
 	}
-	cm.dirCache.Delete(Key(storage, dirPath))
+	key := Key(storage, dirPath)
+	cm.dirCache.Delete(key)
+	publishCacheEvent(cacheEvent{Dirs: []string{key}})
+}
+
+// remove the cached link of a file
+func (cm *CacheManager) deleteLink(storage driver.Driver, path string) {
+	key := Key(storage, path)
+	cm.linkCache.DeleteKey(key)
+	publishCacheEvent(cacheEvent{Links: []string{key}})
 }
 
 // remove object from dirCache.
@@ -97,13 +118,20 @@ func (cm *CacheManager) DeleteDirectory(storage driver.Driver, dirPath string) {
 // if it's a file, remove its link from linkCache.
 func (cm *CacheManager) removeDirectoryObject(storage driver.Driver, dirPath string, obj model.Obj) {
 	key := Key(storage, dirPath)
+	var e cacheEvent
+	defer func() { publishCacheEvent(e) }()
 	if !obj.IsDir() {
-		cm.linkCache.DeleteKey(stdpath.Join(key, obj.GetName()))
+		e.Links = []string{stdpath.Join(key, obj.GetName())}
+		cm.linkCache.DeleteKey(e.Links[0])
 	}
 
 	if storage.Config().NoCache {
 		return
 	}
+	e.Dirs = []string{key}
+	if obj.IsDir() {
+		e.Trees = []string{stdpath.Join(key, obj.GetName())}
+	}
 	if cache, exist := cm.dirCache.Get(key); exist {
 		if obj.IsDir() {
 			cm.deleteDirectoryTree(stdpath.Join(key, obj.GetName()))
@@ -125,6 +153,7 @@ func (cm *CacheManager) GetUser(username string) (*model.User, bool) {
 // remove user data from cache
 func (cm *CacheManager) DeleteUser(username string) {
 	cm.userCache.Delete(username)
+	publishCacheEvent(cacheEvent{Users: []string{username}})
 }
 
 // caches setting
@@ -171,6 +200,18 @@ func (cm *CacheManager) GetStorageDetails(storage driver.Driver) (*model.Storage
 
 func (cm *CacheManager) InvalidateStorageDetails(storage driver.Driver) {
 	cm.detailCache.Delete(storage.GetStorage().MountPath)
+	publishCacheEvent(cacheEvent{Details: []string{storage.GetStorage().MountPath}})
+}
+
+// hit/miss statistics of each cache
+func (cm *CacheManager) Stats() map[string]cache.Stats {
+	return map[string]cache.Stats{
+		"dir":     cm.dirCache.Stats(),
+		"link":    cm.linkCache.Stats(),
+		"user":    cm.userCache.Stats(),
+		"setting": cm.settingCache.Stats(),
+		"detail":  cm.detailCache.Stats(),
+	}
 }
 
 // clears all caches
@@ -0,0 +1,127 @@
+package op
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/cluster"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const cacheChannel = "cache"
+
+// cacheEvent tells the other nodes of a cluster which cached entries are stale.
+// Directory listings are dropped instead of patched, so the payload stays small.
+type cacheEvent struct {
+	Dirs     []string `json:"dirs,omitempty"`
+	Trees    []string `json:"trees,omitempty"`
+	Links    []string `json:"links,omitempty"`
+	Users    []string `json:"users,omitempty"`
+	Details  []string `json:"details,omitempty"`
+	Metas    []string `json:"metas,omitempty"`
+	Sharings []string `json:"sharings,omitempty"`
+	Settings bool     `json:"settings,omitempty"`
+	Webhooks bool     `json:"webhooks,omitempty"`
+	// Storage is the id of a storage created, updated or deleted
+	Storage uint `json:"storage,omitempty"`
+}
+
+func publishCacheEvent(e cacheEvent) {
+	if cluster.Enabled() {
+		cluster.Publish(cacheChannel, e)
+	}
+}
+
+func init() {
+	cluster.Subscribe(cacheChannel, func(payload json.RawMessage) {
+		var e cacheEvent
+		if err := json.Unmarshal(payload, &e); err != nil {
+			log.Warnf("invalid cache event: %+v", err)
+			return
+		}
+		applyCacheEvent(e)
+	})
+}
+
+// applyCacheEvent only touches the local caches, it never publishes again
+func applyCacheEvent(e cacheEvent) {
+	for _, k := range e.Dirs {
+		Cache.dirCache.Delete(k)
+	}
+	for _, k := range e.Trees {
+		Cache.deleteDirectoryTree(k)
+	}
+	for _, k := range e.Links {
+		Cache.linkCache.DeleteKey(k)
+	}
+	for _, k := range e.Users {
+		Cache.userCache.Delete(k)
+		if u := adminUser; u != nil && u.Username == k {
+			adminUser = nil
+		}
+		if u := guestUser; u != nil && u.Username == k {
+			guestUser = nil
+		}
+	}
+	for _, k := range e.Details {
+		Cache.detailCache.Delete(k)
+	}
+	for _, k := range e.Metas {
+		metaCache.Del(k)
+	}
+	for _, k := range e.Sharings {
+		sharingCache.Del(k)
+	}
+	if e.Settings {
+		settingCacheUpdate()
+	}
+	if e.Webhooks {
+		clearWebhooksCache()
+	}
+	if e.Storage != 0 {
+		if err := reloadStorage(context.Background(), e.Storage); err != nil {
+			log.Errorf("failed reload storage [%d] changed by another node: %+v", e.Storage, err)
+		}
+	}
+}
+
+func getStorageById(id uint) (driver.Driver, bool) {
+	var found driver.Driver
+	storagesMap.Range(func(_ string, d driver.Driver) bool {
+		if d.GetStorage().ID == id {
+			found = d
+			return false
+		}
+		return true
+	})
+	return found, found != nil
+}
+
+// reloadStorage makes the loaded storage match its database record
+func reloadStorage(ctx context.Context, id uint) error {
+	if d, ok := getStorageById(id); ok {
+		if err := d.Drop(ctx); err != nil {
+			log.Warnf("failed drop storage [%s]: %+v", d.GetStorage().MountPath, err)
+		}
+		storagesMap.Delete(d.GetStorage().MountPath)
+		storageStatuses.Delete(d.GetStorage().MountPath)
+		Cache.deleteDirectoryTree(Key(d, "/"))
+		Cache.detailCache.Delete(d.GetStorage().MountPath)
+		go callStorageHooks("del", d)
+	}
+	storage, err := db.GetStorageById(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if storage.Disabled {
+		return nil
+	}
+	return LoadStorage(ctx, *storage)
+}
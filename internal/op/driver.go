@@ -139,6 +139,18 @@ func getMainItems(config driver.Config) []driver.Item {
 		Type:    conf.TypeSelect,
 		Options: "front,back",
 	})
+	items = append(items, driver.Item{
+		Name:    "prefetch",
+		Type:    conf.TypeBool,
+		Default: "false",
+		Help:    "keep the hot directories and links of this storage warm in cache, even if prefetch is not enabled globally",
+	})
+	items = append(items, driver.Item{
+		Name:    "block_cache",
+		Type:    conf.TypeBool,
+		Default: "false",
+		Help:    "cache the blocks of proxied files on the disk, need the block cache to be enabled in the config",
+	})
 	items = append(items, driver.Item{
 		Name:     "disable_index",
 		Type:     conf.TypeBool,
@@ -9,6 +9,7 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/metrics"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/stream"
 	"github.com/OpenListTeam/OpenList/v4/pkg/singleflight"
@@ -29,6 +30,7 @@ func List(ctx context.Context, storage driver.Driver, path string, args model.Li
 	log.Debugf("op.List %s", path)
 	key := Key(storage, path)
 	if !args.Refresh {
+		Prefetcher.touchDir(storage, path)
 		if dirCache, exists := Cache.dirCache.Get(key); exists {
 			log.Debugf("use cache when list %s", path)
 			return dirCache.GetSortedObjects(storage), nil
@@ -45,7 +47,9 @@ func List(ctx context.Context, storage driver.Driver, path string, args model.Li
 	}
 
 	objs, err, _ := listG.Do(key, func() ([]model.Obj, error) {
+		start := time.Now()
 		files, err := storage.List(ctx, dir, args)
+		metrics.ObserveDriver(storage.Config().Name, "list", start, err)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to list objs")
 		}
@@ -185,11 +189,32 @@ func Link(ctx context.Context, storage driver.Driver, path string, args model.Li
 	if ol, exists := Cache.linkCache.GetType(key, typeKey); exists {
 		if ol.link.Expiration != nil ||
 			ol.link.SyncClosers.AcquireReference() || !ol.link.RequireReference {
+			Prefetcher.touchLink(storage, path, args, typeKey, ol.link)
 			return ol.link, ol.obj, nil
 		}
 	}
 
-	fn := func() (*objWithLink, error) {
+	retry := 0
+	for {
+		ol, err := fetchLink(ctx, storage, path, args, typeKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ol.link.SyncClosers.AcquireReference() || !ol.link.RequireReference {
+			if retry > 1 {
+				log.Warnf("Link retry successed after %d times: %s %s", retry, key, typeKey)
+			}
+			Prefetcher.touchLink(storage, path, args, typeKey, ol.link)
+			return ol.link, ol.obj, nil
+		}
+		retry++
+	}
+}
+
+// fetchLink gets the link from the storage and caches it
+func fetchLink(ctx context.Context, storage driver.Driver, path string, args model.LinkArgs, typeKey string) (*objWithLink, error) {
+	key := Key(storage, path)
+	ol, err, _ := linkG.Do(key+"/"+typeKey, func() (*objWithLink, error) {
 		file, err := GetUnwrap(ctx, storage, path)
 		if err != nil {
 			return nil, errors.WithMessage(err, "failed to get file")
@@ -198,10 +223,13 @@ func Link(ctx context.Context, storage driver.Driver, path string, args model.Li
 			return nil, errors.WithStack(errs.NotFile)
 		}
 
+		start := time.Now()
 		link, err := storage.Link(ctx, file, args)
+		metrics.ObserveDriver(storage.Config().Name, "link", start, err)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed get link")
 		}
+		useBlockCache(storage, path, file, link)
 		ol := &objWithLink{link: link, obj: file}
 		if link.Expiration != nil {
 			Cache.linkCache.SetTypeWithTTL(key, typeKey, ol, *link.Expiration)
@@ -209,21 +237,8 @@ func Link(ctx context.Context, storage driver.Driver, path string, args model.Li
 			Cache.linkCache.SetTypeWithExpirable(key, typeKey, ol, &link.SyncClosers)
 		}
 		return ol, nil
-	}
-	retry := 0
-	for {
-		ol, err, _ := linkG.Do(key+"/"+typeKey, fn)
-		if err != nil {
-			return nil, nil, err
-		}
-		if ol.link.SyncClosers.AcquireReference() || !ol.link.RequireReference {
-			if retry > 1 {
-				log.Warnf("Link retry successed after %d times: %s %s", retry, key, typeKey)
-			}
-			return ol.link, ol.obj, nil
-		}
-		retry++
-	}
+	})
+	return ol, err
 }
 
 // Other api
@@ -280,6 +295,7 @@ func MakeDir(ctx context.Context, storage driver.Driver, path string, lazyCache
 								if dirCache, exist := Cache.dirCache.Get(Key(storage, parentPath)); exist {
 									dirCache.UpdateObject("", newObj)
 								}
+								publishCacheEvent(cacheEvent{Dirs: []string{Key(storage, parentPath)}})
 							}
 						} else if !utils.IsBool(lazyCache...) {
 							Cache.DeleteDirectory(storage, parentPath)
@@ -353,6 +369,9 @@ func Move(ctx context.Context, storage driver.Driver, srcPath, dstDirPath string
 	default:
 		err = errs.NotImplement
 	}
+	if err == nil {
+		handleObjWriteHook(ctx, storage, ObjWriteMove, srcPath, stdpath.Join(dstDirPath, srcObj.GetName()))
+	}
 
 	if !utils.IsBool(lazyCache...) && err == nil && needHandleObjsUpdateHook() {
 		if !srcObj.IsDir() {
@@ -413,6 +432,9 @@ func Rename(ctx context.Context, storage driver.Driver, srcPath, dstName string,
 	default:
 		return errs.NotImplement
 	}
+	if err == nil {
+		handleObjWriteHook(ctx, storage, ObjWriteRename, srcPath, stdpath.Join(stdpath.Dir(srcPath), dstName))
+	}
 	return errors.WithStack(err)
 }
 
@@ -457,6 +479,9 @@ func Copy(ctx context.Context, storage driver.Driver, srcPath, dstDirPath string
 	default:
 		err = errs.NotImplement
 	}
+	if err == nil {
+		handleObjWriteHook(ctx, storage, ObjWriteCopy, srcPath, stdpath.Join(dstDirPath, srcObj.GetName()))
+	}
 
 	if !utils.IsBool(lazyCache...) && err == nil && needHandleObjsUpdateHook() {
 		if !srcObj.IsDir() {
@@ -500,6 +525,7 @@ func Remove(ctx context.Context, storage driver.Driver, path string) error {
 		err = s.Remove(ctx, model.UnwrapObj(rawObj))
 		if err == nil {
 			Cache.removeDirectoryObject(storage, dirPath, rawObj)
+			handleObjWriteHook(ctx, storage, ObjWriteRemove, path, "")
 		}
 	default:
 		return errs.NotImplement
@@ -507,6 +533,27 @@ func Remove(ctx context.Context, storage driver.Driver, path string) error {
 	return errors.WithStack(err)
 }
 
+// SetModTime changes the timestamps of an object, a zero time is left unchanged
+func SetModTime(ctx context.Context, storage driver.Driver, path string, modified, created time.Time) error {
+	if storage.Config().CheckStatus && storage.GetStorage().Status != WORK {
+		return errors.WithMessagef(errs.StorageNotInit, "storage status: %s", storage.GetStorage().Status)
+	}
+	s, ok := storage.(driver.SetModTime)
+	if !ok {
+		return errs.NotImplement
+	}
+	path = utils.FixAndCleanPath(path)
+	rawObj, err := Get(ctx, storage, path)
+	if err != nil {
+		return errors.WithMessage(err, "failed to get object")
+	}
+	if err = s.SetModTime(ctx, model.UnwrapObj(rawObj), modified, created); err != nil {
+		return errors.WithStack(err)
+	}
+	Cache.DeleteDirectory(storage, stdpath.Dir(path))
+	return nil
+}
+
 func Put(ctx context.Context, storage driver.Driver, dstDirPath string, file model.FileStreamer, up driver.UpdateProgress, lazyCache ...bool) error {
 	close := file.Close
 	defer func() {
@@ -564,12 +611,13 @@ func Put(ctx context.Context, storage driver.Driver, dstDirPath string, file mod
 		log.Warnf("file size < 0, try to get full size from cache")
 		file.CacheFullAndWriter(nil, nil)
 	}
+	start := time.Now()
 	switch s := storage.(type) {
 	case driver.PutResult:
 		var newObj model.Obj
 		newObj, err = s.Put(ctx, parentDir, file, up)
 		if err == nil {
-			Cache.linkCache.DeleteKey(Key(storage, dstPath))
+			Cache.deleteLink(storage, dstPath)
 			if newObj != nil {
 				Cache.addDirectoryObject(storage, dstDirPath, model.WrapObjName(newObj))
 			} else if !utils.IsBool(lazyCache...) {
@@ -579,7 +627,7 @@ func Put(ctx context.Context, storage driver.Driver, dstDirPath string, file mod
 	case driver.Put:
 		err = s.Put(ctx, parentDir, file, up)
 		if err == nil {
-			Cache.linkCache.DeleteKey(Key(storage, dstPath))
+			Cache.deleteLink(storage, dstPath)
 			if !utils.IsBool(lazyCache...) {
 				Cache.DeleteDirectory(storage, dstDirPath)
 			}
@@ -587,6 +635,10 @@ func Put(ctx context.Context, storage driver.Driver, dstDirPath string, file mod
 	default:
 		return errs.NotImplement
 	}
+	metrics.ObserveDriver(storage.Config().Name, "put", start, err)
+	if err == nil {
+		handleObjWriteHook(ctx, storage, ObjWritePut, dstPath, "")
+	}
 	log.Debugf("put file [%s] done", file.GetName())
 	if storage.Config().NoOverwriteUpload && fi != nil && fi.GetSize() > 0 {
 		if err != nil {
@@ -629,7 +681,7 @@ func PutURL(ctx context.Context, storage driver.Driver, dstDirPath, dstName, url
 		var newObj model.Obj
 		newObj, err = s.PutURL(ctx, dstDir, dstName, url)
 		if err == nil {
-			Cache.linkCache.DeleteKey(Key(storage, dstPath))
+			Cache.deleteLink(storage, dstPath)
 			if newObj != nil {
 				Cache.addDirectoryObject(storage, dstDirPath, model.WrapObjName(newObj))
 			} else if !utils.IsBool(lazyCache...) {
@@ -639,7 +691,7 @@ func PutURL(ctx context.Context, storage driver.Driver, dstDirPath, dstName, url
 	case driver.PutURL:
 		err = s.PutURL(ctx, dstDir, dstName, url)
 		if err == nil {
-			Cache.linkCache.DeleteKey(Key(storage, dstPath))
+			Cache.deleteLink(storage, dstPath)
 			if !utils.IsBool(lazyCache...) {
 				Cache.DeleteDirectory(storage, dstDirPath)
 			}
@@ -647,6 +699,9 @@ func PutURL(ctx context.Context, storage driver.Driver, dstDirPath, dstName, url
 	default:
 		return errors.WithStack(errs.NotImplement)
 	}
+	if err == nil {
+		handleObjWriteHook(ctx, storage, ObjWritePut, dstPath, "")
+	}
 	if !utils.IsBool(lazyCache...) && err == nil && needHandleObjsUpdateHook() {
 		go List(context.Background(), storage, dstDirPath, model.ListArgs{Refresh: true})
 	}
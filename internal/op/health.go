@@ -0,0 +1,297 @@
+package op
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	healthProbeTimeout = 30 * time.Second
+	healthMaxBackoff   = time.Hour
+	healthMaxHistory   = 20
+	// prefix of the status set by the health checker
+	healthFailedStatus = "health check failed: "
+)
+
+type HealthRecord struct {
+	Time time.Time `json:"time"`
+	// probe latency in milliseconds
+	Latency int64  `json:"latency"`
+	Error   string `json:"error,omitempty"`
+	// whether it's a re-init attempt rather than a probe
+	Reinit bool `json:"reinit,omitempty"`
+}
+
+type StorageHealth struct {
+	ID        uint      `json:"id"`
+	MountPath string    `json:"mount_path"`
+	Driver    string    `json:"driver"`
+	Status    string    `json:"status"`
+	Healthy   bool      `json:"healthy"`
+	LastCheck time.Time `json:"last_check"`
+	Latency   int64     `json:"latency"`
+	Failures  int       `json:"failures"`
+	// next re-init attempt of an unhealthy storage
+	NextRetry *time.Time     `json:"next_retry,omitempty"`
+	History   []HealthRecord `json:"history"`
+
+	retries int
+}
+
+func (h *StorageHealth) record(r HealthRecord) {
+	h.LastCheck = r.Time
+	h.Latency = r.Latency
+	h.History = append(h.History, r)
+	if len(h.History) > healthMaxHistory {
+		h.History = h.History[len(h.History)-healthMaxHistory:]
+	}
+}
+
+// healthChecker probes the loaded storages periodically, marks the failing ones
+// and re-initializes the storages in error state with backoff
+type healthChecker struct {
+	mu       sync.Mutex
+	health   map[string]*StorageHealth
+	checking sync.Mutex
+	cancel   context.CancelFunc
+	interval time.Duration
+}
+
+var HealthChecker = &healthChecker{
+	health: make(map[string]*StorageHealth),
+}
+
+// Start begins checking if it's enabled in settings, and follows the changes of the settings
+func (hc *healthChecker) Start() {
+	hc.reload()
+	RegisterSettingChangingCallback(hc.reload)
+}
+
+func (hc *healthChecker) reload() {
+	var interval time.Duration
+	if item, _ := GetSettingItemByKey(conf.HealthCheckEnabled); item != nil && item.Value == "true" {
+		interval = time.Duration(settingInt(conf.HealthCheckInterval, 300)) * time.Second
+		if interval < time.Minute {
+			interval = time.Minute
+		}
+	}
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if interval == hc.interval {
+		return
+	}
+	if hc.cancel != nil {
+		hc.cancel()
+		hc.cancel = nil
+	}
+	hc.interval = interval
+	if interval == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	hc.cancel = cancel
+	go hc.run(ctx, interval)
+}
+
+func settingInt(key string, defaultVal int) int {
+	if item, _ := GetSettingItemByKey(key); item != nil {
+		if i, err := strconv.Atoi(item.Value); err == nil {
+			return i
+		}
+	}
+	return defaultVal
+}
+
+func (hc *healthChecker) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		hc.CheckAll(ctx)
+	}
+}
+
+// CheckAll checks all the loaded storages
+func (hc *healthChecker) CheckAll(ctx context.Context) {
+	hc.checking.Lock()
+	defer hc.checking.Unlock()
+	storages := GetAllStorages()
+	alive := make(map[string]struct{}, len(storages))
+	for _, storage := range storages {
+		if ctx.Err() != nil {
+			return
+		}
+		alive[storage.GetStorage().MountPath] = struct{}{}
+		hc.check(ctx, storage, false)
+	}
+	hc.mu.Lock()
+	for mountPath := range hc.health {
+		if _, ok := alive[mountPath]; !ok {
+			delete(hc.health, mountPath)
+		}
+	}
+	hc.mu.Unlock()
+}
+
+// Check checks the storage immediately, the storage in error state is re-initialized ignoring the backoff
+func (hc *healthChecker) Check(ctx context.Context, storage driver.Driver) StorageHealth {
+	return hc.check(ctx, storage, true)
+}
+
+func (hc *healthChecker) check(ctx context.Context, storage driver.Driver, force bool) StorageHealth {
+	s := storage.GetStorage()
+	hc.mu.Lock()
+	h, ok := hc.health[s.MountPath]
+	if !ok {
+		h = &StorageHealth{}
+		hc.health[s.MountPath] = h
+	}
+	hc.mu.Unlock()
+
+	now := time.Now()
+	if s.Status == WORK {
+		latency, err := probeStorage(ctx, storage)
+		r := HealthRecord{Time: now, Latency: latency.Milliseconds()}
+		hc.mu.Lock()
+		if err == nil {
+			h.Failures, h.retries, h.NextRetry = 0, 0, nil
+		} else {
+			r.Error = err.Error()
+			h.Failures++
+		}
+		h.record(r)
+		failures := h.Failures
+		failed := err != nil && failures >= settingInt(conf.HealthCheckFailures, 3)
+		if failed {
+			hc.scheduleRetry(h, now)
+		}
+		hc.mu.Unlock()
+		if failed {
+			log.Warnf("storage [%s] health check failed %d times: %v", s.MountPath, failures, err)
+			s.SetStatus(healthFailedStatus + err.Error())
+			MustSaveDriverStorage(storage)
+		}
+	} else {
+		hc.mu.Lock()
+		due := force || h.NextRetry == nil || !now.Before(*h.NextRetry)
+		hc.mu.Unlock()
+		if due {
+			err := reinitStorage(ctx, storage)
+			r := HealthRecord{Time: now, Latency: time.Since(now).Milliseconds(), Reinit: true}
+			hc.mu.Lock()
+			if err == nil {
+				log.Infof("storage [%s] is recovered", s.MountPath)
+				h.Failures, h.retries, h.NextRetry = 0, 0, nil
+			} else {
+				r.Error = err.Error()
+				h.Failures++
+				hc.scheduleRetry(h, now)
+			}
+			h.record(r)
+			hc.mu.Unlock()
+		}
+	}
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	h.ID, h.MountPath, h.Driver = s.ID, s.MountPath, s.Driver
+	h.Status = s.Status
+	h.Healthy = s.Status == WORK
+	return h.snapshot()
+}
+
+// the backoff starts from the check interval and doubles after each failed re-init
+func (hc *healthChecker) scheduleRetry(h *StorageHealth, now time.Time) {
+	backoff := hc.interval
+	if backoff <= 0 {
+		backoff = time.Minute
+	}
+	backoff <<= h.retries
+	if backoff <= 0 || backoff > healthMaxBackoff {
+		backoff = healthMaxBackoff
+	}
+	h.retries++
+	next := now.Add(backoff)
+	h.NextRetry = &next
+}
+
+func (h *StorageHealth) snapshot() StorageHealth {
+	ret := *h
+	ret.History = append([]HealthRecord(nil), h.History...)
+	return ret
+}
+
+// GetHealth returns the health of the storages that have been checked
+func (hc *healthChecker) GetHealth() []StorageHealth {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	ret := make([]StorageHealth, 0, len(hc.health))
+	for _, h := range hc.health {
+		ret = append(ret, h.snapshot())
+	}
+	return ret
+}
+
+func (hc *healthChecker) GetStorageHealth(mountPath string) (StorageHealth, bool) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	h, ok := hc.health[mountPath]
+	if !ok {
+		return StorageHealth{}, false
+	}
+	return h.snapshot(), true
+}
+
+// probeStorage gets the details of the storage if supported, otherwise lists the root folder without cache
+func probeStorage(ctx context.Context, storage driver.Driver) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+	start := time.Now()
+	err := func() error {
+		if wd, ok := storage.(driver.WithDetails); ok {
+			details, err := wd.GetDetails(ctx)
+			if err == nil {
+				Cache.SetStorageDetails(storage, details)
+				return nil
+			}
+			if !errs.IsNotImplementError(err) && !errs.IsNotSupportError(err) {
+				return err
+			}
+		}
+		root, err := Get(ctx, storage, "/")
+		if err != nil {
+			return err
+		}
+		_, err = storage.List(ctx, root, model.ListArgs{})
+		return err
+	}()
+	return time.Since(start), err
+}
+
+// reinitStorage drops the storage and initializes it again with the storage saved in database
+func reinitStorage(ctx context.Context, storageDriver driver.Driver) error {
+	storage, err := db.GetStorageById(storageDriver.GetStorage().ID)
+	if err != nil {
+		return errors.WithMessage(err, "failed get storage")
+	}
+	if storage.Disabled {
+		return errors.New("storage is disabled")
+	}
+	if err := storageDriver.Drop(ctx); err != nil {
+		log.Warnf("failed drop storage [%s] before re-init: %v", storage.MountPath, err)
+	}
+	return initStorage(ctx, *storage, storageDriver)
+}
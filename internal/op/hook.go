@@ -4,6 +4,7 @@ import (
 	"context"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
@@ -30,6 +31,36 @@ func HandleObjsUpdateHook(ctx context.Context, parent string, objs []model.Obj)
 	}
 }
 
+// ObjWriteHook is called after an object is written through op, the paths include the mount path.
+// dstPath is the new path of the object moved, renamed or copied, it's empty for the other types.
+type ObjWriteHook = func(ctx context.Context, typ, srcPath, dstPath string)
+
+const (
+	ObjWritePut    = "put"
+	ObjWriteRemove = "remove"
+	ObjWriteMove   = "move"
+	ObjWriteRename = "rename"
+	ObjWriteCopy   = "copy"
+)
+
+var objWriteHooks = make([]ObjWriteHook, 0)
+
+func RegisterObjWriteHook(hook ObjWriteHook) {
+	objWriteHooks = append(objWriteHooks, hook)
+}
+
+// handleObjWriteHook is called with the actual paths in the storage
+func handleObjWriteHook(ctx context.Context, storage driver.Driver, typ, srcPath, dstPath string) {
+	mountPath := storage.GetStorage().MountPath
+	srcPath = utils.GetFullPath(mountPath, srcPath)
+	if dstPath != "" {
+		dstPath = utils.GetFullPath(mountPath, dstPath)
+	}
+	for _, hook := range objWriteHooks {
+		hook(ctx, typ, srcPath, dstPath)
+	}
+}
+
 // Setting
 type SettingItemHook func(item *model.SettingItem) error
 
@@ -110,3 +141,27 @@ func callStorageHooks(typ string, storage driver.Driver) {
 func RegisterStorageHook(hook StorageHook) {
 	storageHooks = append(storageHooks, hook)
 }
+
+// StorageStatusHook is called when the status of a loaded storage is changed
+type StorageStatusHook func(storage driver.Driver, oldStatus, newStatus string)
+
+var (
+	storageStatusHooks = make([]StorageStatusHook, 0)
+	// mount path -> last saved status
+	storageStatuses sync.Map
+)
+
+func RegisterStorageStatusHook(hook StorageStatusHook) {
+	storageStatusHooks = append(storageStatusHooks, hook)
+}
+
+func handleStorageStatus(storage driver.Driver) {
+	s := storage.GetStorage()
+	old, loaded := storageStatuses.Swap(s.MountPath, s.Status)
+	if !loaded || old.(string) == s.Status {
+		return
+	}
+	for _, hook := range storageStatusHooks {
+		hook(storage, old.(string), s.Status)
+	}
+}
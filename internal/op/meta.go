@@ -68,6 +68,7 @@ func DeleteMetaById(id uint) error {
 		return err
 	}
 	metaCache.Del(old.Path)
+	defer publishCacheEvent(cacheEvent{Metas: []string{old.Path}})
 	return db.DeleteMetaById(id)
 }
 
@@ -78,12 +79,14 @@ func UpdateMeta(u *model.Meta) error {
 		return err
 	}
 	metaCache.Del(old.Path)
+	defer publishCacheEvent(cacheEvent{Metas: []string{old.Path}})
 	return db.UpdateMeta(u)
 }
 
 func CreateMeta(u *model.Meta) error {
 	u.Path = utils.FixAndCleanPath(u.Path)
 	metaCache.Del(u.Path)
+	defer publishCacheEvent(cacheEvent{Metas: []string{u.Path}})
 	return db.CreateMeta(u)
 }
 
@@ -0,0 +1,154 @@
+package op
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+)
+
+// rulePasswordPrefix marks the archive passwords of the rules encrypted in the database
+const rulePasswordPrefix = "enc:"
+
+// rulePasswordAEAD derives the key of the archive passwords from the jwt secret,
+// so the passwords can't be read from the database alone
+func rulePasswordAEAD() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("offline_download_rule:" + conf.Conf.JwtSecret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, errors.WithStack(err)
+}
+
+func encryptRulePassword(password string) (string, error) {
+	if password == "" {
+		return password, nil
+	}
+	aead, err := rulePasswordAEAD()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", errors.WithStack(err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(password), nil)
+	return rulePasswordPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptRulePassword returns the plain password, the ones saved before the encryption are returned as they are
+func decryptRulePassword(password string) (string, error) {
+	s, ok := strings.CutPrefix(password, rulePasswordPrefix)
+	if !ok {
+		return password, nil
+	}
+	aead, err := rulePasswordAEAD()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("invalid encrypted password")
+	}
+	b, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("failed decrypt the password, the jwt secret may be changed")
+	}
+	return string(b), nil
+}
+
+func redactRulePassword(r *model.OfflineDownloadRule) {
+	if r.Password != "" {
+		r.Password = model.RedactedPassword
+	}
+}
+
+// GetOfflineDownloadRuleById returns the rule with the password redacted
+func GetOfflineDownloadRuleById(id uint) (*model.OfflineDownloadRule, error) {
+	r, err := db.GetOfflineDownloadRuleById(id)
+	if err != nil {
+		return nil, err
+	}
+	redactRulePassword(r)
+	return r, nil
+}
+
+// GetOfflineDownloadRules returns the rules with the passwords redacted
+func GetOfflineDownloadRules(pageIndex, pageSize int) ([]model.OfflineDownloadRule, int64, error) {
+	rules, total, err := db.GetOfflineDownloadRules(pageIndex, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range rules {
+		redactRulePassword(&rules[i])
+	}
+	return rules, total, nil
+}
+
+// GetOfflineDownloadRulesForUser returns the rules applied to the downloads of the user,
+// a nil user only gets the global rules. The passwords are decrypted.
+func GetOfflineDownloadRulesForUser(user *model.User) ([]model.OfflineDownloadRule, error) {
+	var uid uint
+	if user != nil {
+		uid = user.ID
+	}
+	rules, err := db.GetEnabledOfflineDownloadRulesByUserId(uid)
+	if err != nil {
+		return nil, err
+	}
+	valid := rules[:0]
+	for _, r := range rules {
+		if r.Validate() != nil {
+			continue
+		}
+		if r.Password, err = decryptRulePassword(r.Password); err != nil {
+			return nil, errors.WithMessagef(err, "rule [%s]", r.Name)
+		}
+		valid = append(valid, r)
+	}
+	return valid, nil
+}
+
+func CreateOfflineDownloadRule(r *model.OfflineDownloadRule) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if r.Password == model.RedactedPassword {
+		return errors.New("password must not be the redacted placeholder")
+	}
+	var err error
+	if r.Password, err = encryptRulePassword(r.Password); err != nil {
+		return err
+	}
+	return db.CreateOfflineDownloadRule(r)
+}
+
+// UpdateOfflineDownloadRule updates the rule, the password is kept if the redacted placeholder is given
+func UpdateOfflineDownloadRule(r *model.OfflineDownloadRule) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	old, err := db.GetOfflineDownloadRuleById(r.ID)
+	if err != nil {
+		return err
+	}
+	if r.Password == model.RedactedPassword {
+		r.Password = old.Password
+	} else if r.Password, err = encryptRulePassword(r.Password); err != nil {
+		return err
+	}
+	return db.UpdateOfflineDownloadRule(r)
+}
+
+func DeleteOfflineDownloadRuleById(id uint) error {
+	return db.DeleteOfflineDownloadRuleById(id)
+}
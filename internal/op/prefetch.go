@@ -0,0 +1,252 @@
+package op
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+const (
+	prefetchInterval = 30 * time.Second
+	// entries not accessed within the duration are no longer kept warm
+	prefetchHotDuration = 10 * time.Minute
+	// refresh the entries which expire within the duration
+	prefetchAhead = 2 * prefetchInterval
+	// max number of the tracked dirs or links
+	prefetchMaxEntries = 1024
+)
+
+type prefetchEntry struct {
+	storage    driver.Driver
+	path       string
+	args       model.LinkArgs
+	typeKey    string
+	lastAccess time.Time
+}
+
+// prefetcher keeps the recently accessed directories and links warm in the Cache,
+// and warms up the configured directory trees in background. It works for all the storages
+// if it's enabled globally, otherwise only for the storages with prefetch enabled.
+type prefetcher struct {
+	// enabled is set by the global setting
+	enabled  atomic.Bool
+	mu       sync.Mutex
+	dirs     map[string]*prefetchEntry
+	links    map[string]*prefetchEntry
+	limiters map[string]*rate.Limiter
+	warming  map[string]struct{}
+	cancel   context.CancelFunc
+}
+
+var Prefetcher = &prefetcher{
+	dirs:     make(map[string]*prefetchEntry),
+	links:    make(map[string]*prefetchEntry),
+	limiters: make(map[string]*rate.Limiter),
+	warming:  make(map[string]struct{}),
+}
+
+func (p *prefetcher) enabledFor(storage driver.Driver) bool {
+	return p.enabled.Load() || storage.GetStorage().Prefetch
+}
+
+func (p *prefetcher) touchDir(storage driver.Driver, path string) {
+	if !p.enabledFor(storage) || storage.Config().NoCache || storage.GetStorage().CacheExpiration <= 0 {
+		return
+	}
+	p.touch(p.dirs, Key(storage, path), &prefetchEntry{storage: storage, path: path})
+}
+
+func (p *prefetcher) touchLink(storage driver.Driver, path string, args model.LinkArgs, typeKey string, link *model.Link) {
+	// only the links expire at a fixed time can be refreshed ahead
+	if !p.enabledFor(storage) || link.Expiration == nil {
+		return
+	}
+	p.touch(p.links, Key(storage, path)+"/"+typeKey, &prefetchEntry{
+		storage: storage,
+		path:    path,
+		args:    args,
+		typeKey: typeKey,
+	})
+}
+
+func (p *prefetcher) touch(entries map[string]*prefetchEntry, key string, entry *prefetchEntry) {
+	entry.lastAccess = time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := entries[key]; !ok && len(entries) >= prefetchMaxEntries {
+		// drop the least recently accessed one
+		var oldest string
+		for k, e := range entries {
+			if oldest == "" || e.lastAccess.Before(entries[oldest].lastAccess) {
+				oldest = k
+			}
+		}
+		delete(entries, oldest)
+	}
+	entries[key] = entry
+}
+
+// take the hot entries, and drop the cold ones and the ones of the storages no longer prefetched
+func (p *prefetcher) hotEntries(entries map[string]*prefetchEntry) map[string]*prefetchEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ret := make(map[string]*prefetchEntry, len(entries))
+	for k, e := range entries {
+		if time.Since(e.lastAccess) > prefetchHotDuration || !storageAlive(e.storage) || !p.enabledFor(e.storage) {
+			delete(entries, k)
+			continue
+		}
+		ret[k] = e
+	}
+	return ret
+}
+
+func storageAlive(storage driver.Driver) bool {
+	s, err := GetStorageByMountPath(storage.GetStorage().MountPath)
+	return err == nil && s == storage && s.GetStorage().Status == WORK
+}
+
+func (p *prefetcher) limiter(storage driver.Driver) *rate.Limiter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	mountPath := storage.GetStorage().MountPath
+	l, ok := p.limiters[mountPath]
+	if !ok {
+		limit := rate.Inf
+		if item, _ := GetSettingItemByKey(conf.PrefetchRateLimit); item != nil {
+			if f, err := strconv.ParseFloat(item.Value, 64); err == nil && f > .0 {
+				limit = rate.Limit(f)
+			}
+		}
+		l = rate.NewLimiter(limit, 1)
+		p.limiters[mountPath] = l
+	}
+	return l
+}
+
+func (p *prefetcher) refreshDirs(ctx context.Context) {
+	for key, e := range p.hotEntries(p.dirs) {
+		if exp, ok := Cache.dirCache.ExpiresAt(key); ok && time.Until(exp) > prefetchAhead {
+			continue
+		}
+		if err := p.limiter(e.storage).Wait(ctx); err != nil {
+			return
+		}
+		log.Debugf("prefetch dir: %s", key)
+		if _, err := List(ctx, e.storage, e.path, model.ListArgs{Refresh: true}); err != nil {
+			log.Debugf("failed prefetch dir %s: %v", key, err)
+			p.mu.Lock()
+			delete(p.dirs, key)
+			p.mu.Unlock()
+		}
+	}
+}
+
+func (p *prefetcher) refreshLinks(ctx context.Context) {
+	for key, e := range p.hotEntries(p.links) {
+		if exp, ok := Cache.linkCache.TypeExpiresAt(Key(e.storage, e.path), e.typeKey); ok && time.Until(exp) > prefetchAhead {
+			continue
+		}
+		if err := p.limiter(e.storage).Wait(ctx); err != nil {
+			return
+		}
+		log.Debugf("prefetch link: %s", key)
+		if _, err := fetchLink(ctx, e.storage, e.path, e.args, e.typeKey); err != nil {
+			log.Debugf("failed prefetch link %s: %v", key, err)
+			p.mu.Lock()
+			delete(p.links, key)
+			p.mu.Unlock()
+		}
+	}
+}
+
+// warmUp lists the configured directory trees whose root is not in the Cache
+func (p *prefetcher) warmUp(ctx context.Context) {
+	item, _ := GetSettingItemByKey(conf.PrefetchPaths)
+	if item == nil {
+		return
+	}
+	for _, rawPath := range strings.Split(item.Value, "\n") {
+		rawPath = strings.TrimSpace(rawPath)
+		if rawPath == "" {
+			continue
+		}
+		storage, actualPath, err := GetStorageAndActualPath(utils.FixAndCleanPath(rawPath))
+		if err != nil || storage.GetStorage().Status != WORK || storage.Config().NoCache || !p.enabledFor(storage) {
+			continue
+		}
+		key := Key(storage, actualPath)
+		if exp, ok := Cache.dirCache.ExpiresAt(key); ok && time.Until(exp) > prefetchAhead {
+			continue
+		}
+		p.mu.Lock()
+		_, running := p.warming[key]
+		if !running {
+			p.warming[key] = struct{}{}
+		}
+		p.mu.Unlock()
+		if running {
+			continue
+		}
+		go func() {
+			defer func() {
+				p.mu.Lock()
+				delete(p.warming, key)
+				p.mu.Unlock()
+			}()
+			log.Debugf("warm up dir tree: %s", key)
+			RecursivelyListStorage(ctx, storage, actualPath, p.limiter(storage), nil)
+		}()
+	}
+}
+
+// Start begins prefetching, for the storages with prefetch enabled or for all if it's enabled in settings,
+// and follows the changes of the settings
+func (p *prefetcher) Start() {
+	p.reload()
+	RegisterSettingChangingCallback(p.reload)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		p.cancel = cancel
+		go p.run(ctx)
+	}
+}
+
+func (p *prefetcher) reload() {
+	enabled := false
+	if item, _ := GetSettingItemByKey(conf.PrefetchEnabled); item != nil {
+		enabled = item.Value == "true"
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// the rate limit may be changed
+	p.limiters = make(map[string]*rate.Limiter)
+	p.enabled.Store(enabled)
+}
+
+func (p *prefetcher) run(ctx context.Context) {
+	ticker := time.NewTicker(prefetchInterval)
+	defer ticker.Stop()
+	for {
+		p.warmUp(ctx)
+		p.refreshDirs(ctx)
+		p.refreshLinks(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
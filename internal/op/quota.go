@@ -0,0 +1,203 @@
+package op
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+func GetQuotaGroupById(id uint) (*model.QuotaGroup, error) {
+	return db.GetQuotaGroupById(id)
+}
+
+func GetQuotaGroups(pageIndex, pageSize int) ([]model.QuotaGroup, int64, error) {
+	return db.GetQuotaGroups(pageIndex, pageSize)
+}
+
+func CreateQuotaGroup(g *model.QuotaGroup) error {
+	return db.CreateQuotaGroup(g)
+}
+
+func UpdateQuotaGroup(g *model.QuotaGroup) error {
+	if _, err := db.GetQuotaGroupById(g.ID); err != nil {
+		return err
+	}
+	return db.UpdateQuotaGroup(g)
+}
+
+func DeleteQuotaGroupById(id uint) error {
+	members, err := db.GetQuotaGroupMembers(id)
+	if err != nil {
+		return err
+	}
+	if len(members) > 0 {
+		return errors.Errorf("quota group is used by %d users", len(members))
+	}
+	return db.DeleteQuotaGroupById(id)
+}
+
+// GetQuotaUsers returns the users whose usage is accounted
+func GetQuotaUsers() ([]model.User, error) {
+	return db.GetQuotaUsers()
+}
+
+func SaveQuotaUsage(u *model.QuotaUsage) error {
+	return db.SaveQuotaUsage(u)
+}
+
+// GetQuotaUserByPath returns the user with a quota whose base path holds the path, the deepest base path wins.
+// The writes made without a user, e.g. through the S3 server, are accounted to it. It is nil if there is none.
+func GetQuotaUserByPath(path string) (*model.User, error) {
+	users, err := db.GetQuotaUsers()
+	if err != nil {
+		return nil, err
+	}
+	var owner *model.User
+	for i := range users {
+		if utils.IsSubPath(users[i].BasePath, path) && (owner == nil || len(users[i].BasePath) > len(owner.BasePath)) {
+			owner = &users[i]
+		}
+	}
+	return owner, nil
+}
+
+// HasQuota reports whether the usage of the user is accounted
+func HasQuota(u *model.User) bool {
+	return u != nil && (u.QuotaBytes > 0 || u.QuotaFiles > 0 || u.QuotaGroupID > 0)
+}
+
+// GetUserQuota returns the quota of the user and the one of its group, which is nil if the user is not in a group
+func GetUserQuota(u *model.User) (*model.Quota, *model.Quota, error) {
+	usage, err := db.GetQuotaUsage(model.UserQuotaOwner(u.ID))
+	if err != nil {
+		return nil, nil, err
+	}
+	userQuota := &model.Quota{
+		MaxBytes:     u.QuotaBytes,
+		MaxFiles:     u.QuotaFiles,
+		UsedBytes:    usage.Bytes,
+		UsedFiles:    usage.Files,
+		ReconciledAt: usage.ReconciledAt,
+	}
+	if u.QuotaGroupID == 0 {
+		return userQuota, nil, nil
+	}
+	group, err := db.GetQuotaGroupById(u.QuotaGroupID)
+	if err != nil {
+		return nil, nil, err
+	}
+	groupQuota, err := GetGroupQuota(group)
+	if err != nil {
+		return nil, nil, err
+	}
+	return userQuota, groupQuota, nil
+}
+
+func GetGroupQuota(g *model.QuotaGroup) (*model.Quota, error) {
+	usage, err := db.GetQuotaUsage(model.GroupQuotaOwner(g.ID))
+	if err != nil {
+		return nil, err
+	}
+	return &model.Quota{
+		MaxBytes:     g.MaxBytes,
+		MaxFiles:     g.MaxFiles,
+		UsedBytes:    usage.Bytes,
+		UsedFiles:    usage.Files,
+		ReconciledAt: usage.ReconciledAt,
+	}, nil
+}
+
+// CheckQuota returns errs.QuotaExceeded if the user or its group can't take more bytes and files
+func CheckQuota(u *model.User, bytes, files int64) error {
+	if !HasQuota(u) {
+		return nil
+	}
+	// the size of some streams is unknown until they are stored
+	bytes = max(bytes, 0)
+	userQuota, groupQuota, err := GetUserQuota(u)
+	if err != nil {
+		return errors.WithMessage(err, "failed get quota")
+	}
+	if !userQuota.Allows(bytes, files) {
+		return errors.WithStack(errs.QuotaExceeded)
+	}
+	if groupQuota != nil && !groupQuota.Allows(bytes, files) {
+		return errs.NewErr(errs.QuotaExceeded, "quota of the group is used up")
+	}
+	return nil
+}
+
+// ReserveQuota charges the user and its group before a write, it returns errs.QuotaExceeded instead if they can't take it.
+// Unlike CheckQuota followed by AddQuotaUsage, concurrent writes can't exceed the quota together.
+func ReserveQuota(u *model.User, bytes, files int64) error {
+	if !HasQuota(u) {
+		return nil
+	}
+	owner := model.UserQuotaOwner(u.ID)
+	ok, err := db.ReserveQuotaUsage(owner, bytes, files, u.QuotaBytes, u.QuotaFiles)
+	if err != nil {
+		return errors.WithMessage(err, "failed reserve quota")
+	}
+	if !ok {
+		return errors.WithStack(errs.QuotaExceeded)
+	}
+	if u.QuotaGroupID == 0 {
+		return nil
+	}
+	group, err := db.GetQuotaGroupById(u.QuotaGroupID)
+	if err == nil {
+		ok, err = db.ReserveQuotaUsage(model.GroupQuotaOwner(group.ID), bytes, files, group.MaxBytes, group.MaxFiles)
+	}
+	if err == nil && ok {
+		return nil
+	}
+	if rerr := db.AddQuotaUsage(owner, -bytes, -files); rerr != nil {
+		log.Errorf("failed release usage of user %s: %+v", u.Username, rerr)
+	}
+	if err != nil {
+		return errors.WithMessage(err, "failed reserve quota")
+	}
+	return errs.NewErr(errs.QuotaExceeded, "quota of the group is used up")
+}
+
+// AddQuotaUsage charges the user and its group, negative values release the usage
+func AddQuotaUsage(u *model.User, bytes, files int64) {
+	if !HasQuota(u) {
+		return
+	}
+	if err := db.AddQuotaUsage(model.UserQuotaOwner(u.ID), bytes, files); err != nil {
+		log.Errorf("failed account usage of user %s: %+v", u.Username, err)
+	}
+	if u.QuotaGroupID == 0 {
+		return
+	}
+	if err := db.AddQuotaUsage(model.GroupQuotaOwner(u.QuotaGroupID), bytes, files); err != nil {
+		log.Errorf("failed account usage of quota group %d: %+v", u.QuotaGroupID, err)
+	}
+}
+
+// RecountGroupUsage sets the usage of the group to the sum of its members
+func RecountGroupUsage(groupId uint) error {
+	members, err := db.GetQuotaGroupMembers(groupId)
+	if err != nil {
+		return err
+	}
+	total := model.QuotaUsage{Owner: model.GroupQuotaOwner(groupId)}
+	for i, m := range members {
+		usage, err := db.GetQuotaUsage(model.UserQuotaOwner(m.ID))
+		if err != nil {
+			return err
+		}
+		total.Bytes += usage.Bytes
+		total.Files += usage.Files
+		// the group is as old as its least recently reconciled member
+		if i == 0 || (total.ReconciledAt != nil &&
+			(usage.ReconciledAt == nil || usage.ReconciledAt.Before(*total.ReconciledAt))) {
+			total.ReconciledAt = usage.ReconciledAt
+		}
+	}
+	return db.SaveQuotaUsage(&total)
+}
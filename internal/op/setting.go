@@ -29,6 +29,11 @@ func RegisterSettingChangingCallback(f func()) {
 }
 
 func SettingCacheUpdate() {
+	settingCacheUpdate()
+	publishCacheEvent(cacheEvent{Settings: true})
+}
+
+func settingCacheUpdate() {
 	Cache.ClearAll()
 	for _, cb := range settingChangingCallbacks {
 		cb()
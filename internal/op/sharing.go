@@ -130,11 +130,13 @@ func UpdateSharing(sharing *model.Sharing, skipMarshal ...bool) (err error) {
 		}
 	}
 	sharingCache.Del(sharing.ID)
+	defer publishCacheEvent(cacheEvent{Sharings: []string{sharing.ID}})
 	return db.UpdateSharing(sharing.SharingDB)
 }
 
 func DeleteSharing(sid string) error {
 	sharingCache.Del(sid)
+	defer publishCacheEvent(cacheEvent{Sharings: []string{sid}})
 	return db.DeleteSharingById(sid)
 }
 
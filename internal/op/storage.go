@@ -61,6 +61,7 @@ func CreateStorage(ctx context.Context, storage model.Storage) (uint, error) {
 	if err != nil {
 		return storage.ID, errors.WithMessage(err, "failed create storage in database")
 	}
+	publishCacheEvent(cacheEvent{Storage: storage.ID})
 	// already has an id
 	err = initStorage(ctx, storage, storageDriver)
 	go callStorageHooks("add", storageDriver)
@@ -98,6 +99,8 @@ func getCurrentGoroutineStack() string {
 func initStorage(ctx context.Context, storage model.Storage, storageDriver driver.Driver) (err error) {
 	storageDriver.SetStorage(storage)
 	driverStorage := storageDriver.GetStorage()
+	// compare with the status saved in the last run
+	storageStatuses.LoadOrStore(driverStorage.MountPath, driverStorage.Status)
 	defer func() {
 		if err := recover(); err != nil {
 			errInfo := fmt.Sprintf("[panic] err: %v\nstack: %s\n", err, getCurrentGoroutineStack())
@@ -181,6 +184,7 @@ func EnableStorage(ctx context.Context, id uint) error {
 	if err != nil {
 		return errors.WithMessage(err, "failed update storage in db")
 	}
+	publishCacheEvent(cacheEvent{Storage: id})
 	err = LoadStorage(ctx, *storage)
 	if err != nil {
 		return errors.WithMessage(err, "failed load storage")
@@ -211,7 +215,9 @@ func DisableStorage(ctx context.Context, id uint) error {
 	if err != nil {
 		return errors.WithMessage(err, "failed update storage in db")
 	}
+	publishCacheEvent(cacheEvent{Storage: id})
 	storagesMap.Delete(storage.MountPath)
+	storageStatuses.Delete(storage.MountPath)
 	go callStorageHooks("del", storageDriver)
 	return nil
 }
@@ -233,6 +239,7 @@ func UpdateStorage(ctx context.Context, storage model.Storage) error {
 	if err != nil {
 		return errors.WithMessage(err, "failed update storage in database")
 	}
+	publishCacheEvent(cacheEvent{Storage: storage.ID})
 	if storage.Disabled {
 		return nil
 	}
@@ -240,6 +247,7 @@ func UpdateStorage(ctx context.Context, storage model.Storage) error {
 	if oldStorage.MountPath != storage.MountPath {
 		// mount path renamed, need to drop the storage
 		storagesMap.Delete(oldStorage.MountPath)
+		storageStatuses.Delete(oldStorage.MountPath)
 		Cache.DeleteDirectoryTree(storageDriver, "/")
 		Cache.InvalidateStorageDetails(storageDriver)
 	}
@@ -274,6 +282,7 @@ func DeleteStorageById(ctx context.Context, id uint) error {
 		}
 		// delete the storage in the memory
 		storagesMap.Delete(storage.MountPath)
+		storageStatuses.Delete(storage.MountPath)
 		Cache.DeleteDirectoryTree(storageDriver, "/")
 		Cache.InvalidateStorageDetails(storageDriver)
 		go callStorageHooks("del", storageDriver)
@@ -282,6 +291,7 @@ func DeleteStorageById(ctx context.Context, id uint) error {
 	if err := db.DeleteStorageById(id); err != nil {
 		return errors.WithMessage(err, "failed delete storage in database")
 	}
+	publishCacheEvent(cacheEvent{Storage: id})
 	return dropErr
 }
 
@@ -305,6 +315,7 @@ func saveDriverStorage(driver driver.Driver) error {
 	if err != nil {
 		return errors.WithMessage(err, "failed update storage in database")
 	}
+	handleStorageStatus(driver)
 	return nil
 }
 
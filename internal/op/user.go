@@ -82,7 +82,19 @@ func DeleteUserById(id uint) error {
 	if err := DeleteSharingsByCreatorId(id); err != nil {
 		return errors.WithMessage(err, "failed to delete user's sharings")
 	}
-	return db.DeleteUserById(id)
+	if err := db.DeleteUserById(id); err != nil {
+		return err
+	}
+	if err := db.DeleteAPITokensByUserId(id); err != nil {
+		return err
+	}
+	if err := db.DeleteQuotaUsage(model.UserQuotaOwner(id)); err != nil {
+		return err
+	}
+	if old.QuotaGroupID != 0 {
+		return RecountGroupUsage(old.QuotaGroupID)
+	}
+	return nil
 }
 
 func UpdateUser(u *model.User) error {
@@ -97,6 +109,8 @@ func UpdateUser(u *model.User) error {
 		guestUser = nil
 	}
 	Cache.DeleteUser(old.Username)
+	// publish again once saved, the other nodes may have reloaded the old record meanwhile
+	defer publishCacheEvent(cacheEvent{Users: []string{old.Username}})
 	u.BasePath = utils.FixAndCleanPath(u.BasePath)
 	return db.UpdateUser(u)
 }
@@ -0,0 +1,105 @@
+package op
+
+import (
+	"sync"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/pkg/errors"
+)
+
+var (
+	enabledWebhooksMu sync.Mutex
+	enabledWebhooks   []model.Webhook
+	webhooksLoaded    bool
+)
+
+func redactWebhookSecret(w *model.Webhook) {
+	if w.Secret != "" {
+		w.Secret = model.RedactedPassword
+	}
+}
+
+// GetWebhookById returns the webhook with the secret redacted
+func GetWebhookById(id uint) (*model.Webhook, error) {
+	w, err := db.GetWebhookById(id)
+	if err != nil {
+		return nil, err
+	}
+	redactWebhookSecret(w)
+	return w, nil
+}
+
+// GetWebhooks returns the webhooks with the secrets redacted
+func GetWebhooks(pageIndex, pageSize int) ([]model.Webhook, int64, error) {
+	webhooks, total, err := db.GetWebhooks(pageIndex, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range webhooks {
+		redactWebhookSecret(&webhooks[i])
+	}
+	return webhooks, total, nil
+}
+
+// GetEnabledWebhooks returns the enabled webhooks, they are cached until the webhooks are changed
+func GetEnabledWebhooks() ([]model.Webhook, error) {
+	enabledWebhooksMu.Lock()
+	defer enabledWebhooksMu.Unlock()
+	if webhooksLoaded {
+		return enabledWebhooks, nil
+	}
+	webhooks, err := db.GetEnabledWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	enabledWebhooks, webhooksLoaded = webhooks, true
+	return webhooks, nil
+}
+
+func clearWebhooksCache() {
+	enabledWebhooksMu.Lock()
+	enabledWebhooks, webhooksLoaded = nil, false
+	enabledWebhooksMu.Unlock()
+}
+
+func webhooksChanged() {
+	clearWebhooksCache()
+	publishCacheEvent(cacheEvent{Webhooks: true})
+}
+
+func CreateWebhook(w *model.Webhook) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	if w.Secret == model.RedactedPassword {
+		return errors.New("secret must not be the redacted placeholder")
+	}
+	defer webhooksChanged()
+	return db.CreateWebhook(w)
+}
+
+// UpdateWebhook updates the webhook, the secret is kept if it's empty or the redacted placeholder
+func UpdateWebhook(w *model.Webhook) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	old, err := db.GetWebhookById(w.ID)
+	if err != nil {
+		return err
+	}
+	if w.Secret == "" || w.Secret == model.RedactedPassword {
+		w.Secret = old.Secret
+	}
+	defer webhooksChanged()
+	return db.UpdateWebhook(w)
+}
+
+func DeleteWebhookById(id uint) error {
+	defer webhooksChanged()
+	return db.DeleteWebhookById(id)
+}
+
+func GetWebhookDeliveries(webhookId uint, pageIndex, pageSize int) ([]model.WebhookDelivery, int64, error) {
+	return db.GetWebhookDeliveries(webhookId, pageIndex, pageSize)
+}
@@ -26,10 +26,6 @@ var (
 	Quit = atomic.Pointer[chan struct{}]{}
 )
 
-func Running() bool {
-	return Quit.Load() != nil
-}
-
 func BuildIndex(ctx context.Context, indexPaths, ignorePaths []string, maxDepth int, count bool) error {
 	var (
 		err      error
@@ -43,6 +39,11 @@ func BuildIndex(ctx context.Context, indexPaths, ignorePaths []string, maxDepth
 		// other goroutine is running
 		return errs.BuildIndexIsRunning
 	}
+	if !lockIndex() {
+		// other node is running
+		Quit.Store(nil)
+		return errs.BuildIndexIsRunning
+	}
 	var (
 		indexMQ = mq.NewInMemoryMQ[ObjWithParent]()
 		running = atomic.Bool{} // current goroutine running
@@ -54,6 +55,7 @@ func BuildIndex(ctx context.Context, indexPaths, ignorePaths []string, maxDepth
 		ticker := time.NewTicker(time.Second)
 		defer func() {
 			Quit.Store(nil)
+			unlockIndex()
 			wg.Done()
 			// notify walk to exit when StopIndex api called
 			running.Store(false)
@@ -64,6 +66,9 @@ func BuildIndex(ctx context.Context, indexPaths, ignorePaths []string, maxDepth
 			select {
 			case <-ticker.C:
 				tickCount += 1
+				if tickCount == 5 {
+					renewIndexLock()
+				}
 				if indexMQ.Len() < 1000 && tickCount != 5 {
 					continue
 				} else if tickCount >= 5 {
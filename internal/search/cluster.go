@@ -0,0 +1,94 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/cluster"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	indexLockKey     = "index.build"
+	indexLockTTL     = time.Minute
+	indexStopChannel = "index.stop"
+)
+
+func init() {
+	cluster.Subscribe(indexStopChannel, func(json.RawMessage) {
+		stopLocal()
+	})
+}
+
+// shared reports whether the index is shared by the nodes of a cluster, a bleve index lives on every node
+func shared() bool {
+	return cluster.Enabled() && instance != nil && instance.Config().Name != "bleve"
+}
+
+// lockIndex makes sure that a shared index is built by one node at a time
+func lockIndex() bool {
+	if !shared() {
+		return true
+	}
+	ok, err := cluster.Lock(context.Background(), indexLockKey, indexLockTTL)
+	if err != nil {
+		log.Errorf("failed lock index build: %+v", err)
+		return false
+	}
+	return ok
+}
+
+func renewIndexLock() {
+	if !shared() {
+		return
+	}
+	if _, err := cluster.Lock(context.Background(), indexLockKey, indexLockTTL); err != nil {
+		log.Warnf("failed renew index build lock: %+v", err)
+	}
+}
+
+func unlockIndex() {
+	if !shared() {
+		return
+	}
+	if err := cluster.Unlock(context.Background(), indexLockKey); err != nil {
+		log.Warnf("failed unlock index build: %+v", err)
+	}
+}
+
+// Running reports whether the index is being built, by any node of the cluster for a shared index
+func Running() bool {
+	if Quit.Load() != nil {
+		return true
+	}
+	if !shared() {
+		return false
+	}
+	_, ok, err := cluster.Get(context.Background(), indexLockKey)
+	return err == nil && ok
+}
+
+func stopLocal() bool {
+	quit := Quit.Load()
+	if quit == nil {
+		return false
+	}
+	select {
+	case *quit <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// Stop asks the running build to quit, wherever it runs. It returns false if no build is running.
+func Stop() bool {
+	if stopLocal() {
+		return true
+	}
+	if Running() {
+		cluster.Publish(indexStopChannel, nil)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,465 @@
+package stream
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/cache"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultBlockCache is nil if the block cache is disabled
+var DefaultBlockCache *BlockCache
+
+// BlockCache keeps the fixed size blocks of remote files on the disk,
+// the least recently used blocks are evicted once the total size exceeds the cap.
+// The index is rebuilt from the disk on start, so the cache survives restarts.
+type BlockCache struct {
+	dir       string
+	blockSize int64
+	maxSize   int64
+
+	mu      sync.Mutex
+	lru     *list.List // front is the most recently used
+	entries map[string]*list.Element
+	size    int64
+	stats   cache.HitCounter
+}
+
+type cachedBlock struct {
+	name string
+	size int64
+}
+
+func NewBlockCache(dir string, blockSize, maxSize int64) (*BlockCache, error) {
+	if blockSize <= 0 || maxSize < blockSize {
+		return nil, fmt.Errorf("invalid block cache size: block %d, max %d", blockSize, maxSize)
+	}
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return nil, err
+	}
+	c := &BlockCache{
+		dir:       dir,
+		blockSize: blockSize,
+		maxSize:   maxSize,
+		lru:       list.New(),
+		entries:   make(map[string]*list.Element),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *BlockCache) load() error {
+	type found struct {
+		cachedBlock
+		modTime time.Time
+	}
+	var blocks []found
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.HasSuffix(d.Name(), ".tmp") {
+			// left by an interrupted fetch
+			_ = os.Remove(path)
+			return nil
+		}
+		if !isBlockName(d.Name()) || filepath.Base(filepath.Dir(path)) != d.Name()[:2] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		blocks = append(blocks, found{cachedBlock{name: d.Name(), size: info.Size()}, info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].modTime.Before(blocks[j].modTime)
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, b := range blocks {
+		c.entries[b.name] = c.lru.PushFront(&b.cachedBlock)
+		c.size += b.size
+	}
+	c.evict()
+	return nil
+}
+
+// isBlockName reports whether the name is made by blockCacheRangeReader.name,
+// the other files in the dir are left alone
+func isBlockName(name string) bool {
+	prefix, idx, ok := strings.Cut(name, ".")
+	if !ok || len(prefix) != hex.EncodedLen(sha1.Size) {
+		return false
+	}
+	if _, err := hex.DecodeString(prefix); err != nil {
+		return false
+	}
+	_, err := strconv.ParseUint(idx, 10, 63)
+	return err == nil
+}
+
+func (c *BlockCache) path(name string) string {
+	return filepath.Join(c.dir, name[:2], name)
+}
+
+// Size returns the total size of the cached blocks
+func (c *BlockCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Stats returns the hit/miss statistics of the blocks
+func (c *BlockCache) Stats() cache.Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats.Snapshot(len(c.entries))
+}
+
+func (c *BlockCache) has(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[name]
+	return ok
+}
+
+// open opens a cached block, the block is dropped if its size is not as expected
+func (c *BlockCache) open(name string, size int64) (*os.File, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[name]
+	if ok {
+		c.lru.MoveToFront(e)
+	}
+	c.mu.Unlock()
+	c.stats.Record(ok)
+	if !ok {
+		return nil, false
+	}
+	p := c.path(name)
+	f, err := os.Open(p)
+	if err == nil {
+		var info os.FileInfo
+		if info, err = f.Stat(); err == nil && info.Size() != size {
+			err = fmt.Errorf("unexpected size %d", info.Size())
+		}
+	}
+	if err != nil {
+		log.Warnf("drop broken cached block %s: %v", name, err)
+		if f != nil {
+			_ = f.Close()
+		}
+		c.remove(name)
+		return nil, false
+	}
+	// keep the order of lru after restart
+	now := time.Now()
+	_ = os.Chtimes(p, now, now)
+	return f, true
+}
+
+func (c *BlockCache) add(name, tmpPath string, size int64) error {
+	p := c.path(name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o777); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, p); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[name]; ok {
+		c.size -= e.Value.(*cachedBlock).size
+		c.lru.Remove(e)
+	}
+	c.entries[name] = c.lru.PushFront(&cachedBlock{name: name, size: size})
+	c.size += size
+	c.evict()
+	return nil
+}
+
+func (c *BlockCache) remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[name]; ok {
+		c.size -= e.Value.(*cachedBlock).size
+		c.lru.Remove(e)
+		delete(c.entries, name)
+	}
+	_ = os.Remove(c.path(name))
+}
+
+// evict must be called with the lock held
+func (c *BlockCache) evict() {
+	for c.size > c.maxSize {
+		e := c.lru.Back()
+		if e == nil {
+			return
+		}
+		b := e.Value.(*cachedBlock)
+		c.lru.Remove(e)
+		delete(c.entries, b.name)
+		c.size -= b.size
+		if err := os.Remove(c.path(b.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("failed remove cached block %s: %v", b.name, err)
+		}
+	}
+}
+
+// RangeReader wraps the RangeReader of a file, the file is identified by key which should change
+// once the content changes, e.g. contains the size and the modified time.
+func (c *BlockCache) RangeReader(key string, size int64, rr model.RangeReaderIF) model.RangeReaderIF {
+	sum := sha1.Sum([]byte(key))
+	return &blockCacheRangeReader{
+		c:      c,
+		prefix: hex.EncodeToString(sum[:]),
+		size:   size,
+		rr:     rr,
+	}
+}
+
+type blockCacheRangeReader struct {
+	c      *BlockCache
+	prefix string
+	size   int64
+	rr     model.RangeReaderIF
+}
+
+func (r *blockCacheRangeReader) name(idx int64) string {
+	return r.prefix + "." + strconv.FormatInt(idx, 10)
+}
+
+func (r *blockCacheRangeReader) blockLen(idx int64) int64 {
+	return min(r.c.blockSize, r.size-idx*r.c.blockSize)
+}
+
+func (r *blockCacheRangeReader) RangeRead(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
+	if httpRange.Start < 0 || httpRange.Start > r.size {
+		return nil, fmt.Errorf("range start %d out of size %d", httpRange.Start, r.size)
+	}
+	if httpRange.Length < 0 || httpRange.Start+httpRange.Length > r.size {
+		httpRange.Length = r.size - httpRange.Start
+	}
+	return &blockCacheReader{
+		ctx: ctx,
+		r:   r,
+		pos: httpRange.Start,
+		end: httpRange.Start + httpRange.Length,
+	}, nil
+}
+
+// blockCacheReader reads the cached blocks from the disk, and fetches each run of missing blocks
+// by a single request, so sequential reads are not split into many small requests
+type blockCacheReader struct {
+	ctx context.Context
+	r   *blockCacheRangeReader
+	pos int64
+	end int64
+	cur io.ReadCloser
+}
+
+func (br *blockCacheReader) Read(p []byte) (int, error) {
+	for {
+		if br.cur == nil {
+			if br.pos >= br.end {
+				return 0, io.EOF
+			}
+			cur, err := br.next()
+			if err != nil {
+				return 0, err
+			}
+			br.cur = cur
+		}
+		n, err := br.cur.Read(p)
+		br.pos += int64(n)
+		if err == io.EOF {
+			err = br.cur.Close()
+			br.cur = nil
+			if n > 0 || err != nil {
+				return n, err
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (br *blockCacheReader) Close() error {
+	if br.cur != nil {
+		return br.cur.Close()
+	}
+	return nil
+}
+
+func (br *blockCacheReader) next() (io.ReadCloser, error) {
+	r := br.r
+	bs := r.c.blockSize
+	idx := br.pos / bs
+	if f, ok := r.c.open(r.name(idx), r.blockLen(idx)); ok {
+		off := br.pos - idx*bs
+		return &cachedBlockReader{
+			SectionReader: io.NewSectionReader(f, off, min(r.blockLen(idx), br.end-idx*bs)-off),
+			f:             f,
+		}, nil
+	}
+	// the run of missing blocks till the block of end
+	last := (br.end - 1) / bs
+	runEnd := idx + 1
+	for runEnd <= last && !r.c.has(r.name(runEnd)) {
+		runEnd++
+	}
+	start := idx * bs
+	up, err := r.rr.RangeRead(br.ctx, http_range.Range{Start: start, Length: min(runEnd*bs, r.size) - start})
+	if err != nil {
+		return nil, err
+	}
+	return &blockFetcher{
+		r:         r,
+		up:        up,
+		idx:       idx,
+		skip:      br.pos - start,
+		remaining: min(runEnd*bs, br.end) - br.pos,
+	}, nil
+}
+
+type cachedBlockReader struct {
+	*io.SectionReader
+	f *os.File
+}
+
+func (r *cachedBlockReader) Close() error {
+	return r.f.Close()
+}
+
+// blockFetcher reads a run of blocks from the upstream, and saves each completed block into the cache
+type blockFetcher struct {
+	r  *blockCacheRangeReader
+	up io.ReadCloser
+	// the block being written
+	idx     int64
+	tmp     *os.File
+	written int64
+	failed  bool
+	// bytes before the requested start
+	skip      int64
+	remaining int64
+}
+
+func (f *blockFetcher) Read(p []byte) (int, error) {
+	if f.skip > 0 {
+		buf := make([]byte, min(f.skip, 32*utils.KB))
+		for f.skip > 0 {
+			n, err := io.ReadFull(f.up, buf[:min(f.skip, int64(len(buf)))])
+			f.save(buf[:n])
+			f.skip -= int64(n)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	if f.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > f.remaining {
+		p = p[:f.remaining]
+	}
+	n, err := f.up.Read(p)
+	f.save(p[:n])
+	f.remaining -= int64(n)
+	if err == io.EOF && f.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	} else if f.remaining <= 0 {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *blockFetcher) save(b []byte) {
+	for len(b) > 0 && !f.failed {
+		if f.tmp == nil {
+			tmp, err := os.CreateTemp(f.r.c.dir, "*.tmp")
+			if err != nil {
+				f.fail(err)
+				return
+			}
+			f.tmp, f.written = tmp, 0
+		}
+		blockLen := f.r.blockLen(f.idx)
+		n := min(int64(len(b)), blockLen-f.written)
+		if _, err := f.tmp.Write(b[:n]); err != nil {
+			f.fail(err)
+			return
+		}
+		f.written += n
+		b = b[n:]
+		if f.written == blockLen {
+			tmpPath := f.tmp.Name()
+			err := f.tmp.Close()
+			f.tmp = nil
+			if err == nil {
+				err = f.r.c.add(f.r.name(f.idx), tmpPath, blockLen)
+			}
+			if err != nil {
+				_ = os.Remove(tmpPath)
+				f.fail(err)
+				return
+			}
+			f.idx++
+		}
+	}
+}
+
+func (f *blockFetcher) fail(err error) {
+	log.Warnf("failed save block into cache: %v", err)
+	f.failed = true
+	f.discard()
+}
+
+func (f *blockFetcher) discard() {
+	if f.tmp != nil {
+		_ = f.tmp.Close()
+		_ = os.Remove(f.tmp.Name())
+		f.tmp = nil
+	}
+}
+
+func (f *blockFetcher) Close() error {
+	if f.remaining <= 0 && f.tmp != nil && !f.failed {
+		// finish the last block, it's at most one block
+		_, _ = utils.CopyWithBuffer(writerFunc(func(p []byte) (int, error) {
+			f.save(p)
+			return len(p), nil
+		}), io.LimitReader(f.up, f.r.blockLen(f.idx)-f.written))
+	}
+	f.discard()
+	return f.up.Close()
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (w writerFunc) Write(p []byte) (int, error) {
+	return w(p)
+}
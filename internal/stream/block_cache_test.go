@@ -0,0 +1,84 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
+)
+
+func TestBlockCache_RangeRead(t *testing.T) {
+	data := []byte("github.com/OpenListTeam/OpenList/internal/stream")
+	var requests int
+	upstream := RangeReaderFunc(func(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
+		requests++
+		return io.NopCloser(bytes.NewReader(data[httpRange.Start : httpRange.Start+httpRange.Length])), nil
+	})
+	c, err := NewBlockCache(t.TempDir(), 8, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := c.RangeReader("key", int64(len(data)), upstream)
+	read := func(start, length int64) {
+		t.Helper()
+		rc, err := rr.RangeRead(context.Background(), http_range.Range{Start: start, Length: length})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := data[start : start+length]; !bytes.Equal(got, want) {
+			t.Fatalf("range %d-%d: got %q, want %q", start, length, got, want)
+		}
+	}
+
+	read(3, 10)
+	if requests != 1 || c.Size() != 16 {
+		t.Fatalf("requests %d, cached %d", requests, c.Size())
+	}
+	read(0, 16)
+	if requests != 1 {
+		t.Fatalf("cached blocks are requested again")
+	}
+	read(5, int64(len(data))-5)
+	if requests != 2 || c.Size() != int64(len(data)) {
+		t.Fatalf("requests %d, cached %d", requests, c.Size())
+	}
+
+	// the index is rebuilt from the disk
+	c2, err := NewBlockCache(c.dir, 8, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c2.Size() > 32 {
+		t.Fatalf("cache exceeds the cap: %d", c2.Size())
+	}
+}
+
+func TestBlockCache_LoadForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"x", "ab/a", "ab/abcd.1", "zz/" + strings.Repeat("a", 40) + ".1"} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("data"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := NewBlockCache(dir, 8, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Size() != 0 {
+		t.Fatalf("foreign files are indexed: %d", c.Size())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"time"
 
+	"github.com/OpenListTeam/OpenList/v4/internal/metrics"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/pkg/http_range"
 	"golang.org/x/time/rate"
@@ -34,6 +35,47 @@ var (
 	ServerUploadLimit   Limiter
 )
 
+// blockBurstLimiter waits for n tokens in steps of the burst, so reading
+// more than the burst at once doesn't fail
+type blockBurstLimiter struct {
+	*rate.Limiter
+	name string
+}
+
+func (l blockBurstLimiter) WaitN(ctx context.Context, total int) error {
+	if l.Limiter.Limit() != rate.Inf {
+		start := time.Now()
+		defer func() { metrics.AddLimiterWait(l.name, time.Since(start)) }()
+	}
+	for total > 0 {
+		n := l.Burst()
+		if l.Limiter.Limit() == rate.Inf || n > total {
+			n = total
+		}
+		err := l.Limiter.WaitN(ctx, n)
+		if err != nil {
+			return err
+		}
+		total -= n
+	}
+	return nil
+}
+
+// NewSpeedLimiter returns a limiter of speed KB/s, negative means unlimited.
+// The name labels the time spent waiting in metrics.
+func NewSpeedLimiter(name string, speed int) Limiter {
+	limit, burst := SpeedLimit(speed)
+	return blockBurstLimiter{Limiter: rate.NewLimiter(limit, burst), name: name}
+}
+
+// SpeedLimit converts a speed in KB/s to the limit and burst of a limiter
+func SpeedLimit(speed int) (rate.Limit, int) {
+	if speed < 0 {
+		return rate.Inf, 0
+	}
+	return rate.Limit(speed) * 1024.0, speed * 1024
+}
+
 type RateLimitReader struct {
 	io.Reader
 	Limiter Limiter
@@ -0,0 +1,17 @@
+package task
+
+// Hook is called after a task is finished, succeeded or failed
+type Hook func(t TaskExtensionInfo, succeeded bool)
+
+var hooks []Hook
+
+func RegisterHook(hook Hook) {
+	hooks = append(hooks, hook)
+}
+
+// HandleHook should be called in the OnSucceeded and OnFailed of the tasks
+func HandleHook(t TaskExtensionInfo, succeeded bool) {
+	for _, hook := range hooks {
+		hook(t, succeeded)
+	}
+}
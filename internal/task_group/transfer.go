@@ -20,6 +20,12 @@ type SrcPathToRemove string
 // ActualPath
 type DstPathToRefresh string
 
+// PostTransferAction is run after all payloads of the group are handled,
+// dstPath is the mount path the group transferred into
+type PostTransferAction interface {
+	Run(ctx context.Context, dstPath string)
+}
+
 func RefreshAndRemove(dstPath string, payloads ...any) {
 	dstStorage, dstActualPath, err := op.GetStorageAndActualPath(dstPath)
 	if err != nil {
@@ -37,6 +43,7 @@ func RefreshAndRemove(dstPath string, payloads ...any) {
 		listLimiter = rate.NewLimiter(rate.Limit(dstHandleHookLimit), 1)
 	}
 	var ctx context.Context
+	var actions []PostTransferAction
 	for _, payload := range payloads {
 		switch p := payload.(type) {
 		case DstPathToRefresh:
@@ -69,8 +76,13 @@ func RefreshAndRemove(dstPath string, payloads ...any) {
 			if err != nil {
 				log.Error(err)
 			}
+		case PostTransferAction:
+			actions = append(actions, p)
 		}
 	}
+	for _, action := range actions {
+		action.Run(context.Background(), dstPath)
+	}
 }
 
 func verifyAndRemove(ctx context.Context, srcStorage, dstStorage driver.Driver, srcPath, dstPath string, refresh bool) error {
@@ -0,0 +1,290 @@
+// Package traffic limits the speed, the concurrent transfers and the request
+// rate of each user, role and client IP. The counters live in memory of the
+// node serving the request.
+package traffic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// idleTimeout is how long an unused bucket is kept, its limiters are full again by then
+const idleTimeout = 10 * time.Minute
+
+type scope struct {
+	// name is the kind of the scope, it labels the limiters in metrics
+	name  string
+	key   string
+	limit model.TrafficLimit
+}
+
+type bucket struct {
+	limit    model.TrafficLimit
+	download stream.Limiter
+	upload   stream.Limiter
+	requests *rate.Limiter
+	conns    int
+	lastUsed time.Time
+}
+
+func newBucket(s scope) *bucket {
+	return &bucket{
+		limit:    s.limit,
+		download: stream.NewSpeedLimiter(s.name, speed(s.limit.DownloadSpeed)),
+		upload:   stream.NewSpeedLimiter(s.name, speed(s.limit.UploadSpeed)),
+		requests: rate.NewLimiter(requestLimit(s.limit.Requests)),
+	}
+}
+
+func (b *bucket) update(l model.TrafficLimit) {
+	if b.limit == l {
+		return
+	}
+	b.limit = l
+	limit, burst := stream.SpeedLimit(speed(l.DownloadSpeed))
+	b.download.SetLimit(limit)
+	b.download.SetBurst(burst)
+	limit, burst = stream.SpeedLimit(speed(l.UploadSpeed))
+	b.upload.SetLimit(limit)
+	b.upload.SetBurst(burst)
+	limit, burst = requestLimit(l.Requests)
+	b.requests.SetLimit(limit)
+	b.requests.SetBurst(burst)
+}
+
+// speed maps the unset value to unlimited
+func speed(v int) int {
+	if v <= 0 {
+		return -1
+	}
+	return v
+}
+
+func requestLimit(perMinute int) (rate.Limit, int) {
+	if perMinute <= 0 {
+		return rate.Inf, 0
+	}
+	return rate.Limit(perMinute) / 60, perMinute
+}
+
+var (
+	mu        sync.Mutex
+	buckets   = make(map[string]*bucket)
+	lastSweep time.Time
+)
+
+// getBucket must be called with mu held
+func getBucket(s scope, now time.Time) *bucket {
+	if now.Sub(lastSweep) > idleTimeout {
+		for k, b := range buckets {
+			if b.conns == 0 && now.Sub(b.lastUsed) > idleTimeout {
+				delete(buckets, k)
+			}
+		}
+		lastSweep = now
+	}
+	b, ok := buckets[s.key]
+	if !ok {
+		b = newBucket(s)
+		buckets[s.key] = b
+	} else {
+		b.update(s.limit)
+	}
+	b.lastUsed = now
+	return b
+}
+
+// Session holds the limits of one request or transfer until it is released
+type Session struct {
+	buckets  []*bucket
+	download []stream.Limiter
+	upload   []stream.Limiter
+	once     sync.Once
+}
+
+// Acquire takes a transfer slot and a request from every scope of the user and the client IP.
+// The user may be nil if it is unknown, then only the limits of the IP apply.
+func Acquire(u *model.User, ip string) (*Session, error) {
+	scopes := getScopes(u, ip)
+	s := &Session{}
+	if len(scopes) == 0 {
+		return s, nil
+	}
+	now := time.Now()
+	mu.Lock()
+	defer mu.Unlock()
+	bs := make([]*bucket, len(scopes))
+	for i, sc := range scopes {
+		bs[i] = getBucket(sc, now)
+		if c := bs[i].limit.Connections; c > 0 && bs[i].conns >= c {
+			return nil, errs.NewErr(errs.TooManyConnections, "%s limit is %d", sc.name, c)
+		}
+	}
+	// a request is reserved from every scope first, so a scope that is out of
+	// requests doesn't use up the requests of the scopes checked before it
+	rs := make([]*rate.Reservation, 0, len(scopes))
+	for i, sc := range scopes {
+		r := bs[i].requests.ReserveN(now, 1)
+		if !r.OK() || r.DelayFrom(now) > 0 {
+			r.CancelAt(now)
+			for _, r := range rs {
+				r.CancelAt(now)
+			}
+			return nil, errs.NewErr(errs.TooManyRequests, "%s limit is %d per minute", sc.name, bs[i].limit.Requests)
+		}
+		rs = append(rs, r)
+	}
+	for _, b := range bs {
+		b.conns++
+		if b.limit.DownloadSpeed > 0 {
+			s.download = append(s.download, b.download)
+		}
+		if b.limit.UploadSpeed > 0 {
+			s.upload = append(s.upload, b.upload)
+		}
+	}
+	s.buckets = bs
+	return s, nil
+}
+
+// Release gives back the transfer slots, it's safe to call more than once
+func (s *Session) Release() {
+	s.once.Do(func() {
+		if len(s.buckets) == 0 {
+			return
+		}
+		now := time.Now()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, b := range s.buckets {
+			b.conns--
+			b.lastUsed = now
+		}
+	})
+}
+
+func (s *Session) WaitDownload(ctx context.Context, n int) error {
+	for _, l := range s.download {
+		if err := l.WaitN(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Session) WaitUpload(ctx context.Context, n int) error {
+	for _, l := range s.upload {
+		if err := l.WaitN(ctx, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writer limits the data written to w by the download limits
+func (s *Session) Writer(ctx context.Context, w io.Writer) io.Writer {
+	for _, l := range s.download {
+		w = &stream.RateLimitWriter{Writer: w, Limiter: l, Ctx: ctx}
+	}
+	return w
+}
+
+// Reader limits the data read from r by the upload limits
+func (s *Session) Reader(ctx context.Context, r io.ReadCloser) io.ReadCloser {
+	for _, l := range s.upload {
+		r = &stream.RateLimitReader{Reader: r, Limiter: l, Ctx: ctx}
+	}
+	return r
+}
+
+func getScopes(u *model.User, ip string) []scope {
+	var scopes []scope
+	if u != nil {
+		// admins are only limited by their own overrides
+		var l model.TrafficLimit
+		if !u.IsAdmin() {
+			l = model.TrafficLimit{
+				DownloadSpeed: setting.GetInt(conf.UserMaxDownloadSpeed, -1),
+				UploadSpeed:   setting.GetInt(conf.UserMaxUploadSpeed, -1),
+				Connections:   setting.GetInt(conf.UserMaxConnections, -1),
+				Requests:      setting.GetInt(conf.UserMaxRequests, -1),
+			}
+		}
+		l = l.Override(u.Limit)
+		if l.Limited() {
+			scopes = append(scopes, scope{name: "user", key: "user:" + strconv.Itoa(int(u.ID)), limit: l})
+		}
+		role := roleName(u.Role)
+		if l, ok := getRoleLimits()[role]; ok && l.Limited() {
+			scopes = append(scopes, scope{name: "role", key: "role:" + role, limit: l})
+		}
+	}
+	if ip = clientIP(ip); ip != "" {
+		l := model.TrafficLimit{
+			DownloadSpeed: setting.GetInt(conf.IPMaxDownloadSpeed, -1),
+			UploadSpeed:   setting.GetInt(conf.IPMaxUploadSpeed, -1),
+			Connections:   setting.GetInt(conf.IPMaxConnections, -1),
+			Requests:      setting.GetInt(conf.IPMaxRequests, -1),
+		}
+		if l.Limited() {
+			scopes = append(scopes, scope{name: "ip", key: "ip:" + ip, limit: l})
+		}
+	}
+	return scopes
+}
+
+// clientIP strips the port of the remote address of FTP and SFTP clients
+func clientIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func roleName(role int) string {
+	switch role {
+	case model.ADMIN:
+		return "admin"
+	case model.GUEST:
+		return "guest"
+	default:
+		return "general"
+	}
+}
+
+var roleLimits struct {
+	sync.Mutex
+	raw    string
+	limits map[string]model.TrafficLimit
+}
+
+// getRoleLimits parses the setting again only when it has changed
+func getRoleLimits() map[string]model.TrafficLimit {
+	raw := setting.GetStr(conf.RoleTrafficLimits)
+	roleLimits.Lock()
+	defer roleLimits.Unlock()
+	if raw == roleLimits.raw && roleLimits.limits != nil {
+		return roleLimits.limits
+	}
+	limits := make(map[string]model.TrafficLimit)
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &limits); err != nil {
+			log.Warnf("invalid %s: %+v", conf.RoleTrafficLimits, err)
+		}
+	}
+	roleLimits.raw, roleLimits.limits = raw, limits
+	return limits
+}
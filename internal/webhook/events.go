@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/driver"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/task"
+)
+
+type FileData struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	Username string `json:"username,omitempty"`
+}
+
+type TaskData struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Creator    string `json:"creator,omitempty"`
+	Error      string `json:"error,omitempty"`
+	TotalBytes int64  `json:"total_bytes"`
+}
+
+type StorageData struct {
+	ID        uint   `json:"id"`
+	MountPath string `json:"mount_path"`
+	Driver    string `json:"driver"`
+	OldStatus string `json:"old_status"`
+	Status    string `json:"status"`
+}
+
+type ShareData struct {
+	ID      string   `json:"id"`
+	Files   []string `json:"files"`
+	Creator string   `json:"creator,omitempty"`
+	IP      string   `json:"ip"`
+	// number of accesses including this one
+	Accessed int `json:"accessed"`
+}
+
+// EmitFile emits an event of the file operated by the user in ctx
+func EmitFile(ctx context.Context, event, path string, size int64) {
+	data := FileData{Path: path, Size: size}
+	if user, ok := ctx.Value(conf.UserKey).(*model.User); ok && user != nil {
+		data.Username = user.Username
+	}
+	Emit(event, data)
+}
+
+func EmitShareAccessed(s *model.Sharing, ip string) {
+	data := ShareData{ID: s.ID, Files: s.Files, IP: ip, Accessed: s.Accessed}
+	if s.Creator != nil {
+		data.Creator = s.Creator.Username
+	}
+	Emit(model.WebhookEventShareAccessed, data)
+}
+
+func init() {
+	task.RegisterHook(func(t task.TaskExtensionInfo, succeeded bool) {
+		data := TaskData{ID: t.GetID(), Name: t.GetName(), TotalBytes: t.GetTotalBytes()}
+		if creator := t.GetCreator(); creator != nil {
+			data.Creator = creator.Username
+		}
+		event := model.WebhookEventTaskSucceeded
+		if !succeeded {
+			event = model.WebhookEventTaskFailed
+			if err := t.GetErr(); err != nil {
+				data.Error = err.Error()
+			}
+		}
+		Emit(event, data)
+	})
+	op.RegisterStorageStatusHook(func(storage driver.Driver, oldStatus, newStatus string) {
+		s := storage.GetStorage()
+		Emit(model.WebhookEventStorageStatusChange, StorageData{
+			ID:        s.ID,
+			MountPath: s.MountPath,
+			Driver:    s.Driver,
+			OldStatus: oldStatus,
+			Status:    newStatus,
+		})
+	})
+}
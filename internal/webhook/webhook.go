@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/cluster"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/net"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
+	"github.com/OpenListTeam/OpenList/v4/pkg/sign"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	HeaderEvent     = "X-OpenList-Event"
+	HeaderDelivery  = "X-OpenList-Delivery"
+	HeaderSignature = "X-OpenList-Signature"
+)
+
+const (
+	queueSize     = 1024
+	workers       = 4
+	timeout       = 10 * time.Second
+	signExpire    = 5 * time.Minute
+	minBackoff    = 10 * time.Second
+	maxBackoff    = 30 * time.Minute
+	cleanInterval = time.Hour
+)
+
+// Payload is the json body posted to the webhooks
+type Payload struct {
+	Event string `json:"event"`
+	Time  int64  `json:"time"`
+	Data  any    `json:"data"`
+}
+
+type delivery struct {
+	webhook model.Webhook
+	record  *model.WebhookDelivery
+}
+
+var (
+	queue     = make(chan *delivery, queueSize)
+	startOnce sync.Once
+	client    *http.Client
+)
+
+// Emit delivers the event to the enabled webhooks accepting it in background
+func Emit(event string, data any) {
+	webhooks, err := op.GetEnabledWebhooks()
+	if err != nil {
+		log.Errorf("failed get webhooks: %+v", err)
+		return
+	}
+	var payload string
+	for _, w := range webhooks {
+		if !w.Accept(event) {
+			continue
+		}
+		if payload == "" {
+			payload, err = utils.Json.MarshalToString(Payload{Event: event, Time: time.Now().Unix(), Data: data})
+			if err != nil {
+				log.Errorf("failed marshal webhook payload of %s: %+v", event, err)
+				return
+			}
+			startOnce.Do(start)
+		}
+		enqueue(&delivery{
+			webhook: w,
+			record: &model.WebhookDelivery{
+				WebhookID: w.ID,
+				CreatedAt: time.Now(),
+				Event:     event,
+				Payload:   payload,
+			},
+		})
+	}
+}
+
+func enqueue(d *delivery) {
+	select {
+	case queue <- d:
+	default:
+		log.Warnf("webhook queue is full, drop %s delivery to %s", d.record.Event, d.webhook.URL)
+	}
+}
+
+func start() {
+	client = net.NewHttpClient()
+	client.Timeout = timeout
+	for i := 0; i < workers; i++ {
+		go func() {
+			for d := range queue {
+				deliver(d)
+			}
+		}()
+	}
+	go func() {
+		ticker := time.NewTicker(cleanInterval)
+		defer ticker.Stop()
+		for {
+			clean()
+			<-ticker.C
+		}
+	}()
+}
+
+func deliver(d *delivery) {
+	r := d.record
+	if r.ID == 0 {
+		// create the record first, so the id can be sent as the delivery id
+		if err := db.CreateWebhookDelivery(r); err != nil {
+			log.Errorf("failed save webhook delivery: %+v", err)
+		}
+	}
+	r.Attempts++
+	start := time.Now()
+	r.StatusCode, r.Error = 0, ""
+	code, err := post(d.webhook, r)
+	r.Duration = time.Since(start).Milliseconds()
+	r.StatusCode = code
+	r.Success = err == nil
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if r.ID != 0 {
+		if err := db.UpdateWebhookDelivery(r); err != nil {
+			log.Errorf("failed update webhook delivery: %+v", err)
+		}
+	}
+	if err == nil {
+		return
+	}
+	if r.Attempts > setting.GetInt(conf.WebhookMaxRetries, 5) {
+		log.Warnf("failed deliver %s to webhook [%s] after %d attempts: %v", r.Event, d.webhook.Name, r.Attempts, err)
+		return
+	}
+	time.AfterFunc(backoff(r.Attempts), func() { enqueue(d) })
+}
+
+func post(w model.Webhook, r *model.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader([]byte(r.Payload)))
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "OpenList-Webhook/"+conf.Version)
+	req.Header.Set(HeaderEvent, r.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(r.ID), 10))
+	if w.Secret != "" {
+		// receivers verify it by sign.NewHMACSign(secret).Verify(body, signature)
+		req.Header.Set(HeaderSignature, sign.NewHMACSign([]byte(w.Secret)).
+			Sign(r.Payload, time.Now().Add(signExpire).Unix()))
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return res.StatusCode, errors.Errorf("unexpected status: %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+func backoff(attempts int) time.Duration {
+	d := minBackoff << (attempts - 1)
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+func clean() {
+	// deliveries of all nodes are in one table
+	if !cluster.IsLeader() {
+		return
+	}
+	days := setting.GetInt(conf.WebhookRetentionDays, 0)
+	if days <= 0 {
+		return
+	}
+	n, err := db.DeleteWebhookDeliveriesBefore(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Errorf("failed clean webhook deliveries: %+v", err)
+	} else if n > 0 {
+		log.Infof("cleaned %d webhook deliveries older than %d days", n, days)
+	}
+}
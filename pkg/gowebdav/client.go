@@ -331,6 +331,19 @@ func (c *Client) Copy(oldpath, newpath string, overwrite bool) error {
 	return c.copymove("COPY", oldpath, newpath, overwrite)
 }
 
+// SetModTime sets the modification time of a remote file or directory,
+// servers that treat getlastmodified as protected refuse it
+func (c *Client) SetModTime(path string, modTime time.Time) error {
+	return c.proppatch(path,
+		`<d:propertyupdate xmlns:d='DAV:'>
+			<d:set>
+				<d:prop>
+					<d:getlastmodified>`+modTime.UTC().Format(http.TimeFormat)+`</d:getlastmodified>
+				</d:prop>
+			</d:set>
+		</d:propertyupdate>`)
+}
+
 // Read reads the contents of a remote file
 func (c *Client) Read(path string) ([]byte, error) {
 	var stream io.ReadCloser
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
 )
 
@@ -141,6 +142,50 @@ func (c *Client) propfind(path string, self bool, body string, resp interface{},
 	return parseXML(rs.Body, resp, parse)
 }
 
+func (c *Client) proppatch(path string, body string) error {
+	rs, err := c.req("PROPPATCH", path, strings.NewReader(body), func(rq *http.Request) {
+		rq.Header.Add("Content-Type", "application/xml;charset=UTF-8")
+		rq.Header.Add("Accept", "application/xml,text/xml")
+		rq.Header.Add("Accept-Charset", "utf-8")
+		rq.Header.Add("Accept-Encoding", "")
+	})
+	if err != nil {
+		return err
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode != 207 {
+		return newPathError("PROPPATCH", path, rs.StatusCode)
+	}
+
+	// the properties are set together, any status other than 200 means none is set
+	status := 0
+	err = parseXML(rs.Body, &response{}, func(resp interface{}) error {
+		r := resp.(*response)
+		for _, p := range r.Props {
+			// e.g. HTTP/1.1 403 Forbidden
+			code := http.StatusInternalServerError
+			if fields := strings.Fields(p.Status); len(fields) > 1 {
+				if n, err := strconv.Atoi(fields[1]); err == nil {
+					code = n
+				}
+			}
+			if code != 200 && status == 0 {
+				status = code
+			}
+		}
+		r.Props = nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return newPathError("PROPPATCH", path, status)
+	}
+	return nil
+}
+
 func (c *Client) doCopyMove(
 	method string,
 	oldpath string,
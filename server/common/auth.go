@@ -1,10 +1,14 @@
 package common
 
 import (
+	"context"
+	"encoding/json"
 	"time"
 
+	"github.com/OpenListTeam/OpenList/v4/internal/cluster"
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
 	"github.com/OpenListTeam/go-cache"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/pkg/errors"
@@ -18,8 +22,24 @@ type UserClaims struct {
 	jwt.RegisteredClaims
 }
 
+// validTokenCache is keyed by the token hash, which is also the key shared with the other nodes of a cluster
 var validTokenCache = cache.NewMemCache[bool]()
 
+const tokenInvalidatedChannel = "token.invalidated"
+
+func init() {
+	cluster.Subscribe(tokenInvalidatedChannel, func(payload json.RawMessage) {
+		var key string
+		if err := json.Unmarshal(payload, &key); err == nil {
+			validTokenCache.Del(key)
+		}
+	})
+}
+
+func tokenKey(tokenString string) string {
+	return utils.HashData(utils.SHA256, []byte(tokenString))
+}
+
 func GenerateToken(user *model.User) (tokenString string, err error) {
 	claim := UserClaims{
 		Username: user.Username,
@@ -34,7 +54,14 @@ func GenerateToken(user *model.User) (tokenString string, err error) {
 	if err != nil {
 		return "", err
 	}
-	validTokenCache.Set(tokenString, true)
+	key := tokenKey(tokenString)
+	validTokenCache.Set(key, true)
+	if cluster.Enabled() {
+		ttl := time.Duration(conf.Conf.TokenExpiresIn) * time.Hour
+		if err := cluster.Set(context.Background(), "token:"+key, "1", ttl); err != nil {
+			return "", errors.WithMessage(err, "failed share token")
+		}
+	}
 	return tokenString, err
 }
 
@@ -68,11 +95,30 @@ func InvalidateToken(tokenString string) error {
 	if tokenString == "" {
 		return nil // don't invalidate empty guest token
 	}
-	validTokenCache.Del(tokenString)
+	key := tokenKey(tokenString)
+	validTokenCache.Del(key)
+	if cluster.Enabled() {
+		if err := cluster.Del(context.Background(), "token:"+key); err != nil {
+			return errors.WithMessage(err, "failed invalidate shared token")
+		}
+		cluster.Publish(tokenInvalidatedChannel, key)
+	}
 	return nil
 }
 
 func IsTokenInvalidated(tokenString string) bool {
-	_, ok := validTokenCache.Get(tokenString)
-	return !ok
+	key := tokenKey(tokenString)
+	if _, ok := validTokenCache.Get(key); ok {
+		return false
+	}
+	if !cluster.Enabled() {
+		return true
+	}
+	// the token may be issued by another node
+	_, ok, err := cluster.Get(context.Background(), "token:"+key)
+	if err != nil || !ok {
+		return true
+	}
+	validTokenCache.Set(key, true)
+	return false
 }
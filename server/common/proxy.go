@@ -10,6 +10,7 @@ import (
 	"maps"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/metrics"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/net"
 	"github.com/OpenListTeam/OpenList/v4/internal/sign"
@@ -23,6 +24,7 @@ func Proxy(w http.ResponseWriter, r *http.Request, link *model.Link, file model.
 	// 	http.ServeContent(w, r, file.GetName(), file.ModTime(), link.MFile)
 	// 	return nil
 	// }
+	w = &metrics.CountingResponseWriter{ResponseWriter: w}
 
 	if link.Concurrency > 0 || link.PartSize > 0 {
 		attachHeader(w, file, link)
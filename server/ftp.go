@@ -14,6 +14,7 @@ import (
 	"sync"
 
 	"github.com/OpenListTeam/OpenList/v4/drivers/base"
+	"github.com/OpenListTeam/OpenList/v4/internal/audit"
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
@@ -66,13 +67,13 @@ func NewMainDriver() (*FtpMainDriver, error) {
 			ConnectionTimeout:        conf.Conf.FTP.ConnectionTimeout,
 			DisableMLSD:              false,
 			DisableMLST:              false,
-			DisableMFMT:              true,
+			DisableMFMT:              false,
 			Banner:                   setting.GetStr(conf.Announcement),
 			TLSRequired:              tlsRequired,
 			DisableLISTArgs:          false,
 			DisableSite:              false,
 			DisableActiveMode:        conf.Conf.FTP.DisableActiveMode,
-			EnableHASH:               false,
+			EnableHASH:               true,
 			DisableSTAT:              false,
 			DisableSYST:              false,
 			EnableCOMB:               false,
@@ -141,6 +142,7 @@ func (d *FtpMainDriver) AuthUser(cc ftpserver.ClientContext, user, pass string)
 		ctx = context.WithValue(ctx, conf.MetaPassKey, "")
 	}
 	ctx = context.WithValue(ctx, conf.ClientIPKey, cc.RemoteAddr().String())
+	ctx = context.WithValue(ctx, conf.ProtocolKey, audit.ProtocolFTP)
 	ctx = context.WithValue(ctx, conf.ProxyHeaderKey, d.proxyHeader)
 	return ftp.NewAferoAdapter(ctx), nil
 }
@@ -2,6 +2,7 @@ package ftp
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -26,6 +27,13 @@ func NewAferoAdapter(ctx context.Context) *AferoAdapter {
 	return &AferoAdapter{ctx: ctx}
 }
 
+// WithContext returns an adapter of the same user whose calls are also canceled with ctx
+func (a *AferoAdapter) WithContext(ctx context.Context) *AferoAdapter {
+	c, cancel := context.WithCancel(a.ctx)
+	context.AfterFunc(ctx, cancel)
+	return NewAferoAdapter(c)
+}
+
 func (a *AferoAdapter) Create(_ string) (afero.File, error) {
 	// See also GetHandle
 	return nil, errs.NotImplement
@@ -61,6 +69,10 @@ func (a *AferoAdapter) Rename(oldName, newName string) error {
 	return Rename(a.ctx, oldName, newName)
 }
 
+func (a *AferoAdapter) Copy(src, dstDir string) error {
+	return Copy(a.ctx, src, dstDir)
+}
+
 func (a *AferoAdapter) Stat(name string) (os.FileInfo, error) {
 	return Stat(a.ctx, name)
 }
@@ -77,8 +89,33 @@ func (a *AferoAdapter) Chown(_ string, _, _ int) error {
 	return errs.NotSupport
 }
 
-func (a *AferoAdapter) Chtimes(_ string, _ time.Time, _ time.Time) error {
-	return errs.NotSupport
+func (a *AferoAdapter) Truncate(name string, size int64) error {
+	return Truncate(a.ctx, name, size)
+}
+
+func (a *AferoAdapter) Chtimes(name string, _ time.Time, mtime time.Time) error {
+	return SetModTime(a.ctx, name, mtime)
+}
+
+// Hash see also Hash of the package
+func (a *AferoAdapter) Hash(name, algo string, start, end, blockSize int64) ([][]byte, error) {
+	return Hash(a.ctx, name, algo, start, end, blockSize)
+}
+
+var ftpHashAlgos = map[ftpserver.HASHAlgo]string{
+	ftpserver.HASHAlgoCRC32:  "crc32",
+	ftpserver.HASHAlgoMD5:    "md5",
+	ftpserver.HASHAlgoSHA1:   "sha1",
+	ftpserver.HASHAlgoSHA256: "sha256",
+	ftpserver.HASHAlgoSHA512: "sha512",
+}
+
+func (a *AferoAdapter) ComputeHash(name string, algo ftpserver.HASHAlgo, startOffset, endOffset int64) (string, error) {
+	sums, err := a.Hash(name, ftpHashAlgos[algo], startOffset, endOffset, 0)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sums[0]), nil
 }
 
 func (a *AferoAdapter) ReadDir(name string) ([]os.FileInfo, error) {
@@ -3,6 +3,7 @@ package ftp
 import (
 	"context"
 	stdpath "path"
+	"time"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
@@ -85,3 +86,65 @@ func Rename(ctx context.Context, oldPath, newPath string) error {
 		return err
 	}
 }
+
+func Copy(ctx context.Context, srcPath, dstDir string) error {
+	user := ctx.Value(conf.UserKey).(*model.User)
+	if !user.CanCopy() || !user.CanFTPManage() {
+		return errs.PermissionDenied
+	}
+	srcPath, err := user.JoinPath(srcPath)
+	if err != nil {
+		return err
+	}
+	dstDir, err = user.JoinPath(dstDir)
+	if err != nil {
+		return err
+	}
+	_, err = fs.Copy(ctx, srcPath, dstDir)
+	return err
+}
+
+// Truncate only accepts the size of the file, which can't be changed,
+// the size of a file being written is the one of the data written
+func Truncate(ctx context.Context, path string, size int64) error {
+	user := ctx.Value(conf.UserKey).(*model.User)
+	reqPath, err := user.JoinPath(path)
+	if err != nil {
+		return err
+	}
+	if isUploading(reqPath) {
+		return nil
+	}
+	info, err := Stat(ctx, path)
+	if err != nil {
+		return err
+	}
+	if info.Size() != size {
+		return errs.NotSupport
+	}
+	return nil
+}
+
+// SetModTime changes the modification time of a file,
+// the time of a file being written or uploaded is applied once it's stored
+func SetModTime(ctx context.Context, path string, mtime time.Time) error {
+	user := ctx.Value(conf.UserKey).(*model.User)
+	reqPath, err := user.JoinPath(path)
+	if err != nil {
+		return err
+	}
+	if err = uploadAuth(ctx, reqPath); err != nil {
+		return err
+	}
+	if setUploadModTime(reqPath, mtime) {
+		return nil
+	}
+	err = SetStageModTime(reqPath, mtime, func(target string) {
+		ctx := context.WithValue(context.Background(), conf.UserKey, user)
+		_ = fs.SetModTime(ctx, target, mtime, time.Time{})
+	})
+	if !errors.Is(err, errs.ObjectNotFound) {
+		return err
+	}
+	return fs.SetModTime(ctx, reqPath, mtime, time.Time{})
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/internal/traffic"
 	"github.com/OpenListTeam/OpenList/v4/server/common"
 	"github.com/pkg/errors"
 )
@@ -21,7 +22,8 @@ import (
 type FileDownloadProxy struct {
 	model.File
 	io.Closer
-	ctx context.Context
+	ctx     context.Context
+	session *traffic.Session
 }
 
 func OpenDownload(ctx context.Context, reqPath string, offset int64) (*FileDownloadProxy, error) {
@@ -40,8 +42,13 @@ func OpenDownload(ctx context.Context, reqPath string, offset int64) (*FileDownl
 	// directly use proxy
 	header, _ := ctx.Value(conf.ProxyHeaderKey).(http.Header)
 	ip, _ := ctx.Value(conf.ClientIPKey).(string)
+	session, err := traffic.Acquire(user, ip)
+	if err != nil {
+		return nil, err
+	}
 	link, obj, err := fs.Link(ctx, reqPath, model.LinkArgs{IP: ip, Header: header})
 	if err != nil {
+		session.Release()
 		return nil, err
 	}
 	ss, err := stream.NewSeekableStream(&stream.FileStream{
@@ -49,15 +56,17 @@ func OpenDownload(ctx context.Context, reqPath string, offset int64) (*FileDownl
 		Ctx: ctx,
 	}, link)
 	if err != nil {
+		session.Release()
 		_ = link.Close()
 		return nil, err
 	}
 	reader, err := stream.NewReadAtSeeker(ss, offset)
 	if err != nil {
+		session.Release()
 		_ = ss.Close()
 		return nil, err
 	}
-	return &FileDownloadProxy{File: reader, Closer: ss, ctx: ctx}, nil
+	return &FileDownloadProxy{File: reader, Closer: ss, ctx: ctx, session: session}, nil
 }
 
 func (f *FileDownloadProxy) Read(p []byte) (n int, err error) {
@@ -65,7 +74,10 @@ func (f *FileDownloadProxy) Read(p []byte) (n int, err error) {
 	if err != nil {
 		return n, err
 	}
-	err = stream.ClientDownloadLimit.WaitN(f.ctx, n)
+	if err = stream.ClientDownloadLimit.WaitN(f.ctx, n); err != nil {
+		return n, err
+	}
+	err = f.session.WaitDownload(f.ctx, n)
 	return n, err
 }
 
@@ -74,10 +86,18 @@ func (f *FileDownloadProxy) ReadAt(p []byte, off int64) (n int, err error) {
 	if err != nil {
 		return n, err
 	}
-	err = stream.ClientDownloadLimit.WaitN(f.ctx, n)
+	if err = stream.ClientDownloadLimit.WaitN(f.ctx, n); err != nil {
+		return n, err
+	}
+	err = f.session.WaitDownload(f.ctx, n)
 	return n, err
 }
 
+func (f *FileDownloadProxy) Close() error {
+	f.session.Release()
+	return f.Closer.Close()
+}
+
 func (f *FileDownloadProxy) Write(p []byte) (n int, err error) {
 	return 0, errs.NotSupport
 }
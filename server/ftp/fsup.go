@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	stdpath "path"
+	"sync"
 	"time"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
@@ -17,6 +18,7 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/setting"
 	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/internal/traffic"
 	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
 	"github.com/OpenListTeam/OpenList/v4/server/common"
 	ftpserver "github.com/fclairamb/ftpserverlib"
@@ -25,10 +27,11 @@ import (
 
 type FileUploadProxy struct {
 	ftpserver.FileTransfer
-	buffer *os.File
-	path   string
-	ctx    context.Context
-	trunc  bool
+	buffer  *os.File
+	path    string
+	ctx     context.Context
+	trunc   bool
+	session *traffic.Session
 }
 
 func uploadAuth(ctx context.Context, path string) error {
@@ -46,6 +49,52 @@ func uploadAuth(ctx context.Context, path string) error {
 	return nil
 }
 
+// uploadModTimes holds the modification times set while the files are being written,
+// the zero time means the file is open but no time is set yet
+var uploadModTimes = struct {
+	sync.Mutex
+	m map[string]time.Time
+}{m: make(map[string]time.Time)}
+
+func beginUpload(path string) {
+	uploadModTimes.Lock()
+	defer uploadModTimes.Unlock()
+	uploadModTimes.m[path] = time.Time{}
+}
+
+// endUpload returns the modification time set while the file was open
+func endUpload(path string) time.Time {
+	uploadModTimes.Lock()
+	defer uploadModTimes.Unlock()
+	t := uploadModTimes.m[path]
+	delete(uploadModTimes.m, path)
+	return t
+}
+
+func isUploading(path string) bool {
+	uploadModTimes.Lock()
+	defer uploadModTimes.Unlock()
+	_, ok := uploadModTimes.m[path]
+	return ok
+}
+
+// setUploadModTime returns false if the file isn't being written
+func setUploadModTime(path string, t time.Time) bool {
+	uploadModTimes.Lock()
+	defer uploadModTimes.Unlock()
+	if _, ok := uploadModTimes.m[path]; !ok {
+		return false
+	}
+	uploadModTimes.m[path] = t
+	return true
+}
+
+func acquireTraffic(ctx context.Context) (*traffic.Session, error) {
+	user := ctx.Value(conf.UserKey).(*model.User)
+	ip, _ := ctx.Value(conf.ClientIPKey).(string)
+	return traffic.Acquire(user, ip)
+}
+
 func OpenUpload(ctx context.Context, path string, trunc bool) (*FileUploadProxy, error) {
 	err := uploadAuth(ctx, path)
 	if err != nil {
@@ -56,11 +105,17 @@ func OpenUpload(ctx context.Context, path string, trunc bool) (*FileUploadProxy,
 	if setting.GetBool(conf.IgnoreSystemFiles) && utils.IsSystemFile(name) {
 		return nil, errs.IgnoredSystemFile
 	}
+	session, err := acquireTraffic(ctx)
+	if err != nil {
+		return nil, err
+	}
 	tmpFile, err := os.CreateTemp(conf.Conf.TempDir, "file-*")
 	if err != nil {
+		session.Release()
 		return nil, err
 	}
-	return &FileUploadProxy{buffer: tmpFile, path: path, ctx: ctx, trunc: trunc}, nil
+	beginUpload(path)
+	return &FileUploadProxy{buffer: tmpFile, path: path, ctx: ctx, trunc: trunc, session: session}, nil
 }
 
 func (f *FileUploadProxy) Read(p []byte) (n int, err error) {
@@ -72,7 +127,10 @@ func (f *FileUploadProxy) Write(p []byte) (n int, err error) {
 	if err != nil {
 		return n, err
 	}
-	err = stream.ClientUploadLimit.WaitN(f.ctx, n)
+	if err = stream.ClientUploadLimit.WaitN(f.ctx, n); err != nil {
+		return n, err
+	}
+	err = f.session.WaitUpload(f.ctx, n)
 	return n, err
 }
 
@@ -81,6 +139,11 @@ func (f *FileUploadProxy) Seek(offset int64, whence int) (int64, error) {
 }
 
 func (f *FileUploadProxy) Close() error {
+	f.session.Release()
+	modTime := endUpload(f.path)
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
 	dir, name := stdpath.Split(f.path)
 	size, err := f.buffer.Seek(0, io.SeekCurrent)
 	if err != nil {
@@ -90,7 +153,7 @@ func (f *FileUploadProxy) Close() error {
 		return err
 	}
 	arr := make([]byte, 512)
-	if _, err := f.buffer.Read(arr); err != nil {
+	if _, err := f.buffer.Read(arr); err != nil && !errors.Is(err, io.EOF) {
 		return err
 	}
 	contentType := http.DetectContentType(arr)
@@ -123,7 +186,7 @@ func (f *FileUploadProxy) Close() error {
 		Obj: &model.Object{
 			Name:     name,
 			Size:     size,
-			Modified: time.Now(),
+			Modified: modTime,
 		},
 		Mimetype:     contentType,
 		WebPutAsTask: true,
@@ -144,6 +207,7 @@ func (f *FileUploadProxy) Close() error {
 type FileUploadWithLengthProxy struct {
 	ftpserver.FileTransfer
 	ctx           context.Context
+	session       *traffic.Session
 	path          string
 	length        int64
 	first512Bytes [512]byte
@@ -162,10 +226,15 @@ func OpenUploadWithLength(ctx context.Context, path string, trunc bool, length i
 	if setting.GetBool(conf.IgnoreSystemFiles) && utils.IsSystemFile(name) {
 		return nil, errs.IgnoredSystemFile
 	}
+	session, err := acquireTraffic(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if trunc {
 		_ = fs.Remove(ctx, path)
 	}
-	return &FileUploadWithLengthProxy{ctx: ctx, path: path, length: length}, nil
+	beginUpload(path)
+	return &FileUploadWithLengthProxy{ctx: ctx, session: session, path: path, length: length}, nil
 }
 
 func (f *FileUploadWithLengthProxy) Read(p []byte) (n int, err error) {
@@ -224,7 +293,10 @@ func (f *FileUploadWithLengthProxy) Write(p []byte) (n int, err error) {
 	if err != nil {
 		return n, err
 	}
-	err = stream.ClientUploadLimit.WaitN(f.ctx, n)
+	if err = stream.ClientUploadLimit.WaitN(f.ctx, n); err != nil {
+		return n, err
+	}
+	err = f.session.WaitUpload(f.ctx, n)
 	return n, err
 }
 
@@ -233,6 +305,17 @@ func (f *FileUploadWithLengthProxy) Seek(offset int64, whence int) (int64, error
 }
 
 func (f *FileUploadWithLengthProxy) Close() error {
+	f.session.Release()
+	modTime := endUpload(f.path)
+	err := f.close()
+	if err == nil && !modTime.IsZero() {
+		// the stream is made before the time is known
+		_ = fs.SetModTime(f.ctx, f.path, modTime, time.Time{})
+	}
+	return err
+}
+
+func (f *FileUploadWithLengthProxy) close() error {
 	if f.pipeWriter != nil {
 		err := f.pipeWriter.Close()
 		if err != nil {
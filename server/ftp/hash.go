@@ -0,0 +1,93 @@
+package ftp
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+var hashAlgos = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"crc32":  func() hash.Hash { return crc32.NewIEEE() },
+}
+
+// MaxHashBlocks bounds the digests returned by Hash, the SFTP reply of a check-file
+// request holding the sha512 digests of all the blocks must fit in a packet
+const MaxHashBlocks = 4000
+
+// SupportedHash reports whether Hash accepts the algorithm
+func SupportedHash(algo string) bool {
+	_, ok := hashAlgos[algo]
+	return ok
+}
+
+// Hash returns the digests of the consecutive blocks of [start, end) of the file,
+// or a single digest if blockSize is not positive. A negative end means the end of the file.
+// The hash known by the storage is used if the whole file is hashed, otherwise the file is read.
+func Hash(ctx context.Context, path, algo string, start, end, blockSize int64) ([][]byte, error) {
+	newHash, ok := hashAlgos[algo]
+	if !ok {
+		return nil, errors.WithMessagef(errs.NotSupport, "hash algorithm %s", algo)
+	}
+	info, err := Stat(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, errs.NotFile
+	}
+	size := info.Size()
+	if end < 0 || end > size {
+		end = size
+	}
+	if start < 0 || start > end {
+		return nil, errors.Errorf("invalid range %d-%d", start, end)
+	}
+	if blockSize <= 0 || blockSize > end-start {
+		blockSize = end - start
+	}
+	if blockSize > 0 && (end-start+blockSize-1)/blockSize > MaxHashBlocks {
+		return nil, errors.Errorf("too many blocks to hash, %d at most", MaxHashBlocks)
+	}
+	if obj, ok := info.Sys().(model.Obj); ok && start == 0 && end == size && blockSize == size {
+		if ht, ok := utils.GetHashByName(algo); ok {
+			if sum, err := hex.DecodeString(obj.GetHash().GetHash(ht)); err == nil && len(sum) == newHash().Size() {
+				return [][]byte{sum}, nil
+			}
+		}
+	}
+	user := ctx.Value(conf.UserKey).(*model.User)
+	reqPath, err := user.JoinPath(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := OpenDownload(ctx, reqPath, start)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var sums [][]byte
+	for off := start; off < end || len(sums) == 0; off += blockSize {
+		h := newHash()
+		if _, err = io.CopyN(h, f, min(blockSize, end-off)); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		sums = append(sums, h.Sum(nil))
+	}
+	return sums, nil
+}
@@ -39,6 +39,7 @@ type UploadingFile struct {
 	softLinks   []patricia.Prefix
 	mvCallback  func(string)
 	rmCallback  func()
+	mtCallback  func(string)
 }
 
 func (u *UploadingFile) SetRemoveCallback(rm func()) {
@@ -126,10 +127,20 @@ func drop(path patricia.Prefix) {
 			stage.Delete(sl)
 		}
 		stage.Delete(path)
-		if s.currentPath != string(path) {
-			if s.currentPath != "" {
-				go s.mvCallback(s.currentPath)
-			}
+		if s.currentPath == "" {
+			return
+		}
+		moved := s.currentPath != string(path)
+		if moved || s.mtCallback != nil {
+			currentPath, mt := s.currentPath, s.mtCallback
+			go func() {
+				if moved {
+					s.mvCallback(currentPath)
+				}
+				if mt != nil {
+					mt(currentPath)
+				}
+			}()
 		}
 	}
 }
@@ -230,6 +241,29 @@ func RemoveStage(path string) error {
 	return nil
 }
 
+// SetStageModTime changes the modification time of an uploading file,
+// apply is called with the final path of the file once the upload is finished
+func SetStageModTime(path string, t time.Time, apply func(string)) error {
+	stageMutex.Lock()
+	defer stageMutex.Unlock()
+	v := stage.Get(patricia.Prefix(path))
+	if v == nil {
+		return errs.ObjectNotFound
+	}
+	s, ok := v.(*UploadingFile)
+	if !ok {
+		s = v.(*softLink).target
+	}
+	if s.currentPath != path {
+		return ErrStageMoved
+	}
+	s.modTime = t
+	s.mtCallback = apply
+	// StatStage reads the time from the buffer file
+	_ = os.Chtimes(s.name, time.Time{}, t)
+	return nil
+}
+
 type BorrowedFile struct {
 	file *os.File
 	path patricia.Prefix
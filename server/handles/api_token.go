@@ -0,0 +1,101 @@
+package handles
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+type APITokenCreateReq struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    string     `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type APITokenCreateResp struct {
+	model.APIToken
+	// Token is only shown once
+	Token string `json:"token"`
+}
+
+// apiTokenOwner returns the user logged in, tokens can't be managed with an api token
+func apiTokenOwner(c *gin.Context) (*model.User, bool) {
+	user, ok := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if !ok || user.IsGuest() {
+		common.ErrorStrResp(c, "user invalid", 401)
+		return nil, false
+	}
+	if _, ok := c.Request.Context().Value(conf.APITokenKey).(*model.APIToken); ok {
+		common.ErrorStrResp(c, "api tokens can't be managed with an api token", 403)
+		return nil, false
+	}
+	return user, true
+}
+
+func ListMyAPITokens(c *gin.Context) {
+	user, ok := apiTokenOwner(c)
+	if !ok {
+		return
+	}
+	var req model.PageReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	req.Validate()
+	tokens, total, err := op.GetAPITokensByUserId(user.ID, req.Page, req.PerPage)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c, common.PageResp{
+		Content: tokens,
+		Total:   total,
+	})
+}
+
+func CreateMyAPIToken(c *gin.Context) {
+	user, ok := apiTokenOwner(c)
+	if !ok {
+		return
+	}
+	var req APITokenCreateReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	t := model.APIToken{
+		UserId:    user.ID,
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	token, err := op.CreateAPIToken(&t)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	common.SuccessResp(c, APITokenCreateResp{APIToken: t, Token: token})
+}
+
+func RevokeMyAPIToken(c *gin.Context) {
+	user, ok := apiTokenOwner(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		common.ErrorStrResp(c, "id format invalid", 400)
+		return
+	}
+	if err := op.DeleteAPIToken(uint(id), user.ID); err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c)
+}
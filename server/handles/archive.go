@@ -301,6 +301,11 @@ func FsArchiveDecompress(c *gin.Context) {
 func ArchiveDown(c *gin.Context) {
 	archiveRawPath := c.Request.Context().Value(conf.PathKey).(string)
 	innerPath := utils.FixAndCleanPath(c.Query("inner"))
+	if tool.IsNestedPath(innerPath) {
+		// nested archives can only be extracted by the tools
+		ArchiveInternalExtract(c)
+		return
+	}
 	password := c.Query("pass")
 	filename := stdpath.Base(innerPath)
 	storage, err := fs.GetStorage(archiveRawPath, &fs.GetStoragesArgs{})
@@ -335,6 +340,11 @@ func ArchiveDown(c *gin.Context) {
 func ArchiveProxy(c *gin.Context) {
 	archiveRawPath := c.Request.Context().Value(conf.PathKey).(string)
 	innerPath := utils.FixAndCleanPath(c.Query("inner"))
+	if tool.IsNestedPath(innerPath) {
+		// nested archives can only be extracted by the tools
+		ArchiveInternalExtract(c)
+		return
+	}
 	password := c.Query("pass")
 	filename := stdpath.Base(innerPath)
 	storage, err := fs.GetStorage(archiveRawPath, &fs.GetStoragesArgs{})
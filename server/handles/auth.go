@@ -82,7 +82,9 @@ func loginHash(c *gin.Context, req *LoginReq) {
 
 type UserResp struct {
 	model.User
-	Otp bool `json:"otp"`
+	Otp        bool         `json:"otp"`
+	Quota      *model.Quota `json:"quota,omitempty"`
+	GroupQuota *model.Quota `json:"group_quota,omitempty"`
 }
 
 // CurrentUser get current user by token
@@ -96,6 +98,14 @@ func CurrentUser(c *gin.Context) {
 	if userResp.OtpSecret != "" {
 		userResp.Otp = true
 	}
+	if op.HasQuota(user) {
+		var err error
+		userResp.Quota, userResp.GroupQuota, err = op.GetUserQuota(user)
+		if err != nil {
+			common.ErrorResp(c, err, 500)
+			return
+		}
+	}
 	common.SuccessResp(c, userResp)
 }
 
@@ -0,0 +1,16 @@
+package handles
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+func CacheStats(c *gin.Context) {
+	stats := op.Cache.Stats()
+	if bc := stream.DefaultBlockCache; bc != nil {
+		stats["block"] = bc.Stats()
+	}
+	common.SuccessResp(c, stats)
+}
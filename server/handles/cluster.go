@@ -0,0 +1,24 @@
+package handles
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/cluster"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+type ClusterStatusResp struct {
+	Enabled  bool   `json:"enabled"`
+	NodeID   string `json:"node_id"`
+	Leader   string `json:"leader"`
+	IsLeader bool   `json:"is_leader"`
+}
+
+func GetClusterStatus(c *gin.Context) {
+	resp := ClusterStatusResp{
+		Enabled:  cluster.Enabled(),
+		NodeID:   cluster.NodeID(),
+		IsLeader: cluster.IsLeader(),
+	}
+	resp.Leader, _ = cluster.Leader(c)
+	common.SuccessResp(c, resp)
+}
@@ -0,0 +1,49 @@
+package handles
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/drivers/crypt"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+type CryptRekeyReq struct {
+	ID       uint   `json:"id" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Salt     string `json:"salt"`
+	// DstPath is where the re-encrypted data stores, empty to rewrite in place
+	DstPath string `json:"dst_path"`
+}
+
+// CryptRekey queues a task re-encrypting the data of a crypt storage under the new password and salt
+func CryptRekey(c *gin.Context) {
+	var req CryptRekeyReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	s, err := db.GetStorageById(req.ID)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	storage, err := op.GetStorageByMountPath(s.MountPath)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	d, ok := storage.(*crypt.Crypt)
+	if !ok {
+		common.ErrorStrResp(c, "the storage is not a Crypt storage", 400)
+		return
+	}
+	t, err := d.StartRekey(c.Request.Context(), req.Password, req.Salt, req.DstPath)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	common.SuccessResp(c, gin.H{
+		"task": getTaskInfo(t),
+	})
+}
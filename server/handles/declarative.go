@@ -0,0 +1,61 @@
+package handles
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/declarative"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+type ExportConfigReq struct {
+	Passphrase string `json:"passphrase"`
+}
+
+func ExportConfig(c *gin.Context) {
+	var req ExportConfigReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	d, err := declarative.Export(req.Passphrase)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	data, err := d.Marshal()
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c, gin.H{"content": string(data)})
+}
+
+type ApplyConfigReq struct {
+	Content    string `json:"content" binding:"required"`
+	Passphrase string `json:"passphrase"`
+	DryRun     bool   `json:"dry_run"`
+	Prune      bool   `json:"prune"`
+}
+
+func ApplyConfig(c *gin.Context) {
+	var req ApplyConfigReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	d, err := declarative.Parse([]byte(req.Content))
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	changes, err := declarative.Apply(c.Request.Context(), d, declarative.ApplyOptions{
+		Passphrase:   req.Passphrase,
+		DryRun:       req.DryRun,
+		Prune:        req.Prune,
+		LoadStorages: true,
+	})
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c, gin.H{"changes": changes})
+}
@@ -72,15 +72,10 @@ func UpdateIndex(c *gin.Context) {
 }
 
 func StopIndex(c *gin.Context) {
-	quit := search.Quit.Load()
-	if quit == nil {
+	if !search.Stop() {
 		common.ErrorStrResp(c, "index is not running", 400)
 		return
 	}
-	select {
-	case *quit <- struct{}{}:
-	default:
-	}
 	common.SuccessResp(c)
 }
 
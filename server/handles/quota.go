@@ -0,0 +1,171 @@
+package handles
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+func ListQuotaGroups(c *gin.Context) {
+	var req model.PageReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	req.Validate()
+	groups, total, err := op.GetQuotaGroups(req.Page, req.PerPage)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c, common.PageResp{
+		Content: groups,
+		Total:   total,
+	})
+}
+
+type QuotaGroupResp struct {
+	model.QuotaGroup
+	Quota *model.Quota `json:"quota"`
+}
+
+func GetQuotaGroup(c *gin.Context) {
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	group, err := op.GetQuotaGroupById(uint(id))
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	quota, err := op.GetGroupQuota(group)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c, QuotaGroupResp{QuotaGroup: *group, Quota: quota})
+}
+
+func CreateQuotaGroup(c *gin.Context) {
+	var req model.QuotaGroup
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	if err := op.CreateQuotaGroup(&req); err != nil {
+		common.ErrorResp(c, err, 500, true)
+	} else {
+		common.SuccessResp(c)
+	}
+}
+
+func UpdateQuotaGroup(c *gin.Context) {
+	var req model.QuotaGroup
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	if err := op.UpdateQuotaGroup(&req); err != nil {
+		common.ErrorResp(c, err, 500, true)
+	} else {
+		common.SuccessResp(c)
+	}
+}
+
+func DeleteQuotaGroup(c *gin.Context) {
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	if err := op.DeleteQuotaGroupById(uint(id)); err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c)
+}
+
+type UserQuotaResp struct {
+	Quota      *model.Quota `json:"quota"`
+	GroupQuota *model.Quota `json:"group_quota"`
+}
+
+func GetUserQuota(c *gin.Context) {
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	user, err := op.GetUserById(uint(id))
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	var resp UserQuotaResp
+	resp.Quota, resp.GroupQuota, err = op.GetUserQuota(user)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c, resp)
+}
+
+// ReconcileQuota recounts the usage of the user given by id synchronously,
+// or of all users with a quota in background if id is empty
+func ReconcileQuota(c *gin.Context) {
+	idStr := c.Query("id")
+	if idStr == "" {
+		go func() {
+			if err := fs.ReconcileAllQuotas(context.Background()); err != nil {
+				log.Errorf("failed reconcile quotas: %+v", err)
+			}
+		}()
+		common.SuccessResp(c)
+		return
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	user, err := op.GetUserById(uint(id))
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	usage, err := fs.ReconcileQuota(c.Request.Context(), user)
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c, usage)
+}
+
+// reconcileQuotaChange recounts the usage in background if the quota of the user is changed
+func reconcileQuotaChange(old, u *model.User) {
+	if !op.HasQuota(u) {
+		return
+	}
+	if old != nil && op.HasQuota(old) && old.QuotaGroupID == u.QuotaGroupID && old.BasePath == u.BasePath {
+		return
+	}
+	user := *u
+	go func() {
+		if _, err := fs.ReconcileQuota(context.Background(), &user); err != nil {
+			log.Errorf("failed reconcile usage of user %s: %+v", user.Username, err)
+		}
+		if old != nil && old.QuotaGroupID != 0 && old.QuotaGroupID != user.QuotaGroupID {
+			if err := op.RecountGroupUsage(old.QuotaGroupID); err != nil {
+				log.Errorf("failed recount usage of quota group %d: %+v", old.QuotaGroupID, err)
+			}
+		}
+	}()
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/OpenListTeam/OpenList/v4/internal/audit"
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/driver"
 	"github.com/OpenListTeam/OpenList/v4/internal/errs"
@@ -13,6 +14,7 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/setting"
 	"github.com/OpenListTeam/OpenList/v4/internal/sharing"
+	"github.com/OpenListTeam/OpenList/v4/internal/webhook"
 	"github.com/OpenListTeam/OpenList/v4/pkg/utils"
 	"github.com/OpenListTeam/OpenList/v4/server/common"
 	"github.com/OpenListTeam/go-cache"
@@ -538,7 +540,9 @@ func CreateSharing(c *gin.Context) {
 		Creator: user,
 	}
 	var id string
-	if id, err = op.CreateSharing(s); err != nil {
+	id, err = op.CreateSharing(s)
+	audit.Record(c, audit.OpShareCreate, strings.Join(req.Files, "\n"), id, 0, err)
+	if err != nil {
 		common.ErrorResp(c, err, 500)
 	} else {
 		s.ID = id
@@ -558,7 +562,9 @@ func DeleteSharing(c *gin.Context) {
 		common.ErrorResp(c, err, 404)
 		return
 	}
-	if err = op.DeleteSharing(sid); err != nil {
+	err = op.DeleteSharing(sid)
+	audit.Record(c, audit.OpShareDelete, strings.Join(s.Files, "\n"), sid, 0, err)
+	if err != nil {
 		common.ErrorResp(c, err, 500)
 	} else {
 		common.SuccessResp(c)
@@ -594,6 +600,7 @@ func countAccess(ip string, s *model.Sharing) error {
 	if !ok {
 		AccessCache.Set(key, struct{}{}, cache.WithEx[interface{}](AccessCountDelay))
 		s.Accessed += 1
+		webhook.EmitShareAccessed(s, ip)
 		return op.UpdateSharing(s, true)
 	}
 	return nil
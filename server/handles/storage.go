@@ -3,7 +3,9 @@ package handles
 import (
 	"context"
 	"errors"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
@@ -208,3 +210,32 @@ func LoadAllStorages(c *gin.Context) {
 	}(storages)
 	common.SuccessResp(c)
 }
+
+func ListStorageHealth(c *gin.Context) {
+	health := op.HealthChecker.GetHealth()
+	slices.SortFunc(health, func(a, b op.StorageHealth) int {
+		return strings.Compare(a.MountPath, b.MountPath)
+	})
+	common.SuccessResp(c, health)
+}
+
+// CheckStorageHealth checks the storage immediately, and re-inits it if it's in error state
+func CheckStorageHealth(c *gin.Context) {
+	idStr := c.Query("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	storage, err := db.GetStorageById(uint(id))
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	storageDriver, err := op.GetStorageByMountPath(storage.MountPath)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	common.SuccessResp(c, op.HealthChecker.Check(c.Request.Context(), storageDriver))
+}
@@ -0,0 +1,68 @@
+package handles
+
+import (
+	"strconv"
+
+	"github.com/OpenListTeam/OpenList/v4/drivers/strm"
+	"github.com/OpenListTeam/OpenList/v4/internal/db"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+func getStrmStorage(c *gin.Context, id uint) (*strm.Strm, bool) {
+	s, err := db.GetStorageById(id)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return nil, false
+	}
+	storage, err := op.GetStorageByMountPath(s.MountPath)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return nil, false
+	}
+	d, ok := storage.(*strm.Strm)
+	if !ok {
+		common.ErrorStrResp(c, "the storage is not a Strm storage", 400)
+		return nil, false
+	}
+	return d, true
+}
+
+type StrmResyncReq struct {
+	ID uint `json:"id" binding:"required"`
+	// Scan only reports the orphans without writing anything
+	Scan          bool `json:"scan"`
+	RemoveOrphans bool `json:"remove_orphans"`
+}
+
+// StrmResync starts writing all the local strm files of the storage, GetStrmReport gets the progress and the orphans
+func StrmResync(c *gin.Context) {
+	var req StrmResyncReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	d, ok := getStrmStorage(c, req.ID)
+	if !ok {
+		return
+	}
+	if err := d.StartResync(c.Request.Context(), req.Scan, req.RemoveOrphans); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	common.SuccessResp(c)
+}
+
+func GetStrmReport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	d, ok := getStrmStorage(c, uint(id))
+	if !ok {
+		return
+	}
+	common.SuccessResp(c, d.GetSyncReport())
+}
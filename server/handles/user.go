@@ -45,6 +45,7 @@ func CreateUser(c *gin.Context) {
 	if err := op.CreateUser(&req); err != nil {
 		common.ErrorResp(c, err, 500, true)
 	} else {
+		reconcileQuotaChange(nil, &req)
 		common.SuccessResp(c)
 	}
 }
@@ -81,6 +82,7 @@ func UpdateUser(c *gin.Context) {
 	if err := op.UpdateUser(&req); err != nil {
 		common.ErrorResp(c, err, 500)
 	} else {
+		reconcileQuotaChange(user, &req)
 		common.SuccessResp(c)
 	}
 }
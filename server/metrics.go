@@ -0,0 +1,82 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/drivers/crypt"
+	"github.com/OpenListTeam/OpenList/v4/internal/fs"
+	"github.com/OpenListTeam/OpenList/v4/internal/metrics"
+	"github.com/OpenListTeam/OpenList/v4/internal/offline_download/tool"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/internal/task"
+	"github.com/OpenListTeam/OpenList/v4/server/middlewares"
+	"github.com/OpenListTeam/tache"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func Metrics(g *gin.RouterGroup) {
+	registerTaskMetrics("upload", fs.UploadTaskManager)
+	registerTaskMetrics("copy", fs.CopyTaskManager)
+	registerTaskMetrics("move", fs.MoveTaskManager)
+	registerTaskMetrics("offline_download", tool.DownloadTaskManager)
+	registerTaskMetrics("offline_download_transfer", tool.TransferTaskManager)
+	registerTaskMetrics("decompress", fs.ArchiveDownloadTaskManager)
+	registerTaskMetrics("decompress_upload", fs.ArchiveContentUploadTaskManager)
+	registerTaskMetrics("crypt_rekey", crypt.RekeyTaskManager)
+	metrics.MustRegister(cacheCollector{})
+	// prometheus sends the token as a bearer token
+	bearer := func(c *gin.Context) {
+		if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			c.Request.Header.Set("Authorization", token)
+		}
+		c.Next()
+	}
+	g.GET("/metrics", bearer, middlewares.Auth(false), middlewares.AuthAdmin, gin.WrapH(metrics.Handler()))
+}
+
+func registerTaskMetrics[T task.TaskExtensionInfo](name string, manager task.Manager[T]) {
+	depth := func(state string, states ...tache.State) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   metrics.Namespace,
+			Subsystem:   "task",
+			Name:        "queue_depth",
+			Help:        "Number of the tasks waiting or running in each task manager.",
+			ConstLabels: prometheus.Labels{"manager": name, "state": state},
+		}, func() float64 {
+			return float64(len(manager.GetByState(states...)))
+		})
+	}
+	metrics.MustRegister(
+		depth("pending", tache.StatePending, tache.StateWaitingRetry, tache.StateBeforeRetry),
+		depth("running", tache.StateRunning),
+	)
+}
+
+var (
+	cacheHitsDesc   = prometheus.NewDesc(metrics.Namespace+"_cache_hits_total", "Number of the cache hits.", []string{"cache"}, nil)
+	cacheMissesDesc = prometheus.NewDesc(metrics.Namespace+"_cache_misses_total", "Number of the cache misses.", []string{"cache"}, nil)
+	cacheRatioDesc  = prometheus.NewDesc(metrics.Namespace+"_cache_hit_ratio", "Ratio of the cache hits since start.", []string{"cache"}, nil)
+)
+
+// cacheCollector collects the hit statistics of op.Cache and the block cache
+type cacheCollector struct{}
+
+func (cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheHitsDesc
+	ch <- cacheMissesDesc
+	ch <- cacheRatioDesc
+}
+
+func (cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := op.Cache.Stats()
+	if bc := stream.DefaultBlockCache; bc != nil {
+		stats["block"] = bc.Stats()
+	}
+	for name, s := range stats {
+		ch <- prometheus.MustNewConstMetric(cacheHitsDesc, prometheus.CounterValue, float64(s.Hits), name)
+		ch <- prometheus.MustNewConstMetric(cacheMissesDesc, prometheus.CounterValue, float64(s.Misses), name)
+		ch <- prometheus.MustNewConstMetric(cacheRatioDesc, prometheus.GaugeValue, s.HitRatio, name)
+	}
+}
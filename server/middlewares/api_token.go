@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// apiTokenAuth authenticates the request by a personal API token,
+// the scopes of the token restrict what its owner may do
+func apiTokenAuth(c *gin.Context, token string) {
+	t, user, err := op.ValidateAPIToken(token)
+	if err != nil {
+		common.ErrorResp(c, err, 401)
+		c.Abort()
+		return
+	}
+	if scope := routeScope(c.FullPath()); !t.HasScope(scope) {
+		common.ErrorStrResp(c, fmt.Sprintf("api token lacks the %s scope", scope), 403)
+		c.Abort()
+		return
+	}
+	common.GinWithValue(c, conf.UserKey, user, conf.APITokenKey, t)
+	log.Debugf("use api token %d of user: %s", t.ID, user.Username)
+	c.Next()
+}
+
+var (
+	readRoutes = []string{
+		"/api/me",
+		"/api/fs/list",
+		"/api/fs/get",
+		"/api/fs/dirs",
+		"/api/fs/search",
+		"/api/fs/archive/meta",
+		"/api/fs/archive/list",
+		"/api/share/list",
+		"/api/share/get",
+	}
+	uploadRoutes = []string{
+		"/api/fs/put",
+		"/api/fs/form",
+		"/api/fs/mkdir",
+		"/api/fs/get_direct_upload_info",
+	}
+	taskRoutes = []string{
+		"/api/fs/add_offline_download",
+		"/api/fs/archive/decompress",
+	}
+)
+
+// routeScope returns the scope needed by the route, routes not listed need the admin scope
+func routeScope(fullPath string) string {
+	if i := strings.Index(fullPath, "/api/"); i >= 0 {
+		fullPath = fullPath[i:]
+	}
+	switch {
+	case slices.Contains(readRoutes, fullPath):
+		return model.ScopeRead
+	case slices.Contains(uploadRoutes, fullPath):
+		return model.ScopeUpload
+	case slices.Contains(taskRoutes, fullPath), strings.HasPrefix(fullPath, "/api/task/"):
+		return model.ScopeTasks
+	default:
+		return model.ScopeAdmin
+	}
+}
+
+// WebDAVScope returns the scope needed by the WebDAV method
+func WebDAVScope(method string) string {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PROPFIND":
+		return model.ScopeRead
+	case "PUT", "MKCOL", "LOCK", "UNLOCK":
+		return model.ScopeUpload
+	default:
+		return model.ScopeAdmin
+	}
+}
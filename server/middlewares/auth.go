@@ -2,6 +2,7 @@ package middlewares
 
 import (
 	"crypto/subtle"
+	"strings"
 
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/model"
@@ -46,6 +47,10 @@ func Auth(allowDisabledGuest bool) func(c *gin.Context) {
 			c.Next()
 			return
 		}
+		if strings.HasPrefix(token, model.APITokenPrefix) {
+			apiTokenAuth(c, token)
+			return
+		}
 		userClaims, err := common.ParseToken(token)
 		if err != nil {
 			common.ErrorResp(c, err, 401)
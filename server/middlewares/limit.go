@@ -1,9 +1,18 @@
 package middlewares
 
 import (
+	"crypto/subtle"
 	"io"
+	"net/http"
+	"strings"
 
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/model"
+	"github.com/OpenListTeam/OpenList/v4/internal/op"
+	"github.com/OpenListTeam/OpenList/v4/internal/setting"
 	"github.com/OpenListTeam/OpenList/v4/internal/stream"
+	"github.com/OpenListTeam/OpenList/v4/internal/traffic"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
 	"github.com/gin-gonic/gin"
 )
 
@@ -51,3 +60,58 @@ func DownloadRateLimiter(limiter stream.Limiter) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// TrafficLimit applies the limits of the current user and the client IP to the request.
+// Routes without authentication only know the user if the request carries a token.
+func TrafficLimit(c *gin.Context) {
+	user, ok := c.Request.Context().Value(conf.UserKey).(*model.User)
+	if !ok {
+		user = tokenUser(c)
+	}
+	s, err := traffic.Acquire(user, c.ClientIP())
+	if err != nil {
+		common.ErrorPage(c, err, http.StatusTooManyRequests)
+		return
+	}
+	defer s.Release()
+	c.Request.Body = s.Reader(c, c.Request.Body)
+	c.Writer = &ResponseWriterWrapper{
+		ResponseWriter: c.Writer,
+		WrapWriter:     s.Writer(c, c.Writer),
+	}
+	c.Next()
+}
+
+// tokenUser resolves the user of the token with the same checks as Auth, nil if the token is not valid
+func tokenUser(c *gin.Context) *model.User {
+	token := c.GetHeader("Authorization")
+	if token == "" {
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(setting.GetStr(conf.Token))) == 1 {
+		admin, err := op.GetAdmin()
+		if err != nil {
+			return nil
+		}
+		return admin
+	}
+	if strings.HasPrefix(token, model.APITokenPrefix) {
+		_, user, err := op.ValidateAPIToken(token)
+		if err != nil {
+			return nil
+		}
+		return user
+	}
+	if common.IsTokenInvalidated(token) {
+		return nil
+	}
+	claims, err := common.ParseToken(token)
+	if err != nil {
+		return nil
+	}
+	user, err := op.GetUserByName(claims.Username)
+	if err != nil || user.PwdTS != claims.PwdTS || user.Disabled {
+		return nil
+	}
+	return user
+}
@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/internal/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+var metricsGroups = []string{"d", "p", "ad", "ap", "ae", "sd", "sad", "dav", "s3", "api"}
+
+func routeGroup(path string) string {
+	path = strings.TrimPrefix(path, strings.TrimSuffix(conf.URL.Path, "/"))
+	first, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	for _, g := range metricsGroups {
+		if first == g {
+			return g
+		}
+	}
+	return "other"
+}
+
+// Metrics records the requests by route group, the group is taken from the path if empty
+func Metrics(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		g := group
+		if g == "" {
+			// handlers like s3 may rewrite the path
+			g = routeGroup(c.Request.URL.Path)
+		}
+		start := time.Now()
+		c.Next()
+		metrics.ObserveHTTP(g, c.Request.Method, c.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,15 @@
+package middlewares
+
+import (
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+	"github.com/OpenListTeam/OpenList/v4/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+// Protocol marks the requests with the protocol and client ip, which are used by audit logs
+func Protocol(protocol string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		common.GinWithValue(c, conf.ProtocolKey, protocol, conf.ClientIPKey, c.ClientIP())
+		c.Next()
+	}
+}
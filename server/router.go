@@ -2,6 +2,7 @@ package server
 
 import (
 	"github.com/OpenListTeam/OpenList/v4/cmd/flags"
+	"github.com/OpenListTeam/OpenList/v4/internal/audit"
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/message"
 	"github.com/OpenListTeam/OpenList/v4/internal/sign"
@@ -22,6 +23,7 @@ func Init(e *gin.Engine) {
 			c.Redirect(302, conf.URL.Path)
 		})
 	}
+	e.Use(middlewares.Metrics(""))
 	Cors(e)
 	g := e.Group(conf.URL.Path)
 	if conf.Conf.Scheme.HttpPort != -1 && conf.Conf.Scheme.HttpsPort != -1 && conf.Conf.Scheme.ForceHttps {
@@ -34,6 +36,7 @@ func Init(e *gin.Engine) {
 	g.GET("/robots.txt", handles.Robots)
 	g.GET("/manifest.json", static.ManifestJSON)
 	g.GET("/i/:link_name", handles.Plist)
+	Metrics(g)
 	common.SecretKey = []byte(conf.Conf.JwtSecret)
 	g.Use(middlewares.StoragesLoaded)
 	if conf.Conf.MaxConnections > 0 {
@@ -43,29 +46,30 @@ func Init(e *gin.Engine) {
 	S3(g.Group("/s3"))
 
 	downloadLimiter := middlewares.DownloadRateLimiter(stream.ClientDownloadLimit)
+	trafficLimit := middlewares.TrafficLimit
 	signCheck := middlewares.Down(sign.Verify)
-	g.GET("/d/*path", middlewares.PathParse, signCheck, downloadLimiter, handles.Down)
-	g.GET("/p/*path", middlewares.PathParse, signCheck, downloadLimiter, handles.Proxy)
+	g.GET("/d/*path", middlewares.PathParse, signCheck, downloadLimiter, trafficLimit, handles.Down)
+	g.GET("/p/*path", middlewares.PathParse, signCheck, downloadLimiter, trafficLimit, handles.Proxy)
 	g.HEAD("/d/*path", middlewares.PathParse, signCheck, handles.Down)
 	g.HEAD("/p/*path", middlewares.PathParse, signCheck, handles.Proxy)
 	archiveSignCheck := middlewares.Down(sign.VerifyArchive)
-	g.GET("/ad/*path", middlewares.PathParse, archiveSignCheck, downloadLimiter, handles.ArchiveDown)
-	g.GET("/ap/*path", middlewares.PathParse, archiveSignCheck, downloadLimiter, handles.ArchiveProxy)
-	g.GET("/ae/*path", middlewares.PathParse, archiveSignCheck, downloadLimiter, handles.ArchiveInternalExtract)
+	g.GET("/ad/*path", middlewares.PathParse, archiveSignCheck, downloadLimiter, trafficLimit, handles.ArchiveDown)
+	g.GET("/ap/*path", middlewares.PathParse, archiveSignCheck, downloadLimiter, trafficLimit, handles.ArchiveProxy)
+	g.GET("/ae/*path", middlewares.PathParse, archiveSignCheck, downloadLimiter, trafficLimit, handles.ArchiveInternalExtract)
 	g.HEAD("/ad/*path", middlewares.PathParse, archiveSignCheck, handles.ArchiveDown)
 	g.HEAD("/ap/*path", middlewares.PathParse, archiveSignCheck, handles.ArchiveProxy)
 	g.HEAD("/ae/*path", middlewares.PathParse, archiveSignCheck, handles.ArchiveInternalExtract)
 
-	g.GET("/sd/:sid", middlewares.EmptyPathParse, middlewares.SharingIdParse, downloadLimiter, handles.SharingDown)
-	g.GET("/sd/:sid/*path", middlewares.PathParse, middlewares.SharingIdParse, downloadLimiter, handles.SharingDown)
+	g.GET("/sd/:sid", middlewares.EmptyPathParse, middlewares.SharingIdParse, downloadLimiter, trafficLimit, handles.SharingDown)
+	g.GET("/sd/:sid/*path", middlewares.PathParse, middlewares.SharingIdParse, downloadLimiter, trafficLimit, handles.SharingDown)
 	g.HEAD("/sd/:sid", middlewares.EmptyPathParse, middlewares.SharingIdParse, handles.SharingDown)
 	g.HEAD("/sd/:sid/*path", middlewares.PathParse, middlewares.SharingIdParse, handles.SharingDown)
-	g.GET("/sad/:sid", middlewares.EmptyPathParse, middlewares.SharingIdParse, downloadLimiter, handles.SharingArchiveExtract)
-	g.GET("/sad/:sid/*path", middlewares.PathParse, middlewares.SharingIdParse, downloadLimiter, handles.SharingArchiveExtract)
+	g.GET("/sad/:sid", middlewares.EmptyPathParse, middlewares.SharingIdParse, downloadLimiter, trafficLimit, handles.SharingArchiveExtract)
+	g.GET("/sad/:sid/*path", middlewares.PathParse, middlewares.SharingIdParse, downloadLimiter, trafficLimit, handles.SharingArchiveExtract)
 	g.HEAD("/sad/:sid", middlewares.EmptyPathParse, middlewares.SharingIdParse, handles.SharingArchiveExtract)
 	g.HEAD("/sad/:sid/*path", middlewares.PathParse, middlewares.SharingIdParse, handles.SharingArchiveExtract)
 
-	api := g.Group("/api")
+	api := g.Group("/api", middlewares.Protocol(audit.ProtocolWeb))
 	auth := api.Group("", middlewares.Auth(false))
 	webauthn := api.Group("/authn", middlewares.Authn)
 
@@ -77,6 +81,9 @@ func Init(e *gin.Engine) {
 	auth.GET("/me/sshkey/list", handles.ListMyPublicKey)
 	auth.POST("/me/sshkey/add", handles.AddMyPublicKey)
 	auth.POST("/me/sshkey/delete", handles.DeleteMyPublicKey)
+	auth.GET("/me/token/list", handles.ListMyAPITokens)
+	auth.POST("/me/token/create", handles.CreateMyAPIToken)
+	auth.POST("/me/token/revoke", handles.RevokeMyAPIToken)
 	auth.POST("/auth/2fa/generate", handles.Generate2FA)
 	auth.POST("/auth/2fa/verify", handles.Verify2FA)
 	auth.GET("/auth/logout", handles.LogOut)
@@ -133,6 +140,15 @@ func admin(g *gin.RouterGroup) {
 	user.GET("/sshkey/list", handles.ListPublicKeys)
 	user.POST("/sshkey/delete", handles.DeletePublicKey)
 
+	quota := g.Group("/quota")
+	quota.GET("/group/list", handles.ListQuotaGroups)
+	quota.GET("/group/get", handles.GetQuotaGroup)
+	quota.POST("/group/create", handles.CreateQuotaGroup)
+	quota.POST("/group/update", handles.UpdateQuotaGroup)
+	quota.POST("/group/delete", handles.DeleteQuotaGroup)
+	quota.GET("/user", handles.GetUserQuota)
+	quota.POST("/reconcile", handles.ReconcileQuota)
+
 	storage := g.Group("/storage")
 	storage.GET("/list", handles.ListStorages)
 	storage.GET("/get", handles.GetStorage)
@@ -142,6 +158,11 @@ func admin(g *gin.RouterGroup) {
 	storage.POST("/enable", handles.EnableStorage)
 	storage.POST("/disable", handles.DisableStorage)
 	storage.POST("/load_all", handles.LoadAllStorages)
+	storage.GET("/health", handles.ListStorageHealth)
+	storage.POST("/health/check", handles.CheckStorageHealth)
+	storage.POST("/strm/resync", handles.StrmResync)
+	storage.GET("/strm/report", handles.GetStrmReport)
+	storage.POST("/crypt/rekey", handles.CryptRekey)
 
 	driver := g.Group("/driver")
 	driver.GET("/list", handles.ListDriverInfo)
@@ -166,6 +187,13 @@ func admin(g *gin.RouterGroup) {
 	setting.POST("/set_thunderx", handles.SetThunderX)
 	setting.POST("/set_thunder_browser", handles.SetThunderBrowser)
 
+	rule := g.Group("/offline_download_rule")
+	rule.GET("/list", handles.ListOfflineDownloadRules)
+	rule.GET("/get", handles.GetOfflineDownloadRule)
+	rule.POST("/create", handles.CreateOfflineDownloadRule)
+	rule.POST("/update", handles.UpdateOfflineDownloadRule)
+	rule.POST("/delete", handles.DeleteOfflineDownloadRule)
+
 	// retain /admin/task API to ensure compatibility with legacy automation scripts
 	_task(g.Group("/task"))
 
@@ -184,6 +212,26 @@ func admin(g *gin.RouterGroup) {
 	scan.POST("/start", handles.StartManualScan)
 	scan.POST("/stop", handles.StopManualScan)
 	scan.GET("/progress", handles.GetManualScanProgress)
+
+	g.GET("/cache/stats", handles.CacheStats)
+	g.GET("/cluster/status", handles.GetClusterStatus)
+
+	auditLog := g.Group("/audit")
+	auditLog.GET("/list", handles.ListAuditLogs)
+	auditLog.GET("/export", handles.ExportAuditLogs)
+
+	config := g.Group("/config")
+	config.POST("/export", handles.ExportConfig)
+	config.POST("/apply", handles.ApplyConfig)
+
+	webhook := g.Group("/webhook")
+	webhook.GET("/list", handles.ListWebhooks)
+	webhook.GET("/get", handles.GetWebhook)
+	webhook.GET("/events", handles.ListWebhookEvents)
+	webhook.GET("/deliveries", handles.ListWebhookDeliveries)
+	webhook.POST("/create", handles.CreateWebhook)
+	webhook.POST("/update", handles.UpdateWebhook)
+	webhook.POST("/delete", handles.DeleteWebhook)
 }
 
 func fsAndShare(g *gin.RouterGroup) {
@@ -208,8 +256,8 @@ func _fs(g *gin.RouterGroup) {
 	g.POST("/remove", handles.FsRemove)
 	g.POST("/remove_empty_directory", handles.FsRemoveEmptyDirectory)
 	uploadLimiter := middlewares.UploadRateLimiter(stream.ClientUploadLimit)
-	g.PUT("/put", middlewares.FsUp, uploadLimiter, handles.FsStream)
-	g.PUT("/form", middlewares.FsUp, uploadLimiter, handles.FsForm)
+	g.PUT("/put", middlewares.FsUp, uploadLimiter, middlewares.TrafficLimit, handles.FsStream)
+	g.PUT("/form", middlewares.FsUp, uploadLimiter, middlewares.TrafficLimit, handles.FsForm)
 	g.POST("/link", middlewares.AuthAdmin, handles.Link)
 	// g.POST("/add_aria2", handles.AddOfflineDownload)
 	// g.POST("/add_qbit", handles.AddQbittorrent)
@@ -244,6 +292,7 @@ func Cors(r *gin.Engine) {
 }
 
 func InitS3(e *gin.Engine) {
+	e.Use(middlewares.Metrics("s3"))
 	Cors(e)
 	S3Server(e.Group("/"))
 }
@@ -253,6 +253,9 @@ func (b *s3Backend) PutObject(
 	log.Debugf("reqPath: %s", reqPath)
 	fmeta, _ := op.GetNearestMeta(fp)
 	ctx = context.WithValue(ctx, conf.MetaKey, fmeta)
+	if ctx, err = withQuotaUser(ctx, fp); err != nil {
+		return result, err
+	}
 
 	_, err = fs.Get(ctx, reqPath, &fs.GetArgs{})
 	if err != nil {
@@ -354,6 +357,10 @@ func (b *s3Backend) deleteObject(ctx context.Context, bucketName, objectName str
 		return err
 	}
 
+	ctx, err = withQuotaUser(ctx, fp)
+	if err != nil {
+		return err
+	}
 	fs.Remove(ctx, fp)
 	return nil
 }
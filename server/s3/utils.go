@@ -42,6 +42,16 @@ func getBucketByName(name string) (Bucket, error) {
 	return Bucket{}, gofakes3.BucketNotFound(name)
 }
 
+// withQuotaUser accounts the writes to the path to the user whose base path holds it,
+// the S3 server has no user of its own so the user is only used for the quota
+func withQuotaUser(ctx context.Context, path string) (context.Context, error) {
+	user, err := op.GetQuotaUserByPath(path)
+	if err != nil || user == nil {
+		return ctx, err
+	}
+	return fs.WithQuotaUser(ctx, user), nil
+}
+
 func getDirEntries(path string) ([]model.Obj, error) {
 	ctx := context.Background()
 	meta, _ := op.GetNearestMeta(path)
@@ -9,3 +9,19 @@ const (
 	SSH_FXF_TRUNC  = 0x00000010
 	SSH_FXF_EXCL   = 0x00000020
 )
+
+// Packet types and status codes of SFTP version 3
+const (
+	SSH_FXP_INIT           = 1
+	SSH_FXP_VERSION        = 2
+	SSH_FXP_STATUS         = 101
+	SSH_FXP_EXTENDED       = 200
+	SSH_FXP_EXTENDED_REPLY = 201
+
+	SSH_FX_OK                = 0
+	SSH_FX_NO_SUCH_FILE      = 2
+	SSH_FX_PERMISSION_DENIED = 3
+	SSH_FX_FAILURE           = 4
+	SSH_FX_BAD_MESSAGE       = 5
+	SSH_FX_OP_UNSUPPORTED    = 8
+)
@@ -0,0 +1,371 @@
+package sftp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	stdpath "path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/server/ftp"
+	"github.com/pkg/errors"
+)
+
+// errReported means the error has been written to the client already
+var errReported = errors.New("error reported")
+
+// execEnv is the file system and the streams of an exec request
+type execEnv struct {
+	// ctx is canceled when the client closes the session
+	ctx    context.Context
+	fs     *ftp.AferoAdapter
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type command func(e *execEnv, args []string) error
+
+// commands are the only programs that can be run, none of them is run by a shell
+var commands = map[string]command{
+	"scp":       runScp,
+	"ls":        runLs,
+	"du":        runDu,
+	"sha256sum": runSha256sum,
+	"cp":        runCp,
+	"mv":        runMv,
+}
+
+// runCommand returns the exit status of the command line
+func runCommand(e *execEnv, line string) uint32 {
+	args, err := splitCommand(line)
+	if err != nil {
+		_, _ = fmt.Fprintf(e.stderr, "%v\n", err)
+		return 2
+	}
+	if len(args) == 0 {
+		_, _ = fmt.Fprintln(e.stderr, "no command given, only the file transfer is available")
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		_, _ = fmt.Fprintf(e.stderr, "%s: command not allowed\n", args[0])
+		return 127
+	}
+	if err = cmd(e, args[1:]); err != nil {
+		if !errors.Is(err, errReported) {
+			_, _ = fmt.Fprintf(e.stderr, "%s: %v\n", args[0], err)
+		}
+		return 1
+	}
+	return 0
+}
+
+// splitCommand splits the line into words like a POSIX shell without expanding anything
+func splitCommand(line string) ([]string, error) {
+	var args []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' {
+				escaped = true
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inWord {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
+
+// parseFlags splits the single letter flags from the operands, the letters not in allowed are refused
+func parseFlags(args []string, allowed string) (map[byte]bool, []string, error) {
+	flags := make(map[byte]bool)
+	for i, arg := range args {
+		if arg == "--" {
+			return flags, args[i+1:], nil
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			return flags, args[i:], nil
+		}
+		for j := 1; j < len(arg); j++ {
+			if !strings.ContainsRune(allowed, rune(arg[j])) {
+				return nil, nil, errors.Errorf("invalid option -- '%c'", arg[j])
+			}
+			flags[arg[j]] = true
+		}
+	}
+	return flags, nil, nil
+}
+
+func (e *execEnv) isDir(path string) bool {
+	info, err := e.fs.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// warn reports an error of one operand, the command goes on with the next ones
+func (e *execEnv) warn(cmd, path string, err error) {
+	_, _ = fmt.Fprintf(e.stderr, "%s: %s: %v\n", cmd, path, err)
+}
+
+func runLs(e *execEnv, args []string) error {
+	flags, paths, err := parseFlags(args, "la1")
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		paths = []string{"/"}
+	}
+	failed := false
+	for i, p := range paths {
+		info, err := e.fs.Stat(p)
+		if err != nil {
+			e.warn("ls", p, err)
+			failed = true
+			continue
+		}
+		if !info.IsDir() {
+			e.printEntry(info, p, flags['l'])
+			continue
+		}
+		entries, err := e.fs.ReadDir(p)
+		if err != nil {
+			e.warn("ls", p, err)
+			failed = true
+			continue
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+		if len(paths) > 1 {
+			if i > 0 {
+				_, _ = fmt.Fprintln(e.stdout)
+			}
+			_, _ = fmt.Fprintf(e.stdout, "%s:\n", p)
+		}
+		for _, entry := range entries {
+			if !flags['a'] && strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			e.printEntry(entry, entry.Name(), flags['l'])
+		}
+	}
+	if failed {
+		return errReported
+	}
+	return nil
+}
+
+func (e *execEnv) printEntry(info os.FileInfo, name string, long bool) {
+	if !long {
+		_, _ = fmt.Fprintln(e.stdout, name)
+		return
+	}
+	layout := "Jan _2 15:04"
+	if time.Since(info.ModTime()) > 180*24*time.Hour {
+		layout = "Jan _2  2006"
+	}
+	_, _ = fmt.Fprintf(e.stdout, "%s %12d %s %s\n", info.Mode(), info.Size(), info.ModTime().Format(layout), name)
+}
+
+func runDu(e *execEnv, args []string) error {
+	flags, paths, err := parseFlags(args, "sbk")
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		paths = []string{"/"}
+	}
+	format := func(size int64) string {
+		if flags['b'] {
+			return fmt.Sprint(size)
+		}
+		return fmt.Sprint((size + 1023) / 1024)
+	}
+	failed := false
+	var walk func(p string) int64
+	walk = func(p string) int64 {
+		if e.ctx.Err() != nil {
+			return 0
+		}
+		entries, err := e.fs.ReadDir(p)
+		if err != nil {
+			e.warn("du", p, err)
+			failed = true
+			return 0
+		}
+		var total int64
+		for _, entry := range entries {
+			if entry.IsDir() {
+				total += walk(stdpath.Join(p, entry.Name()))
+			} else {
+				total += entry.Size()
+			}
+		}
+		if !flags['s'] && e.ctx.Err() == nil {
+			_, _ = fmt.Fprintf(e.stdout, "%s\t%s\n", format(total), p)
+		}
+		return total
+	}
+	for _, p := range paths {
+		if err = e.ctx.Err(); err != nil {
+			return err
+		}
+		info, err := e.fs.Stat(p)
+		if err != nil {
+			e.warn("du", p, err)
+			failed = true
+			continue
+		}
+		if !info.IsDir() {
+			_, _ = fmt.Fprintf(e.stdout, "%s\t%s\n", format(info.Size()), p)
+			continue
+		}
+		total := walk(p)
+		if err = e.ctx.Err(); err != nil {
+			return err
+		}
+		if flags['s'] {
+			_, _ = fmt.Fprintf(e.stdout, "%s\t%s\n", format(total), p)
+		}
+	}
+	if failed {
+		return errReported
+	}
+	return nil
+}
+
+func runSha256sum(e *execEnv, args []string) error {
+	_, paths, err := parseFlags(args, "b")
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return errors.New("reading standard input is not supported")
+	}
+	failed := false
+	for _, p := range paths {
+		sums, err := e.fs.Hash(p, "sha256", 0, -1, 0)
+		if err != nil {
+			e.warn("sha256sum", p, err)
+			failed = true
+			continue
+		}
+		_, _ = fmt.Fprintf(e.stdout, "%x  %s\n", sums[0], p)
+	}
+	if failed {
+		return errReported
+	}
+	return nil
+}
+
+// targets pairs each source with the directory it's moved or copied into,
+// the name can only be changed if there is a single source
+func (e *execEnv) targets(operands []string) ([]string, string, string, error) {
+	if len(operands) < 2 {
+		return nil, "", "", errors.New("missing destination file operand")
+	}
+	srcs, dst := operands[:len(operands)-1], operands[len(operands)-1]
+	if e.isDir(dst) {
+		return srcs, dst, "", nil
+	}
+	if len(srcs) > 1 {
+		return nil, "", "", errors.Errorf("target '%s' is not a directory", dst)
+	}
+	dir, name := stdpath.Split(dst)
+	return srcs, dir, name, nil
+}
+
+func runCp(e *execEnv, args []string) error {
+	flags, operands, err := parseFlags(args, "rRf")
+	if err != nil {
+		return err
+	}
+	srcs, dstDir, dstName, err := e.targets(operands)
+	if err != nil {
+		return err
+	}
+	failed := false
+	for _, src := range srcs {
+		if e.isDir(src) && !flags['r'] && !flags['R'] {
+			e.warn("cp", src, errors.New("omitting directory, -r not specified"))
+			failed = true
+			continue
+		}
+		if dstName != "" && dstName != stdpath.Base(src) {
+			e.warn("cp", src, errors.New("copying to another name is not supported, copy into a directory instead"))
+			failed = true
+			continue
+		}
+		if err = e.fs.Copy(src, dstDir); err != nil {
+			e.warn("cp", src, err)
+			failed = true
+		}
+	}
+	if failed {
+		return errReported
+	}
+	return nil
+}
+
+func runMv(e *execEnv, args []string) error {
+	_, operands, err := parseFlags(args, "f")
+	if err != nil {
+		return err
+	}
+	srcs, dstDir, dstName, err := e.targets(operands)
+	if err != nil {
+		return err
+	}
+	failed := false
+	for _, src := range srcs {
+		name := dstName
+		if name == "" {
+			name = stdpath.Base(src)
+		}
+		if err = e.fs.Rename(src, stdpath.Join(dstDir, name)); err != nil {
+			e.warn("mv", src, err)
+			failed = true
+		}
+	}
+	if failed {
+		return errReported
+	}
+	return nil
+}
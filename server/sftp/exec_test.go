@@ -0,0 +1,63 @@
+package sftp
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		want []string
+	}{
+		{"", nil},
+		{"  ls   -l\t/a \n", []string{"ls", "-l", "/a"}},
+		{`scp -t '/my dir'`, []string{"scp", "-t", "/my dir"}},
+		{`ls "a \"b\" c" 'd "e"'`, []string{"ls", `a "b" c`, `d "e"`}},
+		{`ls a\ b 'it'\''s'`, []string{"ls", "a b", "it's"}},
+		{`ls '' ""`, []string{"ls", "", ""}},
+		{`ls 'a;rm -rf /' $(x) *`, []string{"ls", "a;rm -rf /", "$(x)", "*"}},
+	} {
+		got, err := splitCommand(tt.line)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Fatalf("%q: got %q, %v", tt.line, got, err)
+		}
+	}
+	for _, line := range []string{`ls 'a`, `ls "a`, `ls a\`, `ls "a\"`} {
+		if _, err := splitCommand(line); err == nil {
+			t.Fatalf("%q: no error", line)
+		}
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	for _, tt := range []struct {
+		args     []string
+		flags    string
+		operands []string
+		err      bool
+	}{
+		{nil, "", nil, false},
+		{[]string{"-la", "/a"}, "al", []string{"/a"}, false},
+		{[]string{"-l", "-a", "/a", "-b"}, "al", []string{"/a", "-b"}, false},
+		{[]string{"-l", "--", "-a"}, "l", []string{"-a"}, false},
+		{[]string{"-", "/a"}, "", []string{"-", "/a"}, false},
+		{[]string{"-lx", "/a"}, "", nil, true},
+	} {
+		flags, operands, err := parseFlags(tt.args, "la1")
+		if tt.err {
+			if err == nil {
+				t.Fatalf("%q: no error", tt.args)
+			}
+			continue
+		}
+		var got []byte
+		for f := range flags {
+			got = append(got, f)
+		}
+		slices.Sort(got)
+		if err != nil || string(got) != tt.flags || !slices.Equal(operands, tt.operands) {
+			t.Fatalf("%q: got %q %q, %v", tt.args, got, operands, err)
+		}
+	}
+}
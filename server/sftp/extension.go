@@ -0,0 +1,214 @@
+package sftp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/errs"
+	"github.com/OpenListTeam/OpenList/v4/server/ftp"
+	"github.com/OpenListTeam/sftpd-openlist/binp"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// maxPacketLength is far above the packets sent by the common clients
+const maxPacketLength = 256 * 1024
+
+// md5QuickCheckSize is the length hashed for the quick check of md5-hash
+const md5QuickCheckSize = 2048
+
+var checkFileAlgos = []string{"md5", "sha1", "sha256", "sha512", "crc32"}
+
+// extendedChannel serves the extended requests to the channel, which sftpd ignores.
+// The other packets are passed to sftpd, which reads the channel through it.
+type extendedChannel struct {
+	ssh.Channel
+	fs  *DriverAdapter
+	rd  *bufio.Reader
+	buf []byte
+}
+
+func newExtendedChannel(c ssh.Channel, fs *DriverAdapter) *extendedChannel {
+	return &extendedChannel{Channel: c, fs: fs, rd: bufio.NewReaderSize(c, 64*1024)}
+}
+
+func (c *extendedChannel) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		pkt, err := c.readPacket()
+		if err != nil {
+			return 0, err
+		}
+		switch pkt[4] {
+		case SSH_FXP_INIT:
+			err = c.writeVersion()
+		case SSH_FXP_EXTENDED:
+			err = c.handleExtended(pkt[5:])
+		default:
+			c.buf = pkt
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// Close is called by sftpd once the client has ended the session
+func (c *extendedChannel) Close() error {
+	sendExitStatus(c.Channel, 0)
+	return c.Channel.Close()
+}
+
+func (c *extendedChannel) readPacket() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.rd, header[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(header[:])
+	if n == 0 || n > maxPacketLength {
+		return nil, errors.Errorf("invalid packet length %d", n)
+	}
+	pkt := make([]byte, 4+n)
+	copy(pkt, header[:])
+	if _, err := io.ReadFull(c.rd, pkt[4:]); err != nil {
+		return nil, err
+	}
+	return pkt, nil
+}
+
+// writeVersion announces the extensions, sftpd replies to init without any
+func (c *extendedChannel) writeVersion() error {
+	var l binp.Len
+	o := binp.Out().LenB32(&l).LenStart(&l).Byte(SSH_FXP_VERSION).B32(3).
+		B32String("check-file").B32String(strings.Join(checkFileAlgos, ",")).
+		B32String("md5-hash").B32String("1").
+		LenDone(&l)
+	_, err := c.Channel.Write(o.Out())
+	return err
+}
+
+func (c *extendedChannel) handleExtended(data []byte) error {
+	var id uint32
+	var name string
+	p := binp.NewParser(data).B32(&id).B32String(&name)
+	if p == nil {
+		return c.writeStatus(id, SSH_FX_BAD_MESSAGE, "invalid extended request")
+	}
+	switch name {
+	case "check-file-name":
+		return c.checkFile(id, p)
+	case "md5-hash":
+		return c.md5Hash(id, p)
+	default:
+		return c.writeStatus(id, SSH_FX_OP_UNSUPPORTED, "unsupported extension "+name)
+	}
+}
+
+// checkFile hashes a range of the file by the first supported algorithm of the client, see
+// https://datatracker.ietf.org/doc/html/draft-ietf-secsh-filexfer-extensions-00#section-3
+func (c *extendedChannel) checkFile(id uint32, p *binp.Parser) error {
+	var name, algos string
+	var start, length uint64
+	var blockSize uint32
+	if p.B32String(&name).B32String(&algos).B64(&start).B64(&length).B32(&blockSize).End() != nil {
+		return c.writeStatus(id, SSH_FX_BAD_MESSAGE, "invalid check-file request")
+	}
+	if blockSize != 0 && blockSize < 256 {
+		return c.writeStatus(id, SSH_FX_FAILURE, "block size is less than 256")
+	}
+	// Hash checks it against the size of the file as well, this saves the stat of an obviously bad request
+	if blockSize != 0 && length/uint64(blockSize) >= ftp.MaxHashBlocks {
+		return c.writeStatus(id, SSH_FX_FAILURE, "too many blocks")
+	}
+	algo := ""
+	for _, a := range strings.Split(algos, ",") {
+		if ftp.SupportedHash(a) {
+			algo = a
+			break
+		}
+	}
+	if algo == "" {
+		return c.writeStatus(id, SSH_FX_OP_UNSUPPORTED, "no supported hash algorithm in "+algos)
+	}
+	sums, err := c.fs.FtpDriver.Hash(name, algo, int64(start), rangeEnd(start, length), int64(blockSize))
+	if err != nil {
+		return c.writeError(id, err)
+	}
+	var l binp.Len
+	o := binp.Out().LenB32(&l).LenStart(&l).Byte(SSH_FXP_EXTENDED_REPLY).B32(id).B32String(algo)
+	for _, sum := range sums {
+		o.Bytes(sum)
+	}
+	o.LenDone(&l)
+	if len(o.Out()) > maxPacketLength {
+		return c.writeStatus(id, SSH_FX_FAILURE, "too many blocks")
+	}
+	_, err = c.Channel.Write(o.Out())
+	return err
+}
+
+// md5Hash replies an empty hash if the first bytes of the file don't match the quick check hash, see
+// https://datatracker.ietf.org/doc/html/draft-ietf-secsh-filexfer-09#section-9.1.1
+func (c *extendedChannel) md5Hash(id uint32, p *binp.Parser) error {
+	var name string
+	var start, length uint64
+	var quickCheck []byte
+	if p.B32String(&name).B64(&start).B64(&length).B32Bytes(&quickCheck).End() != nil {
+		return c.writeStatus(id, SSH_FX_BAD_MESSAGE, "invalid md5-hash request")
+	}
+	if len(quickCheck) > 0 {
+		sums, err := c.fs.FtpDriver.Hash(name, "md5", 0, md5QuickCheckSize, 0)
+		if err != nil {
+			return c.writeError(id, err)
+		}
+		if !bytes.Equal(sums[0], quickCheck) {
+			return c.writeHash(id, nil)
+		}
+	}
+	sums, err := c.fs.FtpDriver.Hash(name, "md5", int64(start), rangeEnd(start, length), 0)
+	if err != nil {
+		return c.writeError(id, err)
+	}
+	return c.writeHash(id, sums[0])
+}
+
+// rangeEnd maps the zero length to the end of the file
+func rangeEnd(start, length uint64) int64 {
+	if length == 0 {
+		return -1
+	}
+	return int64(start + length)
+}
+
+func (c *extendedChannel) writeHash(id uint32, sum []byte) error {
+	var l binp.Len
+	o := binp.Out().LenB32(&l).LenStart(&l).Byte(SSH_FXP_EXTENDED_REPLY).B32(id).B32Bytes(sum).LenDone(&l)
+	_, err := c.Channel.Write(o.Out())
+	return err
+}
+
+func (c *extendedChannel) writeError(id uint32, err error) error {
+	code := uint32(SSH_FX_FAILURE)
+	switch {
+	case errors.Is(err, errs.PermissionDenied):
+		code = SSH_FX_PERMISSION_DENIED
+	case errs.IsObjectNotFound(err):
+		code = SSH_FX_NO_SUCH_FILE
+	case errors.Is(err, errs.NotSupport):
+		code = SSH_FX_OP_UNSUPPORTED
+	}
+	return c.writeStatus(id, code, err.Error())
+}
+
+func (c *extendedChannel) writeStatus(id, code uint32, msg string) error {
+	var l binp.Len
+	o := binp.Out().LenB32(&l).LenStart(&l).Byte(SSH_FXP_STATUS).B32(id).B32(code).
+		B32String(msg).B32String("").LenDone(&l)
+	_, err := c.Channel.Write(o.Out())
+	return err
+}
@@ -0,0 +1,327 @@
+package sftp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	stdpath "path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// scp speaks the legacy protocol of scp, the client runs `scp -t` on the server to upload
+// and `scp -f` to download. See https://web.archive.org/web/20170215184048/https://blogs.oracle.com/janp/entry/how_the_scp_protocol_works
+type scp struct {
+	*execEnv
+	rd        *bufio.Reader
+	recursive bool
+	preserve  bool
+	failed    bool
+}
+
+func runScp(e *execEnv, args []string) error {
+	flags, paths, err := parseFlags(args, "tfrpdv")
+	if err != nil {
+		return err
+	}
+	c := &scp{execEnv: e, rd: bufio.NewReader(e.stdin), recursive: flags['r'], preserve: flags['p']}
+	switch {
+	case flags['t'] && len(paths) == 1:
+		return c.sink(paths[0], flags['d'])
+	case flags['f'] && len(paths) > 0:
+		return c.source(paths)
+	default:
+		return errors.New("only -t with a target or -f with sources is supported")
+	}
+}
+
+func (c *scp) ack() error {
+	_, err := c.stdout.Write([]byte{0})
+	return err
+}
+
+// fail reports the error to the client, which prints it and stops
+func (c *scp) fail(err error) error {
+	_, _ = fmt.Fprintf(c.stdout, "\x01scp: %v\n", err)
+	return errReported
+}
+
+// skip reports the error of a file to the client, which prints it and goes on
+func (c *scp) skip(path string, err error) {
+	c.failed = true
+	_, _ = fmt.Fprintf(c.stdout, "\x01scp: %s: %v\n", path, err)
+}
+
+// response reads the reply of the client to a message
+func (c *scp) response() error {
+	b, err := c.rd.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b == 0 {
+		return nil
+	}
+	msg, err := c.rd.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	return errors.New(strings.TrimSpace(msg))
+}
+
+// sink receives the files into the target, which is a directory or the name of the only file
+func (c *scp) sink(target string, targetDir bool) error {
+	isDir := c.isDir(target)
+	if targetDir && !isDir {
+		return c.fail(errors.Errorf("%s: not a directory", target))
+	}
+	if err := c.ack(); err != nil {
+		return err
+	}
+	// dirs is the stack of the directories being received
+	var dirs []string
+	var mtime time.Time
+	var dirTimes []time.Time
+	for {
+		line, err := c.rd.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) && line == "" {
+				return nil
+			}
+			return err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return c.fail(errors.New("empty message"))
+		}
+		switch line[0] {
+		case 'T':
+			var m, a int64
+			if _, err = fmt.Sscanf(line[1:], "%d 0 %d 0", &m, &a); err != nil {
+				return c.fail(errors.Errorf("invalid time message %q", line))
+			}
+			mtime = time.Unix(m, 0)
+			err = c.ack()
+		case 'C', 'D':
+			size, name, perr := parseScpHeader(line)
+			if perr != nil {
+				return c.fail(perr)
+			}
+			path := target
+			if len(dirs) > 0 {
+				path = stdpath.Join(dirs[len(dirs)-1], name)
+			} else if isDir {
+				path = stdpath.Join(target, name)
+			}
+			if line[0] == 'D' {
+				if !c.recursive {
+					return c.fail(errors.New("received directory without -r"))
+				}
+				if !c.isDir(path) {
+					if err = c.fs.Mkdir(path, 0o755); err != nil {
+						return c.fail(errors.Wrapf(err, "%s", path))
+					}
+				}
+				dirs = append(dirs, path)
+				dirTimes = append(dirTimes, mtime)
+				mtime = time.Time{}
+				err = c.ack()
+				break
+			}
+			if err = c.receive(path, size); err != nil {
+				return err
+			}
+			if c.preserve && !mtime.IsZero() {
+				// the storage may be unable to keep the time, it's not worth failing the copy
+				_ = c.fs.Chtimes(path, mtime, mtime)
+			}
+			mtime = time.Time{}
+			err = c.ack()
+		case 'E':
+			if len(dirs) == 0 {
+				return c.fail(errors.New("unexpected end of directory"))
+			}
+			path, t := dirs[len(dirs)-1], dirTimes[len(dirTimes)-1]
+			dirs, dirTimes = dirs[:len(dirs)-1], dirTimes[:len(dirTimes)-1]
+			if c.preserve && !t.IsZero() {
+				_ = c.fs.Chtimes(path, t, t)
+			}
+			err = c.ack()
+		case 1:
+			// a warning of the client, it goes on
+			continue
+		case 2:
+			return errors.New(strings.TrimSpace(line[1:]))
+		default:
+			return c.fail(errors.Errorf("unknown message %q", line))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseScpHeader parses `C0644 size name` and `D0755 0 name`
+func parseScpHeader(line string) (int64, string, error) {
+	parts := strings.SplitN(line[1:], " ", 3)
+	if len(parts) != 3 {
+		return 0, "", errors.Errorf("invalid message %q", line)
+	}
+	// the mode isn't kept, but a header with a broken one is refused like scp does
+	if mode, err := strconv.ParseUint(parts[0], 8, 32); err != nil || mode > 0o7777 {
+		return 0, "", errors.Errorf("invalid mode in %q", line)
+	}
+	size, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || size < 0 {
+		return 0, "", errors.Errorf("invalid size in %q", line)
+	}
+	name := parts[2]
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return 0, "", errors.Errorf("invalid name %q", name)
+	}
+	return size, name, nil
+}
+
+func (c *scp) receive(path string, size int64) error {
+	c.fs.SetNextFileSize(size)
+	h, err := c.fs.GetHandle(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0)
+	if err != nil {
+		return c.fail(errors.Wrapf(err, "%s", path))
+	}
+	if err = c.ack(); err != nil {
+		_ = h.Close()
+		return err
+	}
+	_, err = io.CopyN(h, c.rd, size)
+	if cerr := h.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return c.fail(errors.Wrapf(err, "%s", path))
+	}
+	return c.response()
+}
+
+// source sends the files, the last path element may be a pattern
+func (c *scp) source(paths []string) error {
+	if err := c.response(); err != nil {
+		return err
+	}
+	for _, p := range paths {
+		matches, err := c.expand(p)
+		if err != nil {
+			c.skip(p, err)
+			continue
+		}
+		for _, m := range matches {
+			if err = c.send(m); err != nil {
+				return err
+			}
+		}
+	}
+	if c.failed {
+		return errReported
+	}
+	return nil
+}
+
+func (c *scp) expand(p string) ([]string, error) {
+	dir, pattern := stdpath.Split(p)
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{p}, nil
+	}
+	entries, err := c.fs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, entry := range entries {
+		if ok, err := stdpath.Match(pattern, entry.Name()); err != nil {
+			return nil, err
+		} else if ok {
+			matches = append(matches, stdpath.Join(dir, entry.Name()))
+		}
+	}
+	if len(matches) == 0 {
+		return nil, errors.New("no such file or directory")
+	}
+	return matches, nil
+}
+
+// send returns an error only if the transfer can't go on
+func (c *scp) send(path string) error {
+	info, err := c.fs.Stat(path)
+	if err != nil {
+		c.skip(path, err)
+		return nil
+	}
+	if info.IsDir() {
+		if !c.recursive {
+			c.skip(path, errors.New("not a regular file"))
+			return nil
+		}
+		return c.sendDir(path, info)
+	}
+	h, err := c.fs.GetHandle(path, os.O_RDONLY, 0)
+	if err != nil {
+		c.skip(path, err)
+		return nil
+	}
+	defer func() { _ = h.Close() }()
+	if err = c.sendTime(info); err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(c.stdout, "C%04o %d %s\n", info.Mode().Perm(), info.Size(), info.Name()); err != nil {
+		return err
+	}
+	if err = c.response(); err != nil {
+		return err
+	}
+	if _, err = io.CopyN(c.stdout, h, info.Size()); err != nil {
+		return err
+	}
+	if err = c.ack(); err != nil {
+		return err
+	}
+	return c.response()
+}
+
+func (c *scp) sendDir(path string, info os.FileInfo) error {
+	entries, err := c.fs.ReadDir(path)
+	if err != nil {
+		c.skip(path, err)
+		return nil
+	}
+	if err = c.sendTime(info); err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(c.stdout, "D%04o 0 %s\n", info.Mode().Perm(), info.Name()); err != nil {
+		return err
+	}
+	if err = c.response(); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err = c.send(stdpath.Join(path, entry.Name())); err != nil {
+			return err
+		}
+	}
+	if _, err = fmt.Fprint(c.stdout, "E\n"); err != nil {
+		return err
+	}
+	return c.response()
+}
+
+func (c *scp) sendTime(info os.FileInfo) error {
+	if !c.preserve {
+		return nil
+	}
+	t := info.ModTime().Unix()
+	if _, err := fmt.Fprintf(c.stdout, "T%d 0 %d 0\n", t, t); err != nil {
+		return err
+	}
+	return c.response()
+}
@@ -0,0 +1,41 @@
+package sftp
+
+import "testing"
+
+func TestParseScpHeader(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		size int64
+		name string
+	}{
+		{"C0644 12 a.txt", 12, "a.txt"},
+		{"D0755 0 dir", 0, "dir"},
+		{"C0644 0 name with spaces", 0, "name with spaces"},
+		{"C0644 9223372036854775807 big", 1<<63 - 1, "big"},
+	} {
+		size, name, err := parseScpHeader(tt.line)
+		if err != nil || size != tt.size || name != tt.name {
+			t.Fatalf("%q: got %d %q, %v", tt.line, size, name, err)
+		}
+	}
+	for _, line := range []string{
+		"C",
+		"C0644 12",
+		"C0644 12 ",
+		"Cxyz 12 a",
+		"C0999 12 a",
+		"C77777 12 a",
+		"C0644 -1 a",
+		"C0644 1e3 a",
+		"C0644 9223372036854775808 a",
+		"C0644 12 .",
+		"C0644 12 ..",
+		"C0644 12 ../a",
+		"C0644 12 a/../../b",
+		"D0755 0 /etc",
+	} {
+		if _, _, err := parseScpHeader(line); err == nil {
+			t.Fatalf("%q: no error", line)
+		}
+	}
+}
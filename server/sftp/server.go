@@ -0,0 +1,159 @@
+package sftp
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/OpenListTeam/sftpd-openlist"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// Server accepts the SSH connections like sftpd.SftpServer, but serves the SFTP
+// channels through an extendedChannel and runs the commands of exec requests, see runCommand
+type Server struct {
+	driver   sftpd.SftpDriver
+	mu       sync.Mutex
+	listener net.Listener
+	closed   bool
+}
+
+func NewServer(driver sftpd.SftpDriver) *Server {
+	return &Server{driver: driver}
+}
+
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.driver.GetConfig().HostPort)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return listener.Close()
+	}
+	s.listener = listener
+	s.mu.Unlock()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.driver.Close()
+	if s.listener != nil {
+		return s.listener.Close()
+	}
+	return nil
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	sc, chans, reqs, err := ssh.NewServerConn(conn, &s.driver.GetConfig().ServerConfig)
+	if err != nil {
+		s.logError("sftpd connection error:", err)
+		return
+	}
+	defer func() { _ = sc.Close() }()
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			s.logError("sftpd connection error:", err)
+			return
+		}
+		go s.handleSession(sc, channel, requests)
+	}
+}
+
+// handleSession serves the first sftp subsystem or exec request of the session
+func (s *Server) handleSession(sc *ssh.ServerConn, channel ssh.Channel, requests <-chan *ssh.Request) {
+	// the requests are closed with the channel, a command still running is canceled then
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := false
+	for req := range requests {
+		ok := false
+		switch {
+		case served:
+		case sftpd.IsSftpRequest(req):
+			ok = true
+			go s.serveSftp(sc, channel)
+		case req.Type == "exec":
+			var payload struct{ Command string }
+			if ok = ssh.Unmarshal(req.Payload, &payload) == nil; ok {
+				go s.serveExec(ctx, sc, channel, payload.Command)
+			}
+		}
+		served = served || ok
+		_ = req.Reply(ok, nil)
+	}
+}
+
+func (s *Server) serveSftp(sc *ssh.ServerConn, channel ssh.Channel) {
+	fs, err := s.driver.GetFileSystem(sc)
+	if err != nil {
+		_ = channel.Close()
+		s.logError("sftpd servechannel failed:", err)
+		return
+	}
+	c := channel
+	if adapter, ok := fs.(*DriverAdapter); ok {
+		c = newExtendedChannel(channel, adapter)
+	}
+	debugf := s.driver.GetConfig().DebugLogFunc
+	if debugf == nil {
+		debugf = func(string, ...interface{}) {}
+	}
+	if err = sftpd.ServeChannel(c, fs, debugf); err != nil && !errors.Is(err, io.EOF) {
+		s.logError("sftpd servechannel failed:", err)
+	}
+}
+
+// serveExec runs one of the restricted commands with the same user context as the sftp file system
+func (s *Server) serveExec(ctx context.Context, sc *ssh.ServerConn, channel ssh.Channel, command string) {
+	defer func() { _ = channel.Close() }()
+	status := uint32(1)
+	fs, err := s.driver.GetFileSystem(sc)
+	if err == nil {
+		if adapter, ok := fs.(*DriverAdapter); ok {
+			status = runCommand(&execEnv{
+				ctx:    ctx,
+				fs:     adapter.FtpDriver.WithContext(ctx),
+				stdin:  channel,
+				stdout: channel,
+				stderr: channel.Stderr(),
+			}, command)
+		}
+	} else {
+		s.logError("sftpd exec failed:", err)
+	}
+	sendExitStatus(channel, status)
+}
+
+// sendExitStatus reports the result of the session, scp takes a session without it as failed
+func sendExitStatus(channel ssh.Channel, status uint32) {
+	_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+}
+
+func (s *Server) logError(v ...interface{}) {
+	if f := s.driver.GetConfig().ErrorLogFunc; f != nil {
+		f(v...)
+	}
+}
@@ -48,8 +48,17 @@ func (s *DriverAdapter) Stat(name string, _ bool) (*sftpd.Attr, error) {
 	return fileInfoToSftpAttr(stat), nil
 }
 
-func (s *DriverAdapter) SetStat(_ string, _ *sftpd.Attr) error {
-	return errs.NotSupport
+func (s *DriverAdapter) SetStat(name string, attr *sftpd.Attr) error {
+	if attr.Flags&sftpd.ATTR_SIZE != 0 {
+		if err := s.FtpDriver.Truncate(name, int64(attr.Size)); err != nil {
+			return err
+		}
+	}
+	// the mode and the owner are ignored, the storages don't keep them
+	if attr.Flags&sftpd.ATTR_TIME != 0 {
+		return s.FtpDriver.Chtimes(name, attr.ATime, attr.MTime)
+	}
+	return nil
 }
 
 func (s *DriverAdapter) ReadLink(_ string) (string, error) {
@@ -11,6 +11,8 @@ import (
 	"github.com/OpenListTeam/OpenList/v4/server/common"
 	"github.com/OpenListTeam/OpenList/v4/server/middlewares"
 
+	"github.com/OpenListTeam/OpenList/v4/internal/audit"
+	"github.com/OpenListTeam/OpenList/v4/internal/cluster"
 	"github.com/OpenListTeam/OpenList/v4/internal/conf"
 	"github.com/OpenListTeam/OpenList/v4/internal/op"
 	"github.com/OpenListTeam/OpenList/v4/internal/setting"
@@ -22,14 +24,18 @@ import (
 var handler *webdav.Handler
 
 func WebDav(dav *gin.RouterGroup) {
+	lockSystem := webdav.NewMemLS()
+	if cluster.Enabled() {
+		lockSystem = webdav.NewClusterLS()
+	}
 	handler = &webdav.Handler{
 		Prefix:     path.Join(conf.URL.Path, "/dav"),
-		LockSystem: webdav.NewMemLS(),
+		LockSystem: lockSystem,
 		Logger: func(request *http.Request, err error) {
 			log.Errorf("%s %s %+v", request.Method, request.URL.Path, err)
 		},
 	}
-	dav.Use(WebDAVAuth)
+	dav.Use(middlewares.Protocol(audit.ProtocolWebDAV), WebDAVAuth, middlewares.TrafficLimit)
 	uploadLimiter := middlewares.UploadRateLimiter(stream.ClientUploadLimit)
 	downloadLimiter := middlewares.DownloadRateLimiter(stream.ClientDownloadLimit)
 	dav.Any("/*path", uploadLimiter, downloadLimiter, ServeWebDAV)
@@ -94,8 +100,12 @@ func WebDAVAuth(c *gin.Context) {
 		c.Abort()
 		return
 	}
+	var token *model.APIToken
 	user, err := op.GetUserByName(username)
-	if err != nil || user.ValidateRawPassword(password) != nil {
+	if err == nil {
+		token, err = validateWebDAVPassword(user, password)
+	}
+	if err != nil {
 		if c.Request.Method == "OPTIONS" {
 			common.GinWithValue(c, conf.UserKey, guest)
 			c.Next()
@@ -143,6 +153,26 @@ func WebDAVAuth(c *gin.Context) {
 		c.Abort()
 		return
 	}
+	if token != nil {
+		if !token.HasScope(middlewares.WebDAVScope(c.Request.Method)) {
+			c.Status(http.StatusForbidden)
+			c.Abort()
+			return
+		}
+		common.GinWithValue(c, conf.APITokenKey, token)
+	}
 	common.GinWithValue(c, conf.UserKey, user)
 	c.Next()
 }
+
+// validateWebDAVPassword accepts the password or an API token of the user,
+// the token is returned if it's used
+func validateWebDAVPassword(user *model.User, password string) (*model.APIToken, error) {
+	if strings.HasPrefix(password, model.APITokenPrefix) {
+		token, owner, err := op.ValidateAPIToken(password)
+		if err == nil && owner.ID == user.ID {
+			return token, nil
+		}
+	}
+	return nil, user.ValidateRawPassword(password)
+}
@@ -0,0 +1,194 @@
+package webdav
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/cluster"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	clusterLocksKey      = "webdav:locks"
+	clusterLocksMutexKey = "webdav:locks:mutex"
+	clusterLocksGenKey   = "webdav:locks:gen"
+	// clusterLockTimeout bounds every operation on the shared lock table
+	clusterLockTimeout = 5 * time.Second
+)
+
+// clusterLock is a lock stored in the shared lock table
+type clusterLock struct {
+	Token   string      `json:"token"`
+	Details LockDetails `json:"details"`
+	// Expiry is zero for the locks with an infinite duration
+	Expiry time.Time `json:"expiry"`
+	// Holder is the node holding the lock by a Confirm call, a dead holder no longer holds it
+	Holder string `json:"holder,omitempty"`
+}
+
+// NewClusterLS returns a LockSystem keeping the locks in the shared state of the cluster,
+// so a lock taken through one node is honored by the others. The whole table is stored
+// under one key and changed under a cluster lock, which is fine for the few locks the
+// WebDAV clients take.
+func NewClusterLS() LockSystem {
+	return &clusterLS{}
+}
+
+type clusterLS struct {
+	// the cluster lock is reentrant for the node, mu serializes the requests of the node
+	mu sync.Mutex
+}
+
+// update loads the lock table into a memLS, calls fn with it and saves it back
+func (c *clusterLS) update(fn func(ctx context.Context, m *memLS, holders map[string]string) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), clusterLockTimeout)
+	defer cancel()
+	for {
+		ok, err := cluster.Lock(ctx, clusterLocksMutexKey, clusterLockTimeout)
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return errors.New("webdav: timeout waiting for the cluster lock table")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	defer func() {
+		_ = cluster.Unlock(context.Background(), clusterLocksMutexKey)
+	}()
+
+	m, holders, err := c.load(ctx)
+	if err != nil {
+		return err
+	}
+	if err = fn(ctx, m, holders); err != nil {
+		return err
+	}
+	return c.save(ctx, m, holders)
+}
+
+func (c *clusterLS) load(ctx context.Context) (*memLS, map[string]string, error) {
+	m := NewMemLS().(*memLS)
+	holders := make(map[string]string)
+	data, ok, err := cluster.Get(ctx, clusterLocksKey)
+	if err != nil || !ok {
+		return m, holders, err
+	}
+	var locks []clusterLock
+	if err = json.Unmarshal([]byte(data), &locks); err != nil {
+		return nil, nil, err
+	}
+	for _, l := range locks {
+		n := m.create(l.Details.Root)
+		n.token = l.Token
+		n.details = l.Details
+		n.expiry = l.Expiry
+		m.byToken[n.token] = n
+		if l.Holder != "" {
+			// a node stopped in the middle of a request must not hold the lock forever
+			if alive, err := cluster.Alive(ctx, l.Holder); err == nil && alive {
+				n.held = true
+				holders[n.token] = l.Holder
+				continue
+			}
+		}
+		if n.details.Duration >= 0 {
+			heap.Push(&m.byExpiry, n)
+		}
+	}
+	return m, holders, nil
+}
+
+func (c *clusterLS) save(ctx context.Context, m *memLS, holders map[string]string) error {
+	locks := make([]clusterLock, 0, len(m.byToken))
+	for token, n := range m.byToken {
+		l := clusterLock{Token: token, Details: n.details}
+		if n.details.Duration >= 0 {
+			l.Expiry = n.expiry
+		}
+		if n.held {
+			l.Holder = holders[token]
+		}
+		locks = append(locks, l)
+	}
+	if len(locks) == 0 {
+		return cluster.Del(ctx, clusterLocksKey)
+	}
+	data, err := json.Marshal(locks)
+	if err != nil {
+		return err
+	}
+	return cluster.Set(ctx, clusterLocksKey, string(data), 0)
+}
+
+func (c *clusterLS) Confirm(now time.Time, name0, name1 string, conditions ...Condition) (func(), error) {
+	var tokens []string
+	err := c.update(func(ctx context.Context, m *memLS, holders map[string]string) error {
+		if _, err := m.Confirm(now, name0, name1, conditions...); err != nil {
+			return err
+		}
+		// the release of the memLS is useless once it is saved, the nodes held are recorded instead
+		for token, n := range m.byToken {
+			if n.held && holders[token] == "" {
+				holders[token] = cluster.NodeID()
+				tokens = append(tokens, token)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		err := c.update(func(ctx context.Context, m *memLS, holders map[string]string) error {
+			for _, token := range tokens {
+				if n := m.byToken[token]; n != nil && n.held {
+					m.unhold(n)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			// the locks are released anyway once this node is gone
+			log.Warnf("failed release webdav locks %v: %+v", tokens, err)
+		}
+	}, nil
+}
+
+func (c *clusterLS) Create(now time.Time, details LockDetails) (token string, err error) {
+	err = c.update(func(ctx context.Context, m *memLS, holders map[string]string) error {
+		// the tokens are unique in the cluster
+		gen, err := cluster.Incr(ctx, clusterLocksGenKey, 0)
+		if err != nil {
+			return err
+		}
+		m.gen = uint64(gen) - 1
+		token, err = m.Create(now, details)
+		return err
+	})
+	return token, err
+}
+
+func (c *clusterLS) Refresh(now time.Time, token string, duration time.Duration) (details LockDetails, err error) {
+	err = c.update(func(ctx context.Context, m *memLS, holders map[string]string) error {
+		details, err = m.Refresh(now, token, duration)
+		return err
+	})
+	return details, err
+}
+
+func (c *clusterLS) Unlock(now time.Time, token string) error {
+	return c.update(func(ctx context.Context, m *memLS, holders map[string]string) error {
+		return m.Unlock(now, token)
+	})
+}
@@ -0,0 +1,61 @@
+package webdav
+
+import (
+	"testing"
+	"time"
+
+	"github.com/OpenListTeam/OpenList/v4/internal/cluster"
+	"github.com/OpenListTeam/OpenList/v4/internal/conf"
+)
+
+func TestClusterLS(t *testing.T) {
+	conf.Conf = &conf.Config{Cluster: conf.Cluster{Enable: true, NodeID: "a", Backend: "memory"}}
+	if err := cluster.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Release()
+	// the lock systems of two nodes share the lock table
+	a, b := NewClusterLS(), NewClusterLS()
+	now := time.Now()
+
+	token, err := a.Create(now, LockDetails{Root: "/dir", Duration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = b.Create(now, LockDetails{Root: "/dir/file", Duration: time.Minute, ZeroDepth: true}); err != ErrLocked {
+		t.Fatalf("lock of a is ignored by b: %v", err)
+	}
+	token2, err := b.Create(now, LockDetails{Root: "/other", Duration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token2 == token {
+		t.Fatalf("tokens are not unique: %s", token)
+	}
+
+	release, err := b.Confirm(now, "/dir/file", "", Condition{Token: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = a.Confirm(now, "/dir", "", Condition{Token: token}); err != ErrConfirmationFailed {
+		t.Fatalf("lock held by b is confirmed by a: %v", err)
+	}
+	if err = a.Unlock(now, token); err != ErrLocked {
+		t.Fatalf("lock held by b is unlocked by a: %v", err)
+	}
+	release()
+	if _, err = a.Refresh(now, token, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err = a.Unlock(now, token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = b.Create(now, LockDetails{Root: "/dir/file", Duration: time.Minute, ZeroDepth: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// expired locks are dropped
+	if err = b.Unlock(now.Add(2*time.Minute), token2); err != ErrNoSuchLock {
+		t.Fatalf("expired lock is kept: %v", err)
+	}
+}